@@ -0,0 +1,144 @@
+# Copyright (c) HashiCorp, Inc.
+# SPDX-License-Identifier: MPL-2.0
+
+data "aws_iam_policy_document" "drift_detection_assume_role" {
+  statement {
+    effect = "Allow"
+
+    principals {
+      type        = "Service"
+      identifiers = ["lambda.amazonaws.com"]
+    }
+
+    actions = ["sts:AssumeRole"]
+  }
+}
+
+resource "aws_iam_role" "drift_detection" {
+  name               = "ServiceCatalogTerraformCloudDriftDetectionRole"
+  assume_role_policy = data.aws_iam_policy_document.drift_detection_assume_role.json
+}
+
+resource "aws_iam_role_policy" "drift_detection" {
+  name   = "ServiceCatalogTerraformCloudDriftDetectionPolicy"
+  role   = aws_iam_role.drift_detection.id
+  policy = data.aws_iam_policy_document.drift_detection.json
+}
+
+data "aws_cloudwatch_event_bus" "drift_events" {
+  name = var.drift_event_bus_name
+}
+
+data "aws_iam_policy_document" "drift_detection" {
+  version = "2012-10-17"
+
+  statement {
+    sid = "tfeCredentialsAccess"
+
+    effect = "Allow"
+
+    actions = ["secretsmanager:GetSecretValue"]
+
+    resources = local.tfc_credentials_secret_arns
+  }
+
+  statement {
+    sid = "EngineStateAccess"
+
+    effect = "Allow"
+
+    actions = ["dynamodb:GetItem", "dynamodb:PutItem"]
+
+    resources = [aws_dynamodb_table.engine_state.arn]
+  }
+
+  statement {
+    sid = "DriftEventsAccess"
+
+    effect = "Allow"
+
+    actions = ["events:PutEvents"]
+
+    resources = [data.aws_cloudwatch_event_bus.drift_events.arn]
+  }
+
+  statement {
+    sid = "DriftMetricsAccess"
+
+    effect = "Allow"
+
+    actions = ["cloudwatch:PutMetricData"]
+
+    resources = ["*"]
+
+    condition {
+      test     = "StringEquals"
+      variable = "cloudwatch:namespace"
+      values   = ["ServiceCatalogTerraformCloud"]
+    }
+  }
+}
+
+resource "aws_iam_role_policy_attachment" "drift_detection" {
+  for_each   = toset(["arn:aws:iam::aws:policy/AWSXrayWriteOnlyAccess", "arn:aws:iam::aws:policy/service-role/AWSLambdaBasicExecutionRole"])
+  role       = aws_iam_role.drift_detection.name
+  policy_arn = each.value
+}
+
+data "archive_file" "drift_detection" {
+  type        = "zip"
+  output_path = "dist/drift_detection.zip"
+  source_file = "${path.module}/lambda-functions/detect-drift/bootstrap"
+}
+
+resource "aws_cloudwatch_log_group" "drift_detection" {
+  name              = "/aws/lambda/ServiceCatalogTerraformCloudDriftDetection"
+  retention_in_days = var.cloudwatch_log_retention_in_days
+}
+
+# Lambda that checks the workspaces of provisioned products for drift, and reports drifted provisioned products as
+# EventBridge events and CloudWatch metrics
+resource "aws_lambda_function" "drift_detection" {
+  filename      = data.archive_file.drift_detection.output_path
+  function_name = "ServiceCatalogTerraformCloudDriftDetection"
+  role          = aws_iam_role.drift_detection.arn
+  handler       = "bootstrap"
+  timeout       = 900
+
+  source_code_hash = data.archive_file.drift_detection.output_base64sha256
+
+  runtime       = "provided.al2"
+  architectures = ["arm64"]
+
+  environment {
+    variables = {
+      TFE_CREDENTIALS_SECRET_ID               = aws_secretsmanager_secret.team_token_values.arn
+      TFE_ORGANIZATION_CREDENTIALS_SECRET_IDS = jsonencode(var.tfc_organization_credentials_secret_arns)
+      TERRAFORM_ORGANIZATION                  = var.tfc_organization
+      ENGINE_STATE_TABLE_NAME                 = aws_dynamodb_table.engine_state.name
+      DRIFT_DETECTION_BATCH_SIZE              = var.drift_detection_batch_size
+      DRIFT_EVENT_BUS_NAME                    = data.aws_cloudwatch_event_bus.drift_events.name
+    }
+  }
+
+  depends_on = [aws_cloudwatch_log_group.drift_detection]
+}
+
+resource "aws_cloudwatch_event_rule" "drift_detection_schedule" {
+  name                = "ServiceCatalogTerraformCloudDriftDetection"
+  description         = "Schedule for checking the workspaces of provisioned products for drift"
+  schedule_expression = var.drift_detection_schedule_expression
+}
+
+resource "aws_cloudwatch_event_target" "drift_detection" {
+  rule = aws_cloudwatch_event_rule.drift_detection_schedule.name
+  arn  = aws_lambda_function.drift_detection.arn
+}
+
+resource "aws_lambda_permission" "drift_detection_schedule" {
+  statement_id  = "AllowExecutionFromEventBridge"
+  action        = "lambda:InvokeFunction"
+  function_name = aws_lambda_function.drift_detection.function_name
+  principal     = "events.amazonaws.com"
+  source_arn    = aws_cloudwatch_event_rule.drift_detection_schedule.arn
+}
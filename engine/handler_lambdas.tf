@@ -68,6 +68,28 @@ data "aws_iam_policy_document" "policy_for_provision_handler" {
     resources = [aws_sfn_state_machine.provision_state_machine.arn]
 
   }
+
+  statement {
+    sid = "AllowDescribeExecution"
+
+    effect = "Allow"
+
+    actions = ["states:DescribeExecution"]
+
+    resources = ["${replace(aws_sfn_state_machine.provision_state_machine.arn, ":stateMachine:", ":execution:")}:*"]
+
+  }
+
+  statement {
+    sid = "AllowPortfolioLookup"
+
+    effect = "Allow"
+
+    actions = ["servicecatalog:ListPortfoliosForProduct"]
+
+    resources = ["*"]
+
+  }
 }
 
 resource "aws_iam_role_policy_attachment" "provision_handler_lambda_execution" {
@@ -90,6 +112,7 @@ resource "aws_lambda_function" "provision_handler" {
   environment {
     variables = {
       TERRAFORM_ORGANIZATION = var.tfc_organization
+      ORGANIZATION_ROUTES    = local.organization_routes
       STATE_MACHINE_ARN      = aws_sfn_state_machine.provision_state_machine.arn
     }
   }
@@ -164,6 +187,28 @@ data "aws_iam_policy_document" "policy_for_terminate_handler" {
     resources = [aws_sfn_state_machine.terminate_state_machine.arn]
 
   }
+
+  statement {
+    sid = "AllowDescribeExecution"
+
+    effect = "Allow"
+
+    actions = ["states:DescribeExecution"]
+
+    resources = ["${replace(aws_sfn_state_machine.terminate_state_machine.arn, ":stateMachine:", ":execution:")}:*"]
+
+  }
+
+  statement {
+    sid = "AllowPortfolioLookup"
+
+    effect = "Allow"
+
+    actions = ["servicecatalog:ListPortfoliosForProduct"]
+
+    resources = ["*"]
+
+  }
 }
 
 resource "aws_lambda_function" "terminate_handler" {
@@ -180,6 +225,7 @@ resource "aws_lambda_function" "terminate_handler" {
   environment {
     variables = {
       TERRAFORM_ORGANIZATION = var.tfc_organization
+      ORGANIZATION_ROUTES    = local.organization_routes
       STATE_MACHINE_ARN      = aws_sfn_state_machine.terminate_state_machine.arn
     }
   }
@@ -254,6 +300,28 @@ data "aws_iam_policy_document" "policy_for_update_handler" {
     resources = [aws_sfn_state_machine.update_state_machine.arn]
 
   }
+
+  statement {
+    sid = "AllowDescribeExecution"
+
+    effect = "Allow"
+
+    actions = ["states:DescribeExecution"]
+
+    resources = ["${replace(aws_sfn_state_machine.update_state_machine.arn, ":stateMachine:", ":execution:")}:*"]
+
+  }
+
+  statement {
+    sid = "AllowPortfolioLookup"
+
+    effect = "Allow"
+
+    actions = ["servicecatalog:ListPortfoliosForProduct"]
+
+    resources = ["*"]
+
+  }
 }
 
 resource "aws_lambda_function" "update_handler" {
@@ -270,6 +338,7 @@ resource "aws_lambda_function" "update_handler" {
   environment {
     variables = {
       TERRAFORM_ORGANIZATION = var.tfc_organization
+      ORGANIZATION_ROUTES    = local.organization_routes
       STATE_MACHINE_ARN      = aws_sfn_state_machine.update_state_machine.arn
     }
   }
@@ -282,3 +351,13 @@ resource "aws_lambda_event_source_mapping" "update_handler_update_queue" {
   enabled                 = true
   function_response_types = ["ReportBatchItemFailures"]
 }
+
+locals {
+  # Routes of provisioning requests to organizations, in the format the provisioning operations handlers parse
+  organization_routes = jsonencode([for route in var.organization_routes : {
+    organization = route.organization
+    productIds   = route.product_ids
+    portfolioIds = route.portfolio_ids
+    accountIds   = route.account_ids
+  }])
+}
@@ -0,0 +1,180 @@
+/*
+ * Copyright (c) HashiCorp, Inc.
+ * SPDX-License-Identifier: MPL-2.0
+ */
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/identifiers"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/secretsmanager"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/tfc"
+	"github.com/hashicorp/go-tfe"
+	"log"
+	"net/http"
+)
+
+const ProductIdMetadataHeaderKey = "Tfp-Aws-Service-Catalog-Product-Id"
+const ProvisionedProductIdMetadataHeaderKey = "Tfp-Aws-Service-Catalog-Prv-Product-Id"
+const ProductVersionMetadataHeaderKey = "Tfp-Aws-Service-Catalog-Product-Ver"
+
+// AdoptWorkspaceHandler brings an existing workspace under the management of the engine, as the workspace of a
+// provisioned product. The workspace is renamed and moved to the project the engine uses for the product, and its
+// variables are made to match the parameters of the provisioned product. Its state is left as it is.
+type AdoptWorkspaceHandler struct {
+	secretsManager secretsmanager.SecretsManager
+	organization   string
+}
+
+func (h *AdoptWorkspaceHandler) HandleRequest(ctx context.Context, request AdoptWorkspaceRequest) (*AdoptWorkspaceResponse, error) {
+	if err := validateRequest(request); err != nil {
+		return nil, err
+	}
+
+	// Send the metadata headers like the send-apply function does, so TFC associates the workspace with the product
+	headers := http.Header{}
+	headers.Set(ProductIdMetadataHeaderKey, request.ProductId)
+	headers.Set(ProvisionedProductIdMetadataHeaderKey, request.ProvisionedProductId)
+	headers.Set(ProductVersionMetadataHeaderKey, request.ProvisioningArtifactId)
+
+	organization := h.organization
+	if request.TerraformOrganization != "" {
+		organization = request.TerraformOrganization
+	}
+
+	organizationSecretsManager, err := secretsmanager.ForOrganization(h.secretsManager, organization)
+	if err != nil {
+		return nil, err
+	}
+
+	tfeClient, err := tfc.GetTFEClientWithHeaders(ctx, organizationSecretsManager, headers)
+	if err != nil {
+		log.Default().Printf("failed to initialize TFE client: %s", err)
+		return nil, err
+	}
+
+	workspace, err := readWorkspace(ctx, tfeClient, organization, request.WorkspaceName)
+	if err != nil {
+		return nil, err
+	}
+	if workspace == nil {
+		return nil, fmt.Errorf("workspace %s does not exist in organization %s", request.WorkspaceName, organization)
+	}
+
+	// The engine uploads the configuration of the product itself, and must be able to queue runs
+	if workspace.VCSRepo != nil {
+		return nil, fmt.Errorf("workspace %s is connected to a VCS repository, disconnect it before adopting it", workspace.Name)
+	}
+	if workspace.Locked {
+		return nil, fmt.Errorf("workspace %s is locked, unlock it before adopting it", workspace.Name)
+	}
+
+	// Make sure the workspace does not take the name of another workspace
+	workspaceName := identifiers.GetWorkspaceName(request.AwsAccountId, request.ProvisionedProductId)
+	if workspaceName != workspace.Name {
+		existing, err := readWorkspace(ctx, tfeClient, organization, workspaceName)
+		if err != nil {
+			return nil, err
+		}
+		if existing != nil {
+			return nil, fmt.Errorf("workspace %s already exists for provisioned product %s", workspaceName, request.ProvisionedProductId)
+		}
+	}
+
+	existingVariables, err := listVariables(ctx, tfeClient, workspace.ID, 0)
+	if err != nil {
+		return nil, err
+	}
+	variablePlan := PlanVariables(existingVariables, desiredVariables(request.Parameters, request.LaunchRoleArn))
+
+	response := &AdoptWorkspaceResponse{
+		WorkspaceId:     workspace.ID,
+		WorkspaceName:   workspaceName,
+		ProjectName:     request.ProductId,
+		VariableChanges: variablePlan.Changes,
+		DryRun:          request.DryRun,
+	}
+
+	if request.DryRun {
+		log.Default().Printf("dry run, not adopting workspace %s as %s", workspace.Name, workspaceName)
+		return response, nil
+	}
+
+	project, err := findOrCreateProject(ctx, tfeClient, organization, request.ProductId)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Default().Printf("renaming workspace %s to %s and moving it to project %s", workspace.Name, workspaceName, project.Name)
+	_, err = tfeClient.Workspaces.UpdateByID(ctx, workspace.ID, tfe.WorkspaceUpdateOptions{
+		Name:    tfe.String(workspaceName),
+		Project: project,
+	})
+	if err != nil {
+		return nil, tfc.Error(err)
+	}
+
+	if err = variablePlan.Apply(ctx, tfeClient, workspace.ID); err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+func validateRequest(request AdoptWorkspaceRequest) error {
+	required := []struct{ field, value string }{
+		{"workspaceName", request.WorkspaceName},
+		{"awsAccountId", request.AwsAccountId},
+		{"provisionedProductId", request.ProvisionedProductId},
+		{"productId", request.ProductId},
+		{"launchRoleArn", request.LaunchRoleArn},
+	}
+	for _, r := range required {
+		if r.value == "" {
+			return fmt.Errorf("%s is required to adopt a workspace", r.field)
+		}
+	}
+
+	if _, _, ok := identifiers.ParseWorkspaceName(identifiers.GetWorkspaceName(request.AwsAccountId, request.ProvisionedProductId)); !ok {
+		return fmt.Errorf("%s is not a valid AWS account ID", request.AwsAccountId)
+	}
+
+	return nil
+}
+
+// readWorkspace reads the workspace with the given name, returning nil if there is no such workspace
+func readWorkspace(ctx context.Context, client *tfe.Client, organization string, workspaceName string) (*tfe.Workspace, error) {
+	workspace, err := client.Workspaces.Read(ctx, organization, workspaceName)
+	if errors.Is(err, tfe.ErrResourceNotFound) {
+		return nil, nil
+	}
+	return workspace, tfc.Error(err)
+}
+
+func findOrCreateProject(ctx context.Context, client *tfe.Client, organization string, projectName string) (*tfe.Project, error) {
+	projects, err := client.Projects.List(ctx, organization, &tfe.ProjectListOptions{
+		ListOptions: tfe.ListOptions{
+			PageSize: 100,
+		},
+		Name: projectName,
+	})
+	if err != nil {
+		return nil, tfc.Error(err)
+	}
+
+	// Check for exact name match, because the search is a "contains" search
+	for _, project := range projects.Items {
+		if project.Name == projectName {
+			return project, nil
+		}
+	}
+
+	log.Default().Printf("no existing project found, creating project %s", projectName)
+	project, err := client.Projects.Create(ctx, organization, tfe.ProjectCreateOptions{
+		Name: projectName,
+	})
+	return project, tfc.Error(err)
+}
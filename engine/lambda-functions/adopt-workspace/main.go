@@ -0,0 +1,66 @@
+/*
+ * Copyright (c) HashiCorp, Inc.
+ * SPDX-License-Identifier: MPL-2.0
+ */
+
+package main
+
+import (
+	"context"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/awsconfig"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/secretsmanager"
+	"log"
+	"os"
+)
+
+type AdoptWorkspaceRequest struct {
+	// WorkspaceName is the name of the existing workspace to adopt
+	WorkspaceName          string      `json:"workspaceName"`
+	AwsAccountId           string      `json:"awsAccountId"`
+	ProvisionedProductId   string      `json:"provisionedProductId"`
+	ProductId              string      `json:"productId"`
+	ProvisioningArtifactId string      `json:"provisioningArtifactId"`
+	LaunchRoleArn          string      `json:"launchRoleArn"`
+	Parameters             []Parameter `json:"parameters"`
+
+	// TerraformOrganization is the organization of the workspace, which must be the organization the requests of the
+	// provisioned product are routed to. The default organization of the engine is used if unset.
+	TerraformOrganization string `json:"terraformOrganization,omitempty"`
+
+	// DryRun reports the changes adopting the workspace would make, without making them
+	DryRun bool `json:"dryRun"`
+}
+
+type Parameter struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+type AdoptWorkspaceResponse struct {
+	WorkspaceId     string          `json:"workspaceId"`
+	WorkspaceName   string          `json:"workspaceName"`
+	ProjectName     string          `json:"projectName"`
+	VariableChanges VariableChanges `json:"variableChanges"`
+	DryRun          bool            `json:"dryRun"`
+}
+
+func main() {
+	// Create temporary context to initialize the handler with
+	initContext := context.TODO()
+
+	sdkConfig := awsconfig.GetSdkConfig(initContext)
+
+	// Create secrets client SDK to fetch TFE credentials
+	secretsManager, err := secretsmanager.NewWithConfig(initContext, sdkConfig)
+	if err != nil {
+		log.Fatalf("failed to initialize secrets manager client: %s", err)
+	}
+
+	handler := AdoptWorkspaceHandler{
+		secretsManager: secretsManager,
+		organization:   os.Getenv("TERRAFORM_ORGANIZATION"),
+	}
+
+	lambda.Start(handler.HandleRequest)
+}
@@ -0,0 +1,179 @@
+/*
+ * Copyright (c) HashiCorp, Inc.
+ * SPDX-License-Identifier: MPL-2.0
+ */
+
+package main
+
+import (
+	"context"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/tfc"
+	"github.com/hashicorp/go-tfe"
+	"log"
+)
+
+const ProviderAuthVariableKey = "TFC_AWS_PROVIDER_AUTH"
+const RunRoleArnVariableKey = "TFC_AWS_RUN_ROLE_ARN"
+
+// VariableChanges lists the keys of the workspace variables that are created, updated and deleted to match the
+// parameters of the provisioned product
+type VariableChanges struct {
+	Created []string `json:"created"`
+	Updated []string `json:"updated"`
+	Deleted []string `json:"deleted"`
+}
+
+// desiredVariable is a variable the workspace must have once it is adopted
+type desiredVariable struct {
+	key         string
+	value       string
+	description string
+	category    tfe.CategoryType
+}
+
+// variableUpdate is an existing variable along with the variable it must be updated to
+type variableUpdate struct {
+	existing *tfe.Variable
+	desired  desiredVariable
+}
+
+// VariablePlan holds the changes needed to make the variables of the workspace match those the engine sets when it
+// provisions a product, leaving the workspace with the same variables as if the engine had created it
+type VariablePlan struct {
+	create  []desiredVariable
+	update  []variableUpdate
+	delete  []*tfe.Variable
+	Changes VariableChanges
+}
+
+// desiredVariables lists the Terraform variables of the product parameters and the ENV variables for OIDC, like
+// the send-apply function configures them
+func desiredVariables(parameters []Parameter, launchRoleArn string) []desiredVariable {
+	desired := []desiredVariable{
+		{key: ProviderAuthVariableKey, value: "true", description: "Enable the Workload Identity integration for AWS.", category: tfe.CategoryEnv},
+		{key: RunRoleArnVariableKey, value: launchRoleArn, description: "The AWS role ARN runs will use to authenticate.", category: tfe.CategoryEnv},
+	}
+
+	for _, parameter := range parameters {
+		desired = append(desired, desiredVariable{
+			key:         parameter.Key,
+			value:       parameter.Value,
+			description: "Provided via AWS Service Catalog",
+			category:    tfe.CategoryTerraform,
+		})
+	}
+
+	return desired
+}
+
+// PlanVariables compares the existing variables of the workspace with the desired ones. Existing variables that are
+// sensitive are always updated, as their value cannot be compared.
+func PlanVariables(existing []*tfe.Variable, desired []desiredVariable) VariablePlan {
+	plan := VariablePlan{
+		Changes: VariableChanges{
+			Created: []string{},
+			Updated: []string{},
+			Deleted: []string{},
+		},
+	}
+
+	existingByKey := map[tfe.CategoryType]map[string]*tfe.Variable{}
+	for _, variable := range existing {
+		if existingByKey[variable.Category] == nil {
+			existingByKey[variable.Category] = map[string]*tfe.Variable{}
+		}
+		existingByKey[variable.Category][variable.Key] = variable
+	}
+
+	desiredKeys := map[tfe.CategoryType]map[string]bool{}
+	for _, variable := range desired {
+		if desiredKeys[variable.category] == nil {
+			desiredKeys[variable.category] = map[string]bool{}
+		}
+		desiredKeys[variable.category][variable.key] = true
+
+		current, found := existingByKey[variable.category][variable.key]
+		if !found {
+			plan.create = append(plan.create, variable)
+			plan.Changes.Created = append(plan.Changes.Created, variable.key)
+			continue
+		}
+
+		if current.Sensitive || current.HCL || current.Value != variable.value {
+			plan.update = append(plan.update, variableUpdate{existing: current, desired: variable})
+			plan.Changes.Updated = append(plan.Changes.Updated, variable.key)
+		}
+	}
+
+	for _, variable := range existing {
+		if !desiredKeys[variable.Category][variable.Key] {
+			plan.delete = append(plan.delete, variable)
+			plan.Changes.Deleted = append(plan.Changes.Deleted, variable.Key)
+		}
+	}
+
+	return plan
+}
+
+// Apply makes the changes of the plan to the variables of the workspace
+func (plan VariablePlan) Apply(ctx context.Context, client *tfe.Client, workspaceId string) error {
+	for _, variable := range plan.delete {
+		log.Default().Printf("deleting %s variable %s, as it is not recognized by the engine", variable.Category, variable.Key)
+		if err := client.Variables.Delete(ctx, workspaceId, variable.ID); err != nil {
+			return tfc.Error(err)
+		}
+	}
+
+	for _, update := range plan.update {
+		log.Default().Printf("updating %s variable %s", update.desired.category, update.desired.key)
+		_, err := client.Variables.Update(ctx, workspaceId, update.existing.ID, tfe.VariableUpdateOptions{
+			Key:      tfe.String(update.desired.key),
+			Value:    tfe.String(update.desired.value),
+			Category: tfe.Category(update.desired.category),
+			HCL:      tfe.Bool(false),
+		})
+		if err != nil {
+			return tfc.Error(err)
+		}
+	}
+
+	for _, variable := range plan.create {
+		log.Default().Printf("creating %s variable %s", variable.category, variable.key)
+		_, err := client.Variables.Create(ctx, workspaceId, tfe.VariableCreateOptions{
+			Key:         tfe.String(variable.key),
+			Value:       tfe.String(variable.value),
+			Description: tfe.String(variable.description),
+			Category:    tfe.Category(variable.category),
+			HCL:         tfe.Bool(false),
+			Sensitive:   tfe.Bool(false),
+		})
+		if err != nil {
+			return tfc.Error(err)
+		}
+	}
+
+	return nil
+}
+
+func listVariables(ctx context.Context, client *tfe.Client, workspaceId string, pageNumber int) ([]*tfe.Variable, error) {
+	variables, err := client.Variables.List(ctx, workspaceId, &tfe.VariableListOptions{
+		ListOptions: tfe.ListOptions{
+			PageNumber: pageNumber,
+			PageSize:   100,
+		},
+	})
+	if err != nil {
+		return nil, tfc.Error(err)
+	}
+
+	// If more variables exists, fetch them as well
+	if variables.TotalCount > ((pageNumber + 1) * 100) {
+		nextPage, err := listVariables(ctx, client, workspaceId, pageNumber+1)
+		if err != nil {
+			return nil, err
+		}
+		return append(variables.Items, nextPage...), nil
+	}
+
+	return variables.Items, nil
+}
@@ -0,0 +1,188 @@
+/*
+ * Copyright (c) HashiCorp, Inc.
+ * SPDX-License-Identifier: MPL-2.0
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awscloudwatch "github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	cwtypes "github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	awseventbridge "github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	ebtypes "github.com/aws/aws-sdk-go-v2/service/eventbridge/types"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/cloudwatch"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/eventbridge"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/secretsmanager"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/tfc"
+	"github.com/hashicorp/go-tfe"
+	"log"
+	"net/http"
+	"time"
+)
+
+const (
+	EventSource           = "service-catalog-engine-for-tfc"
+	TokenAlertEventType   = "Team Token Rotation Required"
+	MetricNamespace       = "ServiceCatalogTerraformCloud"
+	TokenAgeMetric        = "TeamTokenAgeInDays"
+	TokenExpiryMetric     = "TeamTokenDaysUntilExpiry"
+	rotationOverdueMargin = 24 * time.Hour
+)
+
+// CheckTokenExpiryHandler checks the team token in the TFE credentials of each organization expires far enough in the
+// future, and was rotated recently enough, so a rotation that stopped running is noticed before the token expires
+type CheckTokenExpiryHandler struct {
+	secretsManager   secretsmanager.SecretsManager
+	organization     string
+	eventBridge      eventbridge.EventBridge
+	cloudWatch       cloudwatch.CloudWatch
+	rotationInterval time.Duration
+	warningPeriod    time.Duration
+	now              func() time.Time
+}
+
+// TokenAlertEvent is the detail of the EventBridge event sent when the team token expires soon or was not rotated in time
+type TokenAlertEvent struct {
+	TerraformOrganization string     `json:"terraformOrganization"`
+	TeamId                string     `json:"teamId"`
+	CreatedAt             time.Time  `json:"createdAt"`
+	ExpiresAt             *time.Time `json:"expiresAt,omitempty"`
+	ExpiringSoon          bool       `json:"expiringSoon"`
+	RotationOverdue       bool       `json:"rotationOverdue"`
+}
+
+func (h *CheckTokenExpiryHandler) HandleRequest(ctx context.Context, request CheckTokenExpiryRequest) (*CheckTokenExpiryResponse, error) {
+	organizations, err := secretsmanager.Organizations(h.secretsManager, h.organization)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &CheckTokenExpiryResponse{Tokens: []TeamTokenStatus{}}
+	var errs []error
+	for _, organization := range organizations {
+		status, err := h.checkOrganization(ctx, organization)
+		if err != nil {
+			log.Default().Printf("failed to check the team token of organization %s: %s", organization.Name, err)
+			errs = append(errs, err)
+			continue
+		}
+		response.Tokens = append(response.Tokens, *status)
+	}
+
+	// Fail the invocation if any token could not be checked, so it shows up in the metrics of the function
+	if len(errs) > 0 {
+		return response, errors.Join(errs...)
+	}
+
+	return response, nil
+}
+
+// checkOrganization checks the team token of the organization, and alerts if it needs to be rotated
+func (h *CheckTokenExpiryHandler) checkOrganization(ctx context.Context, organization secretsmanager.Organization) (*TeamTokenStatus, error) {
+	tfeCredentialsSecret, err := organization.SecretsManager.GetSecretValue(ctx)
+	if err != nil {
+		log.Default().Printf("failed to fetch TFE credentials: %s", err)
+		return nil, err
+	}
+
+	tfeClient, err := tfc.GetTFEClientWithCredentials(tfeCredentialsSecret, http.Header{})
+	if err != nil {
+		log.Default().Printf("failed to initialize TFE client: %s", err)
+		return nil, err
+	}
+
+	teamToken, err := tfc.ReadTeamToken(ctx, tfeClient, tfeCredentialsSecret.TeamId)
+	if errors.Is(err, tfe.ErrResourceNotFound) {
+		return nil, fmt.Errorf("team %s of the TFE credentials has no token", tfeCredentialsSecret.TeamId)
+	}
+	if err != nil {
+		return nil, tfc.Error(err)
+	}
+
+	now := h.now()
+	status := &TeamTokenStatus{
+		TerraformOrganization: organization.Name,
+		TeamId:                tfeCredentialsSecret.TeamId,
+		CreatedAt:             teamToken.CreatedAt,
+		ExpiresAt:             teamToken.ExpiredAt,
+		ExpiringSoon:          teamToken.ExpiredAt != nil && teamToken.ExpiredAt.Sub(now) < h.warningPeriod,
+		RotationOverdue:       now.Sub(teamToken.CreatedAt) > h.rotationInterval+rotationOverdueMargin,
+	}
+
+	if err = h.putTokenMetrics(ctx, status, now); err != nil {
+		log.Default().Printf("failed to put team token metrics: %s", err)
+		return nil, err
+	}
+
+	if status.ExpiringSoon || status.RotationOverdue {
+		log.Default().Printf("the token of team %s of organization %s was created at %s and needs to be rotated", status.TeamId, organization.Name, status.CreatedAt.Format(time.RFC3339))
+		if err = h.sendTokenAlertEvent(ctx, status); err != nil {
+			log.Default().Printf("failed to send team token alert: %s", err)
+			return nil, err
+		}
+	}
+
+	return status, nil
+}
+
+func (h *CheckTokenExpiryHandler) putTokenMetrics(ctx context.Context, status *TeamTokenStatus, now time.Time) error {
+	dimensions := []cwtypes.Dimension{
+		{Name: aws.String("TeamId"), Value: aws.String(status.TeamId)},
+	}
+
+	metricData := []cwtypes.MetricDatum{
+		{
+			MetricName: aws.String(TokenAgeMetric),
+			Dimensions: dimensions,
+			Value:      aws.Float64(now.Sub(status.CreatedAt).Hours() / 24),
+			Unit:       cwtypes.StandardUnitNone,
+		},
+	}
+
+	// Tokens created before expiry was set on them never expire
+	if status.ExpiresAt != nil {
+		metricData = append(metricData, cwtypes.MetricDatum{
+			MetricName: aws.String(TokenExpiryMetric),
+			Dimensions: dimensions,
+			Value:      aws.Float64(status.ExpiresAt.Sub(now).Hours() / 24),
+			Unit:       cwtypes.StandardUnitNone,
+		})
+	}
+
+	_, err := h.cloudWatch.PutMetricData(ctx, &awscloudwatch.PutMetricDataInput{
+		Namespace:  aws.String(MetricNamespace),
+		MetricData: metricData,
+	})
+	return err
+}
+
+func (h *CheckTokenExpiryHandler) sendTokenAlertEvent(ctx context.Context, status *TeamTokenStatus) error {
+	detail, err := json.Marshal(TokenAlertEvent(*status))
+	if err != nil {
+		return err
+	}
+
+	output, err := h.eventBridge.PutEvents(ctx, &awseventbridge.PutEventsInput{
+		Entries: []ebtypes.PutEventsRequestEntry{
+			{
+				Source:     aws.String(EventSource),
+				DetailType: aws.String(TokenAlertEventType),
+				Detail:     aws.String(string(detail)),
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	if output.FailedEntryCount > 0 && len(output.Entries) > 0 {
+		return fmt.Errorf("failed to send team token alert: %s", aws.ToString(output.Entries[0].ErrorMessage))
+	}
+
+	return nil
+}
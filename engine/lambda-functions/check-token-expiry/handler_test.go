@@ -0,0 +1,179 @@
+/*
+ * Copyright (c) HashiCorp, Inc.
+ * SPDX-License-Identifier: MPL-2.0
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/testutil/cloudwatch"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/testutil/eventbridge"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/testutil/secretsmanager"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/testutil/testtfc"
+	"github.com/hashicorp/go-tfe"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+const teamId = "team-roLYatraNNailuJ2"
+
+func newTestHandler(tfcServer *testtfc.MockTFC, now time.Time) (*CheckTokenExpiryHandler, *eventbridge.MockEventBridge, *cloudwatch.MockCloudWatch) {
+	mockEventBridge := &eventbridge.MockEventBridge{}
+	mockCloudWatch := cloudwatch.NewMockCloudWatch()
+
+	// Create a test instance of the Lambda function, for tokens rotated every 30 days
+	return &CheckTokenExpiryHandler{
+		secretsManager: &secretsmanager.MockSecretsManager{
+			Hostname: tfcServer.Address,
+			TeamId:   teamId,
+			Token:    "supers3cret",
+		},
+		organization:     tfcServer.OrganizationName,
+		eventBridge:      mockEventBridge,
+		cloudWatch:       mockCloudWatch,
+		rotationInterval: 30 * 24 * time.Hour,
+		warningPeriod:    7 * 24 * time.Hour,
+		now:              func() time.Time { return now },
+	}, mockEventBridge, mockCloudWatch
+}
+
+func TestCheckTokenExpiryHandler_Success(t *testing.T) {
+	// Create mock TFC instance
+	tfcServer := testtfc.NewMockTFC()
+	defer tfcServer.Stop()
+
+	// Add a token that was rotated recently
+	now := time.Now().UTC().Truncate(time.Second)
+	tfcServer.TeamTokens[teamId] = &tfe.TeamToken{ID: teamId, CreatedAt: now.Add(-3 * 24 * time.Hour)}
+	tfcServer.TeamTokenExpiries[teamId] = now.Add(34 * 24 * time.Hour)
+
+	testHandler, mockEventBridge, mockCloudWatch := newTestHandler(tfcServer, now)
+
+	// Send the test request
+	response, err := testHandler.HandleRequest(context.Background(), CheckTokenExpiryRequest{})
+	assert.NoError(t, err)
+
+	// Verify the expiry was reported, without an alert
+	assert.Equal(t, 1, len(response.Tokens))
+	assert.Equal(t, tfcServer.OrganizationName, response.Tokens[0].TerraformOrganization)
+	assert.Equal(t, now.Add(34*24*time.Hour), *response.Tokens[0].ExpiresAt)
+	assert.False(t, response.Tokens[0].ExpiringSoon)
+	assert.False(t, response.Tokens[0].RotationOverdue)
+	assert.Empty(t, mockEventBridge.Entries)
+
+	metrics := mockCloudWatch.MetricData[MetricNamespace]
+	assert.Equal(t, 2, len(metrics))
+	assert.Equal(t, TokenAgeMetric, aws.ToString(metrics[0].MetricName))
+	assert.InDelta(t, 3, aws.ToFloat64(metrics[0].Value), 0.01)
+	assert.Equal(t, TokenExpiryMetric, aws.ToString(metrics[1].MetricName))
+	assert.InDelta(t, 34, aws.ToFloat64(metrics[1].Value), 0.01)
+}
+
+func TestCheckTokenExpiryHandler_SuccessExpiringSoon(t *testing.T) {
+	// Create mock TFC instance
+	tfcServer := testtfc.NewMockTFC()
+	defer tfcServer.Stop()
+
+	// Add a token that should have been rotated days ago
+	now := time.Now().UTC().Truncate(time.Second)
+	tfcServer.TeamTokens[teamId] = &tfe.TeamToken{ID: teamId, CreatedAt: now.Add(-35 * 24 * time.Hour)}
+	tfcServer.TeamTokenExpiries[teamId] = now.Add(2 * 24 * time.Hour)
+
+	testHandler, mockEventBridge, _ := newTestHandler(tfcServer, now)
+
+	// Send the test request
+	response, err := testHandler.HandleRequest(context.Background(), CheckTokenExpiryRequest{})
+	assert.NoError(t, err)
+	assert.True(t, response.Tokens[0].ExpiringSoon)
+	assert.True(t, response.Tokens[0].RotationOverdue)
+
+	// Verify an alert was sent
+	assert.Equal(t, 1, len(mockEventBridge.Entries))
+	assert.Equal(t, TokenAlertEventType, aws.ToString(mockEventBridge.Entries[0].DetailType))
+
+	event := TokenAlertEvent{}
+	assert.NoError(t, json.Unmarshal([]byte(aws.ToString(mockEventBridge.Entries[0].Detail)), &event))
+	assert.Equal(t, teamId, event.TeamId)
+	assert.True(t, event.ExpiringSoon)
+}
+
+func TestCheckTokenExpiryHandler_SuccessRotationOverdueWithoutExpiry(t *testing.T) {
+	// Create mock TFC instance
+	tfcServer := testtfc.NewMockTFC()
+	defer tfcServer.Stop()
+
+	// Add a token without expiry, like the token created by Terraform, that was never rotated
+	now := time.Now().UTC().Truncate(time.Second)
+	tfcServer.TeamTokens[teamId] = &tfe.TeamToken{ID: teamId, CreatedAt: now.Add(-45 * 24 * time.Hour)}
+
+	testHandler, mockEventBridge, mockCloudWatch := newTestHandler(tfcServer, now)
+
+	// Send the test request
+	response, err := testHandler.HandleRequest(context.Background(), CheckTokenExpiryRequest{})
+	assert.NoError(t, err)
+
+	// Verify the overdue rotation was alerted on, and no expiry metric was put
+	assert.Nil(t, response.Tokens[0].ExpiresAt)
+	assert.False(t, response.Tokens[0].ExpiringSoon)
+	assert.True(t, response.Tokens[0].RotationOverdue)
+	assert.Equal(t, 1, len(mockEventBridge.Entries))
+	assert.Equal(t, 1, len(mockCloudWatch.MetricData[MetricNamespace]))
+}
+
+func TestCheckTokenExpiryHandler_ErrorNoToken(t *testing.T) {
+	// Create mock TFC instance, without any team tokens
+	tfcServer := testtfc.NewMockTFC()
+	defer tfcServer.Stop()
+
+	testHandler, _, _ := newTestHandler(tfcServer, time.Now())
+
+	// Send the test request
+	_, err := testHandler.HandleRequest(context.Background(), CheckTokenExpiryRequest{})
+	assert.EqualError(t, err, "team team-roLYatraNNailuJ2 of the TFE credentials has no token")
+}
+
+func TestCheckTokenExpiryHandler_SuccessMultipleOrganizations(t *testing.T) {
+	// Create mock TFC instances for the default organization and an organization with its own credentials
+	tfcServer := testtfc.NewMockTFC()
+	defer tfcServer.Stop()
+
+	otherTfcServer := testtfc.NewMockTFC()
+	defer otherTfcServer.Stop()
+	otherTfcServer.OrganizationName = "business-unit-on-tfe"
+
+	// Add a token that was rotated recently to the default organization, and one that expires soon to the other
+	now := time.Now().UTC().Truncate(time.Second)
+	tfcServer.TeamTokens[teamId] = &tfe.TeamToken{ID: teamId, CreatedAt: now.Add(-3 * 24 * time.Hour)}
+	tfcServer.TeamTokenExpiries[teamId] = now.Add(34 * 24 * time.Hour)
+	otherTfcServer.TeamTokens["team-otherteam"] = &tfe.TeamToken{ID: "team-otherteam", CreatedAt: now.Add(-35 * 24 * time.Hour)}
+	otherTfcServer.TeamTokenExpiries["team-otherteam"] = now.Add(2 * 24 * time.Hour)
+
+	testHandler, mockEventBridge, _ := newTestHandler(tfcServer, now)
+	testHandler.secretsManager.(*secretsmanager.MockSecretsManager).Organizations = map[string]*secretsmanager.MockSecretsManager{
+		"business-unit-on-tfe": {
+			Hostname: otherTfcServer.Address,
+			TeamId:   "team-otherteam",
+			Token:    "othersupers3cret",
+		},
+	}
+
+	// Send the test request
+	response, err := testHandler.HandleRequest(context.Background(), CheckTokenExpiryRequest{})
+	assert.NoError(t, err)
+
+	// Verify the tokens of both organizations were checked, and only the expiring token was alerted on
+	assert.Equal(t, 2, len(response.Tokens))
+	assert.False(t, response.Tokens[0].ExpiringSoon)
+	assert.Equal(t, "business-unit-on-tfe", response.Tokens[1].TerraformOrganization)
+	assert.True(t, response.Tokens[1].ExpiringSoon)
+	assert.Equal(t, 1, len(mockEventBridge.Entries))
+
+	event := TokenAlertEvent{}
+	assert.NoError(t, json.Unmarshal([]byte(aws.ToString(mockEventBridge.Entries[0].Detail)), &event))
+	assert.Equal(t, "business-unit-on-tfe", event.TerraformOrganization)
+	assert.Equal(t, "team-otherteam", event.TeamId)
+}
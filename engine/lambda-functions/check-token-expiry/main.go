@@ -0,0 +1,72 @@
+/*
+ * Copyright (c) HashiCorp, Inc.
+ * SPDX-License-Identifier: MPL-2.0
+ */
+
+package main
+
+import (
+	"context"
+	"github.com/aws/aws-lambda-go/lambda"
+	cw "github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	eb "github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/awsconfig"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/cloudwatch"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/eventbridge"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/secretsmanager"
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+type CheckTokenExpiryRequest struct{}
+
+type CheckTokenExpiryResponse struct {
+	// Tokens holds the status of the team token of each organization, starting with the default organization
+	Tokens []TeamTokenStatus `json:"tokens"`
+}
+
+type TeamTokenStatus struct {
+	TerraformOrganization string     `json:"terraformOrganization"`
+	TeamId                string     `json:"teamId"`
+	CreatedAt             time.Time  `json:"createdAt"`
+	ExpiresAt             *time.Time `json:"expiresAt,omitempty"`
+	ExpiringSoon          bool       `json:"expiringSoon"`
+	RotationOverdue       bool       `json:"rotationOverdue"`
+}
+
+func main() {
+	// Create temporary context to initialize the handler with
+	initContext := context.TODO()
+
+	sdkConfig := awsconfig.GetSdkConfig(initContext)
+
+	// Create secrets client SDK to fetch TFE credentials
+	secretsManager, err := secretsmanager.NewWithConfig(initContext, sdkConfig)
+	if err != nil {
+		log.Fatalf("failed to initialize secrets manager client: %s", err)
+	}
+
+	rotationIntervalInDays, err := strconv.Atoi(os.Getenv("TOKEN_ROTATION_INTERVAL_IN_DAYS"))
+	if err != nil {
+		log.Fatalf("failed to parse TOKEN_ROTATION_INTERVAL_IN_DAYS: %s", err)
+	}
+
+	warningInDays, err := strconv.Atoi(os.Getenv("TOKEN_EXPIRY_WARNING_IN_DAYS"))
+	if err != nil {
+		log.Fatalf("failed to parse TOKEN_EXPIRY_WARNING_IN_DAYS: %s", err)
+	}
+
+	handler := CheckTokenExpiryHandler{
+		secretsManager:   secretsManager,
+		organization:     os.Getenv("TERRAFORM_ORGANIZATION"),
+		eventBridge:      eventbridge.EB{Client: eb.NewFromConfig(sdkConfig)},
+		cloudWatch:       cloudwatch.CW{Client: cw.NewFromConfig(sdkConfig)},
+		rotationInterval: time.Duration(rotationIntervalInDays) * 24 * time.Hour,
+		warningPeriod:    time.Duration(warningInDays) * 24 * time.Hour,
+		now:              time.Now,
+	}
+
+	lambda.Start(handler.HandleRequest)
+}
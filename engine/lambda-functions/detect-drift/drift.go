@@ -0,0 +1,176 @@
+/*
+ * Copyright (c) HashiCorp, Inc.
+ * SPDX-License-Identifier: MPL-2.0
+ */
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/tfc"
+	"github.com/hashicorp/go-tfe"
+	"log"
+	"net/url"
+	"time"
+)
+
+// DriftDetectionRunMessage marks the refresh-only plans queued by the engine, so that their results can be found again
+const DriftDetectionRunMessage = "Drift detection by AWS Service Catalog Engine for Terraform Cloud"
+
+type DriftSource string
+
+// Enum values for DriftSource
+const (
+	RefreshOnlyPlan  DriftSource = "refresh-only-plan"
+	HealthAssessment DriftSource = "health-assessment"
+)
+
+// DriftCheck is the result of checking a provisioned product's workspace for drift
+type DriftCheck struct {
+	Organization         string
+	Workspace            *tfe.Workspace
+	AwsAccountId         string
+	ProvisionedProductId string
+	Source               DriftSource
+
+	// CheckId is the ID of the run or the health assessment result that checked the workspace
+	CheckId string
+
+	Drifted          bool
+	DriftedResources []DriftedResource
+}
+
+// DriftedResource is a resource that changed outside of Terraform, along with the actions Terraform detected
+type DriftedResource struct {
+	Address string   `json:"address"`
+	Actions []string `json:"actions"`
+}
+
+// ParseResourceDrift lists the resources that changed outside of Terraform, from the JSON output of a plan
+func ParseResourceDrift(planJSON []byte) ([]DriftedResource, error) {
+	var plan struct {
+		ResourceDrift []struct {
+			Address string `json:"address"`
+			Change  struct {
+				Actions []string `json:"actions"`
+			} `json:"change"`
+		} `json:"resource_drift"`
+	}
+	if err := json.Unmarshal(planJSON, &plan); err != nil {
+		return nil, fmt.Errorf("failed to parse plan JSON output: %w", err)
+	}
+
+	resources := []DriftedResource{}
+	for _, drift := range plan.ResourceDrift {
+		if len(drift.Change.Actions) == 1 && drift.Change.Actions[0] == "no-op" {
+			continue
+		}
+		resources = append(resources, DriftedResource{
+			Address: drift.Address,
+			Actions: drift.Change.Actions,
+		})
+	}
+
+	return resources, nil
+}
+
+// LatestDriftRun returns the most recent refresh-only plan queued by the engine for the workspace, or nil if there is none
+func LatestDriftRun(ctx context.Context, client *tfe.Client, workspaceId string) (*tfe.Run, error) {
+	runs, err := client.Runs.List(ctx, workspaceId, &tfe.RunListOptions{
+		ListOptions: tfe.ListOptions{
+			PageSize: 20,
+		},
+		Operation: string(tfe.RunOperationRefreshOnly),
+	})
+	if err != nil {
+		return nil, tfc.Error(err)
+	}
+
+	// Runs are listed newest first
+	for _, run := range runs.Items {
+		if run.RefreshOnly && run.Message == DriftDetectionRunMessage {
+			return run, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// CheckDriftRun turns a completed refresh-only plan into a drift check, fetching the drifted resources from the plan
+func CheckDriftRun(ctx context.Context, client *tfe.Client, run *tfe.Run) (*DriftCheck, error) {
+	check := &DriftCheck{
+		Source:  RefreshOnlyPlan,
+		CheckId: run.ID,
+		Drifted: run.HasChanges,
+	}
+
+	if !check.Drifted || run.Plan == nil {
+		return check, nil
+	}
+
+	planJSON, err := client.Plans.ReadJSONOutput(ctx, run.Plan.ID)
+	if err != nil {
+		return nil, tfc.Error(err)
+	}
+
+	check.DriftedResources, err = ParseResourceDrift(planJSON)
+	return check, err
+}
+
+// assessmentResult is the result of a health assessment of a workspace, which go-tfe has no API for yet
+type assessmentResult struct {
+	ID        string    `jsonapi:"primary,assessment-results"`
+	Drifted   bool      `jsonapi:"attr,drifted"`
+	Succeeded bool      `jsonapi:"attr,succeeded"`
+	ErrorMsg  string    `jsonapi:"attr,error-msg"`
+	CreatedAt time.Time `jsonapi:"attr,created-at,iso8601"`
+}
+
+// CheckHealthAssessment turns the current health assessment result of the workspace into a drift check, or returns nil
+// if the workspace has not been assessed successfully yet
+func CheckHealthAssessment(ctx context.Context, client *tfe.Client, workspace *tfe.Workspace) (*DriftCheck, error) {
+	request, err := client.NewRequest("GET", fmt.Sprintf("workspaces/%s/current-assessment-result", url.QueryEscape(workspace.ID)), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &assessmentResult{}
+	if err = request.Do(ctx, result); err != nil {
+		if errors.Is(err, tfe.ErrResourceNotFound) {
+			return nil, nil
+		}
+		return nil, tfc.Error(err)
+	}
+
+	if !result.Succeeded {
+		log.Default().Printf("health assessment %s of workspace %s failed, ignoring it: %s", result.ID, workspace.Name, result.ErrorMsg)
+		return nil, nil
+	}
+
+	check := &DriftCheck{
+		Source:  HealthAssessment,
+		CheckId: result.ID,
+		Drifted: result.Drifted,
+	}
+
+	if !check.Drifted {
+		return check, nil
+	}
+
+	request, err = client.NewRequest("GET", fmt.Sprintf("assessment-results/%s/json-output", url.QueryEscape(result.ID)), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var planJSON bytes.Buffer
+	if err = request.Do(ctx, &planJSON); err != nil {
+		return nil, tfc.Error(err)
+	}
+
+	check.DriftedResources, err = ParseResourceDrift(planJSON.Bytes())
+	return check, err
+}
@@ -0,0 +1,202 @@
+/*
+ * Copyright (c) HashiCorp, Inc.
+ * SPDX-License-Identifier: MPL-2.0
+ */
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/cloudwatch"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/enginestate"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/eventbridge"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/identifiers"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/secretsmanager"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/tfc"
+	"github.com/hashicorp/go-tfe"
+	"log"
+	"sort"
+	"time"
+)
+
+// DetectDriftHandler checks the workspaces of all provisioned products for drift, and reports drifted provisioned
+// products as EventBridge events and CloudWatch metrics. Workspaces with health assessments enabled are checked using
+// their latest assessment; the other workspaces are checked by queueing refresh-only plans, a batch per invocation.
+type DetectDriftHandler struct {
+	secretsManager secretsmanager.SecretsManager
+	engineState    enginestate.EngineState
+	eventBridge    eventbridge.EventBridge
+	cloudWatch     cloudwatch.CloudWatch
+	organization   string
+	eventBusName   string
+	batchSize      int
+}
+
+// driftRunCandidate is a workspace that may have a refresh-only plan queued, with the time it was last checked and the
+// client of its organization
+type driftRunCandidate struct {
+	workspace     *tfe.Workspace
+	lastCheckedAt time.Time
+	client        *tfe.Client
+}
+
+func (h *DetectDriftHandler) HandleRequest(ctx context.Context, request DetectDriftRequest) (*DetectDriftResponse, error) {
+	organizations, err := secretsmanager.Organizations(h.secretsManager, h.organization)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &DetectDriftResponse{DriftedWorkspaces: []string{}}
+	var candidates []driftRunCandidate
+	var errs []error
+	for _, organization := range organizations {
+		organizationCandidates, err := h.checkOrganization(ctx, organization, response)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		candidates = append(candidates, organizationCandidates...)
+	}
+
+	// Queue refresh-only plans for the workspaces that were checked the longest time ago, and those never checked before
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].lastCheckedAt.Before(candidates[j].lastCheckedAt)
+	})
+	for _, candidate := range candidates {
+		if response.QueuedRuns >= h.batchSize {
+			break
+		}
+
+		_, err := candidate.client.Runs.Create(ctx, tfe.RunCreateOptions{
+			Workspace:   candidate.workspace,
+			PlanOnly:    tfe.Bool(true),
+			RefreshOnly: tfe.Bool(true),
+			Message:     tfe.String(DriftDetectionRunMessage),
+		})
+		if err != nil {
+			log.Default().Printf("failed to queue refresh-only plan for workspace %s: %s", candidate.workspace.Name, err)
+			errs = append(errs, fmt.Errorf("failed to queue refresh-only plan for workspace %s: %w", candidate.workspace.Name, tfc.Error(err)))
+			continue
+		}
+		response.QueuedRuns++
+	}
+
+	// Fail the invocation if any workspace could not be checked, so it shows up in the metrics of the function
+	if len(errs) > 0 {
+		return response, errors.Join(errs...)
+	}
+
+	return response, nil
+}
+
+// checkOrganization reports the drift checks of the workspaces of the organization, and returns the workspaces due for
+// a new refresh-only plan
+func (h *DetectDriftHandler) checkOrganization(ctx context.Context, organization secretsmanager.Organization, response *DetectDriftResponse) ([]driftRunCandidate, error) {
+	// Fetch the TFE credentials along with the client, the hostname is needed to build the links in the events
+	tfeClient, tfeCredentialsSecret, err := tfc.GetTFEClientAndCredentials(ctx, organization.SecretsManager)
+	if err != nil {
+		log.Default().Printf("failed to initialize TFE client for organization %s: %s", organization.Name, err)
+		return nil, err
+	}
+
+	workspaces, err := tfc.ListProvisionedProductWorkspaces(ctx, tfeClient, organization.Name)
+	if err != nil {
+		log.Default().Printf("failed to list the workspaces of organization %s: %s", organization.Name, err)
+		return nil, err
+	}
+
+	var candidates []driftRunCandidate
+	var errs []error
+	for _, workspace := range workspaces {
+		check, candidate, err := h.checkWorkspace(ctx, tfeClient, workspace)
+		if err != nil {
+			log.Default().Printf("failed to check workspace %s for drift: %s", workspace.Name, err)
+			errs = append(errs, fmt.Errorf("failed to check workspace %s for drift: %w", workspace.Name, err))
+			continue
+		}
+		if candidate != nil {
+			candidate.client = tfeClient
+			candidates = append(candidates, *candidate)
+		}
+		if check == nil {
+			continue
+		}
+		check.Organization = organization.Name
+
+		url := tfc.GetRunUrl(tfeCredentialsSecret.Hostname, organization.Name, workspace.Name, check.CheckId)
+		if check.Source == HealthAssessment {
+			url = tfc.GetWorkspaceDriftUrl(tfeCredentialsSecret.Hostname, organization.Name, workspace.Name)
+		}
+
+		reported, err := h.Report(ctx, *check, url)
+		if err != nil {
+			log.Default().Printf("failed to report drift check of workspace %s: %s", workspace.Name, err)
+			errs = append(errs, fmt.Errorf("failed to report drift check of workspace %s: %w", workspace.Name, err))
+			continue
+		}
+		if !reported {
+			continue
+		}
+
+		response.CheckedWorkspaces++
+		if check.Drifted {
+			log.Default().Printf("workspace %s drifted, %d resources changed outside of Terraform", workspace.Name, len(check.DriftedResources))
+			response.DriftedWorkspaces = append(response.DriftedWorkspaces, workspace.Name)
+		}
+	}
+
+	return candidates, errors.Join(errs...)
+}
+
+// checkWorkspace returns the latest completed drift check of the workspace, if there is one, and whether the workspace
+// is due for a new refresh-only plan
+func (h *DetectDriftHandler) checkWorkspace(ctx context.Context, client *tfe.Client, workspace *tfe.Workspace) (*DriftCheck, *driftRunCandidate, error) {
+	accountId, provisionedProductId, _ := identifiers.ParseWorkspaceName(workspace.Name)
+
+	// TFC checks workspaces with health assessments enabled for drift on its own
+	if workspace.AssessmentsEnabled {
+		check, err := CheckHealthAssessment(ctx, client, workspace)
+		if err != nil || check == nil {
+			return nil, nil, err
+		}
+		check.Workspace, check.AwsAccountId, check.ProvisionedProductId = workspace, accountId, provisionedProductId
+		return check, nil, nil
+	}
+
+	run, err := LatestDriftRun(ctx, client, workspace.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Workspaces that were never checked are queued first
+	if run == nil {
+		return nil, skipLocked(workspace, time.Time{}), nil
+	}
+
+	// Wait for the previous refresh-only plan to complete before queueing the next one
+	if tfc.GetRunPhase(run.Status) != tfc.RunPhaseCompleted {
+		return nil, nil, nil
+	}
+
+	candidate := skipLocked(workspace, run.CreatedAt)
+	if run.Status != tfe.RunPlannedAndFinished {
+		log.Default().Printf("refresh-only plan %s of workspace %s did not complete successfully, its status is %s", run.ID, workspace.Name, run.Status)
+		return nil, candidate, nil
+	}
+
+	check, err := CheckDriftRun(ctx, client, run)
+	if err != nil {
+		return nil, nil, err
+	}
+	check.Workspace, check.AwsAccountId, check.ProvisionedProductId = workspace, accountId, provisionedProductId
+	return check, candidate, nil
+}
+
+// skipLocked makes the workspace a candidate for a refresh-only plan, unless it is locked by a provisioning operation
+func skipLocked(workspace *tfe.Workspace, lastCheckedAt time.Time) *driftRunCandidate {
+	if workspace.Locked {
+		return nil
+	}
+	return &driftRunCandidate{workspace: workspace, lastCheckedAt: lastCheckedAt}
+}
@@ -0,0 +1,286 @@
+/*
+ * Copyright (c) HashiCorp, Inc.
+ * SPDX-License-Identifier: MPL-2.0
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/testutil/cloudwatch"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/testutil/enginestate"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/testutil/eventbridge"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/testutil/secretsmanager"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/testutil/testtfc"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/tfc"
+	"github.com/hashicorp/go-tfe"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+const driftedPlanJSON = `{
+	"resource_drift": [
+		{"address": "aws_s3_bucket.bucket", "change": {"actions": ["update"]}},
+		{"address": "aws_sqs_queue.queue", "change": {"actions": ["delete"]}}
+	]
+}`
+
+func TestDetectDriftHandler_Success(t *testing.T) {
+	// Create mock TFC instance
+	tfcServer := testtfc.NewMockTFC()
+	defer tfcServer.Stop()
+
+	now := time.Now()
+
+	// Add a workspace that was never checked for drift, and one that is locked by a provisioning operation
+	tfcServer.AddWorkspace("123456789042-unchecked-product-instance", testtfc.WorkspaceFactoryParameters{})
+	locked := tfcServer.AddWorkspace("123456789042-locked-product-instance", testtfc.WorkspaceFactoryParameters{})
+	locked.Locked = true
+
+	// Add a workspace whose last refresh-only plan found drift
+	tfcServer.AddWorkspace("123456789042-drifted-product-instance", testtfc.WorkspaceFactoryParameters{})
+	plan := tfcServer.AddPlan(&tfe.Plan{Status: tfe.PlanFinished, HasChanges: true})
+	tfcServer.PlanJSONOutputs[plan.ID] = []byte(driftedPlanJSON)
+	driftRun := tfcServer.AddRun("run-drifted", testtfc.RunFactoryParameters{
+		RunStatus:   tfe.RunPlannedAndFinished,
+		WorkspaceId: "123456789042-drifted-product-instance",
+		Plan:        plan,
+		CreatedAt:   now.Add(-2 * time.Hour),
+		Message:     DriftDetectionRunMessage,
+		RefreshOnly: true,
+		PlanOnly:    true,
+		HasChanges:  true,
+	})
+
+	// Add a workspace whose refresh-only plan is still running
+	tfcServer.AddWorkspace("123456789042-planning-product-instance", testtfc.WorkspaceFactoryParameters{})
+	tfcServer.AddRun("run-planning", testtfc.RunFactoryParameters{
+		RunStatus:   tfe.RunPlanning,
+		WorkspaceId: "123456789042-planning-product-instance",
+		CreatedAt:   now.Add(-1 * time.Minute),
+		Message:     DriftDetectionRunMessage,
+		RefreshOnly: true,
+		PlanOnly:    true,
+	})
+
+	// Add a workspace with health assessments enabled, which drifted
+	assessed := tfcServer.AddWorkspace("123456789042-assessed-product-instance", testtfc.WorkspaceFactoryParameters{})
+	assessed.AssessmentsEnabled = true
+	tfcServer.AddAssessmentResult(assessed.ID, &testtfc.AssessmentResult{
+		ID:        "asmtres-drifted",
+		Drifted:   true,
+		Succeeded: true,
+		CreatedAt: now.Add(-3 * time.Hour),
+	}, []byte(driftedPlanJSON))
+
+	// Add workspaces that must not be checked, as they are not managed by the engine or were terminated
+	tfcServer.AddWorkspace("my-hand-crafted-workspace", testtfc.WorkspaceFactoryParameters{})
+	terminated := tfcServer.AddWorkspace("123456789042-terminated-product-instance", testtfc.WorkspaceFactoryParameters{})
+	terminated.TagNames = []string{tfc.TerminatedTag}
+
+	// Create the TFE client that will send requests to the mock TFC instance
+	mockSecretsManager := &secretsmanager.MockSecretsManager{
+		Hostname: tfcServer.Address,
+		TeamId:   "team-4123nlol",
+		Token:    "supers3cret",
+	}
+	mockEventBridge := &eventbridge.MockEventBridge{}
+	mockCloudWatch := cloudwatch.NewMockCloudWatch()
+
+	// Create a test instance of the Lambda function
+	testHandler := &DetectDriftHandler{
+		secretsManager: mockSecretsManager,
+		engineState:    enginestate.NewMockEngineState(),
+		eventBridge:    mockEventBridge,
+		cloudWatch:     mockCloudWatch,
+		organization:   tfcServer.OrganizationName,
+		batchSize:      10,
+	}
+
+	// Send the test request
+	response, err := testHandler.HandleRequest(context.Background(), DetectDriftRequest{})
+	assert.NoError(t, err)
+
+	// Verify both drifted workspaces were reported
+	assert.Equal(t, 2, response.CheckedWorkspaces)
+	assert.ElementsMatch(t, []string{"123456789042-drifted-product-instance", "123456789042-assessed-product-instance"}, response.DriftedWorkspaces)
+	assert.Equal(t, 2, len(mockEventBridge.Entries))
+
+	events := map[string]DriftDetectedEvent{}
+	for _, entry := range mockEventBridge.Entries {
+		assert.Equal(t, EventSource, aws.ToString(entry.Source))
+		assert.Equal(t, DriftEventType, aws.ToString(entry.DetailType))
+
+		var event DriftDetectedEvent
+		assert.NoError(t, json.Unmarshal([]byte(aws.ToString(entry.Detail)), &event))
+		events[event.WorkspaceName] = event
+	}
+
+	runEvent := events["123456789042-drifted-product-instance"]
+	assert.Equal(t, "123456789042", runEvent.AwsAccountId)
+	assert.Equal(t, "drifted-product-instance", runEvent.ProvisionedProductId)
+	assert.Equal(t, RefreshOnlyPlan, runEvent.DetectedBy)
+	assert.Equal(t, driftRun.ID, runEvent.CheckId)
+	assert.Equal(t, tfc.GetRunUrl(tfcServer.Address, tfcServer.OrganizationName, "123456789042-drifted-product-instance", driftRun.ID), runEvent.Url)
+	assert.Equal(t, 2, runEvent.DriftedResourceCount)
+	assert.Equal(t, []DriftedResource{
+		{Address: "aws_s3_bucket.bucket", Actions: []string{"update"}},
+		{Address: "aws_sqs_queue.queue", Actions: []string{"delete"}},
+	}, runEvent.DriftedResources)
+
+	assessmentEvent := events["123456789042-assessed-product-instance"]
+	assert.Equal(t, HealthAssessment, assessmentEvent.DetectedBy)
+	assert.Equal(t, "asmtres-drifted", assessmentEvent.CheckId)
+	assert.Equal(t, tfc.GetWorkspaceDriftUrl(tfcServer.Address, tfcServer.OrganizationName, "123456789042-assessed-product-instance"), assessmentEvent.Url)
+	assert.Equal(t, 2, assessmentEvent.DriftedResourceCount)
+
+	// Verify the metrics of both checks were put
+	assert.Equal(t, 4, len(mockCloudWatch.MetricData[MetricNamespace]))
+
+	// Verify refresh-only plans were queued for the never checked and the previously checked workspaces only
+	assert.Equal(t, 2, response.QueuedRuns)
+	queued := map[string]bool{}
+	for _, run := range tfcServer.Runs {
+		if run.ID == driftRun.ID || run.ID == "run-planning" {
+			continue
+		}
+		assert.True(t, run.RefreshOnly, "Drift detection runs should be refresh-only")
+		assert.True(t, run.PlanOnly, "Drift detection runs should be speculative")
+		assert.Equal(t, DriftDetectionRunMessage, run.Message)
+		queued[run.Workspace.ID] = true
+	}
+	assert.Equal(t, map[string]bool{
+		"123456789042-unchecked-product-instance": true,
+		"123456789042-drifted-product-instance":   true,
+	}, queued)
+}
+
+func TestDetectDriftHandler_ReportsEachCheckOnce(t *testing.T) {
+	// Create mock TFC instance
+	tfcServer := testtfc.NewMockTFC()
+	defer tfcServer.Stop()
+
+	// Add a workspace whose last refresh-only plan found no drift, and one that was never checked
+	tfcServer.AddWorkspace("123456789042-clean-product-instance", testtfc.WorkspaceFactoryParameters{})
+	tfcServer.AddRun("run-clean", testtfc.RunFactoryParameters{
+		RunStatus:   tfe.RunPlannedAndFinished,
+		WorkspaceId: "123456789042-clean-product-instance",
+		CreatedAt:   time.Now().Add(-1 * time.Hour),
+		Message:     DriftDetectionRunMessage,
+		RefreshOnly: true,
+		PlanOnly:    true,
+	})
+	tfcServer.AddWorkspace("123456789042-unchecked-product-instance", testtfc.WorkspaceFactoryParameters{})
+
+	// Create the TFE client that will send requests to the mock TFC instance
+	mockSecretsManager := &secretsmanager.MockSecretsManager{
+		Hostname: tfcServer.Address,
+		TeamId:   "team-4123nlol",
+		Token:    "supers3cret",
+	}
+	mockEventBridge := &eventbridge.MockEventBridge{}
+	mockCloudWatch := cloudwatch.NewMockCloudWatch()
+
+	// Create a test instance of the Lambda function, which queues a single refresh-only plan per invocation
+	testHandler := &DetectDriftHandler{
+		secretsManager: mockSecretsManager,
+		engineState:    enginestate.NewMockEngineState(),
+		eventBridge:    mockEventBridge,
+		cloudWatch:     mockCloudWatch,
+		organization:   tfcServer.OrganizationName,
+		batchSize:      1,
+	}
+
+	// Send the test request
+	response, err := testHandler.HandleRequest(context.Background(), DetectDriftRequest{})
+	assert.NoError(t, err)
+
+	// Verify the clean check was reported as metrics only, and the never checked workspace was queued first
+	assert.Equal(t, 1, response.CheckedWorkspaces)
+	assert.Empty(t, response.DriftedWorkspaces)
+	assert.Empty(t, mockEventBridge.Entries)
+	assert.Equal(t, 2, len(mockCloudWatch.MetricData[MetricNamespace]))
+	assert.Equal(t, 1, response.QueuedRuns)
+	assert.Equal(t, 2, len(tfcServer.Runs))
+
+	// Make the newly queued run complete, then send the test request again
+	for _, run := range tfcServer.Runs {
+		run.Status = tfe.RunPlannedAndFinished
+	}
+	response, err = testHandler.HandleRequest(context.Background(), DetectDriftRequest{})
+	assert.NoError(t, err)
+
+	// Verify only the new check was reported
+	assert.Equal(t, 1, response.CheckedWorkspaces)
+	assert.Equal(t, 4, len(mockCloudWatch.MetricData[MetricNamespace]))
+}
+
+func TestDetectDriftHandler_SuccessMultipleOrganizations(t *testing.T) {
+	// Create mock TFC instances for the default organization and an organization with its own credentials
+	tfcServer := testtfc.NewMockTFC()
+	defer tfcServer.Stop()
+
+	otherTfcServer := testtfc.NewMockTFC()
+	defer otherTfcServer.Stop()
+	otherTfcServer.OrganizationName = "business-unit-on-tfe"
+
+	// Add a workspace with health assessments enabled, which drifted, to the organization with its own credentials
+	assessed := otherTfcServer.AddWorkspace("123456789042-assessed-product-instance", testtfc.WorkspaceFactoryParameters{})
+	assessed.AssessmentsEnabled = true
+	otherTfcServer.AddAssessmentResult(assessed.ID, &testtfc.AssessmentResult{
+		ID:        "asmtres-drifted",
+		Drifted:   true,
+		Succeeded: true,
+		CreatedAt: time.Now().Add(-3 * time.Hour),
+	}, []byte(driftedPlanJSON))
+
+	// Add a workspace that was never checked for drift to each organization
+	tfcServer.AddWorkspace("123456789042-unchecked-product-instance", testtfc.WorkspaceFactoryParameters{})
+	otherTfcServer.AddWorkspace("123456789042-other-unchecked-product-instance", testtfc.WorkspaceFactoryParameters{})
+
+	// Create the TFE clients that will send requests to the mock TFC instances
+	mockSecretsManager := &secretsmanager.MockSecretsManager{
+		Hostname: tfcServer.Address,
+		TeamId:   "team-4123nlol",
+		Token:    "supers3cret",
+		Organizations: map[string]*secretsmanager.MockSecretsManager{
+			"business-unit-on-tfe": {
+				Hostname: otherTfcServer.Address,
+				TeamId:   "team-otherteam",
+				Token:    "othersupers3cret",
+			},
+		},
+	}
+	mockEventBridge := &eventbridge.MockEventBridge{}
+
+	// Create a test instance of the Lambda function
+	testHandler := &DetectDriftHandler{
+		secretsManager: mockSecretsManager,
+		engineState:    enginestate.NewMockEngineState(),
+		eventBridge:    mockEventBridge,
+		cloudWatch:     cloudwatch.NewMockCloudWatch(),
+		organization:   tfcServer.OrganizationName,
+		batchSize:      10,
+	}
+
+	// Send the test request
+	response, err := testHandler.HandleRequest(context.Background(), DetectDriftRequest{})
+	assert.NoError(t, err)
+
+	// Verify the drift was reported with the organization of the workspace
+	assert.Equal(t, []string{"123456789042-assessed-product-instance"}, response.DriftedWorkspaces)
+	assert.Equal(t, 1, len(mockEventBridge.Entries))
+
+	var event DriftDetectedEvent
+	assert.NoError(t, json.Unmarshal([]byte(aws.ToString(mockEventBridge.Entries[0].Detail)), &event))
+	assert.Equal(t, "business-unit-on-tfe", event.TerraformOrganization)
+	assert.Equal(t, tfc.GetWorkspaceDriftUrl(otherTfcServer.Address, "business-unit-on-tfe", "123456789042-assessed-product-instance"), event.Url)
+
+	// Verify refresh-only plans were queued in both organizations
+	assert.Equal(t, 2, response.QueuedRuns)
+	assert.Equal(t, 1, len(tfcServer.Runs))
+	assert.Equal(t, 1, len(otherTfcServer.Runs))
+}
@@ -0,0 +1,59 @@
+/*
+ * Copyright (c) HashiCorp, Inc.
+ * SPDX-License-Identifier: MPL-2.0
+ */
+
+package main
+
+import (
+	"context"
+	"github.com/aws/aws-lambda-go/lambda"
+	cw "github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	eb "github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/awsconfig"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/cloudwatch"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/enginestate"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/eventbridge"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/secretsmanager"
+	"log"
+	"os"
+	"strconv"
+)
+
+type DetectDriftRequest struct{}
+
+type DetectDriftResponse struct {
+	CheckedWorkspaces int      `json:"checkedWorkspaces"`
+	DriftedWorkspaces []string `json:"driftedWorkspaces"`
+	QueuedRuns        int      `json:"queuedRuns"`
+}
+
+func main() {
+	// Create temporary context to initialize the handler with
+	initContext := context.TODO()
+
+	sdkConfig := awsconfig.GetSdkConfig(initContext)
+
+	// Create secrets client SDK to fetch TFE credentials
+	secretsManager, err := secretsmanager.NewWithConfig(initContext, sdkConfig)
+	if err != nil {
+		log.Fatalf("failed to initialize secrets manager client: %s", err)
+	}
+
+	batchSize, err := strconv.Atoi(os.Getenv("DRIFT_DETECTION_BATCH_SIZE"))
+	if err != nil {
+		log.Fatalf("failed to parse DRIFT_DETECTION_BATCH_SIZE: %s", err)
+	}
+
+	handler := DetectDriftHandler{
+		secretsManager: secretsManager,
+		engineState:    enginestate.NewFromConfig(sdkConfig),
+		eventBridge:    eventbridge.EB{Client: eb.NewFromConfig(sdkConfig)},
+		cloudWatch:     cloudwatch.CW{Client: cw.NewFromConfig(sdkConfig)},
+		organization:   os.Getenv("TERRAFORM_ORGANIZATION"),
+		eventBusName:   os.Getenv("DRIFT_EVENT_BUS_NAME"),
+		batchSize:      batchSize,
+	}
+
+	lambda.Start(handler.HandleRequest)
+}
@@ -0,0 +1,152 @@
+/*
+ * Copyright (c) HashiCorp, Inc.
+ * SPDX-License-Identifier: MPL-2.0
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	cwtypes "github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	ebtypes "github.com/aws/aws-sdk-go-v2/service/eventbridge/types"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/enginestate"
+	"time"
+)
+
+const (
+	EventSource            = "service-catalog-engine-for-tfc"
+	DriftEventType         = "Provisioned Product Drift Detected"
+	MetricNamespace        = "ServiceCatalogTerraformCloud"
+	DriftedMetric          = "ProvisionedProductDrifted"
+	DriftedResourcesMetric = "DriftedResources"
+	reportedCheckTtl       = 30 * 24 * time.Hour
+	maxReportedResources   = 100
+)
+
+// DriftDetectedEvent is the detail of the EventBridge event sent for each drifted provisioned product
+type DriftDetectedEvent struct {
+	AwsAccountId          string            `json:"awsAccountId"`
+	ProvisionedProductId  string            `json:"provisionedProductId"`
+	TerraformOrganization string            `json:"terraformOrganization"`
+	WorkspaceName         string            `json:"workspaceName"`
+	DetectedBy            DriftSource       `json:"detectedBy"`
+	CheckId               string            `json:"checkId"`
+	Url                   string            `json:"url"`
+	DriftedResourceCount  int               `json:"driftedResourceCount"`
+	DriftedResources      []DriftedResource `json:"driftedResources"`
+}
+
+// reportKey is the key of the engine state item holding the last drift check reported for the workspace
+func reportKey(workspaceId string) string {
+	return fmt.Sprintf("drift-report#%s", workspaceId)
+}
+
+// Report sends the drift check to EventBridge and CloudWatch, unless it was reported by a previous invocation already.
+// Returns whether the check was reported.
+func (h *DetectDriftHandler) Report(ctx context.Context, check DriftCheck, url string) (bool, error) {
+	reported, err := h.engineState.Get(ctx, reportKey(check.Workspace.ID))
+	if err != nil {
+		return false, err
+	}
+	if reported != nil && reported.Value == check.CheckId {
+		return false, nil
+	}
+
+	if check.Drifted {
+		if err = h.sendDriftEvent(ctx, check, url); err != nil {
+			return false, err
+		}
+	}
+
+	if err = h.putDriftMetrics(ctx, check); err != nil {
+		return false, err
+	}
+
+	return true, h.engineState.Put(ctx, enginestate.Item{
+		Key:       reportKey(check.Workspace.ID),
+		Value:     check.CheckId,
+		ExpiresAt: time.Now().Add(reportedCheckTtl),
+	})
+}
+
+func (h *DetectDriftHandler) sendDriftEvent(ctx context.Context, check DriftCheck, url string) error {
+	event := DriftDetectedEvent{
+		AwsAccountId:          check.AwsAccountId,
+		ProvisionedProductId:  check.ProvisionedProductId,
+		TerraformOrganization: check.Organization,
+		WorkspaceName:         check.Workspace.Name,
+		DetectedBy:            check.Source,
+		CheckId:               check.CheckId,
+		Url:                   url,
+		DriftedResourceCount:  len(check.DriftedResources),
+		DriftedResources:      check.DriftedResources,
+	}
+
+	// Keep the event well below the size limit of EventBridge, the full list is available in TFC
+	if len(event.DriftedResources) > maxReportedResources {
+		event.DriftedResources = event.DriftedResources[:maxReportedResources]
+	}
+
+	detail, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	entry := ebtypes.PutEventsRequestEntry{
+		Source:     aws.String(EventSource),
+		DetailType: aws.String(DriftEventType),
+		Detail:     aws.String(string(detail)),
+	}
+	if h.eventBusName != "" {
+		entry.EventBusName = aws.String(h.eventBusName)
+	}
+
+	output, err := h.eventBridge.PutEvents(ctx, &eventbridge.PutEventsInput{
+		Entries: []ebtypes.PutEventsRequestEntry{entry},
+	})
+	if err != nil {
+		return err
+	}
+
+	if output.FailedEntryCount > 0 && len(output.Entries) > 0 {
+		return fmt.Errorf("failed to send drift event for workspace %s: %s", check.Workspace.Name, aws.ToString(output.Entries[0].ErrorMessage))
+	}
+
+	return nil
+}
+
+func (h *DetectDriftHandler) putDriftMetrics(ctx context.Context, check DriftCheck) error {
+	dimensions := []cwtypes.Dimension{
+		{Name: aws.String("AwsAccountId"), Value: aws.String(check.AwsAccountId)},
+		{Name: aws.String("ProvisionedProductId"), Value: aws.String(check.ProvisionedProductId)},
+	}
+
+	drifted := 0.0
+	if check.Drifted {
+		drifted = 1
+	}
+
+	_, err := h.cloudWatch.PutMetricData(ctx, &cloudwatch.PutMetricDataInput{
+		Namespace: aws.String(MetricNamespace),
+		MetricData: []cwtypes.MetricDatum{
+			{
+				MetricName: aws.String(DriftedMetric),
+				Dimensions: dimensions,
+				Value:      aws.Float64(drifted),
+				Unit:       cwtypes.StandardUnitCount,
+			},
+			{
+				MetricName: aws.String(DriftedResourcesMetric),
+				Dimensions: dimensions,
+				Value:      aws.Float64(float64(len(check.DriftedResources))),
+				Unit:       cwtypes.StandardUnitCount,
+			},
+		},
+	})
+	return err
+}
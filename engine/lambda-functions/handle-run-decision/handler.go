@@ -0,0 +1,252 @@
+/*
+ * Copyright (c) HashiCorp, Inc.
+ * SPDX-License-Identifier: MPL-2.0
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sfn"
+	awssns "github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/enginestate"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/secretsmanager"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/sns"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/stepfunctions"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/tfc"
+	"github.com/hashicorp/go-tfe"
+	"log"
+	"time"
+)
+
+// RunRejectedError is the error the waiting state machine execution fails with when a run is rejected
+const RunRejectedError = "RunRejected"
+
+// expiryMargin keeps the task token around a bit longer than the wait, so a late decision is refused with a clear error
+const expiryMargin = 5 * time.Minute
+
+type RunDecisionHandler struct {
+	secretsManager          secretsmanager.SecretsManager
+	stepFunctions           stepfunctions.StepFunctions
+	engineState             enginestate.EngineState
+	sns                     sns.SNS
+	runApprovalTopicArn     string
+	runDecisionFunctionName string
+}
+
+func (h *RunDecisionHandler) HandleRequest(ctx context.Context, request RunDecisionRequest) (*RunDecisionResponse, error) {
+	if request.TerraformRunId == "" {
+		return nil, errors.New("terraformRunId is required")
+	}
+	if request.Decision != Request && request.Decision != Approve && request.Decision != Reject && request.Decision != Expire {
+		log.Printf("Unknown decision: %s\n", request.Decision)
+		return nil, errors.New("unknown decision, must be one of: approve, reject, expire")
+	}
+	if request.TaskToken == "" && request.Decision != Expire {
+		return nil, errors.New("taskToken is required to approve or reject a run")
+	}
+
+	if request.Decision == Request {
+		err := h.RequestApproval(ctx, request)
+		if err != nil {
+			return nil, err
+		}
+		return &RunDecisionResponse{
+			TerraformRunId: request.TerraformRunId,
+			Decision:       request.Decision,
+		}, nil
+	}
+
+	// Only the execution that requested the approval of the run may be resumed with the decision
+	if request.Decision != Expire {
+		err := h.verifyTaskToken(ctx, request)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Get TFE Client
+	organizationSecretsManager, err := secretsmanager.ForOrganization(h.secretsManager, request.TerraformOrganization)
+	if err != nil {
+		log.Printf("failed to find the TFE credentials: %s", err)
+		return nil, err
+	}
+	tfeClient, err := tfc.GetTFEClient(ctx, organizationSecretsManager)
+	if err != nil {
+		log.Printf("failed to initialize TFE client: %s", err)
+		return nil, err
+	}
+
+	switch request.Decision {
+	case Approve:
+		err = h.ApproveRun(ctx, tfeClient, request)
+	case Reject:
+		err = h.RejectRun(ctx, tfeClient, request)
+	case Expire:
+		err = h.ExpireRun(ctx, tfeClient, request)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// The execution is no longer waiting for a decision, so the task token is not needed anymore
+	err = h.engineState.Delete(ctx, approvalKey(request.TerraformRunId))
+	if err != nil {
+		log.Default().Printf("failed to delete the task token of run %s, it expires on its own: %v", request.TerraformRunId, err)
+	}
+
+	return &RunDecisionResponse{
+		TerraformRunId: request.TerraformRunId,
+		Decision:       request.Decision,
+	}, nil
+}
+
+// RequestApproval stores the task token of the state machine execution waiting for a decision on the run, then publishes
+// the approval request to the run approval topic
+func (h *RunDecisionHandler) RequestApproval(ctx context.Context, request RunDecisionRequest) error {
+	if request.ApprovalRequest == nil {
+		return errors.New("approvalRequest is required to request the approval of a run")
+	}
+
+	log.Default().Printf("requesting approval of run %s", request.TerraformRunId)
+	err := h.engineState.Put(ctx, enginestate.Item{
+		Key:       approvalKey(request.TerraformRunId),
+		Value:     request.TaskToken,
+		ExpiresAt: time.Now().Add(time.Duration(request.TimeoutSeconds)*time.Second + expiryMargin),
+	})
+	if err != nil {
+		log.Default().Printf("failed to store the task token of run %s: %v", request.TerraformRunId, err)
+		return err
+	}
+
+	approvalRequest := *request.ApprovalRequest
+	approvalRequest.TaskToken = request.TaskToken
+	approvalRequest.TerraformRunId = request.TerraformRunId
+	approvalRequest.TerraformOrganization = request.TerraformOrganization
+	approvalRequest.RunDecisionFunctionName = h.runDecisionFunctionName
+	message, err := json.Marshal(approvalRequest)
+	if err != nil {
+		return err
+	}
+
+	_, err = h.sns.Publish(ctx, &awssns.PublishInput{
+		TopicArn: aws.String(h.runApprovalTopicArn),
+		Subject:  aws.String("Terraform run awaiting approval"),
+		Message:  aws.String(string(message)),
+	})
+	if err != nil {
+		log.Default().Printf("failed to publish approval request: %v", err)
+	}
+	return err
+}
+
+// verifyTaskToken refuses decisions with a task token other than the one of the execution waiting for a decision on the
+// run, so a token cannot be used to resume the execution of another run
+func (h *RunDecisionHandler) verifyTaskToken(ctx context.Context, request RunDecisionRequest) error {
+	item, err := h.engineState.Get(ctx, approvalKey(request.TerraformRunId))
+	if err != nil {
+		return err
+	}
+	if item == nil || item.Value != request.TaskToken {
+		return fmt.Errorf("taskToken does not belong to run %s, or the run is no longer awaiting a decision", request.TerraformRunId)
+	}
+	return nil
+}
+
+// approvalKey is the key of the engine state item holding the task token of the execution waiting for a decision on the run
+func approvalKey(runId string) string {
+	return fmt.Sprintf("run-approval#%s", runId)
+}
+
+// ApproveRun applies the run in TFC, then resumes the state machine execution so it continues polling the run
+func (h *RunDecisionHandler) ApproveRun(ctx context.Context, client *tfe.Client, request RunDecisionRequest) error {
+	log.Default().Printf("approving run %s", request.TerraformRunId)
+	err := client.Runs.Apply(ctx, request.TerraformRunId, tfe.RunApplyOptions{
+		Comment: commentOrDefault(request.Comment, "Approved via AWS Service Catalog"),
+	})
+	if err != nil {
+		log.Default().Printf("failed to apply run: %v", err)
+		return tfc.Error(err)
+	}
+
+	output, err := json.Marshal(RunDecisionResponse{
+		TerraformRunId: request.TerraformRunId,
+		Decision:       Approve,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = h.stepFunctions.SendTaskSuccess(ctx, &sfn.SendTaskSuccessInput{
+		TaskToken: aws.String(request.TaskToken),
+		Output:    aws.String(string(output)),
+	})
+	if err != nil {
+		log.Default().Printf("failed to resume state machine execution: %v", err)
+	}
+	return err
+}
+
+// RejectRun discards the run in TFC, then fails the waiting state machine execution so the rejection is reported to
+// Service Catalog
+func (h *RunDecisionHandler) RejectRun(ctx context.Context, client *tfe.Client, request RunDecisionRequest) error {
+	log.Default().Printf("rejecting run %s", request.TerraformRunId)
+	err := client.Runs.Discard(ctx, request.TerraformRunId, tfe.RunDiscardOptions{
+		Comment: commentOrDefault(request.Comment, "Rejected via AWS Service Catalog"),
+	})
+	if err != nil {
+		log.Default().Printf("failed to discard run: %v", err)
+		return tfc.Error(err)
+	}
+
+	cause := "Run was rejected"
+	if request.Comment != "" {
+		cause = fmt.Sprintf("Run was rejected: %s", request.Comment)
+	}
+
+	_, err = h.stepFunctions.SendTaskFailure(ctx, &sfn.SendTaskFailureInput{
+		TaskToken: aws.String(request.TaskToken),
+		Error:     aws.String(RunRejectedError),
+		Cause:     aws.String(cause),
+	})
+	if err != nil {
+		log.Default().Printf("failed to resume state machine execution: %v", err)
+	}
+	return err
+}
+
+// ExpireRun discards a run that was not approved in time. The state machine execution already stopped waiting when
+// the approval timed out, so there is no execution to resume. Runs that were decided on in TFC in the meantime are left
+// as they are.
+func (h *RunDecisionHandler) ExpireRun(ctx context.Context, client *tfe.Client, request RunDecisionRequest) error {
+	run, err := client.Runs.Read(ctx, request.TerraformRunId)
+	if err != nil {
+		log.Default().Printf("failed to read run: %v", err)
+		return tfc.Error(err)
+	}
+	if !tfc.IsRunAwaitingDecision(run) {
+		log.Default().Printf("run %s is no longer awaiting a decision, with status %s, not expiring it", run.ID, run.Status)
+		return nil
+	}
+
+	log.Default().Printf("expiring run %s", request.TerraformRunId)
+	err = client.Runs.Discard(ctx, request.TerraformRunId, tfe.RunDiscardOptions{
+		Comment: commentOrDefault(request.Comment, "Approval expired in AWS Service Catalog"),
+	})
+	if err != nil {
+		log.Default().Printf("failed to discard run: %v", err)
+		return tfc.Error(err)
+	}
+	return nil
+}
+
+func commentOrDefault(comment string, defaultComment string) *string {
+	if comment == "" {
+		return tfe.String(defaultComment)
+	}
+	return tfe.String(comment)
+}
@@ -0,0 +1,338 @@
+/*
+ * Copyright (c) HashiCorp, Inc.
+ * SPDX-License-Identifier: MPL-2.0
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/enginestate"
+	testenginestate "github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/testutil/enginestate"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/testutil/secretsmanager"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/testutil/sns"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/testutil/stepfunction"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/testutil/testtfc"
+	"github.com/hashicorp/go-tfe"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// newEngineStateAwaitingDecision creates the engine state of an execution that requested the approval of the run
+func newEngineStateAwaitingDecision(runId string, taskToken string) *testenginestate.MockEngineState {
+	state := testenginestate.NewMockEngineState()
+	state.Items[approvalKey(runId)] = enginestate.Item{
+		Key:       approvalKey(runId),
+		Value:     taskToken,
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+	return state
+}
+
+func TestRunDecisionHandler_Request(t *testing.T) {
+	mockEngineState := testenginestate.NewMockEngineState()
+	mockSNS := &sns.MockSNS{}
+
+	// Create a test instance of the Lambda function
+	testHandler := &RunDecisionHandler{
+		secretsManager:          &secretsmanager.MockSecretsManager{},
+		stepFunctions:           &stepfunction.MockStepFunctionsWithSuccessfulResponse{},
+		engineState:             mockEngineState,
+		sns:                     mockSNS,
+		runApprovalTopicArn:     "arn:aws:sns:us-east-1:123456789042:ServiceCatalogTerraformCloudRunApprovalRequests",
+		runDecisionFunctionName: "ServiceCatalogEngineForTerraformCloudHandleRunDecision",
+	}
+
+	// Send the test request
+	_, err := testHandler.HandleRequest(context.Background(), RunDecisionRequest{
+		TaskToken:      "token-of-appreciation",
+		TerraformRunId: "run-awaiting-approval",
+		Decision:       Request,
+		TimeoutSeconds: 3600,
+		ApprovalRequest: &ApprovalRequest{
+			RunUrl:                  "https://app.terraform.io/app/some-org/workspaces/123456789042-amazingly-great-product-instance/runs/run-awaiting-approval",
+			ResourceCounts:          json.RawMessage(`{"additions":1,"changes":0,"destructions":0}`),
+			ServiceCatalogOperation: "PROVISIONING",
+			AwsAccountId:            "123456789042",
+			ProvisionedProductId:    "amazingly-great-product-instance",
+			RecordId:                "rec-123",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Verify the task token was stored for the run
+	item, err := mockEngineState.Get(context.Background(), approvalKey("run-awaiting-approval"))
+	assert.NoError(t, err)
+	assert.Equal(t, "token-of-appreciation", item.Value)
+
+	// Verify the approval request was published, with everything needed to decide on the run
+	assert.Equal(t, 1, len(mockSNS.Messages))
+	var approvalRequest ApprovalRequest
+	err = json.Unmarshal([]byte(*mockSNS.Messages[0].Message), &approvalRequest)
+	assert.NoError(t, err)
+	assert.Equal(t, testHandler.runApprovalTopicArn, *mockSNS.Messages[0].TopicArn)
+	assert.Equal(t, "token-of-appreciation", approvalRequest.TaskToken)
+	assert.Equal(t, "run-awaiting-approval", approvalRequest.TerraformRunId)
+	assert.Equal(t, "ServiceCatalogEngineForTerraformCloudHandleRunDecision", approvalRequest.RunDecisionFunctionName)
+	assert.Equal(t, "rec-123", approvalRequest.RecordId)
+}
+
+func TestRunDecisionHandler_Approve(t *testing.T) {
+	// Create mock TFC instance
+	tfcServer := testtfc.NewMockTFC()
+	defer tfcServer.Stop()
+
+	// Add a run awaiting confirmation
+	run := tfcServer.AddRun("run-awaiting-approval", testtfc.RunFactoryParameters{
+		RunStatus: tfe.RunPlanned,
+		Actions:   &tfe.RunActions{IsConfirmable: true, IsDiscardable: true},
+	})
+
+	mockSecretsManager := &secretsmanager.MockSecretsManager{
+		Hostname: tfcServer.Address,
+		TeamId:   "team-4123nlol",
+		Token:    "supers3cret",
+	}
+	mockStepFunctions := &stepfunction.MockStepFunctionsWithSuccessfulResponse{}
+
+	// Create a test instance of the Lambda function
+	mockEngineState := newEngineStateAwaitingDecision("run-awaiting-approval", "token-of-appreciation")
+	testHandler := &RunDecisionHandler{
+		secretsManager: mockSecretsManager,
+		stepFunctions:  mockStepFunctions,
+		engineState:    mockEngineState,
+	}
+
+	// Send the test request
+	response, err := testHandler.HandleRequest(context.Background(), RunDecisionRequest{
+		TaskToken:      "token-of-appreciation",
+		TerraformRunId: "run-awaiting-approval",
+		Decision:       Approve,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Verify the run was applied and the execution was resumed
+	assert.Equal(t, Approve, response.Decision)
+	assert.Equal(t, tfe.RunConfirmed, run.Status, "run should have been applied")
+	assert.Equal(t, "token-of-appreciation", *mockStepFunctions.SendTaskSuccessInput.TaskToken, "execution should have been resumed")
+	assert.Nil(t, mockStepFunctions.SendTaskFailureInput)
+	assert.Empty(t, mockEngineState.Items, "task token should have been deleted")
+}
+
+func TestRunDecisionHandler_ApproveWithTaskTokenOfAnotherRun(t *testing.T) {
+	// Create mock TFC instance
+	tfcServer := testtfc.NewMockTFC()
+	defer tfcServer.Stop()
+
+	// Add a run awaiting confirmation
+	run := tfcServer.AddRun("run-awaiting-approval", testtfc.RunFactoryParameters{
+		RunStatus: tfe.RunPlanned,
+		Actions:   &tfe.RunActions{IsConfirmable: true, IsDiscardable: true},
+	})
+
+	mockSecretsManager := &secretsmanager.MockSecretsManager{
+		Hostname: tfcServer.Address,
+		TeamId:   "team-4123nlol",
+		Token:    "supers3cret",
+	}
+	mockStepFunctions := &stepfunction.MockStepFunctionsWithSuccessfulResponse{}
+
+	// Create a test instance of the Lambda function, where the run waits for a decision with another task token
+	testHandler := &RunDecisionHandler{
+		secretsManager: mockSecretsManager,
+		stepFunctions:  mockStepFunctions,
+		engineState:    newEngineStateAwaitingDecision("run-awaiting-approval", "token-of-appreciation"),
+	}
+
+	// Send the test request, with the task token of the execution waiting for another run
+	_, err := testHandler.HandleRequest(context.Background(), RunDecisionRequest{
+		TaskToken:      "token-of-another-run",
+		TerraformRunId: "run-awaiting-approval",
+		Decision:       Approve,
+	})
+
+	// Verify the decision was refused, without applying the run or resuming any execution
+	assert.EqualError(t, err, "taskToken does not belong to run run-awaiting-approval, or the run is no longer awaiting a decision")
+	assert.Equal(t, tfe.RunPlanned, run.Status, "run should not have been applied")
+	assert.Nil(t, mockStepFunctions.SendTaskSuccessInput)
+}
+
+func TestRunDecisionHandler_Reject(t *testing.T) {
+	// Create mock TFC instance
+	tfcServer := testtfc.NewMockTFC()
+	defer tfcServer.Stop()
+
+	// Add a run awaiting confirmation
+	run := tfcServer.AddRun("run-awaiting-approval", testtfc.RunFactoryParameters{
+		RunStatus: tfe.RunPlanned,
+		Actions:   &tfe.RunActions{IsConfirmable: true, IsDiscardable: true},
+	})
+
+	mockSecretsManager := &secretsmanager.MockSecretsManager{
+		Hostname: tfcServer.Address,
+		TeamId:   "team-4123nlol",
+		Token:    "supers3cret",
+	}
+	mockStepFunctions := &stepfunction.MockStepFunctionsWithSuccessfulResponse{}
+
+	// Create a test instance of the Lambda function
+	testHandler := &RunDecisionHandler{
+		secretsManager: mockSecretsManager,
+		stepFunctions:  mockStepFunctions,
+		engineState:    newEngineStateAwaitingDecision("run-awaiting-approval", "token-of-appreciation"),
+	}
+
+	// Send the test request
+	_, err := testHandler.HandleRequest(context.Background(), RunDecisionRequest{
+		TaskToken:      "token-of-appreciation",
+		TerraformRunId: "run-awaiting-approval",
+		Decision:       Reject,
+		Comment:        "too many instances",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Verify the run was discarded and the execution was failed
+	assert.Equal(t, tfe.RunDiscarded, run.Status, "run should have been discarded")
+	assert.Equal(t, "token-of-appreciation", *mockStepFunctions.SendTaskFailureInput.TaskToken, "execution should have been resumed")
+	assert.Equal(t, RunRejectedError, *mockStepFunctions.SendTaskFailureInput.Error)
+	assert.Equal(t, "Run was rejected: too many instances", *mockStepFunctions.SendTaskFailureInput.Cause)
+	assert.Nil(t, mockStepFunctions.SendTaskSuccessInput)
+}
+
+func TestRunDecisionHandler_Expire(t *testing.T) {
+	// Create mock TFC instance
+	tfcServer := testtfc.NewMockTFC()
+	defer tfcServer.Stop()
+
+	// Add a run awaiting confirmation
+	run := tfcServer.AddRun("run-awaiting-approval", testtfc.RunFactoryParameters{
+		RunStatus: tfe.RunPlanned,
+		Actions:   &tfe.RunActions{IsConfirmable: true, IsDiscardable: true},
+	})
+
+	mockSecretsManager := &secretsmanager.MockSecretsManager{
+		Hostname: tfcServer.Address,
+		TeamId:   "team-4123nlol",
+		Token:    "supers3cret",
+	}
+	mockStepFunctions := &stepfunction.MockStepFunctionsWithSuccessfulResponse{}
+
+	// Create a test instance of the Lambda function
+	testHandler := &RunDecisionHandler{
+		secretsManager: mockSecretsManager,
+		stepFunctions:  mockStepFunctions,
+		engineState:    newEngineStateAwaitingDecision("run-awaiting-approval", "token-of-appreciation"),
+	}
+
+	// Send the test request, expired runs have no execution waiting for them
+	_, err := testHandler.HandleRequest(context.Background(), RunDecisionRequest{
+		TerraformRunId: "run-awaiting-approval",
+		Decision:       Expire,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Verify the run was discarded without resuming any execution
+	assert.Equal(t, tfe.RunDiscarded, run.Status, "run should have been discarded")
+	assert.Nil(t, mockStepFunctions.SendTaskSuccessInput)
+	assert.Nil(t, mockStepFunctions.SendTaskFailureInput)
+}
+
+func TestRunDecisionHandler_ExpireRunThatIsNoLongerAwaitingDecision(t *testing.T) {
+	// Create mock TFC instance
+	tfcServer := testtfc.NewMockTFC()
+	defer tfcServer.Stop()
+
+	// Add a run that was applied in TFC after the approval timed out
+	run := tfcServer.AddRun("run-applied-in-tfc", testtfc.RunFactoryParameters{
+		RunStatus: tfe.RunApplied,
+		Actions:   &tfe.RunActions{},
+	})
+
+	mockSecretsManager := &secretsmanager.MockSecretsManager{
+		Hostname: tfcServer.Address,
+		TeamId:   "team-4123nlol",
+		Token:    "supers3cret",
+	}
+
+	// Create a test instance of the Lambda function
+	testHandler := &RunDecisionHandler{
+		secretsManager: mockSecretsManager,
+		stepFunctions:  &stepfunction.MockStepFunctionsWithSuccessfulResponse{},
+		engineState:    newEngineStateAwaitingDecision("run-applied-in-tfc", "token-of-appreciation"),
+	}
+
+	// Send the test request
+	_, err := testHandler.HandleRequest(context.Background(), RunDecisionRequest{
+		TerraformRunId: "run-applied-in-tfc",
+		Decision:       Expire,
+	})
+
+	// Verify the run was left as it is
+	assert.NoError(t, err)
+	assert.Equal(t, tfe.RunApplied, run.Status, "run should not have been discarded")
+}
+
+func TestRunDecisionHandler_ApproveRunThatIsNotConfirmable(t *testing.T) {
+	// Create mock TFC instance
+	tfcServer := testtfc.NewMockTFC()
+	defer tfcServer.Stop()
+
+	// Add a run that was already discarded
+	tfcServer.AddRun("run-already-discarded", testtfc.RunFactoryParameters{
+		RunStatus: tfe.RunDiscarded,
+		Actions:   &tfe.RunActions{},
+	})
+
+	mockSecretsManager := &secretsmanager.MockSecretsManager{
+		Hostname: tfcServer.Address,
+		TeamId:   "team-4123nlol",
+		Token:    "supers3cret",
+	}
+	mockStepFunctions := &stepfunction.MockStepFunctionsWithSuccessfulResponse{}
+
+	// Create a test instance of the Lambda function
+	testHandler := &RunDecisionHandler{
+		secretsManager: mockSecretsManager,
+		stepFunctions:  mockStepFunctions,
+		engineState:    newEngineStateAwaitingDecision("run-already-discarded", "token-of-appreciation"),
+	}
+
+	// Send the test request
+	_, err := testHandler.HandleRequest(context.Background(), RunDecisionRequest{
+		TaskToken:      "token-of-appreciation",
+		TerraformRunId: "run-already-discarded",
+		Decision:       Approve,
+	})
+
+	// Verify the decision failed and the execution was left waiting
+	assert.Error(t, err, "approving a run that cannot be applied should fail")
+	assert.Nil(t, mockStepFunctions.SendTaskSuccessInput)
+}
+
+func TestRunDecisionHandler_UnknownDecision(t *testing.T) {
+	// Create a test instance of the Lambda function
+	testHandler := &RunDecisionHandler{
+		secretsManager: &secretsmanager.MockSecretsManager{},
+		stepFunctions:  &stepfunction.MockStepFunctionsWithSuccessfulResponse{},
+	}
+
+	// Send the test request
+	_, err := testHandler.HandleRequest(context.Background(), RunDecisionRequest{
+		TaskToken:      "token-of-appreciation",
+		TerraformRunId: "run-awaiting-approval",
+		Decision:       "maybe",
+	})
+
+	// Verify the decision was refused
+	assert.Error(t, err, "unknown decisions should be refused")
+}
@@ -0,0 +1,90 @@
+/*
+ * Copyright (c) HashiCorp, Inc.
+ * SPDX-License-Identifier: MPL-2.0
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/aws/aws-lambda-go/lambda"
+	awssns "github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/awsconfig"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/enginestate"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/secretsmanager"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/sns"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/stepfunctions"
+	"log"
+	"os"
+)
+
+type RunDecisionRequest struct {
+	TaskToken      string   `json:"taskToken"`
+	TerraformRunId string   `json:"terraformRunId"`
+	Decision       Decision `json:"decision"`
+	Comment        string   `json:"comment"`
+
+	// TerraformOrganization chooses the TFE credentials used to apply or discard the run, the default credentials are
+	// used if unset
+	TerraformOrganization string `json:"terraformOrganization,omitempty"`
+
+	// TimeoutSeconds and ApprovalRequest are only set by the state machines, to request the approval of a run
+	TimeoutSeconds  int              `json:"timeoutSeconds,omitempty"`
+	ApprovalRequest *ApprovalRequest `json:"approvalRequest,omitempty"`
+}
+
+// ApprovalRequest holds the details of a run awaiting approval, which are published to the run approval topic
+type ApprovalRequest struct {
+	TaskToken               string          `json:"taskToken"`
+	TerraformRunId          string          `json:"terraformRunId"`
+	TerraformOrganization   string          `json:"terraformOrganization,omitempty"`
+	RunUrl                  string          `json:"runUrl"`
+	ResourceCounts          json.RawMessage `json:"resourceCounts"`
+	ServiceCatalogOperation string          `json:"serviceCatalogOperation"`
+	AwsAccountId            string          `json:"awsAccountId"`
+	ProvisionedProductId    string          `json:"provisionedProductId"`
+	RecordId                string          `json:"recordId"`
+	RunDecisionFunctionName string          `json:"runDecisionFunctionName"`
+}
+
+type Decision string
+
+// Enum values for Decision
+const (
+	Request Decision = "request"
+	Approve Decision = "approve"
+	Reject  Decision = "reject"
+	Expire  Decision = "expire"
+)
+
+type RunDecisionResponse struct {
+	TerraformRunId string   `json:"terraformRunId"`
+	Decision       Decision `json:"decision"`
+}
+
+func main() {
+	// Create temporary context to initialize the handler with
+	initContext := context.TODO()
+
+	sdkConfig := awsconfig.GetSdkConfig(initContext)
+
+	// Create secrets client SDK to fetch TFE credentials
+	secretsManager, err := secretsmanager.NewWithConfig(initContext, sdkConfig)
+	if err != nil {
+		log.Fatalf("failed to initialize secrets manager client: %s", err)
+	}
+
+	// Create the handler
+	handler := &RunDecisionHandler{
+		secretsManager:          secretsManager,
+		stepFunctions:           stepfunctions.NewFromConfig(sdkConfig),
+		engineState:             enginestate.NewFromConfig(sdkConfig),
+		sns:                     sns.SNSClient{Client: awssns.NewFromConfig(sdkConfig)},
+		runApprovalTopicArn:     os.Getenv("RUN_APPROVAL_TOPIC_ARN"),
+		runDecisionFunctionName: os.Getenv("AWS_LAMBDA_FUNCTION_NAME"),
+	}
+
+	// Start the lambda using the handler
+	lambda.Start(handler.HandleRequest)
+}
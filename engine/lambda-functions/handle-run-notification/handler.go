@@ -0,0 +1,116 @@
+/*
+ * Copyright (c) HashiCorp, Inc.
+ * SPDX-License-Identifier: MPL-2.0
+ */
+
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/enginestate"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/runwaiter"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/secretsmanager"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/stepfunctions"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// SignatureHeader is the header TFC sends the HMAC-SHA512 signature of the notification payload in
+const SignatureHeader = "X-TFE-Notification-Signature"
+
+type RunNotificationHandler struct {
+	notificationToken secretsmanager.NotificationTokenSecret
+	stepFunctions     stepfunctions.StepFunctions
+	engineState       enginestate.EngineState
+}
+
+func (h *RunNotificationHandler) HandleRequest(ctx context.Context, request events.LambdaFunctionURLRequest) (events.LambdaFunctionURLResponse, error) {
+	body := []byte(request.Body)
+	if request.IsBase64Encoded {
+		decoded, err := base64.StdEncoding.DecodeString(request.Body)
+		if err != nil {
+			return respond(http.StatusBadRequest), nil
+		}
+		body = decoded
+	}
+
+	// Only accept notifications signed by TFC
+	token, err := h.notificationToken.GetNotificationToken(ctx)
+	if err != nil {
+		log.Printf("failed to fetch notification token: %s", err)
+		return respond(http.StatusInternalServerError), nil
+	}
+	if !VerifySignature(body, getHeader(request.Headers, SignatureHeader), token) {
+		log.Default().Printf("rejecting notification with invalid signature")
+		return respond(http.StatusUnauthorized), nil
+	}
+
+	notification := &RunNotification{}
+	if err = json.Unmarshal(body, notification); err != nil {
+		log.Default().Printf("rejecting malformed notification: %s", err)
+		return respond(http.StatusBadRequest), nil
+	}
+
+	// Verification requests, sent when the notification configuration is saved, are not about a run
+	if notification.RunId == "" {
+		log.Default().Printf("received verification request for notification configuration %s", notification.NotificationConfigurationId)
+		return respond(http.StatusOK), nil
+	}
+
+	completion := runwaiter.RunCompletion{TerraformRunId: notification.RunId}
+	if len(notification.Notifications) > 0 {
+		completion.RunStatus = notification.Notifications[len(notification.Notifications)-1].RunStatus
+	}
+
+	log.Default().Printf("received notification for run %s with status %s", completion.TerraformRunId, completion.RunStatus)
+	resumed, err := runwaiter.Complete(ctx, h.engineState, h.stepFunctions, completion)
+	if err != nil {
+		log.Printf("failed to resume execution waiting for run %s: %s", completion.TerraformRunId, err)
+		return respond(http.StatusInternalServerError), nil
+	}
+	if !resumed {
+		log.Default().Printf("no execution is waiting for run %s", completion.TerraformRunId)
+	}
+
+	return respond(http.StatusOK), nil
+}
+
+// VerifySignature checks the signature is the hex encoded HMAC-SHA512 of the body, keyed with the notification token
+func VerifySignature(body []byte, signature string, token string) bool {
+	if signature == "" || token == "" {
+		return false
+	}
+
+	expected, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha512.New, []byte(token))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+// getHeader looks up a header case-insensitively, as function URLs pass the headers in lowercase
+func getHeader(headers map[string]string, name string) string {
+	for key, value := range headers {
+		if strings.EqualFold(key, name) {
+			return value
+		}
+	}
+	return ""
+}
+
+func respond(statusCode int) events.LambdaFunctionURLResponse {
+	return events.LambdaFunctionURLResponse{
+		StatusCode: statusCode,
+		Body:       http.StatusText(statusCode),
+	}
+}
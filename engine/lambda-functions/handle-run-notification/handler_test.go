@@ -0,0 +1,160 @@
+/*
+ * Copyright (c) HashiCorp, Inc.
+ * SPDX-License-Identifier: MPL-2.0
+ */
+
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/hex"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/runwaiter"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/testutil/enginestate"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/testutil/secretsmanager"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/testutil/stepfunction"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+const testNotificationToken = "s1gn-me-up"
+
+const testRunNotification = `{
+  "payload_version": 1,
+  "notification_configuration_id": "nc-AeUQ2zfKZzW9TiGZ",
+  "run_url": "https://app.terraform.io/app/team-rocket-blast-off/workspaces/123456789042-amazingly/runs/run-applied",
+  "run_id": "run-applied",
+  "workspace_id": "ws-4329432942",
+  "workspace_name": "123456789042-amazingly",
+  "organization_name": "team-rocket-blast-off",
+  "notifications": [
+    {
+      "message": "Applied",
+      "trigger": "run:completed",
+      "run_status": "applied"
+    }
+  ]
+}`
+
+func TestRunNotificationHandler_ResumesWaitingExecution(t *testing.T) {
+	mockStepFunctions := &stepfunction.MockStepFunctionsWithSuccessfulResponse{}
+	mockEngineState := enginestate.NewMockEngineState()
+
+	// Register an execution waiting for the run
+	err := runwaiter.Register(context.Background(), mockEngineState, "run-applied", "token-of-patience", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Create a test instance of the Lambda function
+	testHandler := &RunNotificationHandler{
+		notificationToken: &secretsmanager.MockNotificationTokenSecret{Token: testNotificationToken},
+		stepFunctions:     mockStepFunctions,
+		engineState:       mockEngineState,
+	}
+
+	// Send the test request
+	response, err := testHandler.HandleRequest(context.Background(), signedRequest(testRunNotification, testNotificationToken))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Verify the waiting execution was resumed and the waiter was removed
+	assert.Equal(t, 200, response.StatusCode)
+	assert.Equal(t, "token-of-patience", *mockStepFunctions.SendTaskSuccessInput.TaskToken)
+	assert.JSONEq(t, `{"terraformRunId": "run-applied", "runStatus": "applied"}`, *mockStepFunctions.SendTaskSuccessInput.Output)
+	assert.Empty(t, mockEngineState.Items)
+}
+
+func TestRunNotificationHandler_NoWaitingExecution(t *testing.T) {
+	mockStepFunctions := &stepfunction.MockStepFunctionsWithSuccessfulResponse{}
+
+	// Create a test instance of the Lambda function
+	testHandler := &RunNotificationHandler{
+		notificationToken: &secretsmanager.MockNotificationTokenSecret{Token: testNotificationToken},
+		stepFunctions:     mockStepFunctions,
+		engineState:       enginestate.NewMockEngineState(),
+	}
+
+	// Send the test request
+	response, err := testHandler.HandleRequest(context.Background(), signedRequest(testRunNotification, testNotificationToken))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Verify the notification was accepted, even though no execution was resumed
+	assert.Equal(t, 200, response.StatusCode)
+	assert.Nil(t, mockStepFunctions.SendTaskSuccessInput)
+}
+
+func TestRunNotificationHandler_InvalidSignature(t *testing.T) {
+	mockStepFunctions := &stepfunction.MockStepFunctionsWithSuccessfulResponse{}
+	mockEngineState := enginestate.NewMockEngineState()
+
+	// Register an execution waiting for the run
+	err := runwaiter.Register(context.Background(), mockEngineState, "run-applied", "token-of-patience", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Create a test instance of the Lambda function
+	testHandler := &RunNotificationHandler{
+		notificationToken: &secretsmanager.MockNotificationTokenSecret{Token: testNotificationToken},
+		stepFunctions:     mockStepFunctions,
+		engineState:       mockEngineState,
+	}
+
+	// Send test requests signed with the wrong token, and without a signature
+	for _, request := range []events.LambdaFunctionURLRequest{
+		signedRequest(testRunNotification, "n0t-the-token"),
+		{Body: testRunNotification, Headers: map[string]string{}},
+	} {
+		response, err := testHandler.HandleRequest(context.Background(), request)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Verify the notification was rejected, and the waiting execution was not resumed
+		assert.Equal(t, 401, response.StatusCode)
+		assert.Nil(t, mockStepFunctions.SendTaskSuccessInput)
+		assert.Equal(t, 1, len(mockEngineState.Items))
+	}
+}
+
+func TestRunNotificationHandler_VerificationRequest(t *testing.T) {
+	mockStepFunctions := &stepfunction.MockStepFunctionsWithSuccessfulResponse{}
+
+	// Create a test instance of the Lambda function
+	testHandler := &RunNotificationHandler{
+		notificationToken: &secretsmanager.MockNotificationTokenSecret{Token: testNotificationToken},
+		stepFunctions:     mockStepFunctions,
+		engineState:       enginestate.NewMockEngineState(),
+	}
+
+	// Send the verification request TFC sends when the notification configuration is saved
+	verification := `{"payload_version": 1, "notification_configuration_id": "nc-AeUQ2zfKZzW9TiGZ", "run_id": null, "notifications": [{"message": "Verification of AWS Service Catalog Engine", "trigger": "verification", "run_status": null}]}`
+	response, err := testHandler.HandleRequest(context.Background(), signedRequest(verification, testNotificationToken))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Verify the verification succeeded
+	assert.Equal(t, 200, response.StatusCode)
+	assert.Nil(t, mockStepFunctions.SendTaskSuccessInput)
+}
+
+func signedRequest(body string, token string) events.LambdaFunctionURLRequest {
+	mac := hmac.New(sha512.New, []byte(token))
+	mac.Write([]byte(body))
+
+	return events.LambdaFunctionURLRequest{
+		Body: body,
+		Headers: map[string]string{
+			"content-type":                 "application/json",
+			"x-tfe-notification-signature": hex.EncodeToString(mac.Sum(nil)),
+		},
+	}
+}
@@ -0,0 +1,51 @@
+/*
+ * Copyright (c) HashiCorp, Inc.
+ * SPDX-License-Identifier: MPL-2.0
+ */
+
+package main
+
+import (
+	"context"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/awsconfig"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/enginestate"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/secretsmanager"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/stepfunctions"
+	"github.com/hashicorp/go-tfe"
+)
+
+// RunNotification is the payload of a notification that TFC sends to generic webhook destinations
+type RunNotification struct {
+	PayloadVersion              int                   `json:"payload_version"`
+	NotificationConfigurationId string                `json:"notification_configuration_id"`
+	RunUrl                      string                `json:"run_url"`
+	RunId                       string                `json:"run_id"`
+	WorkspaceId                 string                `json:"workspace_id"`
+	WorkspaceName               string                `json:"workspace_name"`
+	OrganizationName            string                `json:"organization_name"`
+	Notifications               []NotificationDetails `json:"notifications"`
+}
+
+type NotificationDetails struct {
+	Message   string        `json:"message"`
+	Trigger   string        `json:"trigger"`
+	RunStatus tfe.RunStatus `json:"run_status"`
+}
+
+func main() {
+	// Create temporary context to initialize the handler with
+	initContext := context.TODO()
+
+	sdkConfig := awsconfig.GetSdkConfig(initContext)
+
+	// Create the handler
+	handler := &RunNotificationHandler{
+		notificationToken: secretsmanager.NewNotificationTokenWithConfig(sdkConfig),
+		stepFunctions:     stepfunctions.NewFromConfig(sdkConfig),
+		engineState:       enginestate.NewFromConfig(sdkConfig),
+	}
+
+	// Start the lambda using the handler
+	lambda.Start(handler.HandleRequest)
+}
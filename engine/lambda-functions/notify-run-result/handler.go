@@ -11,45 +11,68 @@ import (
 	"github.com/aws/aws-sdk-go-v2/aws"
 	sc "github.com/aws/aws-sdk-go-v2/service/servicecatalog"
 	"github.com/aws/aws-sdk-go-v2/service/servicecatalog/types"
-	"github.com/google/uuid"
 	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/identifiers"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/model"
 	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/secretsmanager"
 	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/servicecatalog"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/statearchive"
 	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/tfc"
 	"github.com/hashicorp/go-tfe"
 	"log"
 	"fmt"
+	"time"
 )
 
 type NotifyRunResultHandler struct {
 	serviceCatalog servicecatalog.ServiceCatalog
 	secretsManager secretsmanager.SecretsManager
+	outputPrefix   string
+	stateArchive   statearchive.StateArchive
+
+	// workspaceRetention is how long the workspaces of terminated provisioned products are retained before they are
+	// deleted. Workspaces are deleted right away when it is zero.
+	workspaceRetention time.Duration
 }
 
-func (h NotifyRunResultHandler) HandleRequest(ctx context.Context, request NotifyRunResultRequest) (*NotifyRunResultResponse, error) {
+func (h NotifyRunResultHandler) HandleRequest(ctx context.Context, request model.NotifyRunResultRequest) (*NotifyRunResultResponse, error) {
+	// Requests are validated strictly when they are received, so a request that is invalid here must not keep Service
+	// Catalog waiting for a result that never comes
+	if err := request.Validate(); err != nil {
+		log.Default().Printf("invalid request: %s", err)
+		return h.NotifyFailure(ctx, request, err)
+	}
 
-	tfeClient, err := tfc.GetTFEClient(ctx, h.secretsManager)
+	organizationSecretsManager, err := secretsmanager.ForOrganization(h.secretsManager, request.TerraformOrganization)
+	if err != nil {
+		log.Default().Printf("failed to find the TFE credentials: %s", err)
+		return h.NotifyFailure(ctx, request, err)
+	}
+	tfeClient, tfeCredentialsSecret, err := tfc.GetTFEClientAndCredentials(ctx, organizationSecretsManager)
 	if err != nil {
-		log.Fatalf("failed to initialize TFE client: %s", err)
+		log.Default().Printf("failed to initialize TFE client: %s", err)
+		return h.NotifyFailure(ctx, request, err)
 	}
 
+	// Fetch the details of the run, so they can be linked in the result
+	runDetails := GetRunDetails(ctx, tfeClient, tfeCredentialsSecret.Hostname, request)
+
 	switch {
-	case request.ServiceCatalogOperation == Terminating:
-		return h.NotifyTerminateResult(ctx, tfeClient, request)
-	case request.ServiceCatalogOperation == Provisioning:
-		return h.NotifyProvisioningResult(ctx, tfeClient, request)
-	case request.ServiceCatalogOperation == Updating:
-		return h.NotifyUpdatingResult(ctx, tfeClient, request)
+	case request.ServiceCatalogOperation == model.Terminating:
+		return h.NotifyTerminateResult(ctx, tfeClient, request, runDetails)
+	case request.ServiceCatalogOperation == model.Provisioning:
+		return h.NotifyProvisioningResult(ctx, tfeClient, request, runDetails)
+	case request.ServiceCatalogOperation == model.Updating:
+		return h.NotifyUpdatingResult(ctx, tfeClient, request, runDetails)
 	default:
 		log.Printf("Unknown serviceCatalogOperation: %s\n", request.ServiceCatalogOperation)
 		return nil, errors.New("unknown serviceCatalogOperation")
 	}
 }
 
-func (h NotifyRunResultHandler) NotifyTerminateResult(ctx context.Context, tfeClient *tfe.Client, request NotifyRunResultRequest) (*NotifyRunResultResponse, error) {
+func (h NotifyRunResultHandler) NotifyTerminateResult(ctx context.Context, tfeClient *tfe.Client, request model.NotifyRunResultRequest, runDetails *RunDetails) (*NotifyRunResultResponse, error) {
 	// If the termination was successful, delete the workspace
 	if request.ErrorMessage == "" {
-		err := DeleteWorkspace(ctx, tfeClient, request)
+		err := h.DeleteWorkspace(ctx, tfeClient, request)
 		if err != nil {
 			log.Default().Printf("failed to delete workspace: %v", err)
 			request.ErrorMessage = err.Error()
@@ -59,7 +82,7 @@ func (h NotifyRunResultHandler) NotifyTerminateResult(ctx context.Context, tfeCl
 	var status = types.EngineWorkflowStatusSucceeded
 	var failureReason *string = nil
 	if request.ErrorMessage != "" {
-		failureReason = FormatError(request.Error, request.ErrorMessage)
+		failureReason = FormatError(request.Error, request.ErrorMessage, runDetails)
 		status = types.EngineWorkflowStatusFailed
 	}
 
@@ -72,7 +95,7 @@ func (h NotifyRunResultHandler) NotifyTerminateResult(ctx context.Context, tfeCl
 			RecordId:         &request.RecordId,
 			Status:           status,
 			FailureReason:    failureReason,
-			IdempotencyToken: tfe.String(uuid.New().String()),
+			IdempotencyToken: tfe.String(servicecatalog.IdempotencyToken(request.RecordId, string(request.ServiceCatalogOperation), request.TerraformRunId)),
 		},
 	)
 	if err != nil {
@@ -82,21 +105,23 @@ func (h NotifyRunResultHandler) NotifyTerminateResult(ctx context.Context, tfeCl
 	return nil, err
 }
 
-func (h NotifyRunResultHandler) NotifyProvisioningResult(ctx context.Context, tfeClient *tfe.Client, request NotifyRunResultRequest) (*NotifyRunResultResponse, error) {
+func (h NotifyRunResultHandler) NotifyProvisioningResult(ctx context.Context, tfeClient *tfe.Client, request model.NotifyRunResultRequest, runDetails *RunDetails) (*NotifyRunResultResponse, error) {
 	var outputs []types.RecordOutput
 	var err error
 
 	var status = types.EngineWorkflowStatusSucceeded
 	var failureReason *string = nil
 	if request.ErrorMessage != "" {
-		failureReason = FormatError(request.Error, request.ErrorMessage)
+		failureReason = FormatError(request.Error, request.ErrorMessage, runDetails)
 		status = types.EngineWorkflowStatusFailed
 	} else {
-		outputs, err = FetchRunOutputs(ctx, tfeClient, request)
+		outputs, err = FetchRunOutputs(ctx, tfeClient, request, h.outputPrefix)
 		if err != nil {
 			log.Default().Printf("failed to fetch run outputs, Cause: %v", err)
 			status = types.EngineWorkflowStatusFailed
 			failureReason = aws.String(fmt.Sprintf("Failed to fetch run outputs. If re-provisioning/updating the product fails, please file an issue in the repository: https://github.com/hashicorp/aws-service-catalog-engine-for-tfc/issues or contact HashiCorp support. Cause: %v", err))
+		} else {
+			outputs = runDetails.AppendRecordOutputs(outputs)
 		}
 	}
 
@@ -109,12 +134,12 @@ func (h NotifyRunResultHandler) NotifyProvisioningResult(ctx context.Context, tf
 			RecordId:         &request.RecordId,
 			Status:           status,
 			FailureReason:    failureReason,
-			IdempotencyToken: tfe.String(uuid.New().String()),
+			IdempotencyToken: tfe.String(servicecatalog.IdempotencyToken(request.RecordId, string(request.ServiceCatalogOperation), request.TerraformRunId)),
 			Outputs:          outputs,
 			ResourceIdentifier: &types.EngineWorkflowResourceIdentifier{
 				UniqueTag: &types.UniqueTagResourceIdentifier{
-					Key:   tfe.String(request.TracerTag.TracerTagKey),
-					Value: tfe.String(request.TracerTag.TracerTagValue),
+					Key:   tfe.String(request.TracerTag.Key),
+					Value: tfe.String(request.TracerTag.Value),
 				},
 			},
 		},
@@ -126,21 +151,23 @@ func (h NotifyRunResultHandler) NotifyProvisioningResult(ctx context.Context, tf
 	return nil, err
 }
 
-func (h NotifyRunResultHandler) NotifyUpdatingResult(ctx context.Context, tfeClient *tfe.Client, request NotifyRunResultRequest) (*NotifyRunResultResponse, error) {
+func (h NotifyRunResultHandler) NotifyUpdatingResult(ctx context.Context, tfeClient *tfe.Client, request model.NotifyRunResultRequest, runDetails *RunDetails) (*NotifyRunResultResponse, error) {
 	var outputs []types.RecordOutput
 	var err error
 
 	var status = types.EngineWorkflowStatusSucceeded
 	var failureReason *string = nil
 	if request.ErrorMessage != "" {
-		failureReason = FormatError(request.Error, request.ErrorMessage)
+		failureReason = FormatError(request.Error, request.ErrorMessage, runDetails)
 		status = types.EngineWorkflowStatusFailed
 	} else {
-		outputs, err = FetchRunOutputs(ctx, tfeClient, request)
+		outputs, err = FetchRunOutputs(ctx, tfeClient, request, h.outputPrefix)
 		if err != nil {
 			log.Default().Printf("failed to fetch run outputs, Cause: %v", err)
 			status = types.EngineWorkflowStatusFailed
 			failureReason = aws.String(fmt.Sprintf("Failed to fetch run outputs. If updating the product fails, please file an issue in the repository: https://github.com/hashicorp/aws-service-catalog-engine-for-tfc/issues or contact HashiCorp support. Cause: %v", err))
+		} else {
+			outputs = runDetails.AppendRecordOutputs(outputs)
 		}
 	}
 
@@ -148,7 +175,7 @@ func (h NotifyRunResultHandler) NotifyUpdatingResult(ctx context.Context, tfeCli
 	_, err = h.serviceCatalog.NotifyUpdateProvisionedProductEngineWorkflowResult(
 		ctx,
 		&sc.NotifyUpdateProvisionedProductEngineWorkflowResultInput{
-			IdempotencyToken: tfe.String(uuid.New().String()),
+			IdempotencyToken: tfe.String(servicecatalog.IdempotencyToken(request.RecordId, string(request.ServiceCatalogOperation), request.TerraformRunId)),
 			RecordId:         &request.RecordId,
 			Status:           status,
 			WorkflowToken:    &request.WorkflowToken,
@@ -163,31 +190,142 @@ func (h NotifyRunResultHandler) NotifyUpdatingResult(ctx context.Context, tfeCli
 	return nil, err
 }
 
-func FormatError(err string, errorMessage string) *string {
+// NotifyFailure reports the operation as failed without consulting TFC, as the request cannot be processed. The result
+// can only be reported if the request identifies the workflow and the operation, otherwise the cause is returned.
+func (h NotifyRunResultHandler) NotifyFailure(ctx context.Context, request model.NotifyRunResultRequest, cause error) (*NotifyRunResultResponse, error) {
+	if request.WorkflowToken == "" || request.RecordId == "" {
+		return nil, cause
+	}
+
+	errorMessage := cause.Error()
+	if request.ErrorMessage != "" {
+		errorMessage = fmt.Sprintf("%s, after the operation failed: %s", errorMessage, request.ErrorMessage)
+	}
+	failureReason := FormatError(request.Error, errorMessage, nil)
+	idempotencyToken := tfe.String(servicecatalog.IdempotencyToken(request.RecordId, string(request.ServiceCatalogOperation), request.TerraformRunId))
+
+	log.Printf("Notifying %s result %s\n", request.ServiceCatalogOperation, types.EngineWorkflowStatusFailed)
+
+	var err error
+	switch request.ServiceCatalogOperation {
+	case model.Terminating:
+		_, err = h.serviceCatalog.NotifyTerminateProvisionedProductEngineWorkflowResult(ctx, &sc.NotifyTerminateProvisionedProductEngineWorkflowResultInput{
+			WorkflowToken:    &request.WorkflowToken,
+			RecordId:         &request.RecordId,
+			Status:           types.EngineWorkflowStatusFailed,
+			FailureReason:    failureReason,
+			IdempotencyToken: idempotencyToken,
+		})
+	case model.Provisioning:
+		_, err = h.serviceCatalog.NotifyProvisionProductEngineWorkflowResult(ctx, &sc.NotifyProvisionProductEngineWorkflowResultInput{
+			WorkflowToken:    &request.WorkflowToken,
+			RecordId:         &request.RecordId,
+			Status:           types.EngineWorkflowStatusFailed,
+			FailureReason:    failureReason,
+			IdempotencyToken: idempotencyToken,
+		})
+	case model.Updating:
+		_, err = h.serviceCatalog.NotifyUpdateProvisionedProductEngineWorkflowResult(ctx, &sc.NotifyUpdateProvisionedProductEngineWorkflowResultInput{
+			WorkflowToken:    &request.WorkflowToken,
+			RecordId:         &request.RecordId,
+			Status:           types.EngineWorkflowStatusFailed,
+			FailureReason:    failureReason,
+			IdempotencyToken: idempotencyToken,
+		})
+	default:
+		// Service Catalog has a separate API for each operation, so the result cannot be reported
+		log.Printf("Unknown serviceCatalogOperation: %s\n", request.ServiceCatalogOperation)
+		return nil, cause
+	}
+	if err != nil {
+		log.Default().Printf("failed to notify service catalog: %v", err)
+	}
+
+	return nil, err
+}
+
+// MaxFailureReasonLength is the maximum failure reason length allowed by Service Catalog
+const MaxFailureReasonLength = 2048
+
+// minErrorLength is the room kept for the error in the failure reason, however long the summary of the run is
+const minErrorLength = 512
+
+func FormatError(err string, errorMessage string, runDetails *RunDetails) *string {
 	// Simplify the error message (if possible)
 	simplifiedErrorString := SimplifyError(errorMessage)
 
 	// Check if error was due to lambda timeout
 	if err == "States.Timeout" {
-		return aws.String("A lambda function invoked by the state machine has timed out")
+		simplifiedErrorString = "A lambda function invoked by the state machine has timed out"
 	}
 
-	// Max error message length is 2048
-	if len(simplifiedErrorString) <= (2048) {
-		return aws.String(simplifiedErrorString)
+	// Append the summary of the run, so users can jump straight to the run in TFC
+	summary := ""
+	if runSummary := runDetails.Summary(); runSummary != "" {
+		summary = " " + runSummary
+	}
+	// The summary is truncated instead of the error if it does not leave enough room for the error
+	summary = servicecatalog.TruncateMessage(summary, MaxFailureReasonLength-minErrorLength)
+	maxLength := MaxFailureReasonLength - len(summary)
+
+	if len(simplifiedErrorString) <= maxLength {
+		return aws.String(simplifiedErrorString + summary)
 	}
 
 	// Truncate error message to fit maximum failure reason length allowed by Service Catalog.
-	// We use 2045 to make room for the ellipsis.
-	return aws.String(simplifiedErrorString[:2045] + "...")
+	// We make room for the ellipsis.
+	return aws.String(servicecatalog.TruncateMessage(simplifiedErrorString, maxLength-3) + "..." + summary)
 }
 
-func DeleteWorkspace(ctx context.Context, client *tfe.Client, request NotifyRunResultRequest) error {
+// DeleteWorkspace safe-deletes the workspace of the provisioned product. The workspace is not deleted while its state
+// still contains resources, e.g. after a partial destroy, as those resources would be orphaned. If a state archive is
+// configured, the final state and the variables of the workspace are archived before it is deleted. If a retention
+// period is configured, the workspace is locked and tagged as terminated instead, and deleted by the reaper later.
+func (h NotifyRunResultHandler) DeleteWorkspace(ctx context.Context, client *tfe.Client, request model.NotifyRunResultRequest) error {
 	// Get workspace name
 	workspaceName := identifiers.GetWorkspaceName(request.AwsAccountId, request.ProvisionedProductId)
 
-	// Make a call to delete workspace
-	err := client.Workspaces.Delete(ctx, request.TerraformOrganization, workspaceName)
+	workspace, err := client.Workspaces.Read(ctx, request.TerraformOrganization, workspaceName)
+	if errors.Is(err, tfe.ErrResourceNotFound) {
+		// The workspace never existed or was already deleted, e.g. by a previous attempt to notify the result
+		log.Default().Printf("workspace %s does not exist, so there is nothing to delete", workspaceName)
+		return nil
+	}
+	if err != nil {
+		return tfc.Error(err)
+	}
+
+	rawState, err := GetCurrentState(ctx, client, workspace)
+	if err != nil {
+		return err
+	}
+
+	// Make sure the destroy run left no resources behind
+	addresses, err := ParseManagedResources(rawState)
+	if err != nil {
+		return err
+	}
+	if len(addresses) > 0 {
+		return RemainingResourcesError(workspaceName, addresses)
+	}
+
+	// Archive the workspace, as its state history is lost once it is deleted
+	if h.stateArchive != nil {
+		if err := ArchiveWorkspace(ctx, client, h.stateArchive, request, workspace, rawState); err != nil {
+			return err
+		}
+	}
+
+	// Keep the workspace for investigation, if configured
+	if h.workspaceRetention > 0 {
+		return tfc.RetainTerminatedWorkspace(ctx, client, workspace, time.Now())
+	}
+
+	// Make a call to delete workspace, which TFC refuses as well if resources remain
+	err = client.Workspaces.SafeDelete(ctx, request.TerraformOrganization, workspaceName)
+	if err != nil {
+		return tfc.Error(err)
+	}
 
-	return err
+	return nil
 }
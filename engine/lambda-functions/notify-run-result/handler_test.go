@@ -9,13 +9,21 @@ import (
 	"context"
 	"fmt"
 	"github.com/aws/aws-sdk-go-v2/service/servicecatalog/types"
+	"github.com/aws/smithy-go"
+	sharedservicecatalog "github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/servicecatalog"
 	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/testutil/secretsmanager"
 	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/testutil/servicecatalog"
+	testarchive "github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/testutil/statearchive"
 	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/testutil/testtfc"
-	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/tracertag"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/tfc"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/model"
 	"github.com/hashicorp/go-tfe"
 	"github.com/stretchr/testify/assert"
+	"encoding/json"
+	"errors"
+	"strings"
 	"testing"
+	"unicode/utf8"
 	"time"
 )
 
@@ -45,15 +53,15 @@ func TestNotifyRunResultHandler_Terminating_Success(t *testing.T) {
 	}
 
 	// Create test request
-	testRequest := NotifyRunResultRequest{
+	testRequest := model.NotifyRunResultRequest{
 		TerraformRunId: "run-forrest-run",
 		WorkflowToken:  "whistle-while-you-work",
 		RecordId:       "record-this-id",
-		TracerTag: tracertag.TracerTag{
-			TracerTagKey:   "test-tracer-tag-key",
-			TracerTagValue: "test-trace-tag-value",
+		TracerTag: model.TracerTag{
+			Key:   "test-tracer-tag-key",
+			Value: "test-trace-tag-value",
 		},
-		ServiceCatalogOperation: Terminating,
+		ServiceCatalogOperation: model.Terminating,
 		AwsAccountId:            "123456789042",
 		TerraformOrganization:   tfcServer.OrganizationName,
 		ProvisionedProductId:    "amazingly-great-product-instance",
@@ -104,15 +112,15 @@ func TestNotifyRunResultHandler_Terminating_WithError(t *testing.T) {
 	}
 
 	// Create test request
-	testRequest := NotifyRunResultRequest{
+	testRequest := model.NotifyRunResultRequest{
 		TerraformRunId: "run-forrest-run",
 		WorkflowToken:  "whistle-while-you-work",
 		RecordId:       "record-this-id",
-		TracerTag: tracertag.TracerTag{
-			TracerTagKey:   "test-tracer-tag-key",
-			TracerTagValue: "test-trace-tag-value",
+		TracerTag: model.TracerTag{
+			Key:   "test-tracer-tag-key",
+			Value: "test-trace-tag-value",
 		},
-		ServiceCatalogOperation: Terminating,
+		ServiceCatalogOperation: model.Terminating,
 		AwsAccountId:            "123456789042",
 		TerraformOrganization:   tfcServer.OrganizationName,
 		ProvisionedProductId:    "amazingly-great-product-instance",
@@ -157,9 +165,12 @@ func TestNotifyRunResultHandler_Provisioning_Success(t *testing.T) {
 
 	testStateVersionOutput := &tfe.StateVersionOutput{
 		Name:      "super_valuable_information_about_your_infra",
-		Sensitive: true,
-		Type:      "string",
-		Value:     "supervaluableinfo",
+		Sensitive: false,
+		Type:      "object",
+		Value: map[string]interface{}{
+			"endpoint": "https://info.example.com/?a=1&b=2",
+			"ports":    []interface{}{80, 443},
+		},
 	}
 
 	// Add a state version that contains no outputs
@@ -204,15 +215,15 @@ func TestNotifyRunResultHandler_Provisioning_Success(t *testing.T) {
 	}
 
 	// Create test request
-	testRequest := NotifyRunResultRequest{
+	testRequest := model.NotifyRunResultRequest{
 		TerraformRunId: "run-forrest-run",
 		WorkflowToken:  "whistle-while-you-work",
 		RecordId:       "record-this-id",
-		TracerTag: tracertag.TracerTag{
-			TracerTagKey:   "test-tracer-tag-key",
-			TracerTagValue: "test-trace-tag-value",
+		TracerTag: model.TracerTag{
+			Key:   "test-tracer-tag-key",
+			Value: "test-trace-tag-value",
 		},
-		ServiceCatalogOperation: Provisioning,
+		ServiceCatalogOperation: model.Provisioning,
 		AwsAccountId:            "123456789042",
 		TerraformOrganization:   tfcServer.OrganizationName,
 		ProvisionedProductId:    "amazingly-great-product-instance",
@@ -235,12 +246,15 @@ func TestNotifyRunResultHandler_Provisioning_Success(t *testing.T) {
 
 	// Verify the outputs were published correctly
 	actualOutputs := mockServiceCatalog.NotifyProvisionProductEngineWorkflowResultInput.Outputs
-	assert.Equal(t, 1, len(actualOutputs))
-	for _, actualOutput := range actualOutputs {
-		assert.Equal(t, testStateVersionOutput.Name, *actualOutput.OutputKey)
-		assert.Equal(t, testStateVersionOutput.Value, *actualOutput.OutputValue)
-		assert.Nil(t, actualOutput.Description)
-	}
+	assert.Equal(t, 2, len(actualOutputs))
+	assert.Equal(t, testStateVersionOutput.Name, *actualOutputs[0].OutputKey)
+	assert.Equal(t, `{"endpoint":"https://info.example.com/?a=1&b=2","ports":[80,443]}`, *actualOutputs[0].OutputValue, "complex outputs should have been published as JSON")
+	assert.Nil(t, actualOutputs[0].Description)
+
+	// Verify the run was linked in the outputs
+	expectedRunUrl := fmt.Sprintf("%s/app/%s/workspaces/123456789042-amazingly-great-product-instance/runs/run-forrest-run", tfcServer.Address, tfcServer.OrganizationName)
+	assert.Equal(t, RunUrlOutputKey, *actualOutputs[1].OutputKey)
+	assert.Equal(t, expectedRunUrl, *actualOutputs[1].OutputValue)
 
 	// Verify workflow token
 	assert.Equal(t, testRequest.WorkflowToken, *mockServiceCatalog.NotifyProvisionProductEngineWorkflowResultInput.WorkflowToken)
@@ -304,15 +318,15 @@ func TestNotifyRunResultHandler_Provisioning_Success_WithMoreThan100StateVersion
 	}
 
 	// Create test request
-	testRequest := NotifyRunResultRequest{
+	testRequest := model.NotifyRunResultRequest{
 		TerraformRunId: "run-forrest-run",
 		WorkflowToken:  "whistle-while-you-work",
 		RecordId:       "record-this-id",
-		TracerTag: tracertag.TracerTag{
-			TracerTagKey:   "test-tracer-tag-key",
-			TracerTagValue: "test-trace-tag-value",
+		TracerTag: model.TracerTag{
+			Key:   "test-tracer-tag-key",
+			Value: "test-trace-tag-value",
 		},
-		ServiceCatalogOperation: Provisioning,
+		ServiceCatalogOperation: model.Provisioning,
 		AwsAccountId:            "123456789042",
 		TerraformOrganization:   tfcServer.OrganizationName,
 		ProvisionedProductId:    "amazingly-great-product-instance",
@@ -335,7 +349,7 @@ func TestNotifyRunResultHandler_Provisioning_Success_WithMoreThan100StateVersion
 
 	// Verify the outputs were published correctly
 	actualOutputs := mockServiceCatalog.NotifyProvisionProductEngineWorkflowResultInput.Outputs
-	assert.Equal(t, 250, len(actualOutputs))
+	assert.Equal(t, 251, len(actualOutputs), "all outputs and the link to the run should have been published")
 
 	// Verify workflow token
 	assert.Equal(t, testRequest.WorkflowToken, *mockServiceCatalog.NotifyProvisionProductEngineWorkflowResultInput.WorkflowToken)
@@ -371,15 +385,15 @@ func TestNotifyRunResultHandler_Provisioning_MissingApply(t *testing.T) {
 	}
 
 	// Create test request
-	testRequest := NotifyRunResultRequest{
+	testRequest := model.NotifyRunResultRequest{
 		TerraformRunId: "run-forrest-run",
 		WorkflowToken:  "whistle-while-you-work",
 		RecordId:       "record-this-id",
-		TracerTag: tracertag.TracerTag{
-			TracerTagKey:   "test-tracer-tag-key",
-			TracerTagValue: "test-trace-tag-value",
+		TracerTag: model.TracerTag{
+			Key:   "test-tracer-tag-key",
+			Value: "test-trace-tag-value",
 		},
-		ServiceCatalogOperation: Provisioning,
+		ServiceCatalogOperation: model.Provisioning,
 		AwsAccountId:            "123456789042",
 		TerraformOrganization:   tfcServer.OrganizationName,
 		ProvisionedProductId:    "amazingly-great-product-instance",
@@ -453,15 +467,15 @@ func TestNotifyRunResultHandler_Updating_Success(t *testing.T) {
 	}
 
 	// Create test request
-	testRequest := NotifyRunResultRequest{
+	testRequest := model.NotifyRunResultRequest{
 		TerraformRunId: "run-forrest-run",
 		WorkflowToken:  "whistle-while-you-work",
 		RecordId:       "record-this-id",
-		TracerTag: tracertag.TracerTag{
-			TracerTagKey:   "test-tracer-tag-key",
-			TracerTagValue: "test-trace-tag-value",
+		TracerTag: model.TracerTag{
+			Key:   "test-tracer-tag-key",
+			Value: "test-trace-tag-value",
 		},
-		ServiceCatalogOperation: Updating,
+		ServiceCatalogOperation: model.Updating,
 		AwsAccountId:            "123456789042",
 		TerraformOrganization:   tfcServer.OrganizationName,
 		ProvisionedProductId:    "amazingly-great-product-instance",
@@ -484,13 +498,615 @@ func TestNotifyRunResultHandler_Updating_Success(t *testing.T) {
 
 	// Verify the outputs were published correctly
 	actualOutputs := mockServiceCatalog.NotifyUpdateProvisionedProductEngineWorkflowResultInput.Outputs
-	assert.Equal(t, 1, len(actualOutputs))
-	for _, actualOutput := range actualOutputs {
-		assert.Equal(t, testStateVersionOutput.Name, *actualOutput.OutputKey)
-		assert.Equal(t, testStateVersionOutput.Value, *actualOutput.OutputValue)
-		assert.Nil(t, actualOutput.Description)
-	}
+	assert.Equal(t, 2, len(actualOutputs))
+	assert.Equal(t, testStateVersionOutput.Name, *actualOutputs[0].OutputKey)
+	assert.Equal(t, RedactedOutputValue, *actualOutputs[0].OutputValue, "sensitive outputs should have been redacted")
+	assert.NotNil(t, actualOutputs[0].Description)
+
+	// Verify the run was linked in the outputs
+	expectedRunUrl := fmt.Sprintf("%s/app/%s/workspaces/123456789042-amazingly-great-product-instance/runs/run-forrest-run", tfcServer.Address, tfcServer.OrganizationName)
+	assert.Equal(t, RunUrlOutputKey, *actualOutputs[1].OutputKey)
+	assert.Equal(t, expectedRunUrl, *actualOutputs[1].OutputValue)
 
 	// Verify workflow token
 	assert.Equal(t, testRequest.WorkflowToken, *mockServiceCatalog.NotifyUpdateProvisionedProductEngineWorkflowResultInput.WorkflowToken)
 }
+
+func TestNotifyRunResultHandler_Provisioning_WithError_LinksRun(t *testing.T) {
+	// Create mock TFC instance
+	tfcServer := testtfc.NewMockTFC()
+	defer tfcServer.Stop()
+
+	// Add a workspace to the TFC instance
+	tfcServer.AddWorkspace("123456789042-amazingly-great-product-instance", testtfc.WorkspaceFactoryParameters{Name: "the-best-workspace"})
+
+	// Add a Plan
+	testPlan := tfcServer.AddPlan(&tfe.Plan{
+		ResourceAdditions:    3,
+		ResourceChanges:      2,
+		ResourceDestructions: 1,
+		Status:               tfe.PlanFinished,
+	})
+
+	// Add a Run that errored
+	tfcServer.AddRun("run-forrest-run", testtfc.RunFactoryParameters{
+		RunStatus: tfe.RunErrored,
+		Plan:      testPlan,
+	})
+
+	// Create tfe client that will send requests to the mock TFC instance
+	mockSecretsManager := &secretsmanager.MockSecretsManager{
+		Hostname: tfcServer.Address,
+		TeamId:   "team-4123nlol",
+		Token:    "supers3cret",
+	}
+
+	// Create mock ServiceCatalog
+	mockServiceCatalog := servicecatalog.MockServiceCatalog{}
+
+	// Create a test instance of the Lambda function
+	testHandler := &NotifyRunResultHandler{
+		serviceCatalog: &mockServiceCatalog,
+		secretsManager: mockSecretsManager,
+	}
+
+	// Create test request
+	testRequest := model.NotifyRunResultRequest{
+		TerraformRunId: "run-forrest-run",
+		WorkflowToken:  "whistle-while-you-work",
+		RecordId:       "record-this-id",
+		TracerTag: model.TracerTag{
+			Key:   "test-tracer-tag-key",
+			Value: "test-trace-tag-value",
+		},
+		ServiceCatalogOperation: model.Provisioning,
+		AwsAccountId:            "123456789042",
+		TerraformOrganization:   tfcServer.OrganizationName,
+		ProvisionedProductId:    "amazingly-great-product-instance",
+		Error:                   "Error applying run in TFC",
+		ErrorMessage:            strings.Repeat("a", 3000),
+	}
+
+	// Send the test request
+	_, err := testHandler.HandleRequest(context.Background(), testRequest)
+	// Verify no errors were returned
+	if err != nil {
+		t.Error(err)
+	}
+
+	// Verify the workflow was reported as a failure
+	assert.Equal(t, types.EngineWorkflowStatusFailed, mockServiceCatalog.NotifyProvisionProductEngineWorkflowResultInput.Status)
+
+	// Verify the failure reason was truncated, but still links to the run
+	expectedRunUrl := fmt.Sprintf("%s/app/%s/workspaces/123456789042-amazingly-great-product-instance/runs/run-forrest-run", tfcServer.Address, tfcServer.OrganizationName)
+	failureReason := *mockServiceCatalog.NotifyProvisionProductEngineWorkflowResultInput.FailureReason
+	assert.Equal(t, 2048, len(failureReason), "failure reason should have been truncated to the maximum length")
+	assert.True(t, strings.HasSuffix(failureReason, fmt.Sprintf("... Run errored (plan: 3 to add, 2 to change, 1 to destroy): %s", expectedRunUrl)))
+}
+
+func TestFormatError_TruncatesWithoutSplittingCharacters(t *testing.T) {
+	failureReason := *FormatError("Error applying run in TFC", strings.Repeat("é", 3000), &RunDetails{RunStatus: tfe.RunErrored, RunUrl: "https://app.terraform.io/app/org/workspaces/ws/runs/run-forrest-run"})
+
+	assert.LessOrEqual(t, len(failureReason), MaxFailureReasonLength)
+	assert.True(t, utf8.ValidString(failureReason), "failure reason should be valid UTF-8")
+	assert.True(t, strings.HasSuffix(failureReason, "é... Run errored: https://app.terraform.io/app/org/workspaces/ws/runs/run-forrest-run"))
+}
+
+func TestFormatError_TruncatesLongSummary(t *testing.T) {
+	runUrl := "https://tfe.example.com/app/org/workspaces/" + strings.Repeat("w", 3000) + "/runs/run-forrest-run"
+	failureReason := *FormatError("Error applying run in TFC", "Invalid value for variable", &RunDetails{RunStatus: tfe.RunErrored, RunUrl: runUrl})
+
+	// Verify the summary was truncated, and the error was kept
+	assert.LessOrEqual(t, len(failureReason), MaxFailureReasonLength)
+	assert.True(t, strings.HasPrefix(failureReason, "Invalid value for variable Run errored: https://tfe.example.com/app/org/workspaces/www"))
+}
+
+func TestNotifyRunResultHandler_Terminating_RetriesWithSameIdempotencyToken(t *testing.T) {
+	// Create mock TFC instance
+	tfcServer := testtfc.NewMockTFC()
+	defer tfcServer.Stop()
+
+	// Create tfe client that will send requests to the mock TFC instance
+	mockSecretsManager := &secretsmanager.MockSecretsManager{
+		Hostname: tfcServer.Address,
+		TeamId:   "team-4123nlol",
+		Token:    "supers3cret",
+	}
+
+	// Create mock ServiceCatalog
+	mockServiceCatalog := servicecatalog.MockServiceCatalog{}
+
+	// Create a test instance of the Lambda function
+	testHandler := &NotifyRunResultHandler{
+		serviceCatalog: &mockServiceCatalog,
+		secretsManager: mockSecretsManager,
+	}
+
+	// Create test request
+	testRequest := model.NotifyRunResultRequest{
+		TerraformRunId:          "run-forrest-run",
+		WorkflowToken:           "whistle-while-you-work",
+		RecordId:                "record-this-id",
+		ServiceCatalogOperation: model.Terminating,
+		AwsAccountId:            "123456789042",
+		TerraformOrganization:   tfcServer.OrganizationName,
+		ProvisionedProductId:    "amazingly-great-product-instance",
+		Error:                   "My.Bad",
+		ErrorMessage:            "you win some, you lose some",
+	}
+
+	// Send the test request twice, as the state machine does when it retries the notification
+	_, err := testHandler.HandleRequest(context.Background(), testRequest)
+	assert.NoError(t, err)
+	firstIdempotencyToken := *mockServiceCatalog.NotifyTerminateProvisionedProductEngineWorkflowResultInput.IdempotencyToken
+
+	_, err = testHandler.HandleRequest(context.Background(), testRequest)
+	assert.NoError(t, err)
+	secondIdempotencyToken := *mockServiceCatalog.NotifyTerminateProvisionedProductEngineWorkflowResultInput.IdempotencyToken
+
+	// Verify both notifications were sent with the same idempotency token
+	assert.Equal(t, firstIdempotencyToken, secondIdempotencyToken)
+	assert.Equal(t, sharedservicecatalog.IdempotencyToken("record-this-id", "TERMINATING", "run-forrest-run"), firstIdempotencyToken)
+	assert.NotEqual(t, sharedservicecatalog.IdempotencyToken("record-this-id", "UPDATING", "run-forrest-run"), firstIdempotencyToken)
+}
+
+func TestNotifyRunResultHandler_Terminating_RetriesThrottledNotification(t *testing.T) {
+	// Create mock TFC instance
+	tfcServer := testtfc.NewMockTFC()
+	defer tfcServer.Stop()
+
+	// Create tfe client that will send requests to the mock TFC instance
+	mockSecretsManager := &secretsmanager.MockSecretsManager{
+		Hostname: tfcServer.Address,
+		TeamId:   "team-4123nlol",
+		Token:    "supers3cret",
+	}
+
+	// Create mock ServiceCatalog, which throttles the first two requests
+	throttlingError := &smithy.GenericAPIError{Code: "ThrottlingException", Message: "Rate exceeded"}
+	mockServiceCatalog := servicecatalog.MockServiceCatalog{
+		Errors: []error{throttlingError, throttlingError},
+	}
+
+	// Create a test instance of the Lambda function
+	testHandler := &NotifyRunResultHandler{
+		serviceCatalog: sharedservicecatalog.ThrottlingRetrySC{
+			ServiceCatalog: &mockServiceCatalog,
+			MaxAttempts:    3,
+			BaseDelay:      time.Millisecond,
+		},
+		secretsManager: mockSecretsManager,
+	}
+
+	// Create test request
+	testRequest := model.NotifyRunResultRequest{
+		TerraformRunId:          "run-forrest-run",
+		WorkflowToken:           "whistle-while-you-work",
+		RecordId:                "record-this-id",
+		ServiceCatalogOperation: model.Terminating,
+		AwsAccountId:            "123456789042",
+		TerraformOrganization:   tfcServer.OrganizationName,
+		ProvisionedProductId:    "amazingly-great-product-instance",
+		Error:                   "My.Bad",
+		ErrorMessage:            "you win some, you lose some",
+	}
+
+	// Send the test request
+	_, err := testHandler.HandleRequest(context.Background(), testRequest)
+
+	// Verify the notification succeeded after being throttled twice
+	assert.NoError(t, err)
+	assert.Equal(t, 3, mockServiceCatalog.Requests)
+	assert.Equal(t, types.EngineWorkflowStatusFailed, mockServiceCatalog.NotifyTerminateProvisionedProductEngineWorkflowResultInput.Status)
+
+	// Verify the error is returned once the attempts are exhausted
+	mockServiceCatalog.Errors = []error{throttlingError, throttlingError, throttlingError}
+	_, err = testHandler.HandleRequest(context.Background(), testRequest)
+	assert.ErrorIs(t, err, throttlingError)
+
+	// Verify the notification is not retried once the retry would exceed the deadline of the Lambda
+	mockServiceCatalog.Requests = 0
+	mockServiceCatalog.Errors = []error{throttlingError}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	_, err = testHandler.HandleRequest(ctx, testRequest)
+	assert.ErrorIs(t, err, throttlingError)
+	assert.Equal(t, 1, mockServiceCatalog.Requests)
+}
+
+func TestNotifyRunResultHandler_Terminating_ResourcesRemain(t *testing.T) {
+	// Create mock TFC instance
+	tfcServer := testtfc.NewMockTFC()
+	defer tfcServer.Stop()
+
+	// Add a workspace to the TFC instance, whose state still contains a resource after a partial destroy
+	workspace := tfcServer.AddWorkspace("123456789042-amazingly-great-product-instance", testtfc.WorkspaceFactoryParameters{})
+	tfcServer.SetCurrentState(workspace.ID, []byte(`{"version": 4, "resources": [{"mode": "managed", "type": "aws_s3_bucket", "name": "bucket", "instances": [{"attributes": {}}]}]}`))
+
+	// Create tfe client that will send requests to the mock TFC instance
+	mockSecretsManager := &secretsmanager.MockSecretsManager{
+		Hostname: tfcServer.Address,
+		TeamId:   "team-4123nlol",
+		Token:    "supers3cret",
+	}
+
+	// Create mock ServiceCatalog
+	mockServiceCatalog := servicecatalog.MockServiceCatalog{}
+
+	// Create a test instance of the Lambda function
+	testHandler := &NotifyRunResultHandler{
+		serviceCatalog: &mockServiceCatalog,
+		secretsManager: mockSecretsManager,
+	}
+
+	// Create test request
+	testRequest := model.NotifyRunResultRequest{
+		TerraformRunId:          "run-forrest-run",
+		WorkflowToken:           "whistle-while-you-work",
+		RecordId:                "record-this-id",
+		ServiceCatalogOperation: model.Terminating,
+		AwsAccountId:            "123456789042",
+		TerraformOrganization:   tfcServer.OrganizationName,
+		ProvisionedProductId:    "amazingly-great-product-instance",
+	}
+
+	// Send the test request
+	_, err := testHandler.HandleRequest(context.Background(), testRequest)
+	assert.NoError(t, err)
+
+	// Verify the TFC workspace was not deleted
+	assert.Equal(t, 1, len(tfcServer.Workspaces), "The TFC workspace should not have been deleted")
+
+	// Verify the termination was reported as a failure, listing the remaining resource
+	notifyInput := mockServiceCatalog.NotifyTerminateProvisionedProductEngineWorkflowResultInput
+	assert.Equal(t, types.EngineWorkflowStatusFailed, notifyInput.Status)
+	assert.Contains(t, *notifyInput.FailureReason, "still contains 1 resources")
+	assert.Contains(t, *notifyInput.FailureReason, "aws_s3_bucket.bucket")
+}
+
+func TestNotifyRunResultHandler_Terminating_EmptyState(t *testing.T) {
+	// Create mock TFC instance
+	tfcServer := testtfc.NewMockTFC()
+	defer tfcServer.Stop()
+
+	// Add a workspace to the TFC instance, whose state was emptied by the destroy run
+	workspace := tfcServer.AddWorkspace("123456789042-amazingly-great-product-instance", testtfc.WorkspaceFactoryParameters{})
+	tfcServer.SetCurrentState(workspace.ID, []byte(`{"version": 4, "resources": [{"mode": "data", "type": "aws_caller_identity", "name": "current", "instances": [{"attributes": {}}]}]}`))
+
+	// Create tfe client that will send requests to the mock TFC instance
+	mockSecretsManager := &secretsmanager.MockSecretsManager{
+		Hostname: tfcServer.Address,
+		TeamId:   "team-4123nlol",
+		Token:    "supers3cret",
+	}
+
+	// Create mock ServiceCatalog
+	mockServiceCatalog := servicecatalog.MockServiceCatalog{}
+
+	// Create a test instance of the Lambda function
+	testHandler := &NotifyRunResultHandler{
+		serviceCatalog: &mockServiceCatalog,
+		secretsManager: mockSecretsManager,
+	}
+
+	// Create test request
+	testRequest := model.NotifyRunResultRequest{
+		TerraformRunId:          "run-forrest-run",
+		WorkflowToken:           "whistle-while-you-work",
+		RecordId:                "record-this-id",
+		ServiceCatalogOperation: model.Terminating,
+		AwsAccountId:            "123456789042",
+		TerraformOrganization:   tfcServer.OrganizationName,
+		ProvisionedProductId:    "amazingly-great-product-instance",
+	}
+
+	// Send the test request
+	_, err := testHandler.HandleRequest(context.Background(), testRequest)
+	assert.NoError(t, err)
+
+	// Verify the TFC workspace was deleted, as data sources are not left behind
+	assert.Equal(t, 0, len(tfcServer.Workspaces), "The TFC workspace should have been deleted")
+	assert.Equal(t, types.EngineWorkflowStatusSucceeded, mockServiceCatalog.NotifyTerminateProvisionedProductEngineWorkflowResultInput.Status)
+}
+
+func TestNotifyRunResultHandler_Terminating_ArchivesWorkspace(t *testing.T) {
+	// Create mock TFC instance
+	tfcServer := testtfc.NewMockTFC()
+	defer tfcServer.Stop()
+
+	// Add a workspace with state and variables to the TFC instance
+	workspace := tfcServer.AddWorkspace("123456789042-amazingly-great-product-instance", testtfc.WorkspaceFactoryParameters{})
+	state := []byte(`{"version": 4, "serial": 7, "resources": []}`)
+	tfcServer.SetCurrentState(workspace.ID, state)
+	tfcServer.AddVar(&tfe.Variable{Key: "bucket_name", Value: "my-bucket", Category: tfe.CategoryTerraform, Workspace: workspace})
+	tfcServer.AddVar(&tfe.Variable{Key: "api_key", Value: "sup3rs3cret", Category: tfe.CategoryTerraform, Sensitive: true, Workspace: workspace})
+
+	// Create tfe client that will send requests to the mock TFC instance
+	mockSecretsManager := &secretsmanager.MockSecretsManager{
+		Hostname: tfcServer.Address,
+		TeamId:   "team-4123nlol",
+		Token:    "supers3cret",
+	}
+
+	// Create mock ServiceCatalog and state archive
+	mockServiceCatalog := servicecatalog.MockServiceCatalog{}
+	mockStateArchive := testarchive.NewMockStateArchive()
+
+	// Create a test instance of the Lambda function
+	testHandler := &NotifyRunResultHandler{
+		serviceCatalog: &mockServiceCatalog,
+		secretsManager: mockSecretsManager,
+		stateArchive:   mockStateArchive,
+	}
+
+	// Create test request
+	testRequest := model.NotifyRunResultRequest{
+		TerraformRunId:          "run-forrest-run",
+		WorkflowToken:           "whistle-while-you-work",
+		RecordId:                "record-this-id",
+		ServiceCatalogOperation: model.Terminating,
+		AwsAccountId:            "123456789042",
+		TerraformOrganization:   tfcServer.OrganizationName,
+		ProvisionedProductId:    "amazingly-great-product-instance",
+	}
+
+	// Send the test request
+	_, err := testHandler.HandleRequest(context.Background(), testRequest)
+	assert.NoError(t, err)
+
+	// Verify the workspace was deleted
+	assert.Equal(t, 0, len(tfcServer.Workspaces), "The TFC workspace should have been deleted")
+	assert.Equal(t, types.EngineWorkflowStatusSucceeded, mockServiceCatalog.NotifyTerminateProvisionedProductEngineWorkflowResultInput.Status)
+
+	// Verify the state was archived
+	assert.Equal(t, state, mockStateArchive.Objects["123456789042/amazingly-great-product-instance/state.json"])
+
+	// Verify the variables were archived, without the sensitive value
+	var archivedVariables []ArchivedVariable
+	err = json.Unmarshal(mockStateArchive.Objects["123456789042/amazingly-great-product-instance/variables.json"], &archivedVariables)
+	assert.NoError(t, err)
+	assert.Equal(t, []ArchivedVariable{
+		{Key: "bucket_name", Value: "my-bucket", Category: tfe.CategoryTerraform},
+		{Key: "api_key", Category: tfe.CategoryTerraform, Sensitive: true},
+	}, archivedVariables)
+}
+
+func TestNotifyRunResultHandler_Terminating_ArchiveFails(t *testing.T) {
+	// Create mock TFC instance
+	tfcServer := testtfc.NewMockTFC()
+	defer tfcServer.Stop()
+
+	// Add a workspace to the TFC instance
+	tfcServer.AddWorkspace("123456789042-amazingly-great-product-instance", testtfc.WorkspaceFactoryParameters{})
+
+	// Create tfe client that will send requests to the mock TFC instance
+	mockSecretsManager := &secretsmanager.MockSecretsManager{
+		Hostname: tfcServer.Address,
+		TeamId:   "team-4123nlol",
+		Token:    "supers3cret",
+	}
+
+	// Create mock ServiceCatalog and a state archive that cannot be written to
+	mockServiceCatalog := servicecatalog.MockServiceCatalog{}
+	mockStateArchive := testarchive.NewMockStateArchive()
+	mockStateArchive.Err = errors.New("access denied")
+
+	// Create a test instance of the Lambda function
+	testHandler := &NotifyRunResultHandler{
+		serviceCatalog: &mockServiceCatalog,
+		secretsManager: mockSecretsManager,
+		stateArchive:   mockStateArchive,
+	}
+
+	// Create test request
+	testRequest := model.NotifyRunResultRequest{
+		TerraformRunId:          "run-forrest-run",
+		WorkflowToken:           "whistle-while-you-work",
+		RecordId:                "record-this-id",
+		ServiceCatalogOperation: model.Terminating,
+		AwsAccountId:            "123456789042",
+		TerraformOrganization:   tfcServer.OrganizationName,
+		ProvisionedProductId:    "amazingly-great-product-instance",
+	}
+
+	// Send the test request
+	_, err := testHandler.HandleRequest(context.Background(), testRequest)
+	assert.NoError(t, err)
+
+	// Verify the workspace was kept, and the termination was reported as a failure
+	assert.Equal(t, 1, len(tfcServer.Workspaces), "The TFC workspace should not have been deleted")
+	notifyInput := mockServiceCatalog.NotifyTerminateProvisionedProductEngineWorkflowResultInput
+	assert.Equal(t, types.EngineWorkflowStatusFailed, notifyInput.Status)
+	assert.Contains(t, *notifyInput.FailureReason, "failed to archive variables")
+}
+
+func TestNotifyRunResultHandler_Terminating_WorkspaceMissing(t *testing.T) {
+	// Create mock TFC instance, without the workspace of the provisioned product
+	tfcServer := testtfc.NewMockTFC()
+	defer tfcServer.Stop()
+
+	// Create tfe client that will send requests to the mock TFC instance
+	mockSecretsManager := &secretsmanager.MockSecretsManager{
+		Hostname: tfcServer.Address,
+		TeamId:   "team-4123nlol",
+		Token:    "supers3cret",
+	}
+
+	// Create mock ServiceCatalog
+	mockServiceCatalog := servicecatalog.MockServiceCatalog{}
+
+	// Create a test instance of the Lambda function
+	testHandler := &NotifyRunResultHandler{
+		serviceCatalog: &mockServiceCatalog,
+		secretsManager: mockSecretsManager,
+	}
+
+	// Create test request, without a run as the destroy was skipped
+	testRequest := model.NotifyRunResultRequest{
+		TerraformRunId:          "",
+		WorkflowToken:           "whistle-while-you-work",
+		RecordId:                "record-this-id",
+		ServiceCatalogOperation: model.Terminating,
+		AwsAccountId:            "123456789042",
+		TerraformOrganization:   tfcServer.OrganizationName,
+		ProvisionedProductId:    "amazingly-great-product-instance",
+	}
+
+	// Send the test request
+	_, err := testHandler.HandleRequest(context.Background(), testRequest)
+	assert.NoError(t, err)
+
+	// Verify the termination was reported as a success
+	assert.Equal(t, types.EngineWorkflowStatusSucceeded, mockServiceCatalog.NotifyTerminateProvisionedProductEngineWorkflowResultInput.Status)
+}
+
+func TestNotifyRunResultHandler_Terminating_RetainsWorkspace(t *testing.T) {
+	// Create mock TFC instance
+	tfcServer := testtfc.NewMockTFC()
+	defer tfcServer.Stop()
+
+	// Add a workspace to the TFC instance
+	workspace := tfcServer.AddWorkspace("123456789042-amazingly-great-product-instance", testtfc.WorkspaceFactoryParameters{})
+
+	// Create tfe client that will send requests to the mock TFC instance
+	mockSecretsManager := &secretsmanager.MockSecretsManager{
+		Hostname: tfcServer.Address,
+		TeamId:   "team-4123nlol",
+		Token:    "supers3cret",
+	}
+
+	// Create mock ServiceCatalog
+	mockServiceCatalog := servicecatalog.MockServiceCatalog{}
+
+	// Create a test instance of the Lambda function, which retains workspaces for a week
+	testHandler := &NotifyRunResultHandler{
+		serviceCatalog:     &mockServiceCatalog,
+		secretsManager:     mockSecretsManager,
+		workspaceRetention: 7 * 24 * time.Hour,
+	}
+
+	// Create test request
+	testRequest := model.NotifyRunResultRequest{
+		TerraformRunId:          "run-forrest-run",
+		WorkflowToken:           "whistle-while-you-work",
+		RecordId:                "record-this-id",
+		ServiceCatalogOperation: model.Terminating,
+		AwsAccountId:            "123456789042",
+		TerraformOrganization:   tfcServer.OrganizationName,
+		ProvisionedProductId:    "amazingly-great-product-instance",
+	}
+
+	// Send the test request
+	_, err := testHandler.HandleRequest(context.Background(), testRequest)
+	assert.NoError(t, err)
+
+	// Verify the termination was reported as a success
+	assert.Equal(t, types.EngineWorkflowStatusSucceeded, mockServiceCatalog.NotifyTerminateProvisionedProductEngineWorkflowResultInput.Status)
+
+	// Verify the workspace was kept, locked and tagged as terminated
+	assert.Equal(t, 1, len(tfcServer.Workspaces), "The TFC workspace should have been retained")
+	assert.True(t, workspace.Locked, "The TFC workspace should have been locked")
+	assert.Contains(t, workspace.TagNames, "terminated")
+	terminatedAt, found := tfc.ParseTerminatedAt(workspace.TagNames)
+	assert.True(t, found, "The TFC workspace should have been tagged with the termination time")
+	assert.WithinDuration(t, time.Now(), terminatedAt, time.Minute)
+
+	// Verify notifying the result again keeps the workspace as it is
+	_, err = testHandler.HandleRequest(context.Background(), testRequest)
+	assert.NoError(t, err)
+	assert.Equal(t, types.EngineWorkflowStatusSucceeded, mockServiceCatalog.NotifyTerminateProvisionedProductEngineWorkflowResultInput.Status)
+	assert.Equal(t, 2, len(workspace.TagNames))
+}
+
+func TestNotifyRunResultHandler_Provisioning_InvalidRequestNotifiesFailure(t *testing.T) {
+	// Create mock TFC instance
+	tfcServer := testtfc.NewMockTFC()
+	defer tfcServer.Stop()
+
+	// Create tfe client that will send requests to the mock TFC instance
+	mockSecretsManager := &secretsmanager.MockSecretsManager{
+		Hostname: tfcServer.Address,
+		TeamId:   "team-4123nlol",
+		Token:    "supers3cret",
+	}
+
+	// Create mock ServiceCatalog
+	mockServiceCatalog := servicecatalog.MockServiceCatalog{}
+
+	// Create a test instance of the Lambda function
+	testHandler := &NotifyRunResultHandler{
+		serviceCatalog: &mockServiceCatalog,
+		secretsManager: mockSecretsManager,
+	}
+
+	// Create test request, which is missing the account of the provisioned product
+	testRequest := model.NotifyRunResultRequest{
+		WorkflowToken:           "whistle-while-you-work",
+		RecordId:                "record-this-id",
+		ServiceCatalogOperation: model.Provisioning,
+		TerraformOrganization:   tfcServer.OrganizationName,
+		ProvisionedProductId:    "amazingly-great-product-instance",
+		Error:                   "My.Bad",
+		ErrorMessage:            "you win some, you lose some",
+	}
+
+	// Send the test request
+	_, err := testHandler.HandleRequest(context.Background(), testRequest)
+	assert.NoError(t, err)
+
+	// Verify the workflow was reported as a failure, with both the validation error and the original error
+	assert.Equal(t, types.EngineWorkflowStatusFailed, mockServiceCatalog.NotifyProvisionProductEngineWorkflowResultInput.Status)
+	assert.Equal(t, testRequest.WorkflowToken, *mockServiceCatalog.NotifyProvisionProductEngineWorkflowResultInput.WorkflowToken)
+	failureReason := *mockServiceCatalog.NotifyProvisionProductEngineWorkflowResultInput.FailureReason
+	assert.Contains(t, failureReason, "awsAccountId")
+	assert.Contains(t, failureReason, testRequest.ErrorMessage)
+}
+
+func TestNotifyRunResultHandler_InvalidRequestWithoutWorkflowToken(t *testing.T) {
+	// Create mock ServiceCatalog
+	mockServiceCatalog := servicecatalog.MockServiceCatalog{}
+
+	// Create a test instance of the Lambda function
+	testHandler := &NotifyRunResultHandler{
+		serviceCatalog: &mockServiceCatalog,
+		secretsManager: &secretsmanager.MockSecretsManager{},
+	}
+
+	// Send a test request that cannot be reported to Service Catalog
+	_, err := testHandler.HandleRequest(context.Background(), model.NotifyRunResultRequest{
+		RecordId:                "record-this-id",
+		ServiceCatalogOperation: model.Terminating,
+	})
+
+	// Verify the validation error was returned, as nothing could be notified
+	var validationErr model.ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+	assert.Nil(t, mockServiceCatalog.NotifyTerminateProvisionedProductEngineWorkflowResultInput)
+}
+
+func TestNotifyRunResultHandler_Updating_UnknownOrganizationNotifiesFailure(t *testing.T) {
+	// Create mock ServiceCatalog
+	mockServiceCatalog := servicecatalog.MockServiceCatalog{}
+
+	// Create a test instance of the Lambda function, without credentials for the organization of the request
+	testHandler := &NotifyRunResultHandler{
+		serviceCatalog: &mockServiceCatalog,
+		secretsManager: &secretsmanager.MockSecretsManager{Organization: "team-rocket-blast-off"},
+	}
+
+	// Send the test request
+	_, err := testHandler.HandleRequest(context.Background(), model.NotifyRunResultRequest{
+		SchemaVersion:           model.SchemaVersion,
+		WorkflowToken:           "whistle-while-you-work",
+		RecordId:                "record-this-id",
+		ServiceCatalogOperation: model.Updating,
+		AwsAccountId:            "123456789042",
+		TerraformOrganization:   "business-unit-without-credentials",
+		ProvisionedProductId:    "amazingly-great-product-instance",
+	})
+	assert.NoError(t, err)
+
+	// Verify the workflow was reported as a failure
+	assert.Equal(t, types.EngineWorkflowStatusFailed, mockServiceCatalog.NotifyUpdateProvisionedProductEngineWorkflowResultInput.Status)
+	assert.Equal(t, "no TFE credentials are configured for organization business-unit-without-credentials", *mockServiceCatalog.NotifyUpdateProvisionedProductEngineWorkflowResultInput.FailureReason)
+}
@@ -12,30 +12,11 @@ import (
 	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/awsconfig"
 	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/secretsmanager"
 	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/servicecatalog"
-	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/tracertag"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/statearchive"
 	"log"
-)
-
-type NotifyRunResultRequest struct {
-	TerraformRunId          string                  `json:"terraformRunId"`
-	WorkflowToken           string                  `json:"workflowToken"`
-	RecordId                string                  `json:"recordId"`
-	TracerTag               tracertag.TracerTag     `json:"tracerTag"`
-	ServiceCatalogOperation ServiceCatalogOperation `json:"serviceCatalogOperation"`
-	AwsAccountId            string                  `json:"awsAccountId"`
-	TerraformOrganization   string                  `json:"terraformOrganization"`
-	ProvisionedProductId    string                  `json:"provisionedProductId"`
-	Error                   string                  `json:"error"`
-	ErrorMessage            string                  `json:"errorMessage"`
-}
-
-type ServiceCatalogOperation string
-
-// Enum values for ServiceCatalogOperation
-const (
-	Terminating  ServiceCatalogOperation = "TERMINATING"
-	Provisioning ServiceCatalogOperation = "PROVISIONING"
-	Updating     ServiceCatalogOperation = "UPDATING"
+	"os"
+	"strconv"
+	"time"
 )
 
 type NotifyRunResultResponse struct{}
@@ -46,9 +27,9 @@ func main() {
 
 	sdkConfig := awsconfig.GetSdkConfig(initContext)
 	serviceCatalogClient := sc.NewFromConfig(sdkConfig)
-	serviceCatalog := servicecatalog.SC{
+	serviceCatalog := servicecatalog.NewThrottlingRetrySC(servicecatalog.SC{
 		Client: serviceCatalogClient,
-	}
+	})
 
 	// Create secrets client SDK to fetch TFE credentials
 	secretsManager, err := secretsmanager.NewWithConfig(initContext, sdkConfig)
@@ -59,6 +40,21 @@ func main() {
 	handler := NotifyRunResultHandler{
 		serviceCatalog: serviceCatalog,
 		secretsManager: secretsManager,
+		outputPrefix:   os.Getenv("RECORD_OUTPUT_PREFIX"),
+	}
+
+	// Retain the workspaces of terminated provisioned products, if a retention period is configured
+	if retentionInDays := os.Getenv("TERMINATED_WORKSPACE_RETENTION_IN_DAYS"); retentionInDays != "" {
+		days, err := strconv.Atoi(retentionInDays)
+		if err != nil {
+			log.Fatalf("failed to parse TERMINATED_WORKSPACE_RETENTION_IN_DAYS: %s", err)
+		}
+		handler.workspaceRetention = time.Duration(days) * 24 * time.Hour
+	}
+
+	// Archive the workspaces of terminated provisioned products, if the archive is enabled
+	if stateArchive := statearchive.NewFromConfig(sdkConfig); stateArchive != nil {
+		handler.stateArchive = stateArchive
 	}
 
 	lambda.Start(handler.HandleRequest)
@@ -0,0 +1,96 @@
+/*
+ * Copyright (c) HashiCorp, Inc.
+ * SPDX-License-Identifier: MPL-2.0
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/tfc"
+	"github.com/hashicorp/go-tfe"
+	"strings"
+)
+
+// MaxListedResources is the maximum number of remaining resources listed in the failure reason of a termination
+const MaxListedResources = 20
+
+type terraformState struct {
+	Resources []terraformStateResource `json:"resources"`
+}
+
+type terraformStateResource struct {
+	Module    string            `json:"module"`
+	Mode      string            `json:"mode"`
+	Type      string            `json:"type"`
+	Name      string            `json:"name"`
+	Instances []json.RawMessage `json:"instances"`
+}
+
+func (resource terraformStateResource) address() string {
+	address := fmt.Sprintf("%s.%s", resource.Type, resource.Name)
+	if resource.Module != "" {
+		address = fmt.Sprintf("%s.%s", resource.Module, address)
+	}
+	return address
+}
+
+// GetCurrentState returns the raw current state of the workspace, or nil if the workspace has no state. The raw state
+// is inspected rather than the resources of the state version, because TFC processes those asynchronously, so they may
+// not be available yet right after a destroy run.
+func GetCurrentState(ctx context.Context, client *tfe.Client, workspace *tfe.Workspace) ([]byte, error) {
+	stateVersion, err := client.StateVersions.ReadCurrent(ctx, workspace.ID)
+	if errors.Is(err, tfe.ErrResourceNotFound) {
+		// The workspace has never had any state
+		return nil, nil
+	}
+	if err != nil {
+		return nil, tfc.Error(err)
+	}
+
+	rawState, err := client.StateVersions.Download(ctx, stateVersion.DownloadURL)
+	if err != nil {
+		return nil, tfc.Error(err)
+	}
+
+	return rawState, nil
+}
+
+// ParseManagedResources returns the addresses of the managed resources with at least one instance in the raw state
+func ParseManagedResources(rawState []byte) ([]string, error) {
+	if rawState == nil {
+		return nil, nil
+	}
+
+	var state terraformState
+	if err := json.Unmarshal(rawState, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse state: %w", err)
+	}
+
+	var addresses []string
+	for _, resource := range state.Resources {
+		if resource.Mode == "managed" && len(resource.Instances) > 0 {
+			addresses = append(addresses, resource.address())
+		}
+	}
+
+	return addresses, nil
+}
+
+// RemainingResourcesError describes the resources that prevent a workspace from being deleted
+func RemainingResourcesError(workspaceName string, addresses []string) error {
+	listedAddresses := addresses
+	if len(listedAddresses) > MaxListedResources {
+		listedAddresses = listedAddresses[:MaxListedResources]
+	}
+
+	list := strings.Join(listedAddresses, ", ")
+	if omitted := len(addresses) - len(listedAddresses); omitted > 0 {
+		list = fmt.Sprintf("%s and %d more", list, omitted)
+	}
+
+	return fmt.Errorf("workspace %s was not deleted because its state still contains %d resources, which would no longer be managed: %s. Destroy or remove these resources from the state, then terminate the provisioned product again", workspaceName, len(addresses), list)
+}
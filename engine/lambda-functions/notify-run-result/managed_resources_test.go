@@ -0,0 +1,49 @@
+/*
+ * Copyright (c) HashiCorp, Inc.
+ * SPDX-License-Identifier: MPL-2.0
+ */
+
+package main
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestParseManagedResources(t *testing.T) {
+	rawState := []byte(`{
+		"version": 4,
+		"resources": [
+			{"mode": "managed", "type": "aws_s3_bucket", "name": "bucket", "instances": [{"attributes": {}}]},
+			{"mode": "data", "type": "aws_caller_identity", "name": "current", "instances": [{"attributes": {}}]},
+			{"module": "module.network", "mode": "managed", "type": "aws_vpc", "name": "main", "instances": [{"attributes": {}}]},
+			{"mode": "managed", "type": "aws_instance", "name": "scaled_to_zero", "instances": []}
+		]
+	}`)
+
+	addresses, err := ParseManagedResources(rawState)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"aws_s3_bucket.bucket", "module.network.aws_vpc.main"}, addresses)
+}
+
+func TestParseManagedResources_EmptyState(t *testing.T) {
+	addresses, err := ParseManagedResources([]byte(`{"version": 4, "resources": []}`))
+
+	assert.NoError(t, err)
+	assert.Empty(t, addresses)
+}
+
+func TestRemainingResourcesError_TruncatesList(t *testing.T) {
+	var addresses []string
+	for i := 0; i < MaxListedResources+5; i++ {
+		addresses = append(addresses, fmt.Sprintf("aws_s3_bucket.bucket_%d", i))
+	}
+
+	err := RemainingResourcesError("my-workspace", addresses)
+
+	assert.Contains(t, err.Error(), "aws_s3_bucket.bucket_19 and 5 more")
+	assert.False(t, strings.Contains(err.Error(), "aws_s3_bucket.bucket_20"))
+}
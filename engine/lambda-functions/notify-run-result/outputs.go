@@ -0,0 +1,101 @@
+/*
+ * Copyright (c) HashiCorp, Inc.
+ * SPDX-License-Identifier: MPL-2.0
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/servicecatalog/types"
+	"github.com/hashicorp/go-tfe"
+	"log"
+	"strings"
+)
+
+// MaxOutputValueLength is the maximum length of a record output value that is reported to Service Catalog
+const MaxOutputValueLength = 4096
+
+// RedactedOutputValue replaces the value of outputs that are marked as sensitive
+const RedactedOutputValue = "(sensitive value)"
+
+// SelectPublishedOutputs selects the outputs that are published to Service Catalog. When any of the outputs is named
+// with the prefix, only those outputs are published, under their name without the prefix. This lets product authors
+// hide helper outputs from end users. Otherwise, all outputs are published.
+func SelectPublishedOutputs(stateVersionOutputs []*tfe.StateVersionOutput, prefix string) []*tfe.StateVersionOutput {
+	if prefix == "" {
+		return stateVersionOutputs
+	}
+
+	var publishedOutputs []*tfe.StateVersionOutput
+	for _, stateVersionOutput := range stateVersionOutputs {
+		name := strings.TrimPrefix(stateVersionOutput.Name, prefix)
+		if name == stateVersionOutput.Name || name == "" {
+			continue
+		}
+
+		publishedOutput := *stateVersionOutput
+		publishedOutput.Name = name
+		publishedOutputs = append(publishedOutputs, &publishedOutput)
+	}
+
+	if publishedOutputs == nil {
+		return stateVersionOutputs
+	}
+
+	log.Default().Printf("publishing %d of %d outputs, which are named with the prefix %s", len(publishedOutputs), len(stateVersionOutputs), prefix)
+	return publishedOutputs
+}
+
+// ToRecordOutput maps a state version output to a Service Catalog record output. Strings are reported as is, other
+// values as JSON. Sensitive values are redacted, and values that are too long are replaced with a note, as neither can
+// be reported to Service Catalog.
+func ToRecordOutput(stateVersionOutput *tfe.StateVersionOutput) (types.RecordOutput, error) {
+	recordOutput := types.RecordOutput{
+		OutputKey: aws.String(stateVersionOutput.Name),
+	}
+
+	if stateVersionOutput.Sensitive {
+		recordOutput.OutputValue = aws.String(RedactedOutputValue)
+		recordOutput.Description = aws.String("The value is sensitive, and can be found in the state of the workspace in Terraform Cloud")
+		return recordOutput, nil
+	}
+
+	value, err := FormatOutputValue(stateVersionOutput.Value)
+	if err != nil {
+		return recordOutput, err
+	}
+
+	if len(value) > MaxOutputValueLength {
+		log.Default().Printf("value of output %s is %d characters long, which exceeds the maximum of %d characters, replacing it", stateVersionOutput.Name, len(value), MaxOutputValueLength)
+		recordOutput.OutputValue = aws.String(fmt.Sprintf("(value of %d characters is too long to be shown)", len(value)))
+		recordOutput.Description = aws.String("The value is too long to be shown, and can be found in the state of the workspace in Terraform Cloud")
+		return recordOutput, nil
+	}
+
+	recordOutput.OutputValue = aws.String(value)
+	return recordOutput, nil
+}
+
+// FormatOutputValue formats strings without quotes, and any other value, such as numbers, lists and maps, as JSON
+func FormatOutputValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	}
+
+	// Encode without escaping HTML characters, as the value is not shown in HTML
+	buffer := &bytes.Buffer{}
+	encoder := json.NewEncoder(buffer)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return "", err
+	}
+
+	return strings.TrimSuffix(buffer.String(), "\n"), nil
+}
@@ -0,0 +1,98 @@
+/*
+ * Copyright (c) HashiCorp, Inc.
+ * SPDX-License-Identifier: MPL-2.0
+ */
+
+package main
+
+import (
+	"github.com/hashicorp/go-tfe"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestFormatOutputValue(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    interface{}
+		expected string
+	}{
+		{name: "string", value: "hello <world>", expected: "hello <world>"},
+		{name: "number", value: 42.5, expected: "42.5"},
+		{name: "bool", value: true, expected: "true"},
+		{name: "null", value: nil, expected: ""},
+		{name: "list", value: []interface{}{"a", 1}, expected: `["a",1]`},
+		{name: "map with sorted keys", value: map[string]interface{}{"b": 2, "a": map[string]interface{}{"c": nil}}, expected: `{"a":{"c":null},"b":2}`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actual, err := FormatOutputValue(test.value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
+
+func TestToRecordOutput_Sensitive(t *testing.T) {
+	recordOutput, err := ToRecordOutput(&tfe.StateVersionOutput{
+		Name:      "password",
+		Sensitive: true,
+		Value:     "hunter2",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "password", *recordOutput.OutputKey)
+	assert.Equal(t, RedactedOutputValue, *recordOutput.OutputValue)
+	assert.NotContains(t, *recordOutput.Description, "hunter2")
+}
+
+func TestToRecordOutput_TooLong(t *testing.T) {
+	recordOutput, err := ToRecordOutput(&tfe.StateVersionOutput{
+		Name:  "novel",
+		Value: strings.Repeat("a", MaxOutputValueLength+1),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "novel", *recordOutput.OutputKey)
+	assert.Equal(t, "(value of 4097 characters is too long to be shown)", *recordOutput.OutputValue)
+	assert.NotNil(t, recordOutput.Description)
+}
+
+func TestSelectPublishedOutputs(t *testing.T) {
+	stateVersionOutputs := []*tfe.StateVersionOutput{
+		{Name: "sc_bucket_name", Value: "my-bucket"},
+		{Name: "internal_helper", Value: "42"},
+		{Name: "sc_", Value: "nameless"},
+		{Name: "sc_website_url", Value: "https://example.com"},
+	}
+
+	publishedOutputs := SelectPublishedOutputs(stateVersionOutputs, "sc_")
+
+	// Only the prefixed outputs are published, without the prefix
+	assert.Equal(t, 2, len(publishedOutputs))
+	assert.Equal(t, "bucket_name", publishedOutputs[0].Name)
+	assert.Equal(t, "my-bucket", publishedOutputs[0].Value)
+	assert.Equal(t, "website_url", publishedOutputs[1].Name)
+
+	// The state version outputs are left as they are
+	assert.Equal(t, "sc_bucket_name", stateVersionOutputs[0].Name)
+}
+
+func TestSelectPublishedOutputs_NoPrefixedOutputs(t *testing.T) {
+	stateVersionOutputs := []*tfe.StateVersionOutput{
+		{Name: "bucket_name", Value: "my-bucket"},
+		{Name: "internal_helper", Value: "42"},
+	}
+
+	// Products that do not use the prefix publish all their outputs
+	assert.Equal(t, stateVersionOutputs, SelectPublishedOutputs(stateVersionOutputs, "sc_"))
+	assert.Equal(t, stateVersionOutputs, SelectPublishedOutputs(stateVersionOutputs, ""))
+}
@@ -0,0 +1,95 @@
+/*
+ * Copyright (c) HashiCorp, Inc.
+ * SPDX-License-Identifier: MPL-2.0
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/servicecatalog/types"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/identifiers"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/model"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/tfc"
+	"github.com/hashicorp/go-tfe"
+	"log"
+)
+
+// RunUrlOutputKey is the key of the record output that links to the run in TFC
+const RunUrlOutputKey = "TerraformRunUrl"
+
+// RunDetails holds the details of a run that help users find out what happened to their provisioned product
+type RunDetails struct {
+	RunUrl         string
+	RunStatus      tfe.RunStatus
+	ResourceCounts *tfc.ResourceCounts
+}
+
+// GetRunDetails fetches the details of the run from the request. The details are only used to enrich the result sent
+// to Service Catalog, so nil is returned if they are not available rather than failing the notification.
+func GetRunDetails(ctx context.Context, client *tfe.Client, hostname string, request model.NotifyRunResultRequest) *RunDetails {
+	if request.TerraformRunId == "" {
+		return nil
+	}
+
+	run, err := client.Runs.Read(ctx, request.TerraformRunId)
+	if err != nil {
+		log.Default().Printf("failed to fetch run details, continuing without them: %v", err)
+		return nil
+	}
+
+	resourceCounts, err := tfc.GetPlanResourceCounts(ctx, client, run)
+	if err != nil {
+		log.Default().Printf("failed to fetch plan resource counts, continuing without them: %v", err)
+	}
+
+	workspaceName := identifiers.GetWorkspaceName(request.AwsAccountId, request.ProvisionedProductId)
+
+	return &RunDetails{
+		RunUrl:         tfc.GetRunUrl(hostname, request.TerraformOrganization, workspaceName, run.ID),
+		RunStatus:      run.Status,
+		ResourceCounts: resourceCounts,
+	}
+}
+
+// Summary describes the run in a single sentence, which is appended to failure reasons
+func (d *RunDetails) Summary() string {
+	if d == nil {
+		return ""
+	}
+
+	if d.ResourceCounts == nil {
+		return fmt.Sprintf("Run %s: %s", d.RunStatus, d.RunUrl)
+	}
+
+	return fmt.Sprintf("Run %s (plan: %d to add, %d to change, %d to destroy): %s",
+		d.RunStatus,
+		d.ResourceCounts.Additions,
+		d.ResourceCounts.Changes,
+		d.ResourceCounts.Destructions,
+		d.RunUrl,
+	)
+}
+
+// AppendRecordOutputs appends the record outputs that link the provisioned product's record to the run. The link is
+// left out if the workspace has an output with the same key, which is reported as it is.
+func (d *RunDetails) AppendRecordOutputs(outputs []types.RecordOutput) []types.RecordOutput {
+	if d == nil {
+		return outputs
+	}
+
+	for _, output := range outputs {
+		if aws.ToString(output.OutputKey) == RunUrlOutputKey {
+			log.Default().Printf("the workspace has an output named %s, leaving out the link to the run", RunUrlOutputKey)
+			return outputs
+		}
+	}
+
+	return append(outputs, types.RecordOutput{
+		OutputKey:   aws.String(RunUrlOutputKey),
+		OutputValue: aws.String(d.RunUrl),
+		Description: aws.String("Link to the Terraform run that provisioned this record"),
+	})
+}
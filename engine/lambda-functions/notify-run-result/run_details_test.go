@@ -0,0 +1,39 @@
+/*
+ * Copyright (c) HashiCorp, Inc.
+ * SPDX-License-Identifier: MPL-2.0
+ */
+
+package main
+
+import (
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/servicecatalog/types"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRunDetails_AppendRecordOutputs(t *testing.T) {
+	details := &RunDetails{RunUrl: "https://app.terraform.io/app/org/workspaces/ws/runs/run-forrest-run"}
+
+	t.Run("the link to the run is appended to the outputs of the workspace", func(t *testing.T) {
+		outputs := details.AppendRecordOutputs([]types.RecordOutput{{OutputKey: aws.String("bucket"), OutputValue: aws.String("my-bucket")}})
+
+		assert.Equal(t, 2, len(outputs))
+		assert.Equal(t, RunUrlOutputKey, aws.ToString(outputs[1].OutputKey))
+		assert.Equal(t, details.RunUrl, aws.ToString(outputs[1].OutputValue))
+	})
+
+	t.Run("the link to the run does not replace an output of the workspace with the same key", func(t *testing.T) {
+		outputs := details.AppendRecordOutputs([]types.RecordOutput{{OutputKey: aws.String(RunUrlOutputKey), OutputValue: aws.String("user-defined")}})
+
+		assert.Equal(t, 1, len(outputs))
+		assert.Equal(t, "user-defined", aws.ToString(outputs[0].OutputValue))
+	})
+
+	t.Run("no link is appended without run details", func(t *testing.T) {
+		var noDetails *RunDetails
+		outputs := noDetails.AppendRecordOutputs([]types.RecordOutput{{OutputKey: aws.String("bucket"), OutputValue: aws.String("my-bucket")}})
+
+		assert.Equal(t, 1, len(outputs))
+	})
+}
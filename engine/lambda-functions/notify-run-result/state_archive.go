@@ -0,0 +1,97 @@
+/*
+ * Copyright (c) HashiCorp, Inc.
+ * SPDX-License-Identifier: MPL-2.0
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/model"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/statearchive"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/tfc"
+	"github.com/hashicorp/go-tfe"
+	"log"
+)
+
+// ArchivedVariable is a workspace variable as it is archived. Values of sensitive variables are never archived.
+type ArchivedVariable struct {
+	Key         string           `json:"key"`
+	Value       string           `json:"value,omitempty"`
+	Description string           `json:"description,omitempty"`
+	Category    tfe.CategoryType `json:"category"`
+	HCL         bool             `json:"hcl"`
+	Sensitive   bool             `json:"sensitive"`
+}
+
+// ArchiveWorkspace archives the current state and the variables of the workspace before it is deleted
+func ArchiveWorkspace(ctx context.Context, client *tfe.Client, archive statearchive.StateArchive, request model.NotifyRunResultRequest, workspace *tfe.Workspace, rawState []byte) error {
+	if rawState != nil {
+		key := statearchive.Key(request.AwsAccountId, request.ProvisionedProductId, statearchive.StateObjectName)
+		log.Default().Printf("archiving state of workspace %s to %s", workspace.Name, key)
+		if err := archive.Put(ctx, key, rawState); err != nil {
+			return fmt.Errorf("failed to archive state of workspace %s: %w", workspace.Name, err)
+		}
+	}
+
+	variables, err := GetArchivedVariables(ctx, client, workspace, 0)
+	if err != nil {
+		return err
+	}
+
+	body, err := json.MarshalIndent(variables, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	key := statearchive.Key(request.AwsAccountId, request.ProvisionedProductId, statearchive.VariablesObjectName)
+	log.Default().Printf("archiving %d variables of workspace %s to %s", len(variables), workspace.Name, key)
+	if err := archive.Put(ctx, key, body); err != nil {
+		return fmt.Errorf("failed to archive variables of workspace %s: %w", workspace.Name, err)
+	}
+
+	return nil
+}
+
+func GetArchivedVariables(ctx context.Context, client *tfe.Client, workspace *tfe.Workspace, pageNumber int) ([]ArchivedVariable, error) {
+	variables, err := client.Variables.List(ctx, workspace.ID, &tfe.VariableListOptions{
+		ListOptions: tfe.ListOptions{
+			PageNumber: pageNumber,
+			PageSize:   100,
+		},
+	})
+	if err != nil {
+		return nil, tfc.Error(err)
+	}
+
+	archivedVariables := []ArchivedVariable{}
+	for _, variable := range variables.Items {
+		archivedVariable := ArchivedVariable{
+			Key:         variable.Key,
+			Description: variable.Description,
+			Category:    variable.Category,
+			HCL:         variable.HCL,
+			Sensitive:   variable.Sensitive,
+		}
+
+		// TFC does not return the values of sensitive variables, but make sure they never end up in the archive
+		if !variable.Sensitive {
+			archivedVariable.Value = variable.Value
+		}
+
+		archivedVariables = append(archivedVariables, archivedVariable)
+	}
+
+	// If more variables exists, fetch them as well
+	if variables.TotalCount > ((pageNumber + 1) * 100) {
+		nextPage, err := GetArchivedVariables(ctx, client, workspace, pageNumber+1)
+		if err != nil {
+			return nil, err
+		}
+		archivedVariables = append(archivedVariables, nextPage...)
+	}
+
+	return archivedVariables, nil
+}
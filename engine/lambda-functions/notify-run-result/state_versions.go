@@ -9,9 +9,9 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/servicecatalog/types"
 	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/identifiers"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/model"
 	"github.com/hashicorp/go-tfe"
 	"log"
 	"net/url"
@@ -20,7 +20,7 @@ import (
 	"sort"
 )
 
-func FetchRunOutputs(ctx context.Context, client *tfe.Client, request NotifyRunResultRequest) ([]types.RecordOutput, error) {
+func FetchRunOutputs(ctx context.Context, client *tfe.Client, request model.NotifyRunResultRequest, outputPrefix string) ([]types.RecordOutput, error) {
 	// Get workspace name
 	workspaceName := identifiers.GetWorkspaceName(request.AwsAccountId, request.ProvisionedProductId)
 	w, err := client.Workspaces.Read(ctx, request.TerraformOrganization, workspaceName)
@@ -53,11 +53,12 @@ func FetchRunOutputs(ctx context.Context, client *tfe.Client, request NotifyRunR
 
 	log.Default().Print("Mapping run outputs...")
 	var recordOutputs []types.RecordOutput
-	for _, stateVersionOutput := range stateVersionOutputs {
-		recordOutputs = append(recordOutputs, types.RecordOutput{
-			OutputKey:   aws.String(stateVersionOutput.Name),
-			OutputValue: aws.String(fmt.Sprintf("%v", stateVersionOutput.Value)),
-		})
+	for _, stateVersionOutput := range SelectPublishedOutputs(stateVersionOutputs, outputPrefix) {
+		recordOutput, err := ToRecordOutput(stateVersionOutput)
+		if err != nil {
+			return nil, fmt.Errorf("failed to format output %s: %w", stateVersionOutput.Name, err)
+		}
+		recordOutputs = append(recordOutputs, recordOutput)
 	}
 
 	// Map "State Version outputs" into "Service Catalog record outputs"
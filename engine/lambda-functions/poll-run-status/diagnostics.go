@@ -0,0 +1,274 @@
+/*
+ * Copyright (c) HashiCorp, Inc.
+ * SPDX-License-Identifier: MPL-2.0
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/servicecatalog"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/tfc"
+	"github.com/hashicorp/go-tfe"
+	"io"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxErrorMessageLength is the maximum failure reason length allowed by Service Catalog
+const MaxErrorMessageLength = 2048
+
+// maxLogSize limits how much of a log is read, diagnostics are printed near the end but a log is never this large
+const maxLogSize = 4 * 1024 * 1024
+
+// logReadTimeout limits how long reading a log may take, so polling never stalls on a log stream
+const logReadTimeout = 30 * time.Second
+
+var ansiEscapeSequence = regexp.MustCompile(`\x1b\[[0-9;]*[a-zA-Z]`)
+var diagnosticLocation = regexp.MustCompile(`^on (\S+) line (\d+)`)
+
+// Diagnostic is an error diagnostic reported by Terraform
+type Diagnostic struct {
+	Summary  string
+	Detail   string
+	Filename string
+	Line     int
+}
+
+func (d Diagnostic) String() string {
+	message := fmt.Sprintf("Error: %s", d.Summary)
+	if d.Filename != "" {
+		message = fmt.Sprintf("%s (%s line %d)", message, d.Filename, d.Line)
+	}
+	if d.Detail != "" {
+		message = fmt.Sprintf("%s: %s", message, d.Detail)
+	}
+	return message
+}
+
+// jsonLogLine is a line of the structured (JSON) log of a plan or apply
+type jsonLogLine struct {
+	Level      string `json:"@level"`
+	Type       string `json:"type"`
+	Diagnostic *struct {
+		Severity string `json:"severity"`
+		Summary  string `json:"summary"`
+		Detail   string `json:"detail"`
+		Range    *struct {
+			Filename string `json:"filename"`
+			Start    struct {
+				Line int `json:"line"`
+			} `json:"start"`
+		} `json:"range"`
+	} `json:"diagnostic"`
+}
+
+// GetRunDiagnostics fetches the log of the phase of the run that errored, and extracts the error diagnostics from it
+func GetRunDiagnostics(ctx context.Context, client *tfe.Client, run *tfe.Run) ([]Diagnostic, error) {
+	ctx, cancel := context.WithTimeout(ctx, logReadTimeout)
+	defer cancel()
+
+	logs, err := getErroredLogs(ctx, client, run)
+	if err != nil || logs == nil {
+		return nil, err
+	}
+
+	content, err := io.ReadAll(io.LimitReader(logs, maxLogSize))
+	if err != nil {
+		return nil, tfc.Error(err)
+	}
+
+	return ParseDiagnostics(string(content)), nil
+}
+
+func getErroredLogs(ctx context.Context, client *tfe.Client, run *tfe.Run) (io.Reader, error) {
+	// If the apply errored, the diagnostics are in the apply log
+	if run.Apply != nil && run.Apply.ID != "" {
+		apply, err := client.Applies.Read(ctx, run.Apply.ID)
+		if err != nil {
+			return nil, tfc.Error(err)
+		}
+		if apply.Status == tfe.ApplyErrored {
+			log.Default().Printf("fetching log of errored apply %s", apply.ID)
+			logs, err := client.Applies.Logs(ctx, apply.ID)
+			return logs, tfc.Error(err)
+		}
+	}
+
+	// Otherwise the run errored during the plan
+	if run.Plan != nil && run.Plan.ID != "" {
+		log.Default().Printf("fetching log of plan %s", run.Plan.ID)
+		logs, err := client.Plans.Logs(ctx, run.Plan.ID)
+		return logs, tfc.Error(err)
+	}
+
+	return nil, nil
+}
+
+// ParseDiagnostics extracts the error diagnostics from either a structured (JSON) log or a plaintext log
+func ParseDiagnostics(logs string) []Diagnostic {
+	logs = strings.NewReplacer("\x02", "", "\x03", "", "\r", "").Replace(logs)
+	lines := strings.Split(logs, "\n")
+
+	// Plaintext logs may contain JSON too, for example in the output of a provisioner, so the plaintext diagnostics are
+	// still looked for when no structured diagnostics were found
+	if diagnostics, structured := parseJSONDiagnostics(lines); structured && len(diagnostics) > 0 {
+		return diagnostics
+	}
+
+	return parsePlaintextDiagnostics(lines)
+}
+
+func parseJSONDiagnostics(lines []string) ([]Diagnostic, bool) {
+	var diagnostics []Diagnostic
+	structured := false
+
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "{") {
+			continue
+		}
+
+		// Only lines with the fields of the structured log are part of it, other JSON may be printed to any log
+		logLine := &jsonLogLine{}
+		if err := json.Unmarshal([]byte(line), logLine); err != nil || logLine.Level == "" || logLine.Type == "" {
+			continue
+		}
+		structured = true
+
+		if logLine.Type != "diagnostic" || logLine.Diagnostic == nil || logLine.Diagnostic.Severity != "error" {
+			continue
+		}
+
+		diagnostic := Diagnostic{
+			Summary: logLine.Diagnostic.Summary,
+			Detail:  strings.Join(strings.Fields(logLine.Diagnostic.Detail), " "),
+		}
+		if logLine.Diagnostic.Range != nil {
+			diagnostic.Filename = logLine.Diagnostic.Range.Filename
+			diagnostic.Line = logLine.Diagnostic.Range.Start.Line
+		}
+		diagnostics = append(diagnostics, diagnostic)
+	}
+
+	return diagnostics, structured
+}
+
+func parsePlaintextDiagnostics(lines []string) []Diagnostic {
+	var diagnostics []Diagnostic
+	var current *Diagnostic
+	var body []string
+
+	finish := func() {
+		if current != nil {
+			parseDiagnosticBody(current, body)
+			diagnostics = append(diagnostics, *current)
+		}
+		current = nil
+		body = nil
+	}
+
+	for _, line := range lines {
+		line = ansiEscapeSequence.ReplaceAllString(line, "")
+		trimmed := strings.TrimSpace(line)
+
+		// The end of a boxed diagnostic
+		if strings.HasPrefix(trimmed, "╵") {
+			finish()
+			continue
+		}
+
+		// Remove the box drawing around the diagnostic
+		line = strings.TrimPrefix(strings.TrimPrefix(trimmed, "╷"), "│")
+		trimmed = strings.TrimSpace(line)
+
+		switch {
+		case strings.HasPrefix(trimmed, "Error: "):
+			finish()
+			current = &Diagnostic{Summary: strings.TrimSpace(strings.TrimPrefix(trimmed, "Error: "))}
+		case strings.HasPrefix(trimmed, "Warning: "):
+			finish()
+		case current != nil:
+			body = append(body, line)
+		}
+	}
+	finish()
+
+	return diagnostics
+}
+
+// parseDiagnosticBody splits the body of a plaintext diagnostic into paragraphs. The paragraph starting with
+// "on <file> line <n>" holds the location and the source snippet, the other paragraphs hold the detail.
+func parseDiagnosticBody(diagnostic *Diagnostic, body []string) {
+	var details []string
+	var paragraph []string
+
+	flush := func() {
+		if len(paragraph) == 0 {
+			return
+		}
+		if match := diagnosticLocation.FindStringSubmatch(paragraph[0]); match != nil && diagnostic.Filename == "" {
+			diagnostic.Filename = match[1]
+			diagnostic.Line, _ = strconv.Atoi(match[2])
+		} else {
+			details = append(details, strings.Join(paragraph, " "))
+		}
+		paragraph = nil
+	}
+
+	for _, line := range body {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			flush()
+			continue
+		}
+		paragraph = append(paragraph, trimmed)
+	}
+	flush()
+
+	diagnostic.Detail = strings.Join(details, " ")
+}
+
+// FormatDiagnostics joins the diagnostics into a single message of at most maxLength characters. Diagnostics are only
+// ever dropped as a whole, unless the first diagnostic does not fit on its own.
+func FormatDiagnostics(diagnostics []Diagnostic, maxLength int) string {
+	// Room is kept for the note about omitted diagnostics
+	noteRoom := len(omittedNote(len(diagnostics)))
+
+	message := ""
+	for i, diagnostic := range diagnostics {
+		next := diagnostic.String()
+		if i > 0 {
+			next = "\n" + next
+		}
+
+		limit := maxLength
+		if i < len(diagnostics)-1 {
+			limit = maxLength - noteRoom
+		}
+
+		if len(message)+len(next) > limit {
+			included := i
+			if i == 0 {
+				message = servicecatalog.TruncateMessage(next, maxLength-noteRoom-3) + "..."
+				included = 1
+			}
+			if included == len(diagnostics) {
+				return message
+			}
+			return message + omittedNote(len(diagnostics)-included)
+		}
+		message += next
+	}
+
+	return message
+}
+
+func omittedNote(count int) string {
+	return fmt.Sprintf("\n(%d more errors omitted)", count)
+}
@@ -0,0 +1,148 @@
+/*
+ * Copyright (c) HashiCorp, Inc.
+ * SPDX-License-Identifier: MPL-2.0
+ */
+
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+const plaintextLog = "Terraform v1.4.6\n" +
+	"on linux_amd64\n" +
+	"\x1b[31m╷\x1b[0m\x1b[0m\n" +
+	"\x1b[31m│\x1b[0m \x1b[0m\x1b[1m\x1b[31mError: \x1b[0m\x1b[0m\x1b[1mInvalid value for variable\x1b[0m\n" +
+	"\x1b[31m│\x1b[0m \x1b[0m\n" +
+	"\x1b[31m│\x1b[0m \x1b[0m\x1b[0m  on variables.tf line 12:\n" +
+	"\x1b[31m│\x1b[0m \x1b[0m  12: variable \"bucket_name\" {\x1b[0m\n" +
+	"\x1b[31m│\x1b[0m \x1b[0m    \x1b[90m├────────────────\x1b[0m\n" +
+	"\x1b[31m│\x1b[0m \x1b[0m\x1b[0m    \x1b[90m│\x1b[0m var.bucket_name is \"Not_Valid\"\n" +
+	"\x1b[31m│\x1b[0m \x1b[0m\n" +
+	"\x1b[31m│\x1b[0m \x1b[0mBucket names must be lowercase.\n" +
+	"\x1b[31m│\x1b[0m \x1b[0mThis was checked by the validation rule.\n" +
+	"\x1b[31m╵\x1b[0m\x1b[0m\n" +
+	"\x1b[33m╷\x1b[0m\x1b[0m\n" +
+	"\x1b[33m│\x1b[0m \x1b[0m\x1b[1m\x1b[33mWarning: \x1b[0m\x1b[0m\x1b[1mDeprecated attribute\x1b[0m\n" +
+	"\x1b[33m╵\x1b[0m\x1b[0m\n" +
+	"\x1b[31m╷\x1b[0m\x1b[0m\n" +
+	"\x1b[31m│\x1b[0m \x1b[0m\x1b[1m\x1b[31mError: \x1b[0m\x1b[0m\x1b[1mNo valid credential sources found\x1b[0m\n" +
+	"\x1b[31m╵\x1b[0m\x1b[0m\n"
+
+const jsonLog = `{"@level":"info","@message":"Terraform 1.4.6","type":"version","terraform":"1.4.6"}
+{"@level":"warn","@message":"Warning: Deprecated attribute","type":"diagnostic","diagnostic":{"severity":"warning","summary":"Deprecated attribute","detail":""}}
+{"@level":"error","@message":"Error: Unsupported argument","type":"diagnostic","diagnostic":{"severity":"error","summary":"Unsupported argument","detail":"An argument named \"acl\" is not expected here.","range":{"filename":"main.tf","start":{"line":7,"column":3,"byte":112}}}}
+{"@level":"error","@message":"Error: creating S3 Bucket","type":"diagnostic","diagnostic":{"severity":"error","summary":"creating S3 Bucket","detail":"BucketAlreadyExists:\nThe requested bucket name is not available."}}
+`
+
+func TestParseDiagnostics(t *testing.T) {
+	t.Run("plaintext logs", func(t *testing.T) {
+		diagnostics := ParseDiagnostics(plaintextLog)
+
+		assert.Equal(t, []Diagnostic{
+			{
+				Summary:  "Invalid value for variable",
+				Detail:   "Bucket names must be lowercase. This was checked by the validation rule.",
+				Filename: "variables.tf",
+				Line:     12,
+			},
+			{
+				Summary: "No valid credential sources found",
+			},
+		}, diagnostics, "error diagnostics should have been extracted, without warnings and formatting")
+	})
+
+	t.Run("structured logs", func(t *testing.T) {
+		diagnostics := ParseDiagnostics("\x02" + jsonLog + "\x03")
+
+		assert.Equal(t, []Diagnostic{
+			{
+				Summary:  "Unsupported argument",
+				Detail:   "An argument named \"acl\" is not expected here.",
+				Filename: "main.tf",
+				Line:     7,
+			},
+			{
+				Summary: "creating S3 Bucket",
+				Detail:  "BucketAlreadyExists: The requested bucket name is not available.",
+			},
+		}, diagnostics, "error diagnostics should have been extracted, without warnings")
+	})
+
+	t.Run("plaintext logs with JSON output", func(t *testing.T) {
+		diagnostics := ParseDiagnostics("{\"bucket\": \"Not_Valid\", \"type\": \"diagnostic\"}\n" + plaintextLog)
+
+		assert.Equal(t, 2, len(diagnostics), "JSON output should not have been taken for a structured log")
+		assert.Equal(t, "Invalid value for variable", diagnostics[0].Summary)
+	})
+
+	t.Run("plaintext logs following structured logs without errors", func(t *testing.T) {
+		diagnostics := ParseDiagnostics(`{"@level":"info","@message":"Terraform 1.4.6","type":"version","terraform":"1.4.6"}` + "\n" + plaintextLog)
+
+		assert.Equal(t, 2, len(diagnostics), "plaintext diagnostics should have been extracted")
+		assert.Equal(t, "No valid credential sources found", diagnostics[1].Summary)
+	})
+
+	t.Run("logs without errors", func(t *testing.T) {
+		assert.Empty(t, ParseDiagnostics("Terraform v1.4.6\nApply complete! Resources: 1 added, 0 changed, 0 destroyed.\n"))
+	})
+}
+
+func TestFormatDiagnostics(t *testing.T) {
+	t.Run("diagnostics that fit are all included", func(t *testing.T) {
+		message := FormatDiagnostics([]Diagnostic{
+			{Summary: "Unsupported argument", Detail: "An argument named \"acl\" is not expected here.", Filename: "main.tf", Line: 7},
+			{Summary: "No valid credential sources found"},
+		}, MaxErrorMessageLength)
+
+		assert.Equal(t, "Error: Unsupported argument (main.tf line 7): An argument named \"acl\" is not expected here.\nError: No valid credential sources found", message)
+	})
+
+	t.Run("diagnostics that do not fit are omitted as a whole", func(t *testing.T) {
+		message := FormatDiagnostics([]Diagnostic{
+			{Summary: "First", Detail: strings.Repeat("a", 900)},
+			{Summary: "Second", Detail: strings.Repeat("b", 900)},
+			{Summary: "Third", Detail: strings.Repeat("c", 900)},
+		}, MaxErrorMessageLength)
+
+		assert.LessOrEqual(t, len(message), MaxErrorMessageLength)
+		assert.Contains(t, message, "Error: Second")
+		assert.NotContains(t, message, "Error: Third")
+		assert.True(t, strings.HasSuffix(message, "\n(1 more errors omitted)"))
+	})
+
+	t.Run("a single diagnostic that does not fit is truncated", func(t *testing.T) {
+		message := FormatDiagnostics([]Diagnostic{
+			{Summary: "Huge", Detail: strings.Repeat("a", 5000)},
+		}, MaxErrorMessageLength)
+
+		assert.LessOrEqual(t, len(message), MaxErrorMessageLength)
+		assert.True(t, strings.HasPrefix(message, "Error: Huge: aaa"))
+		assert.True(t, strings.HasSuffix(message, "..."))
+	})
+
+	t.Run("a first diagnostic that does not fit is truncated, and the others are omitted", func(t *testing.T) {
+		message := FormatDiagnostics([]Diagnostic{
+			{Summary: "Huge", Detail: strings.Repeat("a", 5000)},
+			{Summary: "Second"},
+			{Summary: "Third"},
+		}, MaxErrorMessageLength)
+
+		assert.LessOrEqual(t, len(message), MaxErrorMessageLength)
+		assert.True(t, strings.HasPrefix(message, "Error: Huge: aaa"))
+		assert.True(t, strings.HasSuffix(message, "...\n(2 more errors omitted)"))
+	})
+
+	t.Run("a single diagnostic is truncated without splitting multibyte characters", func(t *testing.T) {
+		message := FormatDiagnostics([]Diagnostic{
+			{Summary: "Huge", Detail: strings.Repeat("é", 5000)},
+		}, MaxErrorMessageLength)
+
+		assert.LessOrEqual(t, len(message), MaxErrorMessageLength)
+		assert.True(t, utf8.ValidString(message), "truncated message should be valid UTF-8")
+		assert.True(t, strings.HasSuffix(message, "é..."))
+	})
+}
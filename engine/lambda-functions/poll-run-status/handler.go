@@ -7,29 +7,42 @@ package main
 
 import (
 	"context"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/identifiers"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/model"
 	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/secretsmanager"
 	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/tfc"
 	"github.com/hashicorp/go-tfe"
 	"log"
+	"time"
 )
 
 type PollRunStatusHandler struct {
 	secretsManager secretsmanager.SecretsManager
 }
 
-type PollRunStatus struct {
-	TerraformRunId string `json:"terraformRunId"`
-}
-
 type PollRunStatusResponse struct {
-	ProductProvisioningStatus string        `json:"productProvisioningStatus"`
-	RunStatus                 tfe.RunStatus `json:"runStatus"`
-	ErrorMessage              string        `json:"errorMessage"`
+	ProductProvisioningStatus string              `json:"productProvisioningStatus"`
+	RunStatus                 tfe.RunStatus       `json:"runStatus"`
+	ErrorMessage              string              `json:"errorMessage"`
+	RunUrl                    string              `json:"runUrl"`
+	Phase                     tfc.RunPhase        `json:"phase"`
+	ResourceCounts            *tfc.ResourceCounts `json:"resourceCounts"`
+	QueuePosition             int                 `json:"queuePosition"`
+	ElapsedSeconds            int64               `json:"elapsedSeconds"`
 }
 
-func (h *PollRunStatusHandler) HandleRequest(ctx context.Context, request PollRunStatus) (*PollRunStatusResponse, error) {
-	// Get TFE Client
-	tfeClient, err := tfc.GetTFEClient(ctx, h.secretsManager)
+func (h *PollRunStatusHandler) HandleRequest(ctx context.Context, request model.PollRunStatusRequest) (*PollRunStatusResponse, error) {
+	if err := request.Validate(); err != nil {
+		return nil, err
+	}
+
+	// Get TFE Client, along with the TFE credentials, the hostname is needed to build the link to the run
+	organizationSecretsManager, err := secretsmanager.ForOrganization(h.secretsManager, request.TerraformOrganization)
+	if err != nil {
+		log.Printf("failed to find the TFE credentials: %s", err)
+		return nil, err
+	}
+	tfeClient, tfeCredentialsSecret, err := tfc.GetTFEClientAndCredentials(ctx, organizationSecretsManager)
 	if err != nil {
 		log.Printf("failed to initialize TFE client: %s", err)
 		return nil, err
@@ -42,7 +55,53 @@ func (h *PollRunStatusHandler) HandleRequest(ctx context.Context, request PollRu
 	}
 
 	// Respond with the appropriate status so the AWS Step Functions state machine will know what the next step is
-	return RespondWithRunStatus(run.Status)
+	response, err := RespondWithRunStatus(run.Status)
+	if err != nil {
+		return nil, err
+	}
+
+	// Runs that are not auto-applied wait for confirmation once they finished planning
+	isConfirmable := !run.AutoApply && run.Actions != nil && run.Actions.IsConfirmable
+	if isConfirmable {
+		response = awaitingApproval(run.Status)
+	}
+
+	// Add the details of the run, so that the progress of the run can be followed
+	response.Phase = tfc.GetRunPhase(run.Status)
+	if isConfirmable {
+		response.Phase = tfc.RunPhaseAwaitingDecision
+	}
+	if run.Status == tfe.RunPending {
+		response.QueuePosition = run.PositionInQueue
+	}
+	if !run.CreatedAt.IsZero() {
+		response.ElapsedSeconds = int64(time.Since(run.CreatedAt).Seconds())
+	}
+	if request.TerraformOrganization != "" && request.AwsAccountId != "" && request.ProvisionedProductId != "" {
+		workspaceName := identifiers.GetWorkspaceName(request.AwsAccountId, request.ProvisionedProductId)
+		response.RunUrl = tfc.GetRunUrl(tfeCredentialsSecret.Hostname, request.TerraformOrganization, workspaceName, run.ID)
+	}
+
+	// The plan only has its final resource counts once the run completed or awaits a decision. The counts are only
+	// details, so the run status is still reported if they cannot be fetched.
+	if tfc.IsRunSettled(run) {
+		response.ResourceCounts, err = tfc.GetPlanResourceCounts(ctx, tfeClient, run)
+		if err != nil {
+			log.Printf("failed to fetch plan resource counts, continuing without them: %s", err)
+		}
+	}
+
+	// For errored runs, replace the generic error message with the diagnostics that Terraform reported
+	if run.Status == tfe.RunErrored {
+		diagnostics, err := GetRunDiagnostics(ctx, tfeClient, run)
+		if err != nil {
+			log.Printf("failed to fetch diagnostics of errored run, falling back to generic error message: %s", err)
+		} else if len(diagnostics) > 0 {
+			response.ErrorMessage = FormatDiagnostics(diagnostics, MaxErrorMessageLength)
+		}
+	}
+
+	return response, nil
 }
 
 func RespondWithRunStatus(runStatus tfe.RunStatus) (*PollRunStatusResponse, error) {
@@ -58,7 +117,7 @@ func RespondWithRunStatus(runStatus tfe.RunStatus) (*PollRunStatusResponse, erro
 	case runStatus == tfe.RunPlannedAndFinished:
 		return success(runStatus), nil
 	case runStatus == tfe.RunPostPlanAwaitingDecision:
-		return awaitingDecision(runStatus), nil
+		return awaitingApproval(runStatus), nil
 	default:
 		return inProgress(runStatus), nil
 	}
@@ -80,11 +139,11 @@ func inProgress(runStatus tfe.RunStatus) *PollRunStatusResponse {
 	}
 }
 
-func awaitingDecision(runStatus tfe.RunStatus) *PollRunStatusResponse {
+func awaitingApproval(runStatus tfe.RunStatus) *PollRunStatusResponse {
 	return &PollRunStatusResponse{
-		ProductProvisioningStatus: "failed",
+		ProductProvisioningStatus: "awaitingApproval",
 		RunStatus:                 runStatus,
-		ErrorMessage:              "Run requires approval in TFC. Approve the run in TFC, then update the example-product in Service Catalog to clear the error.",
+		ErrorMessage:              "",
 	}
 }
 
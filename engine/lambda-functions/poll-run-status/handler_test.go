@@ -7,11 +7,16 @@ package main
 
 import (
 	"context"
+	"fmt"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/model"
 	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/testutil/secretsmanager"
 	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/testutil/testtfc"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/tfc"
 	"github.com/hashicorp/go-tfe"
 	"github.com/stretchr/testify/assert"
+	"net/http"
 	"testing"
+	"time"
 )
 
 func TestPollRunStatusHandler_Success(t *testing.T) {
@@ -36,7 +41,7 @@ func TestPollRunStatusHandler_Success(t *testing.T) {
 		tfcServer.AddRun("run-421337pending", testtfc.RunFactoryParameters{RunStatus: tfe.RunPending})
 
 		// Create a test request
-		testRequest := PollRunStatus{
+		testRequest := model.PollRunStatusRequest{
 			TerraformRunId: "run-421337pending",
 		}
 
@@ -57,7 +62,7 @@ func TestPollRunStatusHandler_Success(t *testing.T) {
 		tfcServer.AddRun("run-421337applied", testtfc.RunFactoryParameters{RunStatus: tfe.RunApplied})
 
 		// Create a test request
-		testRequest := PollRunStatus{
+		testRequest := model.PollRunStatusRequest{
 			TerraformRunId: "run-421337applied",
 		}
 
@@ -78,7 +83,7 @@ func TestPollRunStatusHandler_Success(t *testing.T) {
 		tfcServer.AddRun("run-421337canceled", testtfc.RunFactoryParameters{RunStatus: tfe.RunCanceled})
 
 		// Create a test request
-		testRequest := PollRunStatus{
+		testRequest := model.PollRunStatusRequest{
 			TerraformRunId: "run-421337canceled",
 		}
 
@@ -99,7 +104,7 @@ func TestPollRunStatusHandler_Success(t *testing.T) {
 		tfcServer.AddRun("run-421337discarded", testtfc.RunFactoryParameters{RunStatus: tfe.RunDiscarded})
 
 		// Create a test request
-		testRequest := PollRunStatus{
+		testRequest := model.PollRunStatusRequest{
 			TerraformRunId: "run-421337discarded",
 		}
 
@@ -120,7 +125,7 @@ func TestPollRunStatusHandler_Success(t *testing.T) {
 		tfcServer.AddRun("run-421337errored", testtfc.RunFactoryParameters{RunStatus: tfe.RunErrored})
 
 		// Create a test request
-		testRequest := PollRunStatus{
+		testRequest := model.PollRunStatusRequest{
 			TerraformRunId: "run-421337errored",
 		}
 
@@ -135,6 +140,110 @@ func TestPollRunStatusHandler_Success(t *testing.T) {
 		assert.Equal(t, tfe.RunErrored, response.RunStatus, "correct run status should be returned")
 		assert.Equal(t, "Failed running terraform apply", response.ErrorMessage, "error should be present in response")
 	})
+
+	t.Run("runs awaiting a decision are evaluated as awaitingApproval", func(t *testing.T) {
+		// Add a mock Run to the mock TFC server
+		tfcServer.AddRun("run-421337awaitingdecision", testtfc.RunFactoryParameters{RunStatus: tfe.RunPostPlanAwaitingDecision})
+
+		// Create a test request
+		testRequest := model.PollRunStatusRequest{
+			TerraformRunId: "run-421337awaitingdecision",
+		}
+
+		// Send the test request to the test instance
+		response, err := testHandler.HandleRequest(context.TODO(), testRequest)
+		if err != nil {
+			t.Error(err)
+		}
+
+		// Check the Lambda response
+		assert.Equal(t, "awaitingApproval", response.ProductProvisioningStatus, "product provisioning status should have been correctly evaluated")
+		assert.Equal(t, tfc.RunPhaseAwaitingDecision, response.Phase, "run should be awaiting a decision")
+		assert.Empty(t, response.ErrorMessage, "no error should be present in response")
+	})
+
+	t.Run("planned runs that are not auto-applied are evaluated as awaitingApproval", func(t *testing.T) {
+		// Add a mock Run to the mock TFC server
+		tfcServer.AddRun("run-421337planned", testtfc.RunFactoryParameters{
+			RunStatus: tfe.RunPlanned,
+			Actions:   &tfe.RunActions{IsConfirmable: true, IsDiscardable: true},
+		})
+
+		// Create a test request
+		testRequest := model.PollRunStatusRequest{
+			TerraformRunId: "run-421337planned",
+		}
+
+		// Send the test request to the test instance
+		response, err := testHandler.HandleRequest(context.TODO(), testRequest)
+		if err != nil {
+			t.Error(err)
+		}
+
+		// Check the Lambda response
+		assert.Equal(t, "awaitingApproval", response.ProductProvisioningStatus, "product provisioning status should have been correctly evaluated")
+		assert.Equal(t, tfe.RunPlanned, response.RunStatus, "correct run status should be returned")
+		assert.Equal(t, tfc.RunPhaseAwaitingDecision, response.Phase, "run should be awaiting a decision")
+	})
+
+	t.Run("runs that errored while planning report the diagnostics of the plan", func(t *testing.T) {
+		// Add a mock Plan with logs and a Run to the mock TFC server
+		testPlan := tfcServer.AddPlan(&tfe.Plan{
+			Status:     tfe.PlanErrored,
+			LogReadURL: tfcServer.AddLogs(jsonLog),
+		})
+		tfcServer.AddRun("run-421337planerrored", testtfc.RunFactoryParameters{
+			RunStatus: tfe.RunErrored,
+			Plan:      testPlan,
+		})
+
+		// Create a test request
+		testRequest := model.PollRunStatusRequest{
+			TerraformRunId: "run-421337planerrored",
+		}
+
+		// Send the test request to the test instance
+		response, err := testHandler.HandleRequest(context.TODO(), testRequest)
+		if err != nil {
+			t.Error(err)
+		}
+
+		// Check the Lambda response
+		assert.Equal(t, "failed", response.ProductProvisioningStatus, "product provisioning status should have been correctly evaluated")
+		assert.Equal(t, "Error: Unsupported argument (main.tf line 7): An argument named \"acl\" is not expected here.\nError: creating S3 Bucket: BucketAlreadyExists: The requested bucket name is not available.", response.ErrorMessage, "diagnostics should be present in response")
+	})
+
+	t.Run("runs that errored while applying report the diagnostics of the apply", func(t *testing.T) {
+		// Add a mock Plan, Apply and Run to the mock TFC server
+		testPlan := tfcServer.AddPlan(&tfe.Plan{
+			Status:     tfe.PlanFinished,
+			LogReadURL: tfcServer.AddLogs("Plan: 1 to add, 0 to change, 0 to destroy."),
+		})
+		testApply := tfcServer.AddApply(&tfe.Apply{
+			Status:     tfe.ApplyErrored,
+			LogReadURL: tfcServer.AddLogs(plaintextLog),
+		})
+		tfcServer.AddRun("run-421337applyerrored", testtfc.RunFactoryParameters{
+			RunStatus: tfe.RunErrored,
+			Plan:      testPlan,
+			Apply:     testApply,
+		})
+
+		// Create a test request
+		testRequest := model.PollRunStatusRequest{
+			TerraformRunId: "run-421337applyerrored",
+		}
+
+		// Send the test request to the test instance
+		response, err := testHandler.HandleRequest(context.TODO(), testRequest)
+		if err != nil {
+			t.Error(err)
+		}
+
+		// Check the Lambda response
+		assert.Equal(t, "failed", response.ProductProvisioningStatus, "product provisioning status should have been correctly evaluated")
+		assert.Equal(t, "Error: Invalid value for variable (variables.tf line 12): Bucket names must be lowercase. This was checked by the validation rule.\nError: No valid credential sources found", response.ErrorMessage, "diagnostics should be present in response")
+	})
 }
 
 func TestPollRunStatusHandler_InvalidTFCToken(t *testing.T) {
@@ -160,7 +269,7 @@ func TestPollRunStatusHandler_InvalidTFCToken(t *testing.T) {
 	tfcServer.AddRun("run-everything-is-fine", testtfc.RunFactoryParameters{RunStatus: tfe.RunApplied})
 
 	// Create a test request
-	testRequest := PollRunStatus{
+	testRequest := model.PollRunStatusRequest{
 		TerraformRunId: "run-everything-is-fine",
 	}
 
@@ -196,7 +305,7 @@ func TestPollRunStatusHandler_CannotConnect(t *testing.T) {
 	}
 
 	// Create a test request
-	testRequest := PollRunStatus{
+	testRequest := model.PollRunStatusRequest{
 		TerraformRunId: "run-everything-is-fine",
 	}
 
@@ -208,6 +317,134 @@ func TestPollRunStatusHandler_CannotConnect(t *testing.T) {
 
 }
 
+func TestPollRunStatusHandler_RunDetails(t *testing.T) {
+	// Create mock TFC instance
+	tfcServer := testtfc.NewMockTFC()
+	defer tfcServer.Stop()
+
+	// Create the TFE client that will send requests to the mock TFC instance
+	mockSecretsManager := &secretsmanager.MockSecretsManager{
+		Hostname: tfcServer.Address,
+		TeamId:   "team-4123nlol",
+		Token:    "supers3cret",
+	}
+
+	// Create a test instance of the Lambda function
+	testHandler := &PollRunStatusHandler{
+		secretsManager: mockSecretsManager,
+	}
+
+	t.Run("pending runs report their position in the queue", func(t *testing.T) {
+		// Add a mock Run to the mock TFC server
+		tfcServer.AddRun("run-421337queued", testtfc.RunFactoryParameters{
+			RunStatus:       tfe.RunPending,
+			PositionInQueue: 3,
+			CreatedAt:       time.Now().Add(-time.Minute * 2),
+		})
+
+		// Create a test request
+		testRequest := model.PollRunStatusRequest{
+			TerraformRunId:        "run-421337queued",
+			AwsAccountId:          "123456789042",
+			TerraformOrganization: tfcServer.OrganizationName,
+			ProvisionedProductId:  "pp-amazing",
+		}
+
+		// Send the test request to the test instance
+		response, err := testHandler.HandleRequest(context.TODO(), testRequest)
+		if err != nil {
+			t.Error(err)
+		}
+
+		// Check the Lambda response
+		assert.Equal(t, tfc.RunPhasePending, response.Phase, "run should be in the pending phase")
+		assert.Equal(t, 3, response.QueuePosition, "position in the queue should be returned")
+		assert.GreaterOrEqual(t, response.ElapsedSeconds, int64(120), "elapsed time should be measured from the creation of the run")
+		assert.Nil(t, response.ResourceCounts, "no resource counts should be returned before the run has a plan")
+		assert.Equal(t, fmt.Sprintf("%s/app/%s/workspaces/123456789042-pp-amazing/runs/run-421337queued", tfcServer.Address, tfcServer.OrganizationName), response.RunUrl, "link to the run should be returned")
+	})
+
+	t.Run("applying runs do not report the resource counts of their plan yet", func(t *testing.T) {
+		// Add a mock Plan and Run to the mock TFC server
+		testPlan := tfcServer.AddPlan(&tfe.Plan{
+			ResourceAdditions:    7,
+			ResourceChanges:      1,
+			ResourceDestructions: 2,
+			Status:               tfe.PlanFinished,
+		})
+		tfcServer.AddRun("run-421337applying", testtfc.RunFactoryParameters{
+			RunStatus:       tfe.RunApplying,
+			Plan:            testPlan,
+			PositionInQueue: 1,
+		})
+
+		// Create a test request, without the details required to link to the run
+		testRequest := model.PollRunStatusRequest{
+			TerraformRunId: "run-421337applying",
+		}
+
+		// Send the test request to the test instance
+		response, err := testHandler.HandleRequest(context.TODO(), testRequest)
+		if err != nil {
+			t.Error(err)
+		}
+
+		// Check the Lambda response
+		assert.Equal(t, "inProgress", response.ProductProvisioningStatus, "product provisioning status should have been correctly evaluated")
+		assert.Equal(t, tfc.RunPhaseApplying, response.Phase, "run should be in the applying phase")
+		assert.Equal(t, 0, response.QueuePosition, "position in the queue should only be returned for pending runs")
+		assert.Nil(t, response.ResourceCounts, "resource counts should only be returned once the run settled")
+		assert.Empty(t, response.RunUrl, "link to the run cannot be built without the workspace details")
+	})
+
+	t.Run("applied runs report the resource counts of their plan", func(t *testing.T) {
+		// Add a mock Plan and Run to the mock TFC server
+		testPlan := tfcServer.AddPlan(&tfe.Plan{
+			ResourceAdditions:    7,
+			ResourceChanges:      1,
+			ResourceDestructions: 2,
+			Status:               tfe.PlanFinished,
+		})
+		tfcServer.AddRun("run-421337applied", testtfc.RunFactoryParameters{
+			RunStatus: tfe.RunApplied,
+			Plan:      testPlan,
+		})
+
+		// Send the test request to the test instance
+		response, err := testHandler.HandleRequest(context.TODO(), model.PollRunStatusRequest{TerraformRunId: "run-421337applied"})
+		if err != nil {
+			t.Error(err)
+		}
+
+		// Check the Lambda response
+		assert.Equal(t, "success", response.ProductProvisioningStatus, "product provisioning status should have been correctly evaluated")
+		assert.Equal(t, tfc.RunPhaseCompleted, response.Phase, "run should be in the completed phase")
+		assert.Equal(t, &tfc.ResourceCounts{Additions: 7, Changes: 1, Destructions: 2}, response.ResourceCounts, "resource counts of the plan should be returned")
+	})
+
+	t.Run("runs are still reported when the resource counts of their plan cannot be fetched", func(t *testing.T) {
+		// Add a mock Plan and Run to the mock TFC server, and make reading the plan fail
+		testPlan := tfcServer.AddPlan(&tfe.Plan{Status: tfe.PlanFinished})
+		tfcServer.AddRun("run-421337noplan", testtfc.RunFactoryParameters{
+			RunStatus: tfe.RunApplied,
+			Plan:      testPlan,
+		})
+		tfcServer.MockRequest(func(r *http.Request) bool {
+			return r.Method == http.MethodGet && r.URL.Path == fmt.Sprintf("/api/v2/plans/%s", testPlan.ID)
+		}, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(404)
+		})
+
+		// Send the test request to the test instance
+		response, err := testHandler.HandleRequest(context.TODO(), model.PollRunStatusRequest{TerraformRunId: "run-421337noplan"})
+		assert.NoError(t, err)
+
+		// Check the Lambda response
+		assert.Equal(t, "success", response.ProductProvisioningStatus, "product provisioning status should have been correctly evaluated")
+		assert.Nil(t, response.ResourceCounts, "no resource counts should be returned when the plan cannot be read")
+	})
+}
+
 func TestPollRunStatusHandler_RetriesFailures(t *testing.T) {
 	// Create mock TFC instance
 	tfcServer := testtfc.NewMockTFC()
@@ -228,7 +465,7 @@ func TestPollRunStatusHandler_RetriesFailures(t *testing.T) {
 	tfcServer.AddRun("run-everything-is-fine", testtfc.RunFactoryParameters{RunStatus: tfe.RunApplied})
 
 	// Create a test request
-	testRequest := PollRunStatus{
+	testRequest := model.PollRunStatusRequest{
 		TerraformRunId: "run-everything-is-fine",
 	}
 
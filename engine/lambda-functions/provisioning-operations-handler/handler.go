@@ -8,17 +8,27 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/sfn"
+	"github.com/aws/aws-sdk-go-v2/service/sfn/types"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/model"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/servicecatalog"
 	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/stepfunctions"
 	"log"
 )
 
+// ErrConflictingExecution is returned when the execution a request is started as already exists for a different request
+var ErrConflictingExecution = errors.New("state machine execution already exists for a different request")
+
 type ProvisioningOperationsHandler struct {
 	terraformOrganization string
 	stepFunctions         stepfunctions.StepFunctions
 	stateMachineArn       string
+	// Routes to the organizations other than the default organization, and the portfolios to route by
+	organizationRoutes []OrganizationRoute
+	portfolios         servicecatalog.PortfolioLister
 }
 
 func (h *ProvisioningOperationsHandler) HandleRequest(ctx context.Context, request ProvisioningOperationsHandlerRequest) (*ProvisioningOperationsHandlerResponse, error) {
@@ -39,12 +49,24 @@ func (h *ProvisioningOperationsHandler) HandleRequest(ctx context.Context, reque
 func (h *ProvisioningOperationsHandler) StartStateMachineExecution(ctx context.Context, record Record) error {
 	log.Default().Printf("Deserializing event from SQS: %s", record.Body)
 
-	stateMachinePayload := &StateMachinePayload{}
+	stateMachinePayload := &model.ProvisioningRequest{}
 	if err := json.Unmarshal([]byte(record.Body), stateMachinePayload); err != nil {
 		return err
 	}
 
-	stateMachinePayload.TerraformOrganization = h.terraformOrganization
+	// Reject malformed requests before they start an execution that fails halfway through
+	if err := stateMachinePayload.Validate(); err != nil {
+		log.Default().Printf("Rejecting invalid request for record %s: %s", stateMachinePayload.RecordId, err.Error())
+		return err
+	}
+
+	terraformOrganization, err := h.RouteOrganization(ctx, stateMachinePayload)
+	if err != nil {
+		return err
+	}
+
+	stateMachinePayload.SchemaVersion = model.SchemaVersion
+	stateMachinePayload.TerraformOrganization = terraformOrganization
 	modifiedPayload, err := json.Marshal(stateMachinePayload)
 	if err != nil {
 		return err
@@ -56,6 +78,11 @@ func (h *ProvisioningOperationsHandler) StartStateMachineExecution(ctx context.C
 		Input:           aws.String(string(modifiedPayload)),
 		Name:            &executionName,
 	})
+	var alreadyExists *types.ExecutionAlreadyExists
+	if errors.As(err, &alreadyExists) {
+		// SQS redelivered a message whose execution was already started
+		return h.CheckExistingExecution(ctx, stepfunctions.ExecutionArn(h.stateMachineArn, executionName), stateMachinePayload)
+	}
 	if err != nil {
 		return err
 	}
@@ -64,3 +91,32 @@ func (h *ProvisioningOperationsHandler) StartStateMachineExecution(ctx context.C
 
 	return nil
 }
+
+// CheckExistingExecution succeeds if the execution that already exists was started for the same request, so redelivered
+// messages are not reported as failures. An execution for a different request means two different requests were given
+// the same name, which is reported as a failure.
+func (h *ProvisioningOperationsHandler) CheckExistingExecution(ctx context.Context, executionArn string, request *model.ProvisioningRequest) error {
+	execution, err := h.stepFunctions.DescribeExecution(ctx, executionArn)
+	if err != nil {
+		return fmt.Errorf("failed to describe existing state machine execution %s: %w", executionArn, err)
+	}
+
+	existingRequest := &model.ProvisioningRequest{}
+	if err := json.Unmarshal([]byte(aws.ToString(execution.Input)), existingRequest); err != nil {
+		return err
+	}
+	if !sameRequest(existingRequest, request) {
+		log.Default().Printf("Rejecting request, state machine execution %s already exists for a different request", executionArn)
+		return fmt.Errorf("%w: %s", ErrConflictingExecution, executionArn)
+	}
+
+	log.Default().Printf("State machine execution %s already exists for the same request, the message was redelivered", executionArn)
+	return nil
+}
+
+// sameRequest returns whether both requests were sent by Service Catalog for the same record. Only fields of the
+// original message are compared, the organization a request is routed to and the schema version are added by the
+// handler and may differ between deliveries.
+func sameRequest(a *model.ProvisioningRequest, b *model.ProvisioningRequest) bool {
+	return a.RecordId == b.RecordId && a.Operation == b.Operation && a.ProvisionedProductId == b.ProvisionedProductId
+}
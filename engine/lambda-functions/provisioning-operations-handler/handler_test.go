@@ -8,6 +8,8 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/model"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/testutil/servicecatalog"
 	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/testutil/stepfunction"
 	"github.com/stretchr/testify/assert"
 	"testing"
@@ -26,10 +28,11 @@ func TestProvisioningOperationsHandler_Success(t *testing.T) {
 	}
 
 	// Create test request
-	testPayload := StateMachinePayload{
+	testPayload := model.ProvisioningRequest{
 		Token:                "tolkien",
 		ProvisionedProductId: "the-best-product-id",
 		RecordId:             "the-best-record-id",
+		Identity:             model.Identity{AwsAccountId: "123456789042"},
 	}
 	testPayloadJson, err := json.Marshal(testPayload)
 	if err != nil {
@@ -50,7 +53,7 @@ func TestProvisioningOperationsHandler_Success(t *testing.T) {
 		t.Error(err)
 	}
 
-	stateMachinePayload := &StateMachinePayload{}
+	stateMachinePayload := &model.ProvisioningRequest{}
 	if err := json.Unmarshal([]byte(mockStepFunctions.StateMachinePayload), &stateMachinePayload); err != nil {
 		t.Fatal(err)
 	}
@@ -59,6 +62,98 @@ func TestProvisioningOperationsHandler_Success(t *testing.T) {
 
 	// Verify Terraform Organization was set
 	assert.Equal(t, "the-best-org", stateMachinePayload.TerraformOrganization, "terraformOrganization was set")
+
+	// Verify the schema version was set
+	assert.Equal(t, model.SchemaVersion, stateMachinePayload.SchemaVersion, "schemaVersion was set")
+}
+
+func TestProvisioningOperationsHandler_SuccessPreservesTracerTag(t *testing.T) {
+
+	// Create mock StepFunctions facade
+	mockStepFunctions := &stepfunction.MockStepFunctionsWithSuccessfulResponse{}
+
+	// Create a test instance of the Lambda function
+	testHandler := &ProvisioningOperationsHandler{
+		terraformOrganization: "the-best-org",
+		stepFunctions:         mockStepFunctions,
+		stateMachineArn:       "arn:::such-a-great-state-machine/like/wow",
+	}
+
+	// Create test request, as Service Catalog sends it
+	testRequest := ProvisioningOperationsHandlerRequest{
+		Records: []Record{{
+			MessageId: "the-best-msg-id",
+			Body:      `{"token":"tolkien","provisionedProductId":"the-best-product-id","recordId":"the-best-record-id","identity":{"awsAccountId":"123456789042"},"tracerTag":{"key":"the-best-key","value":"the-best-value"}}`,
+		}},
+	}
+
+	// Send the test request
+	response, err := testHandler.HandleRequest(context.Background(), testRequest)
+	if err != nil {
+		t.Error(err)
+	}
+	assert.Empty(t, response.BatchItemFailures, "No failures should be returned")
+
+	// Verify the tracer tag was passed on to the state machine as it was sent
+	assert.JSONEq(t, `{"key":"the-best-key","value":"the-best-value"}`, rawField(t, mockStepFunctions.StateMachinePayload, "tracerTag"))
+}
+
+func TestProvisioningOperationsHandler_FailureInvalidRequest(t *testing.T) {
+
+	// Create mock StepFunctions facade
+	mockStepFunctions := &stepfunction.MockStepFunctionsWithSuccessfulResponse{}
+
+	// Create a test instance of the Lambda function
+	testHandler := &ProvisioningOperationsHandler{
+		terraformOrganization: "the-best-org",
+		stepFunctions:         mockStepFunctions,
+		stateMachineArn:       "arn:::such-a-great-state-machine/like/wow",
+	}
+
+	// Create test request, without a record ID, from an account ID that is not valid, and with an artifact outside S3
+	testPayload := model.ProvisioningRequest{
+		Token:                "tolkien",
+		ProvisionedProductId: "the-best-product-id",
+		Identity:             model.Identity{AwsAccountId: "not-an-account"},
+		Artifact:             model.Artifact{Path: "https://example.com/artifact.tar.gz", Type: model.DefaultArtifactType},
+	}
+	testPayloadJson, err := json.Marshal(testPayload)
+	if err != nil {
+		t.Error(err)
+	}
+
+	record := Record{
+		MessageId: "the-best-msg-id",
+		Body:      string(testPayloadJson),
+	}
+
+	// Verify each invalid field is reported
+	err = testHandler.StartStateMachineExecution(context.Background(), record)
+	var validationErr model.ValidationError
+	if assert.ErrorAs(t, err, &validationErr) {
+		assert.Equal(t, []model.FieldError{
+			{Field: "recordId", Message: "is required and must be non empty"},
+			{Field: "identity.awsAccountId", Message: "not-an-account is not a 12 digit AWS account ID"},
+			{Field: "artifact.path", Message: "https://example.com/artifact.tar.gz is not a valid S3 URI"},
+		}, validationErr.Fields)
+	}
+
+	// Verify the record is reported as a failure, without starting an execution
+	response, err := testHandler.HandleRequest(context.Background(), ProvisioningOperationsHandlerRequest{Records: []Record{record}})
+	if err != nil {
+		t.Error(err)
+	}
+	assert.Equal(t, []BatchItemFailure{{ItemIdentifier: "the-best-msg-id"}}, response.BatchItemFailures, "Expected a failure")
+	assert.Empty(t, mockStepFunctions.Executions, "No execution should be started")
+}
+
+// rawField returns the JSON of a field of a JSON object
+func rawField(t *testing.T, object string, field string) string {
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal([]byte(object), &fields); err != nil {
+		t.Fatal(err)
+	}
+	return string(fields[field])
 }
 
 func TestProvisioningOperationsHandler_Failure(t *testing.T) {
@@ -72,10 +167,11 @@ func TestProvisioningOperationsHandler_Failure(t *testing.T) {
 	}
 
 	// Create test request
-	testPayload := StateMachinePayload{
+	testPayload := model.ProvisioningRequest{
 		Token:                "tolkien",
 		ProvisionedProductId: "the-best-product-id",
 		RecordId:             "the-best-record-id",
+		Identity:             model.Identity{AwsAccountId: "123456789042"},
 	}
 	testPayloadJson, err := json.Marshal(testPayload)
 	if err != nil {
@@ -113,10 +209,11 @@ func TestProvisioningOperationsHandler_StateMachinePayload(t *testing.T) {
 	}
 
 	// Create test request
-	testPayload := StateMachinePayload{
+	testPayload := model.ProvisioningRequest{
 		Token:                "tolkien",
 		ProvisionedProductId: "the-best-product-id",
 		RecordId:             "the-best-record-id",
+		Identity:             model.Identity{AwsAccountId: "123456789042"},
 	}
 	testPayloadJson, err := json.Marshal(testPayload)
 	if err != nil {
@@ -142,3 +239,198 @@ func TestProvisioningOperationsHandler_StateMachinePayload(t *testing.T) {
 	}}
 	assert.Equal(t, expectedFailures, response.BatchItemFailures, "Expected a failure")
 }
+
+func TestProvisioningOperationsHandler_SuccessRoutingOrganizations(t *testing.T) {
+	// Create mock Service Catalog portfolios
+	mockPortfolios := &servicecatalog.MockPortfolioLister{
+		PortfolioIds: map[string][]string{
+			"prod-in-portfolio":     {"port-other", "port-business-unit"},
+			"prod-routed-by-itself": {"port-business-unit"},
+		},
+	}
+
+	// Create a test instance of the Lambda function
+	testHandler := &ProvisioningOperationsHandler{
+		terraformOrganization: "the-best-org",
+		stateMachineArn:       "arn:::such-a-great-state-machine/like/wow",
+		portfolios:            mockPortfolios,
+		organizationRoutes: []OrganizationRoute{
+			{Organization: "business-unit-org", PortfolioIds: []string{"port-business-unit"}, AccountIds: []string{"123456789042"}},
+			{Organization: "tfe-org", ProductIds: []string{"prod-routed-by-itself"}},
+		},
+	}
+
+	testCases := []struct {
+		productId            string
+		awsAccountId         string
+		expectedOrganization string
+	}{
+		{"prod-routed-by-itself", "123456789042", "tfe-org"},
+		{"prod-in-portfolio", "210987654321", "business-unit-org"},
+		{"prod-without-portfolio", "123456789042", "business-unit-org"},
+		{"prod-without-portfolio", "210987654321", "the-best-org"},
+	}
+
+	for _, testCase := range testCases {
+		// Create test request
+		testPayload := model.ProvisioningRequest{
+			Token:                "tolkien",
+			ProductId:            testCase.productId,
+			ProvisionedProductId: "the-best-product-id",
+			RecordId:             "the-best-record-id",
+		}
+		testPayload.Identity.AwsAccountId = testCase.awsAccountId
+		testPayloadJson, err := json.Marshal(testPayload)
+		if err != nil {
+			t.Error(err)
+		}
+
+		// Send the test request
+		mockStepFunctions := &stepfunction.MockStepFunctionsWithSuccessfulResponse{}
+		testHandler.stepFunctions = mockStepFunctions
+		response, err := testHandler.HandleRequest(context.Background(), ProvisioningOperationsHandlerRequest{
+			Records: []Record{{MessageId: "the-best-msg-id", Body: string(testPayloadJson)}},
+		})
+		assert.NoError(t, err)
+		assert.Empty(t, response.BatchItemFailures, "No failures should be returned")
+
+		// Verify the request was routed to the organization
+		stateMachinePayload := &model.ProvisioningRequest{}
+		if err := json.Unmarshal([]byte(mockStepFunctions.StateMachinePayload), &stateMachinePayload); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, testCase.expectedOrganization, stateMachinePayload.TerraformOrganization, "product %s in account %s", testCase.productId, testCase.awsAccountId)
+	}
+
+	// Verify the portfolios were not listed for the product routed by itself
+	assert.Equal(t, 3, mockPortfolios.Calls)
+}
+
+func TestProvisioningOperationsHandler_SuccessRedelivered(t *testing.T) {
+
+	// Create mock StepFunctions facade
+	mockStepFunctions := &stepfunction.MockStepFunctionsWithSuccessfulResponse{}
+
+	// Create a test instance of the Lambda function
+	testHandler := &ProvisioningOperationsHandler{
+		terraformOrganization: "the-best-org",
+		stepFunctions:         mockStepFunctions,
+		stateMachineArn:       "arn:aws:states:us-east-1:123456789101:stateMachine:such-a-great-state-machine",
+	}
+
+	// Create test request
+	testPayloadJson, err := json.Marshal(model.ProvisioningRequest{
+		Token:                "tolkien",
+		ProvisionedProductId: "the-best-product-id",
+		RecordId:             "the-best-record-id",
+		Identity:             model.Identity{AwsAccountId: "123456789042"},
+	})
+	if err != nil {
+		t.Error(err)
+	}
+
+	testRequest := ProvisioningOperationsHandlerRequest{
+		Records: []Record{{
+			MessageId: "the-best-msg-id",
+			Body:      string(testPayloadJson),
+		}},
+	}
+
+	// Send the test request, then send it again as SQS would redeliver it
+	for i := 0; i < 2; i++ {
+		response, err := testHandler.HandleRequest(context.Background(), testRequest)
+		if err != nil {
+			t.Error(err)
+		}
+
+		// Verify the redelivered message is not reported as a failure
+		assert.Empty(t, response.BatchItemFailures, "No failures should be returned")
+	}
+
+	// Verify a single execution was started
+	assert.Len(t, mockStepFunctions.Executions, 1)
+	assert.Contains(t, mockStepFunctions.Executions, "arn:aws:states:us-east-1:123456789101:execution:such-a-great-state-machine:the-best-product-id-the-best-record-id")
+}
+
+func TestProvisioningOperationsHandler_SuccessRedeliveredAfterRoutingChanged(t *testing.T) {
+
+	// Create mock StepFunctions facade, with an execution started for the request before the routing of organizations
+	// changed
+	mockStepFunctions := &stepfunction.MockStepFunctionsWithSuccessfulResponse{
+		Executions: map[string]string{
+			"arn:aws:states:us-east-1:123456789101:execution:such-a-great-state-machine:the-best-product-id-the-best-record-id": `{"schemaVersion":1,"token":"tolkien","operation":"PROVISIONING","provisionedProductId":"the-best-product-id","recordId":"the-best-record-id","terraformOrganization":"the-previous-org"}`,
+		},
+	}
+
+	// Create a test instance of the Lambda function
+	testHandler := &ProvisioningOperationsHandler{
+		terraformOrganization: "the-best-org",
+		stepFunctions:         mockStepFunctions,
+		stateMachineArn:       "arn:aws:states:us-east-1:123456789101:stateMachine:such-a-great-state-machine",
+	}
+
+	// Create test request
+	testPayloadJson, err := json.Marshal(model.ProvisioningRequest{
+		Token:                "tolkien",
+		Operation:            "PROVISIONING",
+		ProvisionedProductId: "the-best-product-id",
+		RecordId:             "the-best-record-id",
+		Identity:             model.Identity{AwsAccountId: "123456789042"},
+	})
+	if err != nil {
+		t.Error(err)
+	}
+
+	// Verify the redelivered message is not reported as a failure, although it is routed to another organization now
+	err = testHandler.StartStateMachineExecution(context.Background(), Record{
+		MessageId: "the-best-msg-id",
+		Body:      string(testPayloadJson),
+	})
+	assert.NoError(t, err)
+	assert.Contains(t, mockStepFunctions.Executions["arn:aws:states:us-east-1:123456789101:execution:such-a-great-state-machine:the-best-product-id-the-best-record-id"], "the-previous-org", "No other execution should have been started")
+}
+
+func TestProvisioningOperationsHandler_FailureConflictingExecution(t *testing.T) {
+
+	// Create mock StepFunctions facade, with an execution started for another request with the same name
+	mockStepFunctions := &stepfunction.MockStepFunctionsWithSuccessfulResponse{
+		Executions: map[string]string{
+			"arn:aws:states:us-east-1:123456789101:execution:such-a-great-state-machine:the-best-product-id-the-best-record-id": `{"token":"another-token","operation":"UPDATING","provisionedProductId":"the-best-product-id","recordId":"the-best-record-id"}`,
+		},
+	}
+
+	// Create a test instance of the Lambda function
+	testHandler := &ProvisioningOperationsHandler{
+		terraformOrganization: "the-best-org",
+		stepFunctions:         mockStepFunctions,
+		stateMachineArn:       "arn:aws:states:us-east-1:123456789101:stateMachine:such-a-great-state-machine",
+	}
+
+	// Create test request
+	testPayloadJson, err := json.Marshal(model.ProvisioningRequest{
+		Token:                "tolkien",
+		Operation:            "PROVISIONING",
+		ProvisionedProductId: "the-best-product-id",
+		RecordId:             "the-best-record-id",
+		Identity:             model.Identity{AwsAccountId: "123456789042"},
+	})
+	if err != nil {
+		t.Error(err)
+	}
+
+	record := Record{
+		MessageId: "the-best-msg-id",
+		Body:      string(testPayloadJson),
+	}
+
+	// Verify the conflicting execution is reported as such
+	err = testHandler.StartStateMachineExecution(context.Background(), record)
+	assert.ErrorIs(t, err, ErrConflictingExecution)
+
+	// Verify the record is reported as a failure
+	response, err := testHandler.HandleRequest(context.Background(), ProvisioningOperationsHandlerRequest{Records: []Record{record}})
+	if err != nil {
+		t.Error(err)
+	}
+	assert.Equal(t, []BatchItemFailure{{ItemIdentifier: "the-best-msg-id"}}, response.BatchItemFailures, "Expected a failure")
+}
@@ -8,9 +8,11 @@ package main
 import (
 	"context"
 	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/servicecatalog"
 	"github.com/aws/aws-sdk-go-v2/service/sfn"
 	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/awsconfig"
 	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/stepfunctions"
+	"log"
 	"os"
 )
 
@@ -23,39 +25,6 @@ type Record struct {
 	Body      string `json:"body"`
 }
 
-type StateMachinePayload struct {
-	Token                  string `json:"token"`
-	Operation              string `json:"operation"`
-	ProductId              string `json:"productId"`
-	ProvisionedProductId   string `json:"provisionedProductId"`
-	ProvisionedProductName string `json:"provisionedProductName"`
-	ProvisionedArtifactId  string `json:"provisioningArtifactId"`
-	RecordId               string `json:"recordId"`
-	LaunchRoleArn          string `json:"launchRoleArn"`
-	TerraformOrganization  string `json:"terraformOrganization"`
-	Identity               struct {
-		Principal      string `json:"principal"`
-		AwsAccountId   string `json:"awsAccountId"`
-		OrganizationId string `json:"organizationId"`
-	} `json:"identity"`
-	TracerTag struct {
-		Key   string `json:"token"`
-		Value string `json:"operation"`
-	} `json:"tracerTag"`
-	Artifact struct {
-		Path string `json:"path"`
-		Type string `json:"type"`
-	} `json:"artifact"`
-	Tags []struct {
-		Key   string `json:"key"`
-		Value string `json:"value"`
-	} `json:"tags"`
-	Parameters []struct {
-		Key   string `json:"key"`
-		Value string `json:"value"`
-	} `json:"parameters"`
-}
-
 type ProvisioningOperationsHandlerResponse struct {
 	BatchItemFailures []BatchItemFailure `json:"batchItemFailures"`
 }
@@ -77,6 +46,12 @@ func main() {
 	// Get Terraform Organization
 	terraformOrganization := os.Getenv("TERRAFORM_ORGANIZATION")
 
+	// Get the routes to the other organizations
+	organizationRoutes, err := ParseOrganizationRoutes(os.Getenv("ORGANIZATION_ROUTES"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	// Get state machine arn
 	stateMachineArn := os.Getenv("STATE_MACHINE_ARN")
 
@@ -84,6 +59,8 @@ func main() {
 		terraformOrganization: terraformOrganization,
 		stepFunctions:         stepfunctions.SFN{Client: sfnClient},
 		stateMachineArn:       stateMachineArn,
+		organizationRoutes:    organizationRoutes,
+		portfolios:            servicecatalog.NewFromConfig(sdkConfig),
 	}
 
 	lambda.Start(handler.HandleRequest)
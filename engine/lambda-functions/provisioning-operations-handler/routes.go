@@ -0,0 +1,87 @@
+/*
+ * Copyright (c) HashiCorp, Inc.
+ * SPDX-License-Identifier: MPL-2.0
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/model"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/servicecatalog"
+	"log"
+	"slices"
+)
+
+// OrganizationRoute sends the requests for the listed products, the products of the listed portfolios, and the
+// products provisioned in the listed accounts to the organization
+type OrganizationRoute struct {
+	Organization string   `json:"organization"`
+	ProductIds   []string `json:"productIds,omitempty"`
+	PortfolioIds []string `json:"portfolioIds,omitempty"`
+	AccountIds   []string `json:"accountIds,omitempty"`
+}
+
+// ParseOrganizationRoutes parses the routes from the ORGANIZATION_ROUTES env var, there are none if it is empty
+func ParseOrganizationRoutes(routes string) ([]OrganizationRoute, error) {
+	if routes == "" {
+		return nil, nil
+	}
+
+	var organizationRoutes []OrganizationRoute
+	if err := json.Unmarshal([]byte(routes), &organizationRoutes); err != nil {
+		return nil, fmt.Errorf("failed to parse ORGANIZATION_ROUTES: %w", err)
+	}
+	return organizationRoutes, nil
+}
+
+// RouteOrganization chooses the organization the request is provisioned in. Routes by product take precedence over
+// routes by portfolio, which take precedence over routes by account. Requests without a route are provisioned in the
+// default organization.
+func (h *ProvisioningOperationsHandler) RouteOrganization(ctx context.Context, payload *model.ProvisioningRequest) (string, error) {
+	for _, route := range h.organizationRoutes {
+		if slices.Contains(route.ProductIds, payload.ProductId) {
+			return h.routeTo(payload, route, "product"), nil
+		}
+	}
+
+	if h.routesByPortfolio() {
+		// The request does not name the portfolio it was launched from, so the product is looked up in all portfolios
+		portfolioIds, err := servicecatalog.ListPortfolioIdsForProduct(ctx, h.portfolios, payload.ProductId)
+		if err != nil {
+			return "", fmt.Errorf("failed to list the portfolios of product %s: %w", payload.ProductId, err)
+		}
+
+		for _, route := range h.organizationRoutes {
+			for _, portfolioId := range portfolioIds {
+				if slices.Contains(route.PortfolioIds, portfolioId) {
+					return h.routeTo(payload, route, "portfolio"), nil
+				}
+			}
+		}
+	}
+
+	for _, route := range h.organizationRoutes {
+		if slices.Contains(route.AccountIds, payload.Identity.AwsAccountId) {
+			return h.routeTo(payload, route, "account"), nil
+		}
+	}
+
+	return h.terraformOrganization, nil
+}
+
+func (h *ProvisioningOperationsHandler) routesByPortfolio() bool {
+	for _, route := range h.organizationRoutes {
+		if len(route.PortfolioIds) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *ProvisioningOperationsHandler) routeTo(payload *model.ProvisioningRequest, route OrganizationRoute, routedBy string) string {
+	log.Default().Printf("routing provisioned product %s to organization %s by %s", payload.ProvisionedProductId, route.Organization, routedBy)
+	return route.Organization
+}
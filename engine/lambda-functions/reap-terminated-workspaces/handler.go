@@ -0,0 +1,134 @@
+/*
+ * Copyright (c) HashiCorp, Inc.
+ * SPDX-License-Identifier: MPL-2.0
+ */
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/identifiers"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/secretsmanager"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/tfc"
+	"github.com/hashicorp/go-tfe"
+	"log"
+	"time"
+)
+
+// ReapTerminatedWorkspacesHandler deletes the workspaces of terminated provisioned products once their retention
+// period has passed
+type ReapTerminatedWorkspacesHandler struct {
+	secretsManager secretsmanager.SecretsManager
+	organization   string
+	retention      time.Duration
+	now            func() time.Time
+}
+
+func (h *ReapTerminatedWorkspacesHandler) HandleRequest(ctx context.Context, request ReapTerminatedWorkspacesRequest) (*ReapTerminatedWorkspacesResponse, error) {
+	organizations, err := secretsmanager.Organizations(h.secretsManager, h.organization)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &ReapTerminatedWorkspacesResponse{DeletedWorkspaces: []string{}}
+	var errs []error
+	for _, organization := range organizations {
+		if err := h.reapOrganization(ctx, organization, response); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	// Fail the invocation if any workspace could not be deleted, so it shows up in the metrics of the function
+	if len(errs) > 0 {
+		return response, errors.Join(errs...)
+	}
+
+	return response, nil
+}
+
+// reapOrganization deletes the workspaces of the organization whose retention period has passed. One organization
+// failing does not keep the workspaces of the other organizations from being deleted.
+func (h *ReapTerminatedWorkspacesHandler) reapOrganization(ctx context.Context, organization secretsmanager.Organization, response *ReapTerminatedWorkspacesResponse) error {
+	tfeClient, err := tfc.GetTFEClient(ctx, organization.SecretsManager)
+	if err != nil {
+		log.Default().Printf("failed to initialize TFE client for organization %s: %s", organization.Name, err)
+		return err
+	}
+
+	workspaces, err := listTerminatedWorkspaces(ctx, tfeClient, organization.Name)
+	if err != nil {
+		log.Default().Printf("failed to list the terminated workspaces of organization %s: %s", organization.Name, err)
+		return err
+	}
+
+	var errs []error
+	for _, workspace := range workspaces {
+		terminatedAt, found := tfc.ParseTerminatedAt(workspace.TagNames)
+		if !found {
+			log.Default().Printf("workspace %s is tagged as terminated, but not with the time of termination, skipping it", workspace.Name)
+			continue
+		}
+
+		if h.now().Sub(terminatedAt) < h.retention {
+			response.RetainedWorkspaces++
+			continue
+		}
+
+		if err := deleteWorkspace(ctx, tfeClient, workspace); err != nil {
+			log.Default().Printf("failed to delete workspace %s: %s", workspace.Name, err)
+			errs = append(errs, fmt.Errorf("failed to delete workspace %s: %w", workspace.Name, err))
+			continue
+		}
+
+		log.Default().Printf("deleted workspace %s of organization %s, which was terminated at %s", workspace.Name, organization.Name, terminatedAt.UTC().Format(time.RFC3339))
+		response.DeletedWorkspaces = append(response.DeletedWorkspaces, workspace.Name)
+	}
+
+	return errors.Join(errs...)
+}
+
+// listTerminatedWorkspaces lists the workspaces of terminated provisioned products in the organization
+func listTerminatedWorkspaces(ctx context.Context, client *tfe.Client, organization string) ([]*tfe.Workspace, error) {
+	var workspaces []*tfe.Workspace
+
+	pageNumber := 1
+	for {
+		page, err := client.Workspaces.List(ctx, organization, &tfe.WorkspaceListOptions{
+			ListOptions: tfe.ListOptions{
+				PageNumber: pageNumber,
+				PageSize:   100,
+			},
+			Tags: tfc.TerminatedTag,
+		})
+		if err != nil {
+			return nil, tfc.Error(err)
+		}
+
+		// Only the workspaces of provisioned products are reaped, other workspaces may carry the same tags
+		for _, workspace := range page.Items {
+			if _, _, ok := identifiers.ParseWorkspaceName(workspace.Name); ok {
+				workspaces = append(workspaces, workspace)
+			} else {
+				log.Default().Printf("workspace %s is tagged as terminated, but was not created by the engine, skipping it", workspace.Name)
+			}
+		}
+
+		if page.Pagination == nil || page.NextPage == 0 {
+			return workspaces, nil
+		}
+		pageNumber = page.NextPage
+	}
+}
+
+// deleteWorkspace unlocks and safe-deletes the workspace, so TFC refuses to delete it if it still manages resources
+func deleteWorkspace(ctx context.Context, client *tfe.Client, workspace *tfe.Workspace) error {
+	if workspace.Locked {
+		if _, err := client.Workspaces.Unlock(ctx, workspace.ID); err != nil {
+			return tfc.Error(err)
+		}
+	}
+
+	return tfc.Error(client.Workspaces.SafeDeleteByID(ctx, workspace.ID))
+}
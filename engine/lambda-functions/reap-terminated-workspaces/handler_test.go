@@ -0,0 +1,162 @@
+/*
+ * Copyright (c) HashiCorp, Inc.
+ * SPDX-License-Identifier: MPL-2.0
+ */
+
+package main
+
+import (
+	"context"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/testutil/secretsmanager"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/testutil/testtfc"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/tfc"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReapTerminatedWorkspacesHandler_Success(t *testing.T) {
+	// Create mock TFC instance
+	tfcServer := testtfc.NewMockTFC()
+	defer tfcServer.Stop()
+
+	now := time.Now()
+
+	// Add workspaces terminated long ago and recently, and a workspace that is still in use
+	expired := tfcServer.AddWorkspace("123456789042-expired-product-instance", testtfc.WorkspaceFactoryParameters{})
+	expired.Locked = true
+	expired.TagNames = []string{tfc.TerminatedTag, tfc.TerminatedAtTag(now.Add(-8 * 24 * time.Hour))}
+
+	recent := tfcServer.AddWorkspace("123456789042-recent-product-instance", testtfc.WorkspaceFactoryParameters{})
+	recent.Locked = true
+	recent.TagNames = []string{tfc.TerminatedTag, tfc.TerminatedAtTag(now.Add(-1 * 24 * time.Hour))}
+
+	tfcServer.AddWorkspace("123456789042-active-product-instance", testtfc.WorkspaceFactoryParameters{})
+
+	// Add a workspace the engine does not manage, which happens to carry the same tags
+	unmanaged := tfcServer.AddWorkspace("legacy-network", testtfc.WorkspaceFactoryParameters{})
+	unmanaged.TagNames = []string{tfc.TerminatedTag, tfc.TerminatedAtTag(now.Add(-30 * 24 * time.Hour))}
+
+	// Create the TFE client that will send requests to the mock TFC instance
+	mockSecretsManager := &secretsmanager.MockSecretsManager{
+		Hostname: tfcServer.Address,
+		TeamId:   "team-4123nlol",
+		Token:    "supers3cret",
+	}
+
+	// Create a test instance of the Lambda function, which retains workspaces for a week
+	testHandler := &ReapTerminatedWorkspacesHandler{
+		secretsManager: mockSecretsManager,
+		organization:   tfcServer.OrganizationName,
+		retention:      7 * 24 * time.Hour,
+		now:            func() time.Time { return now },
+	}
+
+	// Send the test request
+	response, err := testHandler.HandleRequest(context.Background(), ReapTerminatedWorkspacesRequest{})
+	assert.NoError(t, err)
+
+	// Verify only the workspace past the retention period was deleted
+	assert.Equal(t, []string{"123456789042-expired-product-instance"}, response.DeletedWorkspaces)
+	assert.Equal(t, 1, response.RetainedWorkspaces)
+	assert.Equal(t, 3, len(tfcServer.Workspaces))
+	assert.Nil(t, tfcServer.Workspaces[expired.ID], "The expired workspace should have been deleted")
+	assert.NotNil(t, tfcServer.Workspaces[recent.ID], "The recently terminated workspace should have been retained")
+	assert.NotNil(t, tfcServer.Workspaces[unmanaged.ID], "The workspace the engine does not manage should have been left alone")
+}
+
+func TestReapTerminatedWorkspacesHandler_DeleteFails(t *testing.T) {
+	// Create mock TFC instance
+	tfcServer := testtfc.NewMockTFC()
+	defer tfcServer.Stop()
+
+	now := time.Now()
+
+	// Add two workspaces past the retention period
+	for _, id := range []string{"123456789042-stubborn-product-instance", "123456789042-expired-product-instance"} {
+		workspace := tfcServer.AddWorkspace(id, testtfc.WorkspaceFactoryParameters{})
+		workspace.TagNames = []string{tfc.TerminatedTag, tfc.TerminatedAtTag(now.Add(-30 * 24 * time.Hour))}
+	}
+
+	// Make TFC refuse to delete one of them, as if it still had resources
+	tfcServer.MockRequest(func(r *http.Request) bool {
+		return strings.HasSuffix(r.URL.Path, "/workspaces/123456789042-stubborn-product-instance/actions/safe-delete")
+	}, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(409)
+		w.Write([]byte(`{"errors":[{"status":"409","title":"conflict","detail":"Workspace is not safe to delete"}]}`))
+	})
+
+	// Create the TFE client that will send requests to the mock TFC instance
+	mockSecretsManager := &secretsmanager.MockSecretsManager{
+		Hostname: tfcServer.Address,
+		TeamId:   "team-4123nlol",
+		Token:    "supers3cret",
+	}
+
+	// Create a test instance of the Lambda function
+	testHandler := &ReapTerminatedWorkspacesHandler{
+		secretsManager: mockSecretsManager,
+		organization:   tfcServer.OrganizationName,
+		retention:      7 * 24 * time.Hour,
+		now:            func() time.Time { return now },
+	}
+
+	// Send the test request
+	response, err := testHandler.HandleRequest(context.Background(), ReapTerminatedWorkspacesRequest{})
+
+	// Verify the other workspace was still deleted, and the failure was reported
+	assert.ErrorContains(t, err, "123456789042-stubborn-product-instance")
+	assert.Equal(t, []string{"123456789042-expired-product-instance"}, response.DeletedWorkspaces)
+	assert.Equal(t, 1, len(tfcServer.Workspaces))
+}
+
+func TestReapTerminatedWorkspacesHandler_SuccessMultipleOrganizations(t *testing.T) {
+	// Create mock TFC instances for the default organization and an organization with its own credentials
+	tfcServer := testtfc.NewMockTFC()
+	defer tfcServer.Stop()
+
+	otherTfcServer := testtfc.NewMockTFC()
+	defer otherTfcServer.Stop()
+	otherTfcServer.OrganizationName = "business-unit-on-tfe"
+
+	now := time.Now()
+
+	// Add a workspace past the retention period to each organization
+	for _, server := range []*testtfc.MockTFC{tfcServer, otherTfcServer} {
+		workspace := server.AddWorkspace("123456789042-expired-product-instance", testtfc.WorkspaceFactoryParameters{})
+		workspace.TagNames = []string{tfc.TerminatedTag, tfc.TerminatedAtTag(now.Add(-8 * 24 * time.Hour))}
+	}
+
+	// Create the TFE clients that will send requests to the mock TFC instances
+	mockSecretsManager := &secretsmanager.MockSecretsManager{
+		Hostname: tfcServer.Address,
+		TeamId:   "team-4123nlol",
+		Token:    "supers3cret",
+		Organizations: map[string]*secretsmanager.MockSecretsManager{
+			"business-unit-on-tfe": {
+				Hostname: otherTfcServer.Address,
+				TeamId:   "team-otherteam",
+				Token:    "othersupers3cret",
+			},
+		},
+	}
+
+	// Create a test instance of the Lambda function
+	testHandler := &ReapTerminatedWorkspacesHandler{
+		secretsManager: mockSecretsManager,
+		organization:   tfcServer.OrganizationName,
+		retention:      7 * 24 * time.Hour,
+		now:            func() time.Time { return now },
+	}
+
+	// Send the test request
+	response, err := testHandler.HandleRequest(context.Background(), ReapTerminatedWorkspacesRequest{})
+	assert.NoError(t, err)
+
+	// Verify the workspaces of both organizations were deleted
+	assert.Equal(t, 2, len(response.DeletedWorkspaces))
+	assert.Empty(t, tfcServer.Workspaces)
+	assert.Empty(t, otherTfcServer.Workspaces)
+}
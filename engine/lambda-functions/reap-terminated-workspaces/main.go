@@ -0,0 +1,51 @@
+/*
+ * Copyright (c) HashiCorp, Inc.
+ * SPDX-License-Identifier: MPL-2.0
+ */
+
+package main
+
+import (
+	"context"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/awsconfig"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/secretsmanager"
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+type ReapTerminatedWorkspacesRequest struct{}
+
+type ReapTerminatedWorkspacesResponse struct {
+	DeletedWorkspaces  []string `json:"deletedWorkspaces"`
+	RetainedWorkspaces int      `json:"retainedWorkspaces"`
+}
+
+func main() {
+	// Create temporary context to initialize the handler with
+	initContext := context.TODO()
+
+	sdkConfig := awsconfig.GetSdkConfig(initContext)
+
+	// Create secrets client SDK to fetch TFE credentials
+	secretsManager, err := secretsmanager.NewWithConfig(initContext, sdkConfig)
+	if err != nil {
+		log.Fatalf("failed to initialize secrets manager client: %s", err)
+	}
+
+	retentionInDays, err := strconv.Atoi(os.Getenv("TERMINATED_WORKSPACE_RETENTION_IN_DAYS"))
+	if err != nil {
+		log.Fatalf("failed to parse TERMINATED_WORKSPACE_RETENTION_IN_DAYS: %s", err)
+	}
+
+	handler := ReapTerminatedWorkspacesHandler{
+		secretsManager: secretsManager,
+		organization:   os.Getenv("TERRAFORM_ORGANIZATION"),
+		retention:      time.Duration(retentionInDays) * 24 * time.Hour,
+		now:            time.Now,
+	}
+
+	lambda.Start(handler.HandleRequest)
+}
@@ -0,0 +1,222 @@
+/*
+ * Copyright (c) HashiCorp, Inc.
+ * SPDX-License-Identifier: MPL-2.0
+ */
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/servicecatalog/types"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/identifiers"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/secretsmanager"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/servicecatalog"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/tfc"
+	"github.com/hashicorp/go-tfe"
+	"log"
+	"sort"
+	"time"
+)
+
+// ReconcileWorkspacesHandler cross-checks the workspaces of provisioned products with Service Catalog. Executions that
+// fail partway through leave behind workspaces without a provisioned product, and provisioned products without a
+// workspace; both are reported, and the orphaned workspaces that do not manage any resources can be deleted.
+type ReconcileWorkspacesHandler struct {
+	secretsManager  secretsmanager.SecretsManager
+	organization    string
+	engineAccountId string
+
+	// scannerForAccount returns the scanner for the provisioned products of the account, or nil if the account cannot
+	// be scanned
+	scannerForAccount func(ctx context.Context, awsAccountId string) (servicecatalog.ProvisionedProductScanner, error)
+
+	// gracePeriod is how long a workspace must exist before it is deleted as an orphan, so workspaces of provisioned
+	// products that are still being created are left alone
+	gracePeriod time.Duration
+	now         func() time.Time
+}
+
+// organizationWorkspace is the workspace of a provisioned product, along with its organization and the client of the
+// organization
+type organizationWorkspace struct {
+	*tfe.Workspace
+	organization string
+	client       *tfe.Client
+}
+
+func (h *ReconcileWorkspacesHandler) HandleRequest(ctx context.Context, request ReconcileWorkspacesRequest) (*ReconcileWorkspacesResponse, error) {
+	workspaces, err := h.listWorkspaces(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Group the workspaces by the account of their provisioned product, the account of the engine is always scanned
+	workspacesByAccount := map[string]map[string]organizationWorkspace{h.engineAccountId: {}}
+	for _, workspace := range workspaces {
+		awsAccountId, provisionedProductId, _ := identifiers.ParseWorkspaceName(workspace.Name)
+		if workspacesByAccount[awsAccountId] == nil {
+			workspacesByAccount[awsAccountId] = map[string]organizationWorkspace{}
+		}
+		workspacesByAccount[awsAccountId][provisionedProductId] = workspace
+	}
+
+	awsAccountIds := make([]string, 0, len(workspacesByAccount))
+	for awsAccountId := range workspacesByAccount {
+		awsAccountIds = append(awsAccountIds, awsAccountId)
+	}
+	sort.Strings(awsAccountIds)
+
+	response := &ReconcileWorkspacesResponse{
+		CheckedWorkspaces:           len(workspaces),
+		OrphanedWorkspaces:          []OrphanedWorkspace{},
+		OrphanedProvisionedProducts: []OrphanedProvisionedProduct{},
+		UnverifiedAccounts:          []string{},
+		CleanUp:                     request.CleanUp,
+	}
+	var errs []error
+	for _, awsAccountId := range awsAccountIds {
+		provisionedProducts, err := h.scanAccount(ctx, awsAccountId)
+		if err != nil {
+			log.Default().Printf("failed to scan the provisioned products of account %s: %s", awsAccountId, err)
+			errs = append(errs, fmt.Errorf("failed to scan the provisioned products of account %s: %w", awsAccountId, err))
+		}
+		if provisionedProducts == nil {
+			response.UnverifiedAccounts = append(response.UnverifiedAccounts, awsAccountId)
+			continue
+		}
+
+		accountWorkspaces := workspacesByAccount[awsAccountId]
+		for _, provisionedProduct := range provisionedProducts {
+			provisionedProductId := aws.ToString(provisionedProduct.Id)
+			if _, found := accountWorkspaces[provisionedProductId]; found {
+				delete(accountWorkspaces, provisionedProductId)
+				continue
+			}
+
+			// Provisioned products that are being changed may not have their workspace yet
+			if provisionedProduct.Status == types.ProvisionedProductStatusUnderChange || provisionedProduct.Status == types.ProvisionedProductStatusPlanInProgress {
+				continue
+			}
+
+			log.Default().Printf("provisioned product %s of account %s has no workspace", provisionedProductId, awsAccountId)
+			response.OrphanedProvisionedProducts = append(response.OrphanedProvisionedProducts, OrphanedProvisionedProduct{
+				AwsAccountId:           awsAccountId,
+				ProvisionedProductId:   provisionedProductId,
+				ProvisionedProductName: aws.ToString(provisionedProduct.Name),
+				Status:                 string(provisionedProduct.Status),
+			})
+		}
+
+		// The workspaces that are left have no provisioned product behind them
+		for _, workspace := range sortedByName(accountWorkspaces) {
+			_, provisionedProductId, _ := identifiers.ParseWorkspaceName(workspace.Name)
+			log.Default().Printf("workspace %s has no provisioned product", workspace.Name)
+
+			orphan := OrphanedWorkspace{
+				TerraformOrganization: workspace.organization,
+				WorkspaceName:         workspace.Name,
+				AwsAccountId:          awsAccountId,
+				ProvisionedProductId:  provisionedProductId,
+				ResourceCount:         workspace.ResourceCount,
+			}
+
+			if request.CleanUp && h.isDeletable(workspace.Workspace) {
+				if err := workspace.client.Workspaces.SafeDeleteByID(ctx, workspace.ID); err != nil {
+					log.Default().Printf("failed to delete orphaned workspace %s: %s", workspace.Name, err)
+					errs = append(errs, fmt.Errorf("failed to delete orphaned workspace %s: %w", workspace.Name, tfc.Error(err)))
+				} else {
+					log.Default().Printf("deleted orphaned workspace %s", workspace.Name)
+					orphan.Deleted = true
+				}
+			}
+
+			response.OrphanedWorkspaces = append(response.OrphanedWorkspaces, orphan)
+		}
+	}
+
+	// Fail the invocation if any account could not be scanned, so it shows up in the metrics of the function
+	if len(errs) > 0 {
+		return response, errors.Join(errs...)
+	}
+
+	return response, nil
+}
+
+// listWorkspaces lists the workspaces of provisioned products in all organizations. Fails if any organization cannot be
+// listed, as the provisioned products of that organization would otherwise be reported as orphans.
+func (h *ReconcileWorkspacesHandler) listWorkspaces(ctx context.Context) ([]organizationWorkspace, error) {
+	organizations, err := secretsmanager.Organizations(h.secretsManager, h.organization)
+	if err != nil {
+		return nil, err
+	}
+
+	var workspaces []organizationWorkspace
+	for _, organization := range organizations {
+		tfeClient, err := tfc.GetTFEClient(ctx, organization.SecretsManager)
+		if err != nil {
+			log.Default().Printf("failed to initialize TFE client for organization %s: %s", organization.Name, err)
+			return nil, err
+		}
+
+		organizationWorkspaces, err := tfc.ListProvisionedProductWorkspaces(ctx, tfeClient, organization.Name)
+		if err != nil {
+			log.Default().Printf("failed to list the workspaces of organization %s: %s", organization.Name, err)
+			return nil, err
+		}
+
+		for _, workspace := range organizationWorkspaces {
+			workspaces = append(workspaces, organizationWorkspace{Workspace: workspace, organization: organization.Name, client: tfeClient})
+		}
+	}
+	return workspaces, nil
+}
+
+// scanAccount lists the provisioned products of the engine in the account, returning nil if the account cannot be
+// scanned
+func (h *ReconcileWorkspacesHandler) scanAccount(ctx context.Context, awsAccountId string) ([]types.ProvisionedProductDetail, error) {
+	scanner, err := h.scannerForAccount(ctx, awsAccountId)
+	if err != nil || scanner == nil {
+		return nil, err
+	}
+
+	provisionedProducts, err := servicecatalog.ScanEngineProvisionedProducts(ctx, scanner)
+	if err != nil {
+		return nil, err
+	}
+
+	// Return an empty list rather than nil, so accounts without provisioned products are not reported as unverified
+	if provisionedProducts == nil {
+		provisionedProducts = []types.ProvisionedProductDetail{}
+	}
+	return provisionedProducts, nil
+}
+
+// isDeletable checks whether the orphaned workspace is empty, unlocked and past the grace period. Workspaces that are
+// locked may still be in use by an execution of the engine.
+func (h *ReconcileWorkspacesHandler) isDeletable(workspace *tfe.Workspace) bool {
+	switch {
+	case workspace.ResourceCount > 0:
+		log.Default().Printf("not deleting orphaned workspace %s, it manages %d resources", workspace.Name, workspace.ResourceCount)
+	case workspace.Locked:
+		log.Default().Printf("not deleting orphaned workspace %s, it is locked", workspace.Name)
+	case h.now().Sub(workspace.CreatedAt) < h.gracePeriod:
+		log.Default().Printf("not deleting orphaned workspace %s, it was created less than %s ago", workspace.Name, h.gracePeriod)
+	default:
+		return true
+	}
+	return false
+}
+
+func sortedByName(workspaces map[string]organizationWorkspace) []organizationWorkspace {
+	sorted := make([]organizationWorkspace, 0, len(workspaces))
+	for _, workspace := range workspaces {
+		sorted = append(sorted, workspace)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+	return sorted
+}
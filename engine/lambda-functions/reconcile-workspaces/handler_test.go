@@ -0,0 +1,202 @@
+/*
+ * Copyright (c) HashiCorp, Inc.
+ * SPDX-License-Identifier: MPL-2.0
+ */
+
+package main
+
+import (
+	"context"
+	"errors"
+	"github.com/aws/aws-sdk-go-v2/service/servicecatalog/types"
+	sc "github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/servicecatalog"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/testutil/secretsmanager"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/testutil/servicecatalog"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/testutil/testtfc"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/tfc"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+const engineAccountId = "123456789042"
+const spokeAccountId = "210987654321"
+
+func newTestHandler(tfcServer *testtfc.MockTFC, scanners map[string]*servicecatalog.MockProvisionedProductScanner, now time.Time) *ReconcileWorkspacesHandler {
+	// Create the TFE client that will send requests to the mock TFC instance
+	mockSecretsManager := &secretsmanager.MockSecretsManager{
+		Hostname: tfcServer.Address,
+		TeamId:   "team-4123nlol",
+		Token:    "supers3cret",
+	}
+
+	return &ReconcileWorkspacesHandler{
+		secretsManager:  mockSecretsManager,
+		organization:    tfcServer.OrganizationName,
+		engineAccountId: engineAccountId,
+		scannerForAccount: func(ctx context.Context, awsAccountId string) (sc.ProvisionedProductScanner, error) {
+			if scanner, found := scanners[awsAccountId]; found {
+				return scanner, nil
+			}
+			return nil, nil
+		},
+		gracePeriod: 24 * time.Hour,
+		now:         func() time.Time { return now },
+	}
+}
+
+func TestReconcileWorkspacesHandler_Report(t *testing.T) {
+	// Create mock TFC instance
+	tfcServer := testtfc.NewMockTFC()
+	defer tfcServer.Stop()
+
+	tfcServer.AddWorkspace("123456789042-amazingly-great-product-instance", testtfc.WorkspaceFactoryParameters{})
+	tfcServer.AddWorkspace("123456789042-abandoned-product-instance", testtfc.WorkspaceFactoryParameters{})
+	tfcServer.AddWorkspace("210987654321-spoke-product-instance", testtfc.WorkspaceFactoryParameters{})
+	tfcServer.AddWorkspace("legacy-network", testtfc.WorkspaceFactoryParameters{})
+
+	// Workspaces of terminated provisioned products that are retained are not orphans
+	terminated := tfcServer.AddWorkspace("123456789042-terminated-product-instance", testtfc.WorkspaceFactoryParameters{})
+	terminated.TagNames = []string{tfc.TerminatedTag}
+
+	scanner := &servicecatalog.MockProvisionedProductScanner{}
+	scanner.AddProvisionedProduct("amazingly-great-product-instance", sc.EngineProductType, types.ProvisionedProductStatusAvailable)
+	scanner.AddProvisionedProduct("vanished-product-instance", sc.EngineProductType, types.ProvisionedProductStatusTainted)
+	scanner.AddProvisionedProduct("launching-product-instance", sc.EngineProductType, types.ProvisionedProductStatusUnderChange)
+	scanner.AddProvisionedProduct("cloudformation-product-instance", "CLOUD_FORMATION_TEMPLATE", types.ProvisionedProductStatusAvailable)
+
+	// Send the test request, the spoke account cannot be scanned
+	testHandler := newTestHandler(tfcServer, map[string]*servicecatalog.MockProvisionedProductScanner{engineAccountId: scanner}, time.Now())
+	response, err := testHandler.HandleRequest(context.Background(), ReconcileWorkspacesRequest{})
+	assert.NoError(t, err)
+
+	// Verify the orphans were reported, and nothing was deleted
+	assert.Equal(t, 3, response.CheckedWorkspaces)
+	assert.Equal(t, []OrphanedWorkspace{{
+		TerraformOrganization: tfcServer.OrganizationName,
+		WorkspaceName:         "123456789042-abandoned-product-instance",
+		AwsAccountId:          engineAccountId,
+		ProvisionedProductId:  "abandoned-product-instance",
+	}}, response.OrphanedWorkspaces)
+	assert.Equal(t, []OrphanedProvisionedProduct{{
+		AwsAccountId:           engineAccountId,
+		ProvisionedProductId:   "vanished-product-instance",
+		ProvisionedProductName: "vanished-product-instance",
+		Status:                 "TAINTED",
+	}}, response.OrphanedProvisionedProducts)
+	assert.Equal(t, []string{spokeAccountId}, response.UnverifiedAccounts)
+	assert.Equal(t, 5, len(tfcServer.Workspaces))
+}
+
+func TestReconcileWorkspacesHandler_CleanUp(t *testing.T) {
+	// Create mock TFC instance
+	tfcServer := testtfc.NewMockTFC()
+	defer tfcServer.Stop()
+
+	now := time.Now()
+
+	empty := tfcServer.AddWorkspace("123456789042-empty-product-instance", testtfc.WorkspaceFactoryParameters{})
+	empty.CreatedAt = now.Add(-48 * time.Hour)
+
+	managing := tfcServer.AddWorkspace("123456789042-managing-product-instance", testtfc.WorkspaceFactoryParameters{})
+	managing.CreatedAt = now.Add(-48 * time.Hour)
+	managing.ResourceCount = 3
+
+	recent := tfcServer.AddWorkspace("123456789042-recent-product-instance", testtfc.WorkspaceFactoryParameters{})
+	recent.CreatedAt = now.Add(-1 * time.Hour)
+
+	spoke := tfcServer.AddWorkspace("210987654321-spoke-product-instance", testtfc.WorkspaceFactoryParameters{})
+	spoke.CreatedAt = now.Add(-48 * time.Hour)
+
+	// Both accounts can be scanned, but neither has any provisioned products
+	testHandler := newTestHandler(tfcServer, map[string]*servicecatalog.MockProvisionedProductScanner{
+		engineAccountId: {},
+		spokeAccountId:  {},
+	}, now)
+
+	// Send the test request
+	response, err := testHandler.HandleRequest(context.Background(), ReconcileWorkspacesRequest{CleanUp: true})
+	assert.NoError(t, err)
+
+	// Verify only the empty workspaces past the grace period were deleted
+	deleted := map[string]bool{}
+	for _, orphan := range response.OrphanedWorkspaces {
+		deleted[orphan.WorkspaceName] = orphan.Deleted
+	}
+	assert.Equal(t, map[string]bool{
+		"123456789042-empty-product-instance":    true,
+		"123456789042-managing-product-instance": false,
+		"123456789042-recent-product-instance":   false,
+		"210987654321-spoke-product-instance":    true,
+	}, deleted)
+	assert.Empty(t, response.UnverifiedAccounts)
+	assert.Equal(t, 2, len(tfcServer.Workspaces))
+	assert.NotNil(t, tfcServer.Workspaces[managing.ID])
+	assert.NotNil(t, tfcServer.Workspaces[recent.ID])
+}
+
+func TestReconcileWorkspacesHandler_ScanFailed(t *testing.T) {
+	// Create mock TFC instance
+	tfcServer := testtfc.NewMockTFC()
+	defer tfcServer.Stop()
+
+	tfcServer.AddWorkspace("123456789042-amazingly-great-product-instance", testtfc.WorkspaceFactoryParameters{})
+
+	scanner := &servicecatalog.MockProvisionedProductScanner{Err: errors.New("access denied")}
+	testHandler := newTestHandler(tfcServer, map[string]*servicecatalog.MockProvisionedProductScanner{engineAccountId: scanner}, time.Now())
+
+	// Send the test request
+	response, err := testHandler.HandleRequest(context.Background(), ReconcileWorkspacesRequest{CleanUp: true})
+
+	// Verify the workspaces of the account were neither reported nor deleted
+	assert.EqualError(t, err, "failed to scan the provisioned products of account 123456789042: access denied")
+	assert.Empty(t, response.OrphanedWorkspaces)
+	assert.Equal(t, []string{engineAccountId}, response.UnverifiedAccounts)
+	assert.Equal(t, 1, len(tfcServer.Workspaces))
+}
+
+func TestReconcileWorkspacesHandler_ReportMultipleOrganizations(t *testing.T) {
+	// Create mock TFC instances for the default organization and an organization with its own credentials
+	tfcServer := testtfc.NewMockTFC()
+	defer tfcServer.Stop()
+
+	otherTfcServer := testtfc.NewMockTFC()
+	defer otherTfcServer.Stop()
+	otherTfcServer.OrganizationName = "business-unit-on-tfe"
+
+	tfcServer.AddWorkspace("123456789042-amazingly-great-product-instance", testtfc.WorkspaceFactoryParameters{})
+	otherTfcServer.AddWorkspace("123456789042-routed-product-instance", testtfc.WorkspaceFactoryParameters{})
+	otherTfcServer.AddWorkspace("123456789042-abandoned-product-instance", testtfc.WorkspaceFactoryParameters{})
+
+	scanner := &servicecatalog.MockProvisionedProductScanner{}
+	scanner.AddProvisionedProduct("amazingly-great-product-instance", sc.EngineProductType, types.ProvisionedProductStatusAvailable)
+	scanner.AddProvisionedProduct("routed-product-instance", sc.EngineProductType, types.ProvisionedProductStatusAvailable)
+
+	testHandler := newTestHandler(tfcServer, map[string]*servicecatalog.MockProvisionedProductScanner{engineAccountId: scanner}, time.Now())
+	testHandler.secretsManager = &secretsmanager.MockSecretsManager{
+		Hostname: tfcServer.Address,
+		TeamId:   "team-4123nlol",
+		Token:    "supers3cret",
+		Organizations: map[string]*secretsmanager.MockSecretsManager{
+			"business-unit-on-tfe": {
+				Hostname: otherTfcServer.Address,
+				TeamId:   "team-otherteam",
+				Token:    "othersupers3cret",
+			},
+		},
+	}
+
+	// Send the test request
+	response, err := testHandler.HandleRequest(context.Background(), ReconcileWorkspacesRequest{})
+	assert.NoError(t, err)
+
+	// Verify the provisioned product routed to the other organization was matched with its workspace
+	assert.Equal(t, 3, response.CheckedWorkspaces)
+	assert.Equal(t, []OrphanedWorkspace{{
+		TerraformOrganization: "business-unit-on-tfe",
+		WorkspaceName:         "123456789042-abandoned-product-instance",
+		AwsAccountId:          engineAccountId,
+		ProvisionedProductId:  "abandoned-product-instance",
+	}}, response.OrphanedWorkspaces)
+	assert.Empty(t, response.OrphanedProvisionedProducts)
+}
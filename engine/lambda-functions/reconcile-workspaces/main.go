@@ -0,0 +1,110 @@
+/*
+ * Copyright (c) HashiCorp, Inc.
+ * SPDX-License-Identifier: MPL-2.0
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	sc "github.com/aws/aws-sdk-go-v2/service/servicecatalog"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/awsconfig"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/secretsmanager"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/servicecatalog"
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+type ReconcileWorkspacesRequest struct {
+	// CleanUp deletes the orphaned workspaces that do not manage any resources
+	CleanUp bool `json:"cleanUp"`
+}
+
+type OrphanedWorkspace struct {
+	TerraformOrganization string `json:"terraformOrganization"`
+	WorkspaceName         string `json:"workspaceName"`
+	AwsAccountId          string `json:"awsAccountId"`
+	ProvisionedProductId  string `json:"provisionedProductId"`
+	ResourceCount         int    `json:"resourceCount"`
+	Deleted               bool   `json:"deleted"`
+}
+
+type OrphanedProvisionedProduct struct {
+	AwsAccountId           string `json:"awsAccountId"`
+	ProvisionedProductId   string `json:"provisionedProductId"`
+	ProvisionedProductName string `json:"provisionedProductName"`
+	Status                 string `json:"status"`
+}
+
+type ReconcileWorkspacesResponse struct {
+	CheckedWorkspaces           int                          `json:"checkedWorkspaces"`
+	OrphanedWorkspaces          []OrphanedWorkspace          `json:"orphanedWorkspaces"`
+	OrphanedProvisionedProducts []OrphanedProvisionedProduct `json:"orphanedProvisionedProducts"`
+	UnverifiedAccounts          []string                     `json:"unverifiedAccounts"`
+	CleanUp                     bool                         `json:"cleanUp"`
+}
+
+func main() {
+	// Create temporary context to initialize the handler with
+	initContext := context.TODO()
+
+	sdkConfig := awsconfig.GetSdkConfig(initContext)
+
+	// Create secrets client SDK to fetch TFE credentials
+	secretsManager, err := secretsmanager.NewWithConfig(initContext, sdkConfig)
+	if err != nil {
+		log.Fatalf("failed to initialize secrets manager client: %s", err)
+	}
+
+	// Look up the account of the engine, its provisioned products are scanned without assuming a role
+	identity, err := sts.NewFromConfig(sdkConfig).GetCallerIdentity(initContext, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		log.Fatalf("failed to look up the account of the engine: %s", err)
+	}
+	callerArn, err := arn.Parse(aws.ToString(identity.Arn))
+	if err != nil {
+		log.Fatalf("failed to parse the ARN of the engine: %s", err)
+	}
+
+	gracePeriodInHours, err := strconv.Atoi(os.Getenv("ORPHAN_GRACE_PERIOD_IN_HOURS"))
+	if err != nil {
+		log.Fatalf("failed to parse ORPHAN_GRACE_PERIOD_IN_HOURS: %s", err)
+	}
+
+	engineAccountId := aws.ToString(identity.Account)
+	spokeRoleName := os.Getenv("RECONCILIATION_ROLE_NAME")
+
+	handler := ReconcileWorkspacesHandler{
+		secretsManager:  secretsManager,
+		organization:    os.Getenv("TERRAFORM_ORGANIZATION"),
+		engineAccountId: engineAccountId,
+		scannerForAccount: func(ctx context.Context, awsAccountId string) (servicecatalog.ProvisionedProductScanner, error) {
+			if awsAccountId == engineAccountId {
+				return sc.NewFromConfig(sdkConfig), nil
+			}
+
+			// Provisioned products of other accounts can only be scanned through a role in those accounts
+			if spokeRoleName == "" {
+				return nil, nil
+			}
+
+			roleArn := fmt.Sprintf("arn:%s:iam::%s:role/%s", callerArn.Partition, awsAccountId, spokeRoleName)
+			spokeConfig, err := awsconfig.GetSdkConfigWithRoleArn(ctx, sdkConfig, roleArn)
+			if err != nil {
+				return nil, err
+			}
+			return sc.NewFromConfig(spokeConfig), nil
+		},
+		gracePeriod: time.Duration(gracePeriodInHours) * time.Hour,
+		now:         time.Now,
+	}
+
+	lambda.Start(handler.HandleRequest)
+}
@@ -0,0 +1,79 @@
+/*
+ * Copyright (c) HashiCorp, Inc.
+ * SPDX-License-Identifier: MPL-2.0
+ */
+
+package main
+
+import (
+	"context"
+	"errors"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/enginestate"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/runwaiter"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/secretsmanager"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/stepfunctions"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/tfc"
+	"log"
+	"time"
+)
+
+// expiryMargin keeps the task token around a bit longer than the wait, so a late notification is still recognized
+const expiryMargin = 5 * time.Minute
+
+type RegisterRunWaiterHandler struct {
+	secretsManager secretsmanager.SecretsManager
+	stepFunctions  stepfunctions.StepFunctions
+	engineState    enginestate.EngineState
+}
+
+func (h *RegisterRunWaiterHandler) HandleRequest(ctx context.Context, request RegisterRunWaiterRequest) (*RegisterRunWaiterResponse, error) {
+	if request.TerraformRunId == "" {
+		return nil, errors.New("terraformRunId is required")
+	}
+	if request.TaskToken == "" {
+		return nil, errors.New("taskToken is required")
+	}
+
+	// Store the task token, so the run notification webhook can resume the execution once the run settles
+	expiresAt := time.Now().Add(time.Duration(request.TimeoutSeconds)*time.Second + expiryMargin)
+	err := runwaiter.Register(ctx, h.engineState, request.TerraformRunId, request.TaskToken, expiresAt)
+	if err != nil {
+		log.Printf("failed to register waiter for run %s: %s", request.TerraformRunId, err)
+		return nil, err
+	}
+
+	// Get TFE Client
+	organizationSecretsManager, err := secretsmanager.ForOrganization(h.secretsManager, request.TerraformOrganization)
+	if err != nil {
+		log.Printf("failed to find the TFE credentials: %s", err)
+		return nil, err
+	}
+	tfeClient, err := tfc.GetTFEClient(ctx, organizationSecretsManager)
+	if err != nil {
+		log.Printf("failed to initialize TFE client: %s", err)
+		return nil, err
+	}
+
+	// The run may have settled before the waiter was registered, in which case its notification was already sent
+	run, err := tfeClient.Runs.Read(ctx, request.TerraformRunId)
+	if err != nil {
+		return nil, tfc.Error(err)
+	}
+
+	resumed := false
+	if tfc.IsRunSettled(run) {
+		log.Default().Printf("run %s already settled with status %s, resuming execution", run.ID, run.Status)
+		resumed, err = runwaiter.Complete(ctx, h.engineState, h.stepFunctions, runwaiter.RunCompletion{
+			TerraformRunId: run.ID,
+			RunStatus:      run.Status,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &RegisterRunWaiterResponse{
+		TerraformRunId: request.TerraformRunId,
+		Resumed:        resumed,
+	}, nil
+}
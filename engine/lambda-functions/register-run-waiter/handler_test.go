@@ -0,0 +1,126 @@
+/*
+ * Copyright (c) HashiCorp, Inc.
+ * SPDX-License-Identifier: MPL-2.0
+ */
+
+package main
+
+import (
+	"context"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/runwaiter"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/testutil/enginestate"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/testutil/secretsmanager"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/testutil/stepfunction"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/testutil/testtfc"
+	"github.com/hashicorp/go-tfe"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestRegisterRunWaiterHandler_RunInProgress(t *testing.T) {
+	// Create mock TFC instance
+	tfcServer := testtfc.NewMockTFC()
+	defer tfcServer.Stop()
+
+	// Add a run that is still applying
+	tfcServer.AddRun("run-in-progress", testtfc.RunFactoryParameters{
+		RunStatus: tfe.RunApplying,
+	})
+
+	mockSecretsManager := &secretsmanager.MockSecretsManager{
+		Hostname: tfcServer.Address,
+		TeamId:   "team-4123nlol",
+		Token:    "supers3cret",
+	}
+	mockStepFunctions := &stepfunction.MockStepFunctionsWithSuccessfulResponse{}
+	mockEngineState := enginestate.NewMockEngineState()
+
+	// Create a test instance of the Lambda function
+	testHandler := &RegisterRunWaiterHandler{
+		secretsManager: mockSecretsManager,
+		stepFunctions:  mockStepFunctions,
+		engineState:    mockEngineState,
+	}
+
+	// Send the test request
+	response, err := testHandler.HandleRequest(context.Background(), RegisterRunWaiterRequest{
+		TaskToken:      "token-of-patience",
+		TerraformRunId: "run-in-progress",
+		TimeoutSeconds: 300,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Verify the task token was stored until the wait times out, and the execution was not resumed yet
+	assert.False(t, response.Resumed)
+	assert.Nil(t, mockStepFunctions.SendTaskSuccessInput)
+
+	waiter := mockEngineState.Items[runwaiter.Key("run-in-progress")]
+	assert.Equal(t, "token-of-patience", waiter.Value)
+	assert.WithinDuration(t, time.Now().Add(300*time.Second+expiryMargin), waiter.ExpiresAt, 5*time.Second)
+}
+
+func TestRegisterRunWaiterHandler_RunAlreadySettled(t *testing.T) {
+	// Create mock TFC instance
+	tfcServer := testtfc.NewMockTFC()
+	defer tfcServer.Stop()
+
+	// Add a run that completed before the waiter was registered
+	tfcServer.AddRun("run-already-applied", testtfc.RunFactoryParameters{
+		RunStatus: tfe.RunApplied,
+	})
+
+	mockSecretsManager := &secretsmanager.MockSecretsManager{
+		Hostname: tfcServer.Address,
+		TeamId:   "team-4123nlol",
+		Token:    "supers3cret",
+	}
+	mockStepFunctions := &stepfunction.MockStepFunctionsWithSuccessfulResponse{}
+	mockEngineState := enginestate.NewMockEngineState()
+
+	// Create a test instance of the Lambda function
+	testHandler := &RegisterRunWaiterHandler{
+		secretsManager: mockSecretsManager,
+		stepFunctions:  mockStepFunctions,
+		engineState:    mockEngineState,
+	}
+
+	// Send the test request
+	response, err := testHandler.HandleRequest(context.Background(), RegisterRunWaiterRequest{
+		TaskToken:      "token-of-patience",
+		TerraformRunId: "run-already-applied",
+		TimeoutSeconds: 300,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Verify the execution was resumed right away, and the waiter was removed
+	assert.True(t, response.Resumed)
+	assert.Equal(t, "token-of-patience", *mockStepFunctions.SendTaskSuccessInput.TaskToken)
+	assert.JSONEq(t, `{"terraformRunId": "run-already-applied", "runStatus": "applied"}`, *mockStepFunctions.SendTaskSuccessInput.Output)
+	assert.Empty(t, mockEngineState.Items)
+}
+
+func TestRegisterRunWaiterHandler_MissingTaskToken(t *testing.T) {
+	mockEngineState := enginestate.NewMockEngineState()
+
+	// Create a test instance of the Lambda function
+	testHandler := &RegisterRunWaiterHandler{
+		secretsManager: &secretsmanager.MockSecretsManager{},
+		stepFunctions:  &stepfunction.MockStepFunctionsWithSuccessfulResponse{},
+		engineState:    mockEngineState,
+	}
+
+	// Send the test request
+	_, err := testHandler.HandleRequest(context.Background(), RegisterRunWaiterRequest{
+		TerraformRunId: "run-in-progress",
+		TimeoutSeconds: 300,
+	})
+
+	// Verify an error was returned and nothing was stored
+	assert.EqualError(t, err, "taskToken is required")
+	assert.Empty(t, mockEngineState.Items)
+}
@@ -0,0 +1,53 @@
+/*
+ * Copyright (c) HashiCorp, Inc.
+ * SPDX-License-Identifier: MPL-2.0
+ */
+
+package main
+
+import (
+	"context"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/awsconfig"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/enginestate"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/secretsmanager"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/stepfunctions"
+	"log"
+)
+
+type RegisterRunWaiterRequest struct {
+	TaskToken      string `json:"taskToken"`
+	TerraformRunId string `json:"terraformRunId"`
+	TimeoutSeconds int    `json:"timeoutSeconds"`
+
+	// TerraformOrganization chooses the TFE credentials used to read the run, the default credentials are used if unset
+	TerraformOrganization string `json:"terraformOrganization,omitempty"`
+}
+
+type RegisterRunWaiterResponse struct {
+	TerraformRunId string `json:"terraformRunId"`
+	Resumed        bool   `json:"resumed"`
+}
+
+func main() {
+	// Create temporary context to initialize the handler with
+	initContext := context.TODO()
+
+	sdkConfig := awsconfig.GetSdkConfig(initContext)
+
+	// Create secrets client SDK to fetch TFE credentials
+	secretsManager, err := secretsmanager.NewWithConfig(initContext, sdkConfig)
+	if err != nil {
+		log.Fatalf("failed to initialize secrets manager client: %s", err)
+	}
+
+	// Create the handler
+	handler := &RegisterRunWaiterHandler{
+		secretsManager: secretsManager,
+		stepFunctions:  stepfunctions.NewFromConfig(sdkConfig),
+		engineState:    enginestate.NewFromConfig(sdkConfig),
+	}
+
+	// Start the lambda using the handler
+	lambda.Start(handler.HandleRequest)
+}
@@ -9,6 +9,7 @@ import (
 	"context"
 	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/fileutils"
 	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/identifiers"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/model"
 	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/secretsmanager"
 	"github.com/hashicorp/go-tfe"
 	"time"
@@ -16,13 +17,20 @@ import (
 )
 
 type SendApplyHandler struct {
-	secretsManager   secretsmanager.SecretsManager
-	s3Downloader     fileutils.S3Downloader
-	region           string
-	terraformVersion string
+	secretsManager     secretsmanager.SecretsManager
+	s3Downloader       fileutils.S3Downloader
+	region             string
+	terraformVersion   string
+	requireRunApproval bool
+	runNotificationUrl string
+	notificationToken  secretsmanager.NotificationTokenSecret
 }
 
-func (h *SendApplyHandler) HandleRequest(ctx context.Context, request SendApplyRequest) (*SendApplyResponse, error) {
+func (h *SendApplyHandler) HandleRequest(ctx context.Context, request model.SendApplyRequest) (*SendApplyResponse, error) {
+	if err := request.Validate(); err != nil {
+		return nil, err
+	}
+
 	// Create TFC Applier to ensure that metadata headers are supplied in requests
 	applier, err := h.NewTFCApplier(ctx, request)
 	if err != nil {
@@ -67,6 +75,19 @@ func (h *SendApplyHandler) HandleRequest(ctx context.Context, request SendApplyR
 		return nil, err
 	}
 
+	// Notify the engine when runs in the workspace complete, so the state machine does not have to poll them
+	if h.runNotificationUrl != "" {
+		token, err := h.notificationToken.GetNotificationToken(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		err = applier.ConfigureRunNotifications(ctx, w, h.runNotificationUrl, token)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// Create configuration version to acquire upload link for configuration files to be sent to
 	cv, err := applier.CreateConfigurationVersion(ctx, w.ID)
 	if err != nil {
@@ -115,7 +136,7 @@ func (h *SendApplyHandler) HandleRequest(ctx context.Context, request SendApplyR
 	run, err := applier.tfeClient.Runs.Create(ctx, tfe.RunCreateOptions{
 		Workspace:            w,
 		ConfigurationVersion: cv,
-		AutoApply:            tfe.Bool(true),
+		AutoApply:            tfe.Bool(!h.requireRunApproval),
 	})
 	if err != nil {
 		return nil, tfc.Error(err)
@@ -12,10 +12,10 @@ import (
 	"fmt"
 	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/fileutils"
 	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/identifiers"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/model"
 	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/testutil/s3"
 	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/testutil/secretsmanager"
 	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/testutil/testtfc"
-	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/tracertag"
 	"github.com/hashicorp/go-tfe"
 	"github.com/stretchr/testify/assert"
 	"io"
@@ -49,30 +49,34 @@ func TestSendApplyHandler_Success(t *testing.T) {
 	}
 
 	// Create test request
-	testRequest := SendApplyRequest{
+	testRequest := model.SendApplyRequest{
 		AwsAccountId:          "123456789042",
 		TerraformOrganization: tfcServer.OrganizationName,
 		ProvisionedProductId:  "amazingly-great-product-instance",
-		Artifact: Artifact{
+		Artifact: model.Artifact{
 			Path: "s3://wowzers-this-is-some/fake/artifact/path",
-			Type: "beeg-test",
+			Type: "AWS_S3",
 		},
-		LaunchRoleArn: "arn:::some/fake/role/arn",
+		LaunchRoleArn: "arn:aws:iam::123456789042:role/some-fake-role",
 		ProductId:     "id-4-number-1-best-product",
-		Tags:          make([]AWSTag, 0),
-		TracerTag: tracertag.TracerTag{
-			TracerTagKey:   "test-tracer-tag-key",
-			TracerTagValue: "test-trace-tag-value",
+		Tags:          make([]model.Tag, 0),
+		TracerTag: model.TracerTag{
+			Key:   "test-tracer-tag-key",
+			Value: "test-trace-tag-value",
 		},
 	}
 
 	// Send the test request
-	_, err := testHandler.HandleRequest(context.Background(), testRequest)
+	response, err := testHandler.HandleRequest(context.Background(), testRequest)
 	// Verify no errors were returned
 	if err != nil {
 		t.Fatal(err)
 	}
 
+	// Check the run is applied automatically
+	run := tfcServer.Runs[fmt.Sprintf("/api/v2/runs/%s", response.TerraformRunId)]
+	assert.True(t, run.AutoApply, "run should have been applied automatically")
+
 	// Check uploaded artifact contains overrides
 	entries := GetArtifactEntryNames(t, tfcServer.UploadedArtifact())
 
@@ -160,41 +164,46 @@ func TestSendApplyHandler_Success_UpdatingExistingWorkspace(t *testing.T) {
 		MockArtifactPath: MockArtifactPath,
 	}
 
-	// Create a test instance of the Lambda function
+	// Create a test instance of the Lambda function, that requires runs to be approved
 	testHandler := &SendApplyHandler{
-		secretsManager: mockSecretsManager,
-		s3Downloader:   mockDownloader,
-		region:         "narnia-west-2",
+		secretsManager:     mockSecretsManager,
+		s3Downloader:       mockDownloader,
+		region:             "narnia-west-2",
+		requireRunApproval: true,
 	}
 
 	// Create test request
-	testRequest := SendApplyRequest{
+	testRequest := model.SendApplyRequest{
 		AwsAccountId:          "123456789042",
 		TerraformOrganization: tfcServer.OrganizationName,
 		ProvisionedProductId:  "amazingly-great-product-instance",
-		Artifact: Artifact{
+		Artifact: model.Artifact{
 			Path: "s3://wowzers-this-is-some/fake/artifact/path",
-			Type: "beeg-test",
+			Type: "AWS_S3",
 		},
-		LaunchRoleArn: "arn:::some/fake/role/arn",
+		LaunchRoleArn: "arn:aws:iam::123456789042:role/some-fake-role",
 		ProductId:     "id-4-number-1-best-product",
-		Tags:          make([]AWSTag, 0),
-		TracerTag: tracertag.TracerTag{
-			TracerTagKey:   "test-tracer-tag-key",
-			TracerTagValue: "test-trace-tag-value",
+		Tags:          make([]model.Tag, 0),
+		TracerTag: model.TracerTag{
+			Key:   "test-tracer-tag-key",
+			Value: "test-trace-tag-value",
 		},
 	}
 
 	// Send the test request
-	_, err := testHandler.HandleRequest(context.Background(), testRequest)
+	response, err := testHandler.HandleRequest(context.Background(), testRequest)
 	// Verify no errors were returned
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
 
 	// Check Variables were updated
 	assert.Equal(t, "true", providerAuthVar.Value)
-	assert.Equal(t, "arn:::some/fake/role/arn", runRoleArnVar.Value)
+	assert.Equal(t, "arn:aws:iam::123456789042:role/some-fake-role", runRoleArnVar.Value)
+
+	// Check the run waits for approval
+	run := tfcServer.Runs[fmt.Sprintf("/api/v2/runs/%s", response.TerraformRunId)]
+	assert.False(t, run.AutoApply, "run should not have been applied automatically")
 }
 
 func TestSendApplyHandler_Success_PurgesUnknownVariables(t *testing.T) {
@@ -250,24 +259,24 @@ func TestSendApplyHandler_Success_PurgesUnknownVariables(t *testing.T) {
 	}
 
 	// Create test request
-	testRequest := SendApplyRequest{
+	testRequest := model.SendApplyRequest{
 		AwsAccountId:          "123456789042",
 		TerraformOrganization: tfcServer.OrganizationName,
 		ProvisionedProductId:  "amazingly-great-product-instance",
-		Artifact: Artifact{
+		Artifact: model.Artifact{
 			Path: "s3://wowzers-this-is-some/fake/artifact/path",
-			Type: "beeg-test",
+			Type: "AWS_S3",
 		},
-		LaunchRoleArn: "arn:::some/fake/role/arn",
+		LaunchRoleArn: "arn:aws:iam::123456789042:role/some-fake-role",
 		ProductId:     "id-4-number-1-best-product",
-		Tags:          make([]AWSTag, 0),
-		Parameters: []Parameter{
+		Tags:          make([]model.Tag, 0),
+		Parameters: []model.Parameter{
 			{Key: "keep_me", Value: "i want to live!"},
 			{Key: "keep_me_too", Value: "i also want to live!!"},
 		},
-		TracerTag: tracertag.TracerTag{
-			TracerTagKey:   "test-tracer-tag-key",
-			TracerTagValue: "test-trace-tag-value",
+		TracerTag: model.TracerTag{
+			Key:   "test-tracer-tag-key",
+			Value: "test-trace-tag-value",
 		},
 	}
 
@@ -311,20 +320,20 @@ func TestSendApplyHandler_Success_ProjectAlreadyExists(t *testing.T) {
 	}
 
 	// Create test request
-	testRequest := SendApplyRequest{
+	testRequest := model.SendApplyRequest{
 		AwsAccountId:          "123456789042",
 		TerraformOrganization: tfcServer.OrganizationName,
 		ProvisionedProductId:  "amazingly-great-product-instance",
-		Artifact: Artifact{
+		Artifact: model.Artifact{
 			Path: "s3://wowzers-this-is-some/fake/artifact/path",
-			Type: "beeg-test",
+			Type: "AWS_S3",
 		},
-		LaunchRoleArn: "arn:::some/fake/role/arn",
+		LaunchRoleArn: "arn:aws:iam::123456789042:role/some-fake-role",
 		ProductId:     "id-4-number-1-best-product",
-		Tags:          make([]AWSTag, 0),
-		TracerTag: tracertag.TracerTag{
-			TracerTagKey:   "test-tracer-tag-key",
-			TracerTagValue: "test-trace-tag-value",
+		Tags:          make([]model.Tag, 0),
+		TracerTag: model.TracerTag{
+			Key:   "test-tracer-tag-key",
+			Value: "test-trace-tag-value",
 		},
 	}
 
@@ -336,6 +345,82 @@ func TestSendApplyHandler_Success_ProjectAlreadyExists(t *testing.T) {
 	}
 }
 
+func TestSendApplyHandler_Success_ConfiguresRunNotifications(t *testing.T) {
+	// Create mock TFC instance
+	tfcServer := testtfc.NewMockTFC()
+	defer tfcServer.Stop()
+
+	mockSecretsManager := &secretsmanager.MockSecretsManager{
+		Hostname: tfcServer.Address,
+		TeamId:   "team-4123nlol",
+		Token:    "supers3cret",
+	}
+	mockNotificationToken := &secretsmanager.MockNotificationTokenSecret{Token: "s1gn-me-up"}
+
+	// Create mock S3 downloader
+	const MockArtifactPath = "../../../example-product/product.tar.gz"
+	mockDownloader := &s3.MockDownloader{
+		MockArtifactPath: MockArtifactPath,
+	}
+
+	// Create a test instance of the Lambda function, that configures run notifications
+	testHandler := &SendApplyHandler{
+		secretsManager:     mockSecretsManager,
+		s3Downloader:       mockDownloader,
+		region:             "narnia-west-2",
+		runNotificationUrl: "https://notify-me.lambda-url.narnia-west-2.on.aws/",
+		notificationToken:  mockNotificationToken,
+	}
+
+	// Create test request
+	testRequest := model.SendApplyRequest{
+		AwsAccountId:          "123456789042",
+		TerraformOrganization: tfcServer.OrganizationName,
+		ProvisionedProductId:  "amazingly-great-product-instance",
+		Artifact: model.Artifact{
+			Path: "s3://wowzers-this-is-some/fake/artifact/path",
+			Type: "AWS_S3",
+		},
+		LaunchRoleArn: "arn:aws:iam::123456789042:role/some-fake-role",
+		ProductId:     "id-4-number-1-best-product",
+		Tags:          make([]model.Tag, 0),
+		TracerTag: model.TracerTag{
+			Key:   "test-tracer-tag-key",
+			Value: "test-trace-tag-value",
+		},
+	}
+
+	// Send the test request
+	_, err := testHandler.HandleRequest(context.Background(), testRequest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Verify the run notifications were configured on the workspace
+	keys := reflect.ValueOf(tfcServer.Workspaces).MapKeys()
+	workspaceId := keys[0].String()
+	notificationConfigurations := tfcServer.WorkspaceNotificationConfigurations(workspaceId)
+	assert.Equal(t, 1, len(notificationConfigurations))
+	assert.Equal(t, RunNotificationConfigurationName, notificationConfigurations[0].Name)
+	assert.Equal(t, tfe.NotificationDestinationTypeGeneric, notificationConfigurations[0].DestinationType)
+	assert.Equal(t, "https://notify-me.lambda-url.narnia-west-2.on.aws/", notificationConfigurations[0].URL)
+	assert.Equal(t, "s1gn-me-up", notificationConfigurations[0].Token)
+	assert.True(t, notificationConfigurations[0].Enabled)
+	assert.ElementsMatch(t, []string{"run:completed", "run:errored", "run:needs_attention"}, notificationConfigurations[0].Triggers)
+
+	// Send the request again, after the notification token changed
+	mockNotificationToken.Token = "s1gn-me-up-again"
+	_, err = testHandler.HandleRequest(context.Background(), testRequest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Verify the existing notification configuration was updated, rather than a new one being created
+	notificationConfigurations = tfcServer.WorkspaceNotificationConfigurations(workspaceId)
+	assert.Equal(t, 1, len(notificationConfigurations))
+	assert.Equal(t, "s1gn-me-up-again", notificationConfigurations[0].Token)
+}
+
 func TestSendApplyHandler_ErrorFetchingArtifactFromS3(t *testing.T) {
 	// Create mock TFC instance
 	tfcServer := testtfc.NewMockTFC()
@@ -362,20 +447,20 @@ func TestSendApplyHandler_ErrorFetchingArtifactFromS3(t *testing.T) {
 	}
 
 	// Create test request
-	testRequest := SendApplyRequest{
+	testRequest := model.SendApplyRequest{
 		AwsAccountId:          "123456789042",
 		TerraformOrganization: tfcServer.OrganizationName,
 		ProvisionedProductId:  "amazingly-great-product-instance",
-		Artifact: Artifact{
+		Artifact: model.Artifact{
 			Path: "s3://wowzers-this-is-some/fake/artifact/path",
-			Type: "beeg-test",
+			Type: "AWS_S3",
 		},
-		LaunchRoleArn: "arn:::some/fake/role/arn",
+		LaunchRoleArn: "arn:aws:iam::123456789042:role/some-fake-role",
 		ProductId:     "id-4-number-1-best-product",
-		Tags:          make([]AWSTag, 0),
-		TracerTag: tracertag.TracerTag{
-			TracerTagKey:   "test-tracer-tag-key",
-			TracerTagValue: "test-trace-tag-value",
+		Tags:          make([]model.Tag, 0),
+		TracerTag: model.TracerTag{
+			Key:   "test-tracer-tag-key",
+			Value: "test-trace-tag-value",
 		},
 	}
 
@@ -11,39 +11,10 @@ import (
 	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/awsconfig"
 	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/fileutils"
 	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/secretsmanager"
-	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/tracertag"
 	"log"
 	"os"
 )
 
-type SendApplyRequest struct {
-	AwsAccountId          string              `json:"awsAccountId"`
-	TerraformOrganization string              `json:"terraformOrganization"`
-	ProvisionedProductId  string              `json:"provisionedProductId"`
-	ProvisionedArtifactId string              `json:"provisioningArtifactId"`
-	Artifact              Artifact            `json:"artifact"`
-	LaunchRoleArn         string              `json:"launchRoleArn"`
-	ProductId             string              `json:"productId"`
-	Parameters            []Parameter         `json:"parameters"`
-	Tags                  []AWSTag            `json:"tags"`
-	TracerTag             tracertag.TracerTag `json:"tracerTag"`
-}
-
-type Parameter struct {
-	Key   string `json:"key"`
-	Value string `json:"value"`
-}
-
-type AWSTag struct {
-	Key   string `json:"key"`
-	Value string `json:"value"`
-}
-
-type Artifact struct {
-	Path string `json:"path"`
-	Type string `json:"type"`
-}
-
 type SendApplyResponse struct {
 	TerraformRunId string `json:"terraformRunId"`
 }
@@ -66,12 +37,21 @@ func main() {
 	// Get Terraform Version
 	terraformVersion := os.Getenv("TERRAFORM_VERSION")
 
+	// Check if runs must be approved before they are applied
+	requireRunApproval := os.Getenv("REQUIRE_RUN_APPROVAL") == "true"
+
+	// Get the URL that TFC sends run notifications to
+	runNotificationUrl := os.Getenv("RUN_NOTIFICATION_URL")
+
 	// Create the handler
 	handler := &SendApplyHandler{
-		s3Downloader:     s3Downloader,
-		secretsManager:   secretsManager,
-		region:           sdkConfig.Region,
-		terraformVersion: terraformVersion,
+		s3Downloader:       s3Downloader,
+		secretsManager:     secretsManager,
+		region:             sdkConfig.Region,
+		terraformVersion:   terraformVersion,
+		requireRunApproval: requireRunApproval,
+		runNotificationUrl: runNotificationUrl,
+		notificationToken:  secretsmanager.NewNotificationTokenWithConfig(sdkConfig),
 	}
 
 	// Start the lambda using the handler
@@ -8,7 +8,7 @@ package main
 import (
 	"encoding/json"
 	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/fileutils"
-	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/tracertag"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/model"
 	"io"
 	"log"
 	"os"
@@ -19,7 +19,7 @@ type ConfigurationOverride struct {
 	fileContents string
 }
 
-func CreateAWSProviderOverrides(region string, tags []AWSTag, tracerTag tracertag.TracerTag) (*ConfigurationOverride, error) {
+func CreateAWSProviderOverrides(region string, tags []model.Tag, tracerTag model.TracerTag) (*ConfigurationOverride, error) {
 	// Format AWS billing tags
 	formattedTags := map[string]interface{}{}
 	for _, tag := range tags {
@@ -27,7 +27,7 @@ func CreateAWSProviderOverrides(region string, tags []AWSTag, tracerTag tracerta
 	}
 
 	// Add tracer tag for resource tracking
-	formattedTags[tracerTag.TracerTagKey] = tracerTag.TracerTagValue
+	formattedTags[tracerTag.Key] = tracerTag.Value
 
 	// The keys need to be strings, the values can be
 	// any serializable value
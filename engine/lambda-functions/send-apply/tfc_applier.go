@@ -7,6 +7,8 @@ package main
 
 import (
 	"context"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/model"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/secretsmanager"
 	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/tfc"
 	"github.com/hashicorp/go-tfe"
 	"log"
@@ -16,6 +18,9 @@ import (
 const ProviderAuthVariableKey = "TFC_AWS_PROVIDER_AUTH"
 const RunRoleArnVariableKey = "TFC_AWS_RUN_ROLE_ARN"
 
+// RunNotificationConfigurationName is the name of the notification configuration that notifies the engine about runs
+const RunNotificationConfigurationName = "AWS Service Catalog Engine"
+
 const ProductIdMetadataHeaderKey = "Tfp-Aws-Service-Catalog-Product-Id"
 const ProvisionedProductIdMetadataHeaderKey = "Tfp-Aws-Service-Catalog-Prv-Product-Id"
 const ProductVersionMetadataHeaderKey = "Tfp-Aws-Service-Catalog-Product-Ver"
@@ -25,14 +30,19 @@ type TFCApplier struct {
 	terraformVersion string
 }
 
-func (h *SendApplyHandler) NewTFCApplier(ctx context.Context, request SendApplyRequest) (*TFCApplier, error) {
+func (h *SendApplyHandler) NewTFCApplier(ctx context.Context, request model.SendApplyRequest) (*TFCApplier, error) {
 	headers := http.Header{}
 
 	headers.Set(ProductIdMetadataHeaderKey, request.ProductId)
 	headers.Set(ProvisionedProductIdMetadataHeaderKey, request.ProvisionedProductId)
 	headers.Set(ProductVersionMetadataHeaderKey, request.ProvisionedArtifactId)
 
-	tfeClient, err := tfc.GetTFEClientWithHeaders(ctx, h.secretsManager, headers)
+	organizationSecretsManager, err := secretsmanager.ForOrganization(h.secretsManager, request.TerraformOrganization)
+	if err != nil {
+		return nil, err
+	}
+
+	tfeClient, err := tfc.GetTFEClientWithHeaders(ctx, organizationSecretsManager, headers)
 	return &TFCApplier{
 		tfeClient:        tfeClient,
 		terraformVersion: h.terraformVersion,
@@ -152,7 +162,7 @@ func (applier *TFCApplier) UpdateWorkspaceOIDCVariables(ctx context.Context, w *
 	return applier.FindOrCreateENVVariable(ctx, w, RunRoleArnVariableKey, launchRoleArn, "The AWS role ARN runs will use to authenticate.")
 }
 
-func (applier *TFCApplier) UpdateWorkspaceParameterVariables(ctx context.Context, w *tfe.Workspace, parameters []Parameter) error {
+func (applier *TFCApplier) UpdateWorkspaceParameterVariables(ctx context.Context, w *tfe.Workspace, parameters []model.Parameter) error {
 	for _, parameter := range parameters {
 		log.Default().Printf("Updating variable %s", parameter.Key)
 		err := applier.FindOrCreateTerraformVariable(ctx, w, parameter.Key, parameter.Value)
@@ -163,6 +173,67 @@ func (applier *TFCApplier) UpdateWorkspaceParameterVariables(ctx context.Context
 	return nil
 }
 
+// ConfigureRunNotifications creates or updates the notification configuration that sends the engine a notification when a run in the workspace completes, errors or needs attention
+func (applier *TFCApplier) ConfigureRunNotifications(ctx context.Context, w *tfe.Workspace, url string, token string) error {
+	triggers := []tfe.NotificationTriggerType{
+		tfe.NotificationTriggerCompleted,
+		tfe.NotificationTriggerErrored,
+		tfe.NotificationTriggerNeedsAttention,
+	}
+
+	notificationConfiguration, err := applier.findNotificationConfigurationByName(ctx, w, RunNotificationConfigurationName, 0)
+	if err != nil {
+		return err
+	}
+
+	if notificationConfiguration != nil {
+		log.Default().Printf("Updating run notification configuration with ID: %s", notificationConfiguration.ID)
+		_, err = applier.tfeClient.NotificationConfigurations.Update(ctx, notificationConfiguration.ID, tfe.NotificationConfigurationUpdateOptions{
+			Enabled:  tfe.Bool(true),
+			Token:    tfe.String(token),
+			Triggers: triggers,
+			URL:      tfe.String(url),
+		})
+		return tfc.Error(err)
+	}
+
+	log.Default().Printf("Creating run notification configuration")
+	_, err = applier.tfeClient.NotificationConfigurations.Create(ctx, w.ID, tfe.NotificationConfigurationCreateOptions{
+		DestinationType: tfe.NotificationDestination(tfe.NotificationDestinationTypeGeneric),
+		Enabled:         tfe.Bool(true),
+		Name:            tfe.String(RunNotificationConfigurationName),
+		Token:           tfe.String(token),
+		Triggers:        triggers,
+		URL:             tfe.String(url),
+	})
+	return tfc.Error(err)
+}
+
+func (applier *TFCApplier) findNotificationConfigurationByName(ctx context.Context, w *tfe.Workspace, name string, pageNumber int) (*tfe.NotificationConfiguration, error) {
+	notificationConfigurations, err := applier.tfeClient.NotificationConfigurations.List(ctx, w.ID, &tfe.NotificationConfigurationListOptions{
+		ListOptions: tfe.ListOptions{
+			PageNumber: pageNumber,
+			PageSize:   100,
+		},
+	})
+	if err != nil {
+		return nil, tfc.Error(err)
+	}
+
+	for _, notificationConfiguration := range notificationConfigurations.Items {
+		if notificationConfiguration.Name == name {
+			return notificationConfiguration, nil
+		}
+	}
+
+	// If more notification configurations exists, fetch them and check them as well
+	if notificationConfigurations.TotalCount > ((pageNumber + 1) * 100) {
+		return applier.findNotificationConfigurationByName(ctx, w, name, pageNumber+1)
+	}
+
+	return nil, nil
+}
+
 func (applier *TFCApplier) CreateConfigurationVersion(ctx context.Context, workspaceId string) (*tfe.ConfigurationVersion, error) {
 	newConfigurationVersion, err := applier.tfeClient.ConfigurationVersions.Create(ctx,
 		workspaceId,
@@ -239,7 +310,7 @@ func (applier *TFCApplier) findVariableByKey(ctx context.Context, w *tfe.Workspa
 }
 
 // PurgeVariables purges all non-recognized variables from the workspace. This helps ensure parity between Service Catalog and TFC
-func (applier *TFCApplier) PurgeVariables(ctx context.Context, w *tfe.Workspace, parameters []Parameter) error {
+func (applier *TFCApplier) PurgeVariables(ctx context.Context, w *tfe.Workspace, parameters []model.Parameter) error {
 	log.Default().Printf("building lookups for unrecognized variables in workspace")
 	allowedTerraformVarKeysMap := map[string]bool{}
 	for _, parameter := range parameters {
@@ -7,6 +7,8 @@ package main
 
 import (
 	"context"
+	"errors"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/model"
 	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/secretsmanager"
 	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/tfc"
 	"github.com/hashicorp/go-tfe"
@@ -14,12 +16,22 @@ import (
 )
 
 type SendDestroyHandler struct {
-	secretsManager secretsmanager.SecretsManager
+	secretsManager     secretsmanager.SecretsManager
+	requireRunApproval bool
 }
 
-func (h *SendDestroyHandler) HandleRequest(ctx context.Context, request SendDestroyRequest) (*SendDestroyResponse, error) {
+func (h *SendDestroyHandler) HandleRequest(ctx context.Context, request model.SendDestroyRequest) (*SendDestroyResponse, error) {
+	if err := request.Validate(); err != nil {
+		return nil, err
+	}
+
 	// Get TFE Client
-	tfeClient, err := tfc.GetTFEClient(ctx, h.secretsManager)
+	organizationSecretsManager, err := secretsmanager.ForOrganization(h.secretsManager, request.TerraformOrganization)
+	if err != nil {
+		log.Default().Printf("failed to find the TFE credentials: %s", err)
+		return nil, err
+	}
+	tfeClient, err := tfc.GetTFEClient(ctx, organizationSecretsManager)
 	if err != nil {
 		log.Default().Printf("failed to initialize TFE client: %s", err)
 		return nil, err
@@ -29,6 +41,19 @@ func (h *SendDestroyHandler) HandleRequest(ctx context.Context, request SendDest
 
 	// Get the workspace
 	workspace, err := tfeClient.Workspaces.Read(ctx, request.TerraformOrganization, workspaceId)
+	if errors.Is(err, tfe.ErrResourceNotFound) {
+		// The workspace may never have been created, e.g. when provisioning failed early, or it was deleted by hand
+		missing, err := isWorkspaceMissing(ctx, tfeClient, request.TerraformOrganization)
+		if err != nil {
+			log.Default().Printf("Workspace couldn't be found, and failed to confirm it does not exist: %s", err)
+			return nil, tfc.Error(err)
+		}
+		if missing {
+			log.Default().Printf("Workspace %s does not exist, so there is nothing to destroy. Considering the provisioned product terminated", workspaceId)
+			return &SendDestroyResponse{WorkspaceMissing: true}, nil
+		}
+		log.Default().Printf("Workspace %s couldn't be found, but the team may not have access to it", workspaceId)
+	}
 	if err != nil {
 		log.Default().Printf("Workspace does not exist or couldn't be found: %s", err)
 		return nil, tfc.Error(err)
@@ -39,7 +64,7 @@ func (h *SendDestroyHandler) HandleRequest(ctx context.Context, request SendDest
 		IsDestroy: tfe.Bool(true),
 		Message:   tfe.String("Terminating example-product via AWS Service Catalog"),
 		Workspace: workspace,
-		AutoApply: tfe.Bool(true),
+		AutoApply: tfe.Bool(!h.requireRunApproval),
 	})
 	if err != nil {
 		log.Default().Printf("Failed to queue destroy run: %s", err)
@@ -48,3 +73,15 @@ func (h *SendDestroyHandler) HandleRequest(ctx context.Context, request SendDest
 
 	return &SendDestroyResponse{TerraformRunId: run.ID}, err
 }
+
+// isWorkspaceMissing confirms that the workspace does not exist. TFC responds with "not found" to requests for
+// workspaces the team has no access to as well, and hides those workspaces from listings, so the workspace is only
+// missing if the team may manage all workspaces of the organization, which lets it read every one of them.
+func isWorkspaceMissing(ctx context.Context, client *tfe.Client, organization string) (bool, error) {
+	org, err := client.Organizations.Read(ctx, organization)
+	if err != nil {
+		return false, err
+	}
+
+	return org.Permissions != nil && org.Permissions.CanCreateWorkspace, nil
+}
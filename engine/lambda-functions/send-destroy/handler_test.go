@@ -8,6 +8,7 @@ package main
 import (
 	"context"
 	"fmt"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/model"
 	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/testutil/secretsmanager"
 	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/testutil/testtfc"
 	"github.com/stretchr/testify/assert"
@@ -36,7 +37,7 @@ func TestSendDestroyHandler_Success(t *testing.T) {
 	}
 
 	// Create test request
-	testRequest := SendDestroyRequest{
+	testRequest := model.SendDestroyRequest{
 		AwsAccountId:          "123456789042",
 		TerraformOrganization: tfcServer.OrganizationName,
 		ProvisionedProductId:  "amazingly-great-product-instance",
@@ -55,6 +56,45 @@ func TestSendDestroyHandler_Success(t *testing.T) {
 
 	assert.NotNil(t, destroyRun, "A run should have been created")
 	assert.True(t, destroyRun.IsDestroy, "The new run should be a destroy run")
+	assert.True(t, destroyRun.AutoApply, "The new run should be applied automatically")
+}
+
+func TestSendDestroyHandler_SuccessRequiringApproval(t *testing.T) {
+	// Create mock TFC instance
+	tfcServer := testtfc.NewMockTFC()
+	defer tfcServer.Stop()
+
+	tfcServer.AddWorkspace("123456789042-amazingly-great-product-instance", testtfc.WorkspaceFactoryParameters{
+		Name: "123456789042-amazingly-great-product-instance",
+	})
+
+	// Create tfe client that will send requests to the mock TFC instance
+	mockSecretsManager := &secretsmanager.MockSecretsManager{
+		Hostname: tfcServer.Address,
+		TeamId:   "team-4123nlol",
+		Token:    "supers3cret",
+	}
+
+	// Create a test instance of the Lambda function, that requires runs to be approved
+	testHandler := &SendDestroyHandler{
+		secretsManager:     mockSecretsManager,
+		requireRunApproval: true,
+	}
+
+	// Send the test request
+	response, err := testHandler.HandleRequest(context.Background(), model.SendDestroyRequest{
+		AwsAccountId:          "123456789042",
+		TerraformOrganization: tfcServer.OrganizationName,
+		ProvisionedProductId:  "amazingly-great-product-instance",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Check the destroy run waits for approval
+	destroyRun := tfcServer.Runs[fmt.Sprintf("/api/v2/runs/%s", response.TerraformRunId)]
+	assert.True(t, destroyRun.IsDestroy, "The new run should be a destroy run")
+	assert.False(t, destroyRun.AutoApply, "The new run should not have been applied automatically")
 }
 
 func TestSendDestroyHandler_WorkspaceMissing(t *testing.T) {
@@ -75,7 +115,76 @@ func TestSendDestroyHandler_WorkspaceMissing(t *testing.T) {
 	}
 
 	// Create test request
-	testRequest := SendDestroyRequest{
+	testRequest := model.SendDestroyRequest{
+		AwsAccountId:          "123456789042",
+		TerraformOrganization: tfcServer.OrganizationName,
+		ProvisionedProductId:  "amazingly-great-product-instance",
+	}
+
+	// Send the test request
+	response, err := testHandler.HandleRequest(context.Background(), testRequest)
+
+	// Verify the workspace was reported as missing, without a destroy run
+	assert.NoError(t, err)
+	assert.True(t, response.WorkspaceMissing, "The workspace should have been reported as missing")
+	assert.Equal(t, "", response.TerraformRunId)
+	assert.Equal(t, 0, len(tfcServer.Runs), "No run should have been created")
+}
+
+func TestSendDestroyHandler_WorkspaceNotAccessible(t *testing.T) {
+	// Create mock TFC instance
+	tfcServer := testtfc.NewMockTFC()
+	defer tfcServer.Stop()
+
+	// Create the TFE client that will send requests to the mock TFC instance
+	mockSecretsManager := &secretsmanager.MockSecretsManager{
+		Hostname: tfcServer.Address,
+		TeamId:   "team-4123nlol",
+		Token:    "supers3cret",
+	}
+
+	// The team cannot manage all workspaces of the organization, so it may not see the workspace
+	tfcServer.CanManageWorkspaces = false
+
+	// Create a test instance of the Lambda function
+	testHandler := &SendDestroyHandler{
+		secretsManager: mockSecretsManager,
+	}
+
+	// Create test request
+	testRequest := model.SendDestroyRequest{
+		AwsAccountId:          "123456789042",
+		TerraformOrganization: tfcServer.OrganizationName,
+		ProvisionedProductId:  "amazingly-great-product-instance",
+	}
+
+	// Send the test request
+	_, err := testHandler.HandleRequest(context.Background(), testRequest)
+	// Verify the handler returned an error, instead of considering the provisioned product terminated
+	assert.Error(t, err, "Handler should have responded with an error")
+	assert.Equal(t, 0, len(tfcServer.Runs), "No run should have been created")
+}
+
+func TestSendDestroyHandler_Unauthorized(t *testing.T) {
+	// Create mock TFC instance
+	tfcServer := testtfc.NewMockTFC()
+	defer tfcServer.Stop()
+	tfcServer.SetToken("the-actual-token")
+
+	// Create the TFE client that will send requests to the mock TFC instance, with an outdated token
+	mockSecretsManager := &secretsmanager.MockSecretsManager{
+		Hostname: tfcServer.Address,
+		TeamId:   "team-4123nlol",
+		Token:    "supers3cret",
+	}
+
+	// Create a test instance of the Lambda function
+	testHandler := &SendDestroyHandler{
+		secretsManager: mockSecretsManager,
+	}
+
+	// Create test request
+	testRequest := model.SendDestroyRequest{
 		AwsAccountId:          "123456789042",
 		TerraformOrganization: tfcServer.OrganizationName,
 		ProvisionedProductId:  "amazingly-great-product-instance",
@@ -86,3 +195,128 @@ func TestSendDestroyHandler_WorkspaceMissing(t *testing.T) {
 	// Verify the handler returned an error
 	assert.Error(t, err, "Handler should have responded with an error")
 }
+
+func TestSendDestroyHandler_SuccessAfterTokenRotation(t *testing.T) {
+	// Create mock TFC instance, that only accepts the current token
+	tfcServer := testtfc.NewMockTFC()
+	defer tfcServer.Stop()
+	tfcServer.SetToken("supers3cret")
+
+	tfcServer.AddWorkspace("123456789042-amazingly-great-product-instance", testtfc.WorkspaceFactoryParameters{
+		Name: "123456789042-amazingly-great-product-instance",
+	})
+
+	// Create the TFE client that will send requests to the mock TFC instance
+	mockSecretsManager := &secretsmanager.MockSecretsManager{
+		Hostname: tfcServer.Address,
+		TeamId:   "team-4123nlol",
+		Token:    "supers3cret",
+	}
+
+	// Create a test instance of the Lambda function
+	testHandler := &SendDestroyHandler{
+		secretsManager: mockSecretsManager,
+	}
+
+	// Create test request
+	testRequest := model.SendDestroyRequest{
+		AwsAccountId:          "123456789042",
+		TerraformOrganization: tfcServer.OrganizationName,
+		ProvisionedProductId:  "amazingly-great-product-instance",
+	}
+
+	// Send the test request, caching the credentials
+	_, err := testHandler.HandleRequest(context.Background(), testRequest)
+	assert.NoError(t, err)
+
+	// Rotate the token, so the cached token is rejected as unauthorized
+	tfcServer.SetToken("newsupers3cret")
+	mockSecretsManager.Token = "newsupers3cret"
+
+	// Verify the request is retried with the rotated token
+	response, err := testHandler.HandleRequest(context.Background(), testRequest)
+	assert.NoError(t, err)
+
+	runPath := fmt.Sprintf("/api/v2/runs/%s", response.TerraformRunId)
+	assert.NotNil(t, tfcServer.Runs[runPath], "A run should have been created")
+}
+
+func TestSendDestroyHandler_SuccessWithOrganizationCredentials(t *testing.T) {
+	// Create mock TFC instances, of the default organization and of an organization with its own credentials
+	tfcServer := testtfc.NewMockTFC()
+	defer tfcServer.Stop()
+
+	otherTfcServer := testtfc.NewMockTFC()
+	defer otherTfcServer.Stop()
+	otherTfcServer.OrganizationName = "business-unit-on-tfe"
+
+	otherTfcServer.AddWorkspace("123456789042-amazingly-great-product-instance", testtfc.WorkspaceFactoryParameters{
+		Name: "123456789042-amazingly-great-product-instance",
+	})
+
+	// Create the TFE client that will send requests to the mock TFC instance of the organization
+	mockSecretsManager := &secretsmanager.MockSecretsManager{
+		Hostname: tfcServer.Address,
+		TeamId:   "team-4123nlol",
+		Token:    "supers3cret",
+		Organizations: map[string]*secretsmanager.MockSecretsManager{
+			"business-unit-on-tfe": {
+				Hostname: otherTfcServer.Address,
+				TeamId:   "team-otherteam",
+				Token:    "othersupers3cret",
+			},
+		},
+	}
+
+	// Create a test instance of the Lambda function
+	testHandler := &SendDestroyHandler{
+		secretsManager: mockSecretsManager,
+	}
+
+	// Send the test request
+	response, err := testHandler.HandleRequest(context.Background(), model.SendDestroyRequest{
+		AwsAccountId:          "123456789042",
+		TerraformOrganization: "business-unit-on-tfe",
+		ProvisionedProductId:  "amazingly-great-product-instance",
+	})
+	assert.NoError(t, err)
+
+	// Verify the run was created with the credentials of the organization
+	runPath := fmt.Sprintf("/api/v2/runs/%s", response.TerraformRunId)
+	assert.NotNil(t, otherTfcServer.Runs[runPath], "A run should have been created")
+	assert.Empty(t, tfcServer.Runs)
+}
+
+func TestSendDestroyHandler_FailureUnknownOrganization(t *testing.T) {
+	// Create mock TFC instance
+	tfcServer := testtfc.NewMockTFC()
+	defer tfcServer.Stop()
+
+	tfcServer.AddWorkspace("123456789042-amazingly-great-product-instance", testtfc.WorkspaceFactoryParameters{
+		Name: "123456789042-amazingly-great-product-instance",
+	})
+
+	// Create the TFE client that will send requests to the mock TFC instance of the default organization
+	mockSecretsManager := &secretsmanager.MockSecretsManager{
+		Hostname:     tfcServer.Address,
+		TeamId:       "team-4123nlol",
+		Token:        "supers3cret",
+		Organization: tfcServer.OrganizationName,
+	}
+
+	// Create a test instance of the Lambda function
+	testHandler := &SendDestroyHandler{
+		secretsManager: mockSecretsManager,
+	}
+
+	// Send a test request for an organization without credentials
+	_, err := testHandler.HandleRequest(context.Background(), model.SendDestroyRequest{
+		AwsAccountId:          "123456789042",
+		TerraformOrganization: "business-unit-without-credentials",
+		ProvisionedProductId:  "amazingly-great-product-instance",
+	})
+
+	// Verify the credentials of the default organization were not used instead
+	assert.EqualError(t, err, "no TFE credentials are configured for organization business-unit-without-credentials")
+	assert.Empty(t, tfcServer.Runs)
+}
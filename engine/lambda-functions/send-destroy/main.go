@@ -12,16 +12,14 @@ import (
 	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/awsconfig"
 	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/secretsmanager"
 	"log"
+	"os"
 )
 
-type SendDestroyRequest struct {
-	AwsAccountId          string `json:"awsAccountId"`
-	TerraformOrganization string `json:"terraformOrganization"`
-	ProvisionedProductId  string `json:"provisionedProductId"`
-}
-
 type SendDestroyResponse struct {
 	TerraformRunId string `json:"terraformRunId"`
+
+	// WorkspaceMissing is set when the workspace of the provisioned product does not exist, so there is nothing to destroy
+	WorkspaceMissing bool `json:"workspaceMissing"`
 }
 
 func main() {
@@ -37,8 +35,12 @@ func main() {
 		log.Fatalf("failed to initialize secrets manager client: %s", err)
 	}
 
+	// Check if destroy runs must be approved before they are applied
+	requireRunApproval := os.Getenv("REQUIRE_RUN_APPROVAL") == "true"
+
 	handler := SendDestroyHandler{
-		secretsManager: secretsManager,
+		secretsManager:     secretsManager,
+		requireRunApproval: requireRunApproval,
 	}
 
 	lambda.Start(handler.HandleRequest)
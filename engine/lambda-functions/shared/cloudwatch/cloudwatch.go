@@ -0,0 +1,23 @@
+/*
+ * Copyright (c) HashiCorp, Inc.
+ * SPDX-License-Identifier: MPL-2.0
+ */
+
+package cloudwatch
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+)
+
+type CloudWatch interface {
+	PutMetricData(ctx context.Context, input *cloudwatch.PutMetricDataInput) (*cloudwatch.PutMetricDataOutput, error)
+}
+
+type CW struct {
+	Client *cloudwatch.Client
+}
+
+func (cloudWatch CW) PutMetricData(ctx context.Context, input *cloudwatch.PutMetricDataInput) (*cloudwatch.PutMetricDataOutput, error) {
+	return cloudWatch.Client.PutMetricData(ctx, input)
+}
@@ -0,0 +1,150 @@
+/*
+ * Copyright (c) HashiCorp, Inc.
+ * SPDX-License-Identifier: MPL-2.0
+ */
+
+package enginestate
+
+import (
+	"context"
+	"errors"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"os"
+	"strconv"
+	"time"
+)
+
+const keyAttribute = "key"
+const valueAttribute = "value"
+const expiresAtAttribute = "expiresAt"
+
+// EngineState stores small pieces of state that the engine must share between Lambda invocations and state machine executions
+type EngineState interface {
+	// Get returns the item with the given key, or nil if there is no such item or it has expired
+	Get(ctx context.Context, key string) (*Item, error)
+	Put(ctx context.Context, item Item) error
+	// PutIfAbsent puts the item unless an unexpired item with the same key exists, and returns whether it was put
+	PutIfAbsent(ctx context.Context, item Item) (bool, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// Item is a single entry in the engine state. Items with a non-zero ExpiresAt are removed once they expire.
+type Item struct {
+	Key       string
+	Value     string
+	ExpiresAt time.Time
+}
+
+func (item Item) expired(now time.Time) bool {
+	return !item.ExpiresAt.IsZero() && !now.Before(item.ExpiresAt)
+}
+
+type DynamoDBEngineState struct {
+	Client    *dynamodb.Client
+	TableName string
+}
+
+// NewFromConfig creates a new engine state client, using the table from the ENGINE_STATE_TABLE_NAME env var
+func NewFromConfig(sdkConfig aws.Config) *DynamoDBEngineState {
+	return &DynamoDBEngineState{
+		Client:    dynamodb.NewFromConfig(sdkConfig),
+		TableName: os.Getenv("ENGINE_STATE_TABLE_NAME"),
+	}
+}
+
+func (state DynamoDBEngineState) Get(ctx context.Context, key string) (*Item, error) {
+	output, err := state.Client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(state.TableName),
+		Key:            keyOf(key),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil || output.Item == nil {
+		return nil, err
+	}
+
+	item := fromAttributes(output.Item)
+
+	// DynamoDB removes expired items lazily, so they must be filtered out here
+	if item.expired(time.Now()) {
+		return nil, nil
+	}
+
+	return item, nil
+}
+
+func (state DynamoDBEngineState) Put(ctx context.Context, item Item) error {
+	_, err := state.Client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(state.TableName),
+		Item:      toAttributes(item),
+	})
+	return err
+}
+
+func (state DynamoDBEngineState) PutIfAbsent(ctx context.Context, item Item) (bool, error) {
+	_, err := state.Client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(state.TableName),
+		Item:      toAttributes(item),
+		// Expired items may not have been removed by DynamoDB yet, and are replaced like missing items
+		ConditionExpression: aws.String("attribute_not_exists(#key) OR #expiresAt <= :now"),
+		ExpressionAttributeNames: map[string]string{
+			"#key":       keyAttribute,
+			"#expiresAt": expiresAtAttribute,
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":now": &types.AttributeValueMemberN{Value: strconv.FormatInt(time.Now().Unix(), 10)},
+		},
+	})
+
+	var conditionalCheckFailed *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionalCheckFailed) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (state DynamoDBEngineState) Delete(ctx context.Context, key string) error {
+	_, err := state.Client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(state.TableName),
+		Key:       keyOf(key),
+	})
+	return err
+}
+
+func keyOf(key string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		keyAttribute: &types.AttributeValueMemberS{Value: key},
+	}
+}
+
+func toAttributes(item Item) map[string]types.AttributeValue {
+	attributes := map[string]types.AttributeValue{
+		keyAttribute:   &types.AttributeValueMemberS{Value: item.Key},
+		valueAttribute: &types.AttributeValueMemberS{Value: item.Value},
+	}
+
+	if !item.ExpiresAt.IsZero() {
+		attributes[expiresAtAttribute] = &types.AttributeValueMemberN{Value: strconv.FormatInt(item.ExpiresAt.Unix(), 10)}
+	}
+
+	return attributes
+}
+
+func fromAttributes(attributes map[string]types.AttributeValue) *Item {
+	item := &Item{}
+
+	if key, ok := attributes[keyAttribute].(*types.AttributeValueMemberS); ok {
+		item.Key = key.Value
+	}
+	if value, ok := attributes[valueAttribute].(*types.AttributeValueMemberS); ok {
+		item.Value = value.Value
+	}
+	if expiresAt, ok := attributes[expiresAtAttribute].(*types.AttributeValueMemberN); ok {
+		if seconds, err := strconv.ParseInt(expiresAt.Value, 10, 64); err == nil {
+			item.ExpiresAt = time.Unix(seconds, 0)
+		}
+	}
+
+	return item
+}
@@ -0,0 +1,23 @@
+/*
+ * Copyright (c) HashiCorp, Inc.
+ * SPDX-License-Identifier: MPL-2.0
+ */
+
+package eventbridge
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+)
+
+type EventBridge interface {
+	PutEvents(ctx context.Context, input *eventbridge.PutEventsInput) (*eventbridge.PutEventsOutput, error)
+}
+
+type EB struct {
+	Client *eventbridge.Client
+}
+
+func (eventBridge EB) PutEvents(ctx context.Context, input *eventbridge.PutEventsInput) (*eventbridge.PutEventsOutput, error) {
+	return eventBridge.Client.PutEvents(ctx, input)
+}
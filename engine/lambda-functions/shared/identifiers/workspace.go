@@ -5,9 +5,31 @@
 
 package identifiers
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
+
+const awsAccountIdLength = 12
 
 // GetWorkspaceName gets the workspace name, which is `${accountId} - ${provisionedProductId}`
 func GetWorkspaceName(awsAccountId string, provisionedProductId string) string {
 	return fmt.Sprintf("%s-%s", awsAccountId, provisionedProductId)
 }
+
+// ParseWorkspaceName splits a workspace name built by GetWorkspaceName into the AWS account ID and the provisioned
+// product ID. It reports false for names that do not follow that convention, such as workspaces not managed by the engine.
+func ParseWorkspaceName(workspaceName string) (awsAccountId string, provisionedProductId string, ok bool) {
+	awsAccountId, provisionedProductId, found := strings.Cut(workspaceName, "-")
+	if !found || len(awsAccountId) != awsAccountIdLength || provisionedProductId == "" {
+		return "", "", false
+	}
+
+	for _, char := range awsAccountId {
+		if char < '0' || char > '9' {
+			return "", "", false
+		}
+	}
+
+	return awsAccountId, provisionedProductId, true
+}
@@ -0,0 +1,53 @@
+/*
+ * Copyright (c) HashiCorp, Inc.
+ * SPDX-License-Identifier: MPL-2.0
+ */
+
+// Package model defines the payloads passed between Service Catalog, the SQS queues, the state machines and the Lambda
+// functions of the engine, so every Lambda function reads and validates them the same way.
+package model
+
+// SchemaVersion is the version of the payloads this engine sends and understands. Payloads without a version were sent
+// before payloads were versioned, and are read as version 1.
+const SchemaVersion = 1
+
+// ServiceCatalogOperation is the operation of Service Catalog a run was sent for
+type ServiceCatalogOperation string
+
+// Enum values for ServiceCatalogOperation
+const (
+	Terminating  ServiceCatalogOperation = "TERMINATING"
+	Provisioning ServiceCatalogOperation = "PROVISIONING"
+	Updating     ServiceCatalogOperation = "UPDATING"
+)
+
+// Identity is who requested the operation, and the account the provisioned product is in
+type Identity struct {
+	Principal      string `json:"principal"`
+	AwsAccountId   string `json:"awsAccountId"`
+	OrganizationId string `json:"organizationId"`
+}
+
+// Artifact is where the configuration files of the product version are stored
+type Artifact struct {
+	Path string `json:"path"`
+	Type string `json:"type"`
+}
+
+// Parameter is a product parameter, set as a Terraform variable of the workspace
+type Parameter struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// Tag is an AWS tag of the provisioned product, added to the default tags of the AWS provider
+type Tag struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// TracerTag is the tag the state machines add to the AWS resources of a provisioned product, to trace them back to it
+type TracerTag struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
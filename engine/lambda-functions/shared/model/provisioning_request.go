@@ -0,0 +1,45 @@
+/*
+ * Copyright (c) HashiCorp, Inc.
+ * SPDX-License-Identifier: MPL-2.0
+ */
+
+package model
+
+// ProvisioningRequest is the message Service Catalog sends to the SQS queues of an operation. The provisioning
+// operations handlers pass it on as the input of the state machine of the operation, with the schema version and the
+// organization the request is routed to set.
+type ProvisioningRequest struct {
+	SchemaVersion          int         `json:"schemaVersion,omitempty"`
+	Token                  string      `json:"token"`
+	Operation              string      `json:"operation"`
+	ProductId              string      `json:"productId"`
+	ProvisionedProductId   string      `json:"provisionedProductId"`
+	ProvisionedProductName string      `json:"provisionedProductName"`
+	ProvisionedArtifactId  string      `json:"provisioningArtifactId"`
+	RecordId               string      `json:"recordId"`
+	LaunchRoleArn          string      `json:"launchRoleArn"`
+	TerraformOrganization  string      `json:"terraformOrganization"`
+	Identity               Identity    `json:"identity"`
+	TracerTag              TracerTag   `json:"tracerTag"`
+	Artifact               Artifact    `json:"artifact"`
+	Tags                   []Tag       `json:"tags"`
+	Parameters             []Parameter `json:"parameters"`
+}
+
+// Validate returns a ValidationError listing the fields of the request that are missing or invalid. The artifact is
+// only validated when it is set, as Service Catalog does not send one to terminate a provisioned product.
+func (r ProvisioningRequest) Validate() error {
+	v := &validator{}
+	v.schemaVersion(r.SchemaVersion)
+	v.required("token", r.Token)
+	v.required("provisionedProductId", r.ProvisionedProductId)
+	v.required("recordId", r.RecordId)
+	v.awsAccountId("identity.awsAccountId", r.Identity.AwsAccountId)
+	v.launchRoleArn("launchRoleArn", r.LaunchRoleArn)
+	if r.Artifact != (Artifact{}) {
+		v.artifact("artifact", r.Artifact)
+	}
+	v.tags("tags", r.Tags)
+	v.parameters("parameters", r.Parameters)
+	return v.err("provisioning request")
+}
@@ -0,0 +1,118 @@
+/*
+ * Copyright (c) HashiCorp, Inc.
+ * SPDX-License-Identifier: MPL-2.0
+ */
+
+package model
+
+import (
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// DefaultArtifactType is the only type of artifact the engine can download
+const DefaultArtifactType = "AWS_S3"
+
+var awsAccountIdPattern = regexp.MustCompile(`^\d{12}$`)
+
+// FieldError is a field of a payload that is missing or invalid
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+func (e FieldError) Error() string {
+	return fmt.Sprintf("%s %s", e.Field, e.Message)
+}
+
+// ValidationError lists the fields of a payload that are missing or invalid
+type ValidationError struct {
+	Payload string
+	Fields  []FieldError
+}
+
+func (e ValidationError) Error() string {
+	messages := make([]string, len(e.Fields))
+	for i, field := range e.Fields {
+		messages[i] = field.Error()
+	}
+	return fmt.Sprintf("invalid %s: %s", e.Payload, strings.Join(messages, "; "))
+}
+
+// validator collects the field errors of a payload
+type validator struct {
+	fields []FieldError
+}
+
+func (v *validator) fail(field string, format string, args ...any) {
+	v.fields = append(v.fields, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) required(field string, value string) bool {
+	if value == "" {
+		v.fail(field, "is required and must be non empty")
+		return false
+	}
+	return true
+}
+
+func (v *validator) schemaVersion(version int) {
+	if version < 0 || version > SchemaVersion {
+		v.fail("schemaVersion", "%d is not supported, must be at most %d", version, SchemaVersion)
+	}
+}
+
+func (v *validator) awsAccountId(field string, value string) {
+	if v.required(field, value) && !awsAccountIdPattern.MatchString(value) {
+		v.fail(field, "%s is not a 12 digit AWS account ID", value)
+	}
+}
+
+func (v *validator) launchRoleArn(field string, value string) {
+	if value == "" {
+		return
+	}
+
+	launchRoleArn, err := arn.Parse(value)
+	if err != nil {
+		v.fail(field, "%s is not a syntactically valid ARN", value)
+	} else if launchRoleArn.Service != "iam" {
+		v.fail(field, "%s is not a valid iam ARN", value)
+	}
+}
+
+func (v *validator) artifact(field string, artifact Artifact) {
+	if v.required(field+".type", artifact.Type) && artifact.Type != DefaultArtifactType {
+		v.fail(field+".type", "%s is not supported, must be %s", artifact.Type, DefaultArtifactType)
+	}
+
+	if v.required(field+".path", artifact.Path) {
+		artifactUri, err := url.Parse(artifact.Path)
+		if err != nil || artifactUri.Scheme != "s3" || artifactUri.Host == "" || artifactUri.Path == "" {
+			v.fail(field+".path", "%s is not a valid S3 URI", artifact.Path)
+		}
+	}
+}
+
+func (v *validator) parameters(field string, parameters []Parameter) {
+	for i, parameter := range parameters {
+		v.required(fmt.Sprintf("%s[%d].key", field, i), parameter.Key)
+	}
+}
+
+func (v *validator) tags(field string, tags []Tag) {
+	for i, tag := range tags {
+		v.required(fmt.Sprintf("%s[%d].key", field, i), tag.Key)
+	}
+}
+
+// err returns the field errors of the payload, or nil if it is valid
+func (v *validator) err(payload string) error {
+	if len(v.fields) == 0 {
+		return nil
+	}
+	return ValidationError{Payload: payload, Fields: v.fields}
+}
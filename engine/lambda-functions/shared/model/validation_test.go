@@ -0,0 +1,95 @@
+/*
+ * Copyright (c) HashiCorp, Inc.
+ * SPDX-License-Identifier: MPL-2.0
+ */
+
+package model
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func validSendApplyRequest() SendApplyRequest {
+	return SendApplyRequest{
+		SchemaVersion:         SchemaVersion,
+		AwsAccountId:          "123456789042",
+		TerraformOrganization: "team-rocket",
+		ProvisionedProductId:  "pp-amazinglygreat",
+		Artifact:              Artifact{Path: "s3://wowzers-this-is-some/artifact.tar.gz", Type: DefaultArtifactType},
+		LaunchRoleArn:         "arn:aws:iam::123456789042:role/launch-role",
+		ProductId:             "prod-numberonebest",
+		Parameters:            []Parameter{{Key: "keep_me", Value: "i want to live!"}},
+		TracerTag:             TracerTag{Key: "SERVICE_CATALOG_TERRAFORM_INTEGRATION-DO_NOT_DELETE", Value: "pp-amazinglygreat"},
+	}
+}
+
+func TestSendApplyRequest_Valid(t *testing.T) {
+	assert.NoError(t, validSendApplyRequest().Validate())
+}
+
+func TestSendApplyRequest_UnversionedIsValid(t *testing.T) {
+	request := validSendApplyRequest()
+	request.SchemaVersion = 0
+
+	assert.NoError(t, request.Validate())
+}
+
+func TestSendApplyRequest_FieldErrors(t *testing.T) {
+	request := validSendApplyRequest()
+	request.SchemaVersion = SchemaVersion + 1
+	request.AwsAccountId = "1234"
+	request.TerraformOrganization = ""
+	request.Artifact = Artifact{Path: "s3://bucket-without-key", Type: "GIT"}
+	request.LaunchRoleArn = "arn:aws:s3:::not-a-role"
+	request.Parameters = append(request.Parameters, Parameter{Value: "i have no name"})
+
+	err := request.Validate()
+
+	var validationErr ValidationError
+	if assert.ErrorAs(t, err, &validationErr) {
+		assert.Equal(t, "send apply request", validationErr.Payload)
+		assert.Equal(t, []FieldError{
+			{Field: "schemaVersion", Message: "2 is not supported, must be at most 1"},
+			{Field: "awsAccountId", Message: "1234 is not a 12 digit AWS account ID"},
+			{Field: "terraformOrganization", Message: "is required and must be non empty"},
+			{Field: "artifact.type", Message: "GIT is not supported, must be AWS_S3"},
+			{Field: "artifact.path", Message: "s3://bucket-without-key is not a valid S3 URI"},
+			{Field: "launchRoleArn", Message: "arn:aws:s3:::not-a-role is not a valid iam ARN"},
+			{Field: "parameters[1].key", Message: "is required and must be non empty"},
+		}, validationErr.Fields)
+	}
+	assert.EqualError(t, err, "invalid send apply request: schemaVersion 2 is not supported, must be at most 1; awsAccountId 1234 is not a 12 digit AWS account ID; terraformOrganization is required and must be non empty; artifact.type GIT is not supported, must be AWS_S3; artifact.path s3://bucket-without-key is not a valid S3 URI; launchRoleArn arn:aws:s3:::not-a-role is not a valid iam ARN; parameters[1].key is required and must be non empty")
+}
+
+func TestProvisioningRequest_TerminateWithoutArtifact(t *testing.T) {
+	request := ProvisioningRequest{}
+	err := json.Unmarshal([]byte(`{
+		"token": "tolkien",
+		"operation": "TERMINATE_PROVISIONED_PRODUCT",
+		"provisionedProductId": "pp-amazinglygreat",
+		"recordId": "rec-thebestrecord",
+		"identity": {"awsAccountId": "123456789042"},
+		"tracerTag": {"key": "the-best-key", "value": "the-best-value"}
+	}`), &request)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.NoError(t, request.Validate())
+	assert.Equal(t, TracerTag{Key: "the-best-key", Value: "the-best-value"}, request.TracerTag)
+}
+
+func TestNotifyRunResultRequest_UnknownOperation(t *testing.T) {
+	request := NotifyRunResultRequest{
+		WorkflowToken:           "whistle-while-you-work",
+		RecordId:                "rec-thebestrecord",
+		ServiceCatalogOperation: "IMPORTING",
+		AwsAccountId:            "123456789042",
+		TerraformOrganization:   "team-rocket",
+		ProvisionedProductId:    "pp-amazinglygreat",
+	}
+
+	assert.EqualError(t, request.Validate(), `invalid notify run result request: serviceCatalogOperation "IMPORTING" is not one of PROVISIONING, UPDATING or TERMINATING`)
+}
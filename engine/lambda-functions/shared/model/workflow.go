@@ -0,0 +1,112 @@
+/*
+ * Copyright (c) HashiCorp, Inc.
+ * SPDX-License-Identifier: MPL-2.0
+ */
+
+package model
+
+// SendApplyRequest is the payload the state machines send the send apply Lambda function, to start a run applying the
+// configuration of the product version
+type SendApplyRequest struct {
+	SchemaVersion         int         `json:"schemaVersion,omitempty"`
+	AwsAccountId          string      `json:"awsAccountId"`
+	TerraformOrganization string      `json:"terraformOrganization"`
+	ProvisionedProductId  string      `json:"provisionedProductId"`
+	ProvisionedArtifactId string      `json:"provisioningArtifactId"`
+	Artifact              Artifact    `json:"artifact"`
+	LaunchRoleArn         string      `json:"launchRoleArn"`
+	ProductId             string      `json:"productId"`
+	Parameters            []Parameter `json:"parameters"`
+	Tags                  []Tag       `json:"tags"`
+	TracerTag             TracerTag   `json:"tracerTag"`
+}
+
+// Validate returns a ValidationError listing the fields of the request that are missing or invalid
+func (r SendApplyRequest) Validate() error {
+	v := &validator{}
+	v.schemaVersion(r.SchemaVersion)
+	v.awsAccountId("awsAccountId", r.AwsAccountId)
+	v.required("terraformOrganization", r.TerraformOrganization)
+	v.required("provisionedProductId", r.ProvisionedProductId)
+	v.required("productId", r.ProductId)
+	v.artifact("artifact", r.Artifact)
+	v.launchRoleArn("launchRoleArn", r.LaunchRoleArn)
+	v.parameters("parameters", r.Parameters)
+	v.tags("tags", r.Tags)
+	v.required("tracerTag.key", r.TracerTag.Key)
+	return v.err("send apply request")
+}
+
+// SendDestroyRequest is the payload the terminate state machine sends the send destroy Lambda function, to start a run
+// destroying the resources of the provisioned product
+type SendDestroyRequest struct {
+	SchemaVersion         int    `json:"schemaVersion,omitempty"`
+	AwsAccountId          string `json:"awsAccountId"`
+	TerraformOrganization string `json:"terraformOrganization"`
+	ProvisionedProductId  string `json:"provisionedProductId"`
+}
+
+// Validate returns a ValidationError listing the fields of the request that are missing or invalid
+func (r SendDestroyRequest) Validate() error {
+	v := &validator{}
+	v.schemaVersion(r.SchemaVersion)
+	v.awsAccountId("awsAccountId", r.AwsAccountId)
+	v.required("terraformOrganization", r.TerraformOrganization)
+	v.required("provisionedProductId", r.ProvisionedProductId)
+	return v.err("send destroy request")
+}
+
+// PollRunStatusRequest is the payload the state machines send the poll run status Lambda function, to read the status
+// of a run. The link to the run is only reported when the workspace of the run is known.
+type PollRunStatusRequest struct {
+	SchemaVersion         int    `json:"schemaVersion,omitempty"`
+	TerraformRunId        string `json:"terraformRunId"`
+	AwsAccountId          string `json:"awsAccountId"`
+	TerraformOrganization string `json:"terraformOrganization"`
+	ProvisionedProductId  string `json:"provisionedProductId"`
+}
+
+// Validate returns a ValidationError listing the fields of the request that are missing or invalid
+func (r PollRunStatusRequest) Validate() error {
+	v := &validator{}
+	v.schemaVersion(r.SchemaVersion)
+	v.required("terraformRunId", r.TerraformRunId)
+	if r.AwsAccountId != "" {
+		v.awsAccountId("awsAccountId", r.AwsAccountId)
+	}
+	return v.err("poll run status request")
+}
+
+// NotifyRunResultRequest is the payload the state machines send the notify run result Lambda function, to report the
+// result of the operation to Service Catalog. The ID of the run is empty if the operation failed before the run was
+// created, and the error is set if it failed.
+type NotifyRunResultRequest struct {
+	SchemaVersion           int                     `json:"schemaVersion,omitempty"`
+	TerraformRunId          string                  `json:"terraformRunId"`
+	WorkflowToken           string                  `json:"workflowToken"`
+	RecordId                string                  `json:"recordId"`
+	TracerTag               TracerTag               `json:"tracerTag"`
+	ServiceCatalogOperation ServiceCatalogOperation `json:"serviceCatalogOperation"`
+	AwsAccountId            string                  `json:"awsAccountId"`
+	TerraformOrganization   string                  `json:"terraformOrganization"`
+	ProvisionedProductId    string                  `json:"provisionedProductId"`
+	Error                   string                  `json:"error"`
+	ErrorMessage            string                  `json:"errorMessage"`
+}
+
+// Validate returns a ValidationError listing the fields of the request that are missing or invalid
+func (r NotifyRunResultRequest) Validate() error {
+	v := &validator{}
+	v.schemaVersion(r.SchemaVersion)
+	v.required("workflowToken", r.WorkflowToken)
+	v.required("recordId", r.RecordId)
+	switch r.ServiceCatalogOperation {
+	case Terminating, Provisioning, Updating:
+	default:
+		v.fail("serviceCatalogOperation", "%q is not one of %s, %s or %s", r.ServiceCatalogOperation, Provisioning, Updating, Terminating)
+	}
+	v.awsAccountId("awsAccountId", r.AwsAccountId)
+	v.required("terraformOrganization", r.TerraformOrganization)
+	v.required("provisionedProductId", r.ProvisionedProductId)
+	return v.err("notify run result request")
+}
@@ -0,0 +1,74 @@
+/*
+ * Copyright (c) HashiCorp, Inc.
+ * SPDX-License-Identifier: MPL-2.0
+ */
+
+package runwaiter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sfn"
+	"github.com/aws/aws-sdk-go-v2/service/sfn/types"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/enginestate"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/stepfunctions"
+	"github.com/hashicorp/go-tfe"
+	"log"
+	"time"
+)
+
+// RunCompletion is the output of the state machine task that waits for a run, once the run settled
+type RunCompletion struct {
+	TerraformRunId string        `json:"terraformRunId"`
+	RunStatus      tfe.RunStatus `json:"runStatus"`
+}
+
+// Key is the key of the engine state item holding the task token of the state machine execution waiting for the run
+func Key(runId string) string {
+	return fmt.Sprintf("run-waiter#%s", runId)
+}
+
+// Register stores the task token of the state machine execution waiting for the run, until the wait times out
+func Register(ctx context.Context, state enginestate.EngineState, runId string, taskToken string, expiresAt time.Time) error {
+	return state.Put(ctx, enginestate.Item{
+		Key:       Key(runId),
+		Value:     taskToken,
+		ExpiresAt: expiresAt,
+	})
+}
+
+// Complete resumes the state machine execution waiting for the run, if there is one. Returns whether an execution was resumed.
+func Complete(ctx context.Context, state enginestate.EngineState, stepFunctions stepfunctions.StepFunctions, completion RunCompletion) (bool, error) {
+	item, err := state.Get(ctx, Key(completion.TerraformRunId))
+	if err != nil || item == nil {
+		return false, err
+	}
+
+	output, err := json.Marshal(completion)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = stepFunctions.SendTaskSuccess(ctx, &sfn.SendTaskSuccessInput{
+		TaskToken: aws.String(item.Value),
+		Output:    aws.String(string(output)),
+	})
+
+	// The execution may have stopped waiting already, because the wait timed out or another notification completed it
+	var taskTimedOut *types.TaskTimedOut
+	var invalidToken *types.InvalidToken
+	var taskDoesNotExist *types.TaskDoesNotExist
+	resumed := err == nil
+	if errors.As(err, &taskTimedOut) || errors.As(err, &invalidToken) || errors.As(err, &taskDoesNotExist) {
+		log.Default().Printf("execution waiting for run %s is no longer waiting: %s", completion.TerraformRunId, err)
+		err = nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return resumed, state.Delete(ctx, Key(completion.TerraformRunId))
+}
@@ -0,0 +1,43 @@
+/*
+ * Copyright (c) HashiCorp, Inc.
+ * SPDX-License-Identifier: MPL-2.0
+ */
+
+package secretsmanager
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"os"
+)
+
+// NotificationTokenSecret provides the token TFC uses to sign the run notifications it sends to the engine
+type NotificationTokenSecret interface {
+	GetNotificationToken(ctx context.Context) (string, error)
+}
+
+type NotificationTokenSM struct {
+	Client   *secretsmanager.Client
+	SecretID string
+}
+
+// NewNotificationTokenWithConfig creates a new secrets manager client for the secret named by the RUN_NOTIFICATION_TOKEN_SECRET_ID env var
+func NewNotificationTokenWithConfig(sdkConfig aws.Config) *NotificationTokenSM {
+	return &NotificationTokenSM{
+		Client:   secretsmanager.NewFromConfig(sdkConfig),
+		SecretID: os.Getenv("RUN_NOTIFICATION_TOKEN_SECRET_ID"),
+	}
+}
+
+func (sm NotificationTokenSM) GetNotificationToken(ctx context.Context) (string, error) {
+	secret, err := sm.Client.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId:     aws.String(sm.SecretID),
+		VersionStage: aws.String(CurrentVersionStage),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return aws.ToString(secret.SecretString), nil
+}
@@ -0,0 +1,119 @@
+/*
+ * Copyright (c) HashiCorp, Inc.
+ * SPDX-License-Identifier: MPL-2.0
+ */
+
+package secretsmanager
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"os"
+	"sort"
+)
+
+// OrganizationSecrets chooses the secret holding the TFE credentials of an organization, which is either the default
+// organization of the engine or one of the organizations with their own credentials
+type OrganizationSecrets interface {
+	ForOrganization(organization string) (SecretsManager, error)
+	// OrganizationNames returns the names of the organizations with their own TFE credentials
+	OrganizationNames() []string
+}
+
+// Organization is an organization the engine provisions workspaces in, along with the secret holding its TFE credentials
+type Organization struct {
+	Name           string
+	SecretsManager SecretsManager
+}
+
+// ForOrganization returns the secret holding the TFE credentials of the organization, or the secret itself for the
+// default organization. Fails for organizations the engine has no credentials for, instead of using the credentials
+// of the default organization with them.
+func (sm *SM) ForOrganization(organization string) (SecretsManager, error) {
+	if organization == "" || organization == sm.Organization {
+		return sm, nil
+	}
+	if organizationSm, found := sm.Organizations[organization]; found {
+		return organizationSm, nil
+	}
+	return nil, fmt.Errorf("no TFE credentials are configured for organization %s", organization)
+}
+
+func (sm *SM) OrganizationNames() []string {
+	names := make([]string, 0, len(sm.Organizations))
+	for name := range sm.Organizations {
+		names = append(names, name)
+	}
+	return names
+}
+
+// newOrganizationSecrets creates a secrets manager for the TFE credentials of each organization in the
+// TFE_ORGANIZATION_CREDENTIALS_SECRET_IDS env var, which maps the names of the organizations to the IDs of the secrets
+func newOrganizationSecrets(ctx context.Context, client *secretsmanager.Client) (map[string]*SM, error) {
+	organizations := map[string]*SM{}
+
+	secretIds := os.Getenv("TFE_ORGANIZATION_CREDENTIALS_SECRET_IDS")
+	if secretIds == "" {
+		return organizations, nil
+	}
+
+	secretIdsByOrganization := map[string]string{}
+	if err := json.Unmarshal([]byte(secretIds), &secretIdsByOrganization); err != nil {
+		return nil, fmt.Errorf("failed to parse TFE_ORGANIZATION_CREDENTIALS_SECRET_IDS: %w", err)
+	}
+
+	for organization, secretId := range secretIdsByOrganization {
+		sm := &SM{
+			Client:   client,
+			SecretID: secretId,
+		}
+
+		// The hostname and team of the credentials are needed to rotate them, like those of the default organization
+		secret, err := sm.GetSecretValue(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch the TFE credentials of organization %s: %w", organization, err)
+		}
+		sm.Hostname = secret.Hostname
+		sm.TeamID = secret.TeamId
+
+		organizations[organization] = sm
+	}
+	return organizations, nil
+}
+
+// ForOrganization returns the secret holding the TFE credentials of the organization, when the secrets manager keeps the
+// credentials of organizations apart, or the secrets manager itself otherwise
+func ForOrganization(secretsManager SecretsManager, organization string) (SecretsManager, error) {
+	if organizationSecrets, ok := secretsManager.(OrganizationSecrets); ok {
+		return organizationSecrets.ForOrganization(organization)
+	}
+	return secretsManager, nil
+}
+
+// Organizations returns the default organization of the engine, followed by the organizations with their own TFE
+// credentials in the order of their names
+func Organizations(secretsManager SecretsManager, defaultOrganization string) ([]Organization, error) {
+	organizations := []Organization{{Name: defaultOrganization, SecretsManager: secretsManager}}
+
+	organizationSecrets, ok := secretsManager.(OrganizationSecrets)
+	if !ok {
+		return organizations, nil
+	}
+
+	names := organizationSecrets.OrganizationNames()
+	sort.Strings(names)
+	for _, name := range names {
+		if name == defaultOrganization {
+			continue
+		}
+
+		organizationSm, err := organizationSecrets.ForOrganization(name)
+		if err != nil {
+			return nil, err
+		}
+		organizations = append(organizations, Organization{Name: name, SecretsManager: organizationSm})
+	}
+	return organizations, nil
+}
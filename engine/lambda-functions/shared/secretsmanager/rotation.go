@@ -0,0 +1,138 @@
+/*
+ * Copyright (c) HashiCorp, Inc.
+ * SPDX-License-Identifier: MPL-2.0
+ */
+
+package secretsmanager
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+)
+
+// PendingVersionStage is AWS' hardcoded label that indicates the version of a secret that is being rotated
+const PendingVersionStage = "AWSPENDING"
+
+// RotatingSecretsManager stages new TFE credentials as the pending version of the secret, so they can be validated
+// before they are made current. Until then, the engine keeps using the current version.
+type RotatingSecretsManager interface {
+	SecretsManager
+	PutPendingTeamCredentials(ctx context.Context, versionId string, teamId string, token string) (string, error)
+	GetPendingSecretValue(ctx context.Context, versionId string) (*TFECredentialsSecret, error)
+	PromotePendingVersion(ctx context.Context, versionId string) error
+	DiscardPendingVersion(ctx context.Context, versionId string) error
+}
+
+// PutPendingTeamCredentials stores the credentials as a new version of the secret labeled AWSPENDING, and returns the
+// ID of the version. The ID is generated when no version ID is given.
+func (sm SM) PutPendingTeamCredentials(ctx context.Context, versionId string, teamId string, token string) (string, error) {
+	serializedSecretValue, err := json.Marshal(&TFECredentialsSecret{
+		Hostname: sm.Hostname,
+		TeamId:   teamId,
+		Token:    token,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	input := &secretsmanager.PutSecretValueInput{
+		SecretId:      aws.String(sm.SecretID),
+		SecretString:  aws.String(string(serializedSecretValue)),
+		VersionStages: []string{PendingVersionStage},
+	}
+	if versionId != "" {
+		input.ClientRequestToken = aws.String(versionId)
+	}
+
+	output, err := sm.Client.PutSecretValue(ctx, input)
+	if err != nil {
+		return "", err
+	}
+
+	return aws.ToString(output.VersionId), nil
+}
+
+// GetPendingSecretValue fetches the credentials of the version labeled AWSPENDING with the given ID, returning nil if
+// there is no such version
+func (sm SM) GetPendingSecretValue(ctx context.Context, versionId string) (*TFECredentialsSecret, error) {
+	secret, err := sm.Client.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId:     aws.String(sm.SecretID),
+		VersionId:    aws.String(versionId),
+		VersionStage: aws.String(PendingVersionStage),
+	})
+	var notFound *types.ResourceNotFoundException
+	if errors.As(err, &notFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	tfeCredentialsSecret := &TFECredentialsSecret{}
+	err = json.Unmarshal([]byte(aws.ToString(secret.SecretString)), tfeCredentialsSecret)
+	return tfeCredentialsSecret, err
+}
+
+// PromotePendingVersion moves the AWSCURRENT label to the pending version, and removes its AWSPENDING label. Versions
+// that were promoted already are left as they are.
+func (sm SM) PromotePendingVersion(ctx context.Context, versionId string) error {
+	stages, err := sm.versionStages(ctx)
+	if err != nil {
+		return err
+	}
+
+	currentVersionId, found := stages[CurrentVersionStage]
+	if !found {
+		return fmt.Errorf("secret %s has no version labeled %s", sm.SecretID, CurrentVersionStage)
+	}
+
+	if currentVersionId != versionId {
+		_, err = sm.Client.UpdateSecretVersionStage(ctx, &secretsmanager.UpdateSecretVersionStageInput{
+			SecretId:            aws.String(sm.SecretID),
+			VersionStage:        aws.String(CurrentVersionStage),
+			MoveToVersionId:     aws.String(versionId),
+			RemoveFromVersionId: aws.String(currentVersionId),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if stages[PendingVersionStage] != versionId {
+		return nil
+	}
+	return sm.DiscardPendingVersion(ctx, versionId)
+}
+
+// DiscardPendingVersion removes the AWSPENDING label from the version, leaving the current version as it is
+func (sm SM) DiscardPendingVersion(ctx context.Context, versionId string) error {
+	_, err := sm.Client.UpdateSecretVersionStage(ctx, &secretsmanager.UpdateSecretVersionStageInput{
+		SecretId:            aws.String(sm.SecretID),
+		VersionStage:        aws.String(PendingVersionStage),
+		RemoveFromVersionId: aws.String(versionId),
+	})
+	return err
+}
+
+// versionStages maps the staging labels of the secret to the IDs of the versions they are attached to
+func (sm SM) versionStages(ctx context.Context) (map[string]string, error) {
+	secret, err := sm.Client.DescribeSecret(ctx, &secretsmanager.DescribeSecretInput{
+		SecretId: aws.String(sm.SecretID),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	stages := map[string]string{}
+	for versionId, versionStages := range secret.VersionIdsToStages {
+		for _, stage := range versionStages {
+			stages[stage] = versionId
+		}
+	}
+	return stages, nil
+}
@@ -29,6 +29,13 @@ type SM struct {
 	SecretID string
 	Hostname string
 	TeamID   string
+
+	// Organization is the name of the default organization of the engine, whose TFE credentials are held by the secret
+	Organization string
+
+	// Organizations holds the secrets of the organizations with their own TFE credentials, by the names of the
+	// organizations
+	Organizations map[string]*SM
 }
 
 // NewWithConfig create a new secrets manager client and initialize it with values from the ENV and the secret
@@ -52,6 +59,12 @@ func NewWithConfig(ctx context.Context, sdkConfig aws.Config) (*SM, error) {
 	}
 	sm.Hostname = latestSecret.Hostname
 	sm.TeamID = latestSecret.TeamId
+	sm.Organization = os.Getenv("TERRAFORM_ORGANIZATION")
+
+	sm.Organizations, err = newOrganizationSecrets(ctx, client)
+	if err != nil {
+		return nil, err
+	}
 
 	return sm, err
 }
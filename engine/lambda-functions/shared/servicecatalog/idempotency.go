@@ -0,0 +1,21 @@
+/*
+ * Copyright (c) HashiCorp, Inc.
+ * SPDX-License-Identifier: MPL-2.0
+ */
+
+package servicecatalog
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+)
+
+// idempotencyNamespace is the namespace of the idempotency tokens, so they cannot collide with other name based UUIDs
+var idempotencyNamespace = uuid.MustParse("5bd4b0ac-1f7a-4d6e-9d3c-6a0b8c2f1e47")
+
+// IdempotencyToken derives the idempotency token of a workflow result from the record, operation and Terraform run it
+// reports. Retries of the same notification then share a token, so Service Catalog records the result only once.
+func IdempotencyToken(recordId string, operation string, terraformRunId string) string {
+	name := fmt.Sprintf("%s/%s/%s", recordId, operation, terraformRunId)
+	return uuid.NewSHA1(idempotencyNamespace, []byte(name)).String()
+}
@@ -0,0 +1,24 @@
+/*
+ * Copyright (c) HashiCorp, Inc.
+ * SPDX-License-Identifier: MPL-2.0
+ */
+
+package servicecatalog
+
+import "unicode/utf8"
+
+// TruncateMessage returns the longest prefix of the message that is at most maxLength bytes long, without splitting a
+// multibyte character, so messages truncated to the limits of Service Catalog remain valid UTF-8
+func TruncateMessage(message string, maxLength int) string {
+	if len(message) <= maxLength {
+		return message
+	}
+	if maxLength <= 0 {
+		return ""
+	}
+
+	for maxLength > 0 && !utf8.RuneStart(message[maxLength]) {
+		maxLength--
+	}
+	return message[:maxLength]
+}
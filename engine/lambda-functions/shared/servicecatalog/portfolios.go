@@ -0,0 +1,46 @@
+/*
+ * Copyright (c) HashiCorp, Inc.
+ * SPDX-License-Identifier: MPL-2.0
+ */
+
+package servicecatalog
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/servicecatalog"
+)
+
+// maxPortfolioPageSize is the largest page size Service Catalog accepts when listing portfolios
+const maxPortfolioPageSize = 20
+
+// PortfolioLister lists the portfolios that contain a product, which *servicecatalog.Client implements
+type PortfolioLister interface {
+	ListPortfoliosForProduct(ctx context.Context, input *servicecatalog.ListPortfoliosForProductInput, optFns ...func(*servicecatalog.Options)) (*servicecatalog.ListPortfoliosForProductOutput, error)
+}
+
+// ListPortfolioIdsForProduct lists the IDs of all the portfolios that contain the product
+func ListPortfolioIdsForProduct(ctx context.Context, lister PortfolioLister, productId string) ([]string, error) {
+	var portfolioIds []string
+
+	var pageToken *string
+	for {
+		page, err := lister.ListPortfoliosForProduct(ctx, &servicecatalog.ListPortfoliosForProductInput{
+			ProductId: aws.String(productId),
+			PageSize:  maxPortfolioPageSize,
+			PageToken: pageToken,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, portfolio := range page.PortfolioDetails {
+			portfolioIds = append(portfolioIds, aws.ToString(portfolio.Id))
+		}
+
+		if page.NextPageToken == nil || *page.NextPageToken == "" {
+			return portfolioIds, nil
+		}
+		pageToken = page.NextPageToken
+	}
+}
@@ -0,0 +1,88 @@
+/*
+ * Copyright (c) HashiCorp, Inc.
+ * SPDX-License-Identifier: MPL-2.0
+ */
+
+package servicecatalog
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/aws-sdk-go-v2/service/servicecatalog"
+	"log"
+	"time"
+)
+
+const DefaultMaxAttempts = 6
+const DefaultBaseDelay = 1 * time.Second
+const maxDelay = 20 * time.Second
+
+// deadlineMargin is the time left before the deadline of the context for the last attempt, and for reporting its
+// error, so a retry never makes the Lambda time out
+const deadlineMargin = 5 * time.Second
+
+// ThrottlingRetrySC retries requests to Service Catalog with exponential backoff while they are throttled. The AWS SDK
+// only retries throttled requests a few times in quick succession, which is not enough when many workflows complete
+// at the same time.
+type ThrottlingRetrySC struct {
+	ServiceCatalog ServiceCatalog
+	MaxAttempts    int
+	BaseDelay      time.Duration
+}
+
+// NewThrottlingRetrySC wraps the given Service Catalog client with the default retry settings
+func NewThrottlingRetrySC(serviceCatalog ServiceCatalog) ThrottlingRetrySC {
+	return ThrottlingRetrySC{
+		ServiceCatalog: serviceCatalog,
+		MaxAttempts:    DefaultMaxAttempts,
+		BaseDelay:      DefaultBaseDelay,
+	}
+}
+
+func (serviceCatalog ThrottlingRetrySC) NotifyProvisionProductEngineWorkflowResult(ctx context.Context, input *servicecatalog.NotifyProvisionProductEngineWorkflowResultInput) (*servicecatalog.NotifyProvisionProductEngineWorkflowResultOutput, error) {
+	return retryThrottled(ctx, serviceCatalog, func() (*servicecatalog.NotifyProvisionProductEngineWorkflowResultOutput, error) {
+		return serviceCatalog.ServiceCatalog.NotifyProvisionProductEngineWorkflowResult(ctx, input)
+	})
+}
+
+func (serviceCatalog ThrottlingRetrySC) NotifyTerminateProvisionedProductEngineWorkflowResult(ctx context.Context, input *servicecatalog.NotifyTerminateProvisionedProductEngineWorkflowResultInput) (*servicecatalog.NotifyTerminateProvisionedProductEngineWorkflowResultOutput, error) {
+	return retryThrottled(ctx, serviceCatalog, func() (*servicecatalog.NotifyTerminateProvisionedProductEngineWorkflowResultOutput, error) {
+		return serviceCatalog.ServiceCatalog.NotifyTerminateProvisionedProductEngineWorkflowResult(ctx, input)
+	})
+}
+
+func (serviceCatalog ThrottlingRetrySC) NotifyUpdateProvisionedProductEngineWorkflowResult(ctx context.Context, input *servicecatalog.NotifyUpdateProvisionedProductEngineWorkflowResultInput) (*servicecatalog.NotifyUpdateProvisionedProductEngineWorkflowResultOutput, error) {
+	return retryThrottled(ctx, serviceCatalog, func() (*servicecatalog.NotifyUpdateProvisionedProductEngineWorkflowResultOutput, error) {
+		return serviceCatalog.ServiceCatalog.NotifyUpdateProvisionedProductEngineWorkflowResult(ctx, input)
+	})
+}
+
+// IsThrottlingError reports whether the error is returned because Service Catalog throttled the request
+func IsThrottlingError(err error) bool {
+	return retry.IsErrorThrottles(retry.DefaultThrottles).IsErrorThrottle(err) == aws.TrueTernary
+}
+
+func retryThrottled[T any](ctx context.Context, serviceCatalog ThrottlingRetrySC, request func() (T, error)) (T, error) {
+	delay := serviceCatalog.BaseDelay
+	for attempt := 1; ; attempt++ {
+		output, err := request()
+		if err == nil || !IsThrottlingError(err) || attempt >= serviceCatalog.MaxAttempts {
+			return output, err
+		}
+
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay+deadlineMargin {
+			log.Default().Printf("request to service catalog was throttled (attempt %d of %d), not retrying as it would exceed the deadline", attempt, serviceCatalog.MaxAttempts)
+			return output, err
+		}
+
+		log.Default().Printf("request to service catalog was throttled (attempt %d of %d), retrying in %s", attempt, serviceCatalog.MaxAttempts, delay)
+		select {
+		case <-ctx.Done():
+			return output, err
+		case <-time.After(delay):
+		}
+
+		delay = min(delay*2, maxDelay)
+	}
+}
@@ -0,0 +1,55 @@
+/*
+ * Copyright (c) HashiCorp, Inc.
+ * SPDX-License-Identifier: MPL-2.0
+ */
+
+package servicecatalog
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/servicecatalog"
+	"github.com/aws/aws-sdk-go-v2/service/servicecatalog/types"
+)
+
+// EngineProductType is the type of the Service Catalog products provisioned by the engine
+const EngineProductType = "TERRAFORM_CLOUD"
+
+// maxScanPageSize is the largest page size Service Catalog accepts when scanning provisioned products
+const maxScanPageSize = 20
+
+// ProvisionedProductScanner lists the provisioned products of an account, which *servicecatalog.Client implements
+type ProvisionedProductScanner interface {
+	ScanProvisionedProducts(ctx context.Context, input *servicecatalog.ScanProvisionedProductsInput, optFns ...func(*servicecatalog.Options)) (*servicecatalog.ScanProvisionedProductsOutput, error)
+}
+
+// ScanEngineProvisionedProducts lists all the provisioned products of the account that were provisioned by the engine
+func ScanEngineProvisionedProducts(ctx context.Context, scanner ProvisionedProductScanner) ([]types.ProvisionedProductDetail, error) {
+	var provisionedProducts []types.ProvisionedProductDetail
+
+	var pageToken *string
+	for {
+		page, err := scanner.ScanProvisionedProducts(ctx, &servicecatalog.ScanProvisionedProductsInput{
+			AccessLevelFilter: &types.AccessLevelFilter{
+				Key:   types.AccessLevelFilterKeyAccount,
+				Value: aws.String("self"),
+			},
+			PageSize:  maxScanPageSize,
+			PageToken: pageToken,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, provisionedProduct := range page.ProvisionedProducts {
+			if provisionedProduct.Type != nil && *provisionedProduct.Type == EngineProductType {
+				provisionedProducts = append(provisionedProducts, provisionedProduct)
+			}
+		}
+
+		if page.NextPageToken == nil || *page.NextPageToken == "" {
+			return provisionedProducts, nil
+		}
+		pageToken = page.NextPageToken
+	}
+}
@@ -0,0 +1,23 @@
+/*
+ * Copyright (c) HashiCorp, Inc.
+ * SPDX-License-Identifier: MPL-2.0
+ */
+
+package sns
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+)
+
+type SNS interface {
+	Publish(ctx context.Context, input *sns.PublishInput) (*sns.PublishOutput, error)
+}
+
+type SNSClient struct {
+	Client *sns.Client
+}
+
+func (snsClient SNSClient) Publish(ctx context.Context, input *sns.PublishInput) (*sns.PublishOutput, error) {
+	return snsClient.Client.Publish(ctx, input)
+}
@@ -0,0 +1,68 @@
+/*
+ * Copyright (c) HashiCorp, Inc.
+ * SPDX-License-Identifier: MPL-2.0
+ */
+
+package statearchive
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"os"
+)
+
+const StateObjectName = "state.json"
+const VariablesObjectName = "variables.json"
+
+// StateArchive keeps the final state and variables of deleted workspaces, for audit and recovery
+type StateArchive interface {
+	Put(ctx context.Context, key string, body []byte) error
+}
+
+// Key returns the key of an archived object of the provisioned product
+func Key(awsAccountId string, provisionedProductId string, objectName string) string {
+	return fmt.Sprintf("%s/%s/%s", awsAccountId, provisionedProductId, objectName)
+}
+
+type S3StateArchive struct {
+	Client     *s3.Client
+	BucketName string
+	KmsKeyId   string
+}
+
+// NewFromConfig creates a new state archive client, using the bucket and KMS key from the STATE_ARCHIVE_BUCKET_NAME
+// and STATE_ARCHIVE_KMS_KEY_ID env vars. It returns nil if no bucket is configured, which disables the archive.
+func NewFromConfig(sdkConfig aws.Config) *S3StateArchive {
+	bucketName := os.Getenv("STATE_ARCHIVE_BUCKET_NAME")
+	if bucketName == "" {
+		return nil
+	}
+
+	return &S3StateArchive{
+		Client:     s3.NewFromConfig(sdkConfig),
+		BucketName: bucketName,
+		KmsKeyId:   os.Getenv("STATE_ARCHIVE_KMS_KEY_ID"),
+	}
+}
+
+func (archive S3StateArchive) Put(ctx context.Context, key string, body []byte) error {
+	input := &s3.PutObjectInput{
+		Bucket:               aws.String(archive.BucketName),
+		Key:                  aws.String(key),
+		Body:                 bytes.NewReader(body),
+		ContentType:          aws.String("application/json"),
+		ServerSideEncryption: types.ServerSideEncryptionAwsKms,
+	}
+
+	// Without a key, the AWS managed key of S3 is used
+	if archive.KmsKeyId != "" {
+		input.SSEKMSKeyId = aws.String(archive.KmsKeyId)
+	}
+
+	_, err := archive.Client.PutObject(ctx, input)
+	return err
+}
@@ -10,13 +10,27 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/sfn"
 	"github.com/aws/aws-sdk-go-v2/service/sfn/types"
 	"github.com/aws/aws-sdk-go-v2/aws"
+	"strings"
+	"time"
 )
 
 type StepFunctions interface {
 	StartExecution(ctx context.Context, input *sfn.StartExecutionInput) (*sfn.StartExecutionOutput, error)
-	GetStateMachineExecutionCount(ctx context.Context, stateMachineArn string) (int, error)
+	DescribeExecution(ctx context.Context, executionArn string) (*sfn.DescribeExecutionOutput, error)
+	ListRunningExecutions(ctx context.Context, stateMachineArn string) ([]RunningExecution, error)
+	SendTaskSuccess(ctx context.Context, input *sfn.SendTaskSuccessInput) (*sfn.SendTaskSuccessOutput, error)
+	SendTaskFailure(ctx context.Context, input *sfn.SendTaskFailureInput) (*sfn.SendTaskFailureOutput, error)
 }
 
+// RunningExecution is an execution of a state machine that is still running
+type RunningExecution struct {
+	ExecutionArn string    `json:"executionArn"`
+	StartedAt    time.Time `json:"startedAt"`
+}
+
+// maxListExecutionsPageSize is the largest page of executions the Step Functions API returns
+const maxListExecutionsPageSize = 1000
+
 type SFN struct {
 	Client *sfn.Client
 }
@@ -34,14 +48,45 @@ func (stepFunctions SFN) StartExecution(ctx context.Context, input *sfn.StartExe
 	return stepFunctions.Client.StartExecution(ctx, input)
 }
 
-func (stepFunctions SFN) GetStateMachineExecutionCount(ctx context.Context, stateMachineArn string) (int, error) {
-	stateMachineExecutionsList, err := stepFunctions.Client.ListExecutions(ctx, &sfn.ListExecutionsInput{
+// DescribeExecution describes the execution, including the input it was started with
+func (stepFunctions SFN) DescribeExecution(ctx context.Context, executionArn string) (*sfn.DescribeExecutionOutput, error) {
+	return stepFunctions.Client.DescribeExecution(ctx, &sfn.DescribeExecutionInput{ExecutionArn: &executionArn})
+}
+
+// ExecutionArn returns the ARN of the execution of the state machine with the given name
+func ExecutionArn(stateMachineArn string, executionName string) string {
+	return strings.Replace(stateMachineArn, ":stateMachine:", ":execution:", 1) + ":" + executionName
+}
+
+// ListRunningExecutions lists all running executions of the state machine, across all pages
+func (stepFunctions SFN) ListRunningExecutions(ctx context.Context, stateMachineArn string) ([]RunningExecution, error) {
+	paginator := sfn.NewListExecutionsPaginator(stepFunctions.Client, &sfn.ListExecutionsInput{
 		StateMachineArn: &stateMachineArn,
 		StatusFilter:    types.ExecutionStatusRunning,
+		MaxResults:      maxListExecutionsPageSize,
 	})
-	if err != nil {
-		return 0, err
+
+	executions := []RunningExecution{}
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, execution := range page.Executions {
+			executions = append(executions, RunningExecution{
+				ExecutionArn: aws.ToString(execution.ExecutionArn),
+				StartedAt:    aws.ToTime(execution.StartDate),
+			})
+		}
 	}
+	return executions, nil
+}
+
+func (stepFunctions SFN) SendTaskSuccess(ctx context.Context, input *sfn.SendTaskSuccessInput) (*sfn.SendTaskSuccessOutput, error) {
+	return stepFunctions.Client.SendTaskSuccess(ctx, input)
+}
 
-	return len(stateMachineExecutionsList.Executions), nil
+func (stepFunctions SFN) SendTaskFailure(ctx context.Context, input *sfn.SendTaskFailureInput) (*sfn.SendTaskFailureOutput, error) {
+	return stepFunctions.Client.SendTaskFailure(ctx, input)
 }
@@ -0,0 +1,35 @@
+/*
+ * Copyright (c) HashiCorp, Inc.
+ * SPDX-License-Identifier: MPL-2.0
+ */
+
+package cloudwatch
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+)
+
+type MockCloudWatch struct {
+	// MetricData is all the metric data the mock received, keyed by the namespace it was put in
+	MetricData map[string][]types.MetricDatum
+
+	Err error
+}
+
+func NewMockCloudWatch() *MockCloudWatch {
+	return &MockCloudWatch{
+		MetricData: map[string][]types.MetricDatum{},
+	}
+}
+
+func (cloudWatch *MockCloudWatch) PutMetricData(ctx context.Context, input *cloudwatch.PutMetricDataInput) (*cloudwatch.PutMetricDataOutput, error) {
+	if cloudWatch.Err != nil {
+		return nil, cloudWatch.Err
+	}
+
+	namespace := *input.Namespace
+	cloudWatch.MetricData[namespace] = append(cloudWatch.MetricData[namespace], input.MetricData...)
+	return &cloudwatch.PutMetricDataOutput{}, nil
+}
@@ -0,0 +1,69 @@
+/*
+ * Copyright (c) HashiCorp, Inc.
+ * SPDX-License-Identifier: MPL-2.0
+ */
+
+package enginestate
+
+import (
+	"context"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/enginestate"
+	"sync"
+	"time"
+)
+
+type MockEngineState struct {
+	Items map[string]enginestate.Item
+
+	lock sync.Mutex
+}
+
+func NewMockEngineState() *MockEngineState {
+	return &MockEngineState{
+		Items: map[string]enginestate.Item{},
+	}
+}
+
+func (state *MockEngineState) Get(ctx context.Context, key string) (*enginestate.Item, error) {
+	state.lock.Lock()
+	defer state.lock.Unlock()
+
+	item, found := state.Items[key]
+	if !found {
+		return nil, nil
+	}
+	if !item.ExpiresAt.IsZero() && !time.Now().Before(item.ExpiresAt) {
+		return nil, nil
+	}
+
+	return &item, nil
+}
+
+func (state *MockEngineState) Put(ctx context.Context, item enginestate.Item) error {
+	state.lock.Lock()
+	defer state.lock.Unlock()
+
+	state.Items[item.Key] = item
+	return nil
+}
+
+func (state *MockEngineState) PutIfAbsent(ctx context.Context, item enginestate.Item) (bool, error) {
+	state.lock.Lock()
+	defer state.lock.Unlock()
+
+	existing, found := state.Items[item.Key]
+	if found && (existing.ExpiresAt.IsZero() || time.Now().Before(existing.ExpiresAt)) {
+		return false, nil
+	}
+
+	state.Items[item.Key] = item
+	return true, nil
+}
+
+func (state *MockEngineState) Delete(ctx context.Context, key string) error {
+	state.lock.Lock()
+	defer state.lock.Unlock()
+
+	delete(state.Items, key)
+	return nil
+}
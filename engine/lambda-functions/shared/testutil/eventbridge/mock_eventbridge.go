@@ -0,0 +1,28 @@
+/*
+ * Copyright (c) HashiCorp, Inc.
+ * SPDX-License-Identifier: MPL-2.0
+ */
+
+package eventbridge
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge/types"
+)
+
+type MockEventBridge struct {
+	// Entries are all the events the mock received
+	Entries []types.PutEventsRequestEntry
+
+	Err error
+}
+
+func (eventBridge *MockEventBridge) PutEvents(ctx context.Context, input *eventbridge.PutEventsInput) (*eventbridge.PutEventsOutput, error) {
+	if eventBridge.Err != nil {
+		return nil, eventBridge.Err
+	}
+
+	eventBridge.Entries = append(eventBridge.Entries, input.Entries...)
+	return &eventbridge.PutEventsOutput{}, nil
+}
@@ -15,6 +15,9 @@ type MockLambdaFunction struct {
 	Provisioning bool
 	Updating     bool
 	Terminating  bool
+
+	// PendingTransitions is the number of status reads that still report the event source mappings as transitioning
+	PendingTransitions int
 }
 
 type MockLambdaFunctionWithErrorResponse struct {
@@ -42,6 +45,29 @@ func (l *MockLambdaFunction) GetEventSourceMappingUuidTuples(ctx context.Context
 	}, nil
 }
 
+func (l *MockLambdaFunction) GetEventSourceMappingStatus(ctx context.Context, uuid string) (lambda.EventSourceMappingStatus, error) {
+	var enabled bool
+	switch uuid {
+	case "provisioningUuid":
+		enabled = l.Provisioning
+	case "updatingUuid":
+		enabled = l.Updating
+	case "terminatingUuid":
+		enabled = l.Terminating
+	default:
+		return "", errors.New("function name or uuid not found")
+	}
+
+	if l.PendingTransitions > 0 {
+		l.PendingTransitions--
+		if enabled {
+			return lambda.EventSourceEnabling, nil
+		}
+		return lambda.EventSourceDisabling, nil
+	}
+	return boolToEventSourceMappingStatus(enabled), nil
+}
+
 func (l *MockLambdaFunction) EnableEventSourceMapping(ctx context.Context, functionName string, uuid string) error {
 	if functionName == "provisioningFunctionName" && uuid == "provisioningUuid" {
 		l.Provisioning = true
@@ -84,6 +110,10 @@ func (l *MockLambdaFunctionWithErrorResponse) GetEventSourceMappingUuidTuples(ct
 	return nil, errors.New("function name or uuid not found")
 }
 
+func (l *MockLambdaFunctionWithErrorResponse) GetEventSourceMappingStatus(ctx context.Context, uuid string) (lambda.EventSourceMappingStatus, error) {
+	return "", errors.New("function name or uuid not found")
+}
+
 func (l *MockLambdaFunctionWithErrorResponse) EnableEventSourceMapping(ctx context.Context, functionName string, uuid string) error {
 	return errors.New("function name or uuid not found")
 }
@@ -9,12 +9,45 @@ import (
 	"context"
 	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/secretsmanager"
 	"errors"
+	"fmt"
 )
 
 type MockSecretsManager struct {
 	Hostname string
 	TeamId   string
 	Token    string
+
+	// The credentials staged as the pending version of the secret, if any
+	PendingVersionId string
+	PendingTeamId    string
+	PendingToken     string
+
+	// The name of the default organization. If it is unset, all organizations without their own TFE credentials are
+	// treated as the default organization.
+	Organization string
+
+	// The credentials of the organizations with their own TFE credentials, by the names of the organizations
+	Organizations map[string]*MockSecretsManager
+
+	versions int
+}
+
+func (msm *MockSecretsManager) ForOrganization(organization string) (secretsmanager.SecretsManager, error) {
+	if organizationMsm, found := msm.Organizations[organization]; found {
+		return organizationMsm, nil
+	}
+	if organization != "" && msm.Organization != "" && organization != msm.Organization {
+		return nil, fmt.Errorf("no TFE credentials are configured for organization %s", organization)
+	}
+	return msm, nil
+}
+
+func (msm *MockSecretsManager) OrganizationNames() []string {
+	var names []string
+	for name := range msm.Organizations {
+		names = append(names, name)
+	}
+	return names
 }
 
 func (msm *MockSecretsManager) GetSecretValue(ctx context.Context) (*secretsmanager.TFECredentialsSecret, error) {
@@ -30,6 +63,44 @@ func (msm *MockSecretsManager) UpdateSecretValue(ctx context.Context, secretValu
 	return nil
 }
 
+func (msm *MockSecretsManager) PutPendingTeamCredentials(ctx context.Context, versionId string, teamId string, token string) (string, error) {
+	msm.versions++
+	msm.PendingVersionId = versionId
+	if versionId == "" {
+		msm.PendingVersionId = fmt.Sprintf("version-%d", msm.versions)
+	}
+	msm.PendingTeamId = teamId
+	msm.PendingToken = token
+	return msm.PendingVersionId, nil
+}
+
+func (msm *MockSecretsManager) GetPendingSecretValue(ctx context.Context, versionId string) (*secretsmanager.TFECredentialsSecret, error) {
+	if versionId == "" || versionId != msm.PendingVersionId {
+		return nil, nil
+	}
+	return &secretsmanager.TFECredentialsSecret{
+		Hostname: msm.Hostname,
+		TeamId:   msm.PendingTeamId,
+		Token:    msm.PendingToken,
+	}, nil
+}
+
+func (msm *MockSecretsManager) PromotePendingVersion(ctx context.Context, versionId string) error {
+	if versionId != msm.PendingVersionId {
+		return fmt.Errorf("version %s is not pending", versionId)
+	}
+	msm.TeamId = msm.PendingTeamId
+	msm.Token = msm.PendingToken
+	return msm.DiscardPendingVersion(ctx, versionId)
+}
+
+func (msm *MockSecretsManager) DiscardPendingVersion(ctx context.Context, versionId string) error {
+	msm.PendingVersionId = ""
+	msm.PendingTeamId = ""
+	msm.PendingToken = ""
+	return nil
+}
+
 type MockSecretsManagerWithoutUpdate struct {
 	Hostname string
 	TeamId   string
@@ -47,3 +118,27 @@ func (msm *MockSecretsManagerWithoutUpdate) GetSecretValue(ctx context.Context)
 func (msm *MockSecretsManagerWithoutUpdate) UpdateSecretValue(ctx context.Context, secretValue string) error {
 	return errors.New("no update for you! ")
 }
+
+func (msm *MockSecretsManagerWithoutUpdate) PutPendingTeamCredentials(ctx context.Context, versionId string, teamId string, token string) (string, error) {
+	return "", errors.New("no update for you! ")
+}
+
+func (msm *MockSecretsManagerWithoutUpdate) GetPendingSecretValue(ctx context.Context, versionId string) (*secretsmanager.TFECredentialsSecret, error) {
+	return nil, nil
+}
+
+func (msm *MockSecretsManagerWithoutUpdate) PromotePendingVersion(ctx context.Context, versionId string) error {
+	return errors.New("no update for you! ")
+}
+
+func (msm *MockSecretsManagerWithoutUpdate) DiscardPendingVersion(ctx context.Context, versionId string) error {
+	return errors.New("no update for you! ")
+}
+
+type MockNotificationTokenSecret struct {
+	Token string
+}
+
+func (msm *MockNotificationTokenSecret) GetNotificationToken(ctx context.Context) (string, error) {
+	return msm.Token, nil
+}
@@ -0,0 +1,50 @@
+/*
+ * Copyright (c) HashiCorp, Inc.
+ * SPDX-License-Identifier: MPL-2.0
+ */
+
+package servicecatalog
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/servicecatalog"
+	"github.com/aws/aws-sdk-go-v2/service/servicecatalog/types"
+	"strconv"
+)
+
+// MockPortfolioLister returns the portfolios of each product in pages of the requested size
+type MockPortfolioLister struct {
+	// PortfolioIds holds the IDs of the portfolios that contain each product, by the IDs of the products
+	PortfolioIds map[string][]string
+	Err          error
+	// Calls is the number of pages that were listed
+	Calls int
+}
+
+func (lister *MockPortfolioLister) ListPortfoliosForProduct(ctx context.Context, input *servicecatalog.ListPortfoliosForProductInput, optFns ...func(*servicecatalog.Options)) (*servicecatalog.ListPortfoliosForProductOutput, error) {
+	lister.Calls++
+	if lister.Err != nil {
+		return nil, lister.Err
+	}
+
+	var portfolios []types.PortfolioDetail
+	for _, portfolioId := range lister.PortfolioIds[aws.ToString(input.ProductId)] {
+		portfolios = append(portfolios, types.PortfolioDetail{Id: aws.String(portfolioId)})
+	}
+
+	start := 0
+	if input.PageToken != nil {
+		start, _ = strconv.Atoi(*input.PageToken)
+	}
+
+	end := start + int(input.PageSize)
+	if end >= len(portfolios) {
+		return &servicecatalog.ListPortfoliosForProductOutput{PortfolioDetails: portfolios[start:]}, nil
+	}
+
+	return &servicecatalog.ListPortfoliosForProductOutput{
+		PortfolioDetails: portfolios[start:end],
+		NextPageToken:    aws.String(strconv.Itoa(end)),
+	}, nil
+}
@@ -0,0 +1,51 @@
+/*
+ * Copyright (c) HashiCorp, Inc.
+ * SPDX-License-Identifier: MPL-2.0
+ */
+
+package servicecatalog
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/servicecatalog"
+	"github.com/aws/aws-sdk-go-v2/service/servicecatalog/types"
+	"strconv"
+)
+
+// MockProvisionedProductScanner returns its provisioned products in pages of the requested size
+type MockProvisionedProductScanner struct {
+	ProvisionedProducts []types.ProvisionedProductDetail
+	Err                 error
+}
+
+// AddProvisionedProduct adds a provisioned product of the given type and status to the mock
+func (scanner *MockProvisionedProductScanner) AddProvisionedProduct(provisionedProductId string, productType string, status types.ProvisionedProductStatus) {
+	scanner.ProvisionedProducts = append(scanner.ProvisionedProducts, types.ProvisionedProductDetail{
+		Id:     aws.String(provisionedProductId),
+		Name:   aws.String(provisionedProductId),
+		Type:   aws.String(productType),
+		Status: status,
+	})
+}
+
+func (scanner *MockProvisionedProductScanner) ScanProvisionedProducts(ctx context.Context, input *servicecatalog.ScanProvisionedProductsInput, optFns ...func(*servicecatalog.Options)) (*servicecatalog.ScanProvisionedProductsOutput, error) {
+	if scanner.Err != nil {
+		return nil, scanner.Err
+	}
+
+	start := 0
+	if input.PageToken != nil {
+		start, _ = strconv.Atoi(*input.PageToken)
+	}
+
+	end := start + int(input.PageSize)
+	if end >= len(scanner.ProvisionedProducts) {
+		return &servicecatalog.ScanProvisionedProductsOutput{ProvisionedProducts: scanner.ProvisionedProducts[start:]}, nil
+	}
+
+	return &servicecatalog.ScanProvisionedProductsOutput{
+		ProvisionedProducts: scanner.ProvisionedProducts[start:end],
+		NextPageToken:       aws.String(strconv.Itoa(end)),
+	}, nil
+}
@@ -14,19 +14,45 @@ type MockServiceCatalog struct {
 	NotifyProvisionProductEngineWorkflowResultInput            *servicecatalog.NotifyProvisionProductEngineWorkflowResultInput
 	NotifyTerminateProvisionedProductEngineWorkflowResultInput *servicecatalog.NotifyTerminateProvisionedProductEngineWorkflowResultInput
 	NotifyUpdateProvisionedProductEngineWorkflowResultInput    *servicecatalog.NotifyUpdateProvisionedProductEngineWorkflowResultInput
+
+	// Errors are returned by the subsequent requests, one error per request, before requests succeed again
+	Errors []error
+
+	// Requests is the number of requests the mock received, including the failed ones
+	Requests int
 }
 
 func (serviceCatalog *MockServiceCatalog) NotifyProvisionProductEngineWorkflowResult(ctx context.Context, input *servicecatalog.NotifyProvisionProductEngineWorkflowResultInput) (*servicecatalog.NotifyProvisionProductEngineWorkflowResultOutput, error) {
+	if err := serviceCatalog.nextError(); err != nil {
+		return nil, err
+	}
 	serviceCatalog.NotifyProvisionProductEngineWorkflowResultInput = input
 	return nil, nil
 }
 
 func (serviceCatalog *MockServiceCatalog) NotifyTerminateProvisionedProductEngineWorkflowResult(ctx context.Context, input *servicecatalog.NotifyTerminateProvisionedProductEngineWorkflowResultInput) (*servicecatalog.NotifyTerminateProvisionedProductEngineWorkflowResultOutput, error) {
+	if err := serviceCatalog.nextError(); err != nil {
+		return nil, err
+	}
 	serviceCatalog.NotifyTerminateProvisionedProductEngineWorkflowResultInput = input
 	return nil, nil
 }
 
 func (serviceCatalog *MockServiceCatalog) NotifyUpdateProvisionedProductEngineWorkflowResult(ctx context.Context, input *servicecatalog.NotifyUpdateProvisionedProductEngineWorkflowResultInput) (*servicecatalog.NotifyUpdateProvisionedProductEngineWorkflowResultOutput, error) {
+	if err := serviceCatalog.nextError(); err != nil {
+		return nil, err
+	}
 	serviceCatalog.NotifyUpdateProvisionedProductEngineWorkflowResultInput = input
 	return nil, nil
 }
+
+func (serviceCatalog *MockServiceCatalog) nextError() error {
+	serviceCatalog.Requests++
+	if len(serviceCatalog.Errors) == 0 {
+		return nil
+	}
+
+	err := serviceCatalog.Errors[0]
+	serviceCatalog.Errors = serviceCatalog.Errors[1:]
+	return err
+}
@@ -0,0 +1,27 @@
+/*
+ * Copyright (c) HashiCorp, Inc.
+ * SPDX-License-Identifier: MPL-2.0
+ */
+
+package sns
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+)
+
+type MockSNS struct {
+	// Messages are all the messages the mock published
+	Messages []*sns.PublishInput
+
+	Err error
+}
+
+func (mockSNS *MockSNS) Publish(ctx context.Context, input *sns.PublishInput) (*sns.PublishOutput, error) {
+	if mockSNS.Err != nil {
+		return nil, mockSNS.Err
+	}
+
+	mockSNS.Messages = append(mockSNS.Messages, input)
+	return &sns.PublishOutput{}, nil
+}
@@ -0,0 +1,38 @@
+/*
+ * Copyright (c) HashiCorp, Inc.
+ * SPDX-License-Identifier: MPL-2.0
+ */
+
+package statearchive
+
+import (
+	"context"
+	"sync"
+)
+
+type MockStateArchive struct {
+	Objects map[string][]byte
+
+	// Err is returned by all requests, when set
+	Err error
+
+	lock sync.Mutex
+}
+
+func NewMockStateArchive() *MockStateArchive {
+	return &MockStateArchive{
+		Objects: map[string][]byte{},
+	}
+}
+
+func (archive *MockStateArchive) Put(ctx context.Context, key string, body []byte) error {
+	archive.lock.Lock()
+	defer archive.lock.Unlock()
+
+	if archive.Err != nil {
+		return archive.Err
+	}
+
+	archive.Objects[key] = body
+	return nil
+}
@@ -8,19 +8,60 @@ package stepfunction
 import (
 	"context"
 	"errors"
+	"fmt"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/sfn"
+	"github.com/aws/aws-sdk-go-v2/service/sfn/types"
 	"github.com/aws/smithy-go/middleware"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/stepfunctions"
 	"time"
 )
 
 type MockStepFunctionsWithSuccessfulResponse struct {
-	StateMachinePayload string
+	StateMachinePayload  string
+	SendTaskSuccessInput *sfn.SendTaskSuccessInput
+	SendTaskFailureInput *sfn.SendTaskFailureInput
+
+	// RunningExecutions overrides the running executions of each state machine ARN, when set
+	RunningExecutions map[string][]stepfunctions.RunningExecution
+
+	// Executions are the inputs of the executions started, by their ARNs. Starting an execution that already exists
+	// fails with ExecutionAlreadyExists.
+	Executions map[string]string
+}
+
+// ExecutionsStartedLongAgo is when the executions of the mock that are not started just now were started
+var ExecutionsStartedLongAgo = time.Date(2023, 5, 1, 0, 0, 0, 0, time.UTC)
+
+// mockRunningExecutions returns the given number of running executions of the state machine, of which the given number
+// of oldest executions were started long ago, and the others just now
+func mockRunningExecutions(stateMachineArn string, count int, startedLongAgo int) []stepfunctions.RunningExecution {
+	executions := []stepfunctions.RunningExecution{}
+	for i := 0; i < count; i++ {
+		startedAt := time.Now()
+		if i < startedLongAgo {
+			startedAt = ExecutionsStartedLongAgo.Add(time.Duration(i) * time.Minute)
+		}
+		executions = append(executions, stepfunctions.RunningExecution{
+			ExecutionArn: fmt.Sprintf("%s:execution-%d", stateMachineArn, i),
+			StartedAt:    startedAt,
+		})
+	}
+	return executions
 }
 
 type MockStepFunctionsWithErrorResponse struct{}
 
 func (stepFunctions *MockStepFunctionsWithSuccessfulResponse) StartExecution(ctx context.Context, input *sfn.StartExecutionInput) (*sfn.StartExecutionOutput, error) {
+	executionArn := stepfunctions.ExecutionArn(aws.ToString(input.StateMachineArn), aws.ToString(input.Name))
+	if _, exists := stepFunctions.Executions[executionArn]; exists {
+		return nil, &types.ExecutionAlreadyExists{Message: aws.String("Execution Already Exists: '" + executionArn + "'")}
+	}
+	if stepFunctions.Executions == nil {
+		stepFunctions.Executions = map[string]string{}
+	}
+	stepFunctions.Executions[executionArn] = *input.Input
+
 	// Capture payload
 	stepFunctions.StateMachinePayload = *input.Input
 
@@ -35,26 +76,71 @@ func (stepFunctions *MockStepFunctionsWithSuccessfulResponse) StartExecution(ctx
 	}, nil
 }
 
-func (stepFunctions *MockStepFunctionsWithSuccessfulResponse) GetStateMachineExecutionCount(ctx context.Context, stateMachineArn string) (int, error) {
+func (stepFunctions *MockStepFunctionsWithSuccessfulResponse) DescribeExecution(ctx context.Context, executionArn string) (*sfn.DescribeExecutionOutput, error) {
+	input, exists := stepFunctions.Executions[executionArn]
+	if !exists {
+		return nil, &types.ExecutionDoesNotExist{Message: aws.String("Execution Does Not Exist: '" + executionArn + "'")}
+	}
+
+	return &sfn.DescribeExecutionOutput{
+		ExecutionArn: aws.String(executionArn),
+		Input:        aws.String(input),
+		Status:       types.ExecutionStatusRunning,
+	}, nil
+}
+
+// ListRunningExecutions returns 11, 11 and 1 executions of the provisioning, updating and terminating state machines,
+// of which 2, 0 and 1 were started long ago, unless the running executions are overridden
+func (stepFunctions *MockStepFunctionsWithSuccessfulResponse) ListRunningExecutions(ctx context.Context, stateMachineArn string) ([]stepfunctions.RunningExecution, error) {
+	if stepFunctions.RunningExecutions != nil {
+		return stepFunctions.RunningExecutions[stateMachineArn], nil
+	}
+
 	if stateMachineArn == "arn:provision-thing-123" {
-		return 11, nil
+		return mockRunningExecutions(stateMachineArn, 11, 2), nil
 	}
 
 	if stateMachineArn == "arn:update-thing-123" {
-		return 11, nil
+		return mockRunningExecutions(stateMachineArn, 11, 0), nil
 	}
 
 	if stateMachineArn == "arn:terminate-thing-123" {
-		return 1, nil
+		return mockRunningExecutions(stateMachineArn, 1, 1), nil
 	}
 
-	return 0, errors.New("invalid state machine arn")
+	return nil, errors.New("invalid state machine arn")
+}
+
+func (stepFunctions *MockStepFunctionsWithSuccessfulResponse) SendTaskSuccess(ctx context.Context, input *sfn.SendTaskSuccessInput) (*sfn.SendTaskSuccessOutput, error) {
+	// Capture input
+	stepFunctions.SendTaskSuccessInput = input
+
+	return &sfn.SendTaskSuccessOutput{}, nil
+}
+
+func (stepFunctions *MockStepFunctionsWithSuccessfulResponse) SendTaskFailure(ctx context.Context, input *sfn.SendTaskFailureInput) (*sfn.SendTaskFailureOutput, error) {
+	// Capture input
+	stepFunctions.SendTaskFailureInput = input
+
+	return &sfn.SendTaskFailureOutput{}, nil
 }
 
 func (stepFunctions *MockStepFunctionsWithErrorResponse) StartExecution(ctx context.Context, input *sfn.StartExecutionInput) (*sfn.StartExecutionOutput, error) {
 	return nil, errors.New("whoopsies")
 }
 
-func (stepFunctions *MockStepFunctionsWithErrorResponse) GetStateMachineExecutionCount(ctx context.Context, stateMachineArn string) (int, error) {
-	return 0, errors.New("wrong function called")
+func (stepFunctions *MockStepFunctionsWithErrorResponse) DescribeExecution(ctx context.Context, executionArn string) (*sfn.DescribeExecutionOutput, error) {
+	return nil, errors.New("whoopsies")
+}
+
+func (stepFunctions *MockStepFunctionsWithErrorResponse) ListRunningExecutions(ctx context.Context, stateMachineArn string) ([]stepfunctions.RunningExecution, error) {
+	return nil, errors.New("wrong function called")
+}
+
+func (stepFunctions *MockStepFunctionsWithErrorResponse) SendTaskSuccess(ctx context.Context, input *sfn.SendTaskSuccessInput) (*sfn.SendTaskSuccessOutput, error) {
+	return nil, errors.New("whoopsies")
+}
+
+func (stepFunctions *MockStepFunctionsWithErrorResponse) SendTaskFailure(ctx context.Context, input *sfn.SendTaskFailureInput) (*sfn.SendTaskFailureOutput, error) {
+	return nil, errors.New("whoopsies")
 }
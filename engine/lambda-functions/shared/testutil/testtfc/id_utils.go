@@ -55,6 +55,17 @@ func ApplyId() string {
 	return fmt.Sprintf("apply-%s", trimmedSha)
 }
 
+func PlanId() string {
+	uniqueIdentifier := uuid.New().String()
+
+	hasher := sha1.New()
+	hasher.Write([]byte(uniqueIdentifier))
+	sha := base64.URLEncoding.EncodeToString(hasher.Sum(nil))
+
+	trimmedSha := TruncateString(sha, 16)
+	return fmt.Sprintf("plan-%s", trimmedSha)
+}
+
 func StateVersionId(workspaceId string) string {
 	uniqueIdentifier := fmt.Sprintf("%s %s", workspaceId, uuid.New().String())
 
@@ -77,6 +88,17 @@ func RunId(run *tfe.Run) string {
 	return fmt.Sprintf("cv-%s", trimmedSha)
 }
 
+func NotificationConfigurationId(workspaceId string) string {
+	uniqueIdentifier := fmt.Sprintf("%s %s", workspaceId, uuid.New().String())
+
+	hasher := sha1.New()
+	hasher.Write([]byte(uniqueIdentifier))
+	sha := base64.URLEncoding.EncodeToString(hasher.Sum(nil))
+
+	trimmedSha := TruncateString(sha, 16)
+	return fmt.Sprintf("nc-%s", trimmedSha)
+}
+
 func TruncateString(str string, length int) string {
 	if length <= 0 {
 		return ""
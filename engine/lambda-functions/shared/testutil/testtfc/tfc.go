@@ -12,6 +12,7 @@ import (
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/testutil/mocking"
 	"github.com/hashicorp/go-tfe"
@@ -25,6 +26,10 @@ type MockTFC struct {
 
 	OrganizationName string
 
+	// CanManageWorkspaces is whether the team of the token may manage all workspaces of the organization, and thereby
+	// read each of them
+	CanManageWorkspaces bool
+
 	http *httptest.Server
 
 	// Projects is a map of all the Projects the mock TFC contains, with their respective id as the keys
@@ -45,6 +50,30 @@ type MockTFC struct {
 	// Applies is a map containing the all the Applies the mock TFC contains, the keys are the paths for the Applies
 	Applies map[string]*tfe.Apply
 
+	// Plans is a map containing the all the Plans the mock TFC contains, the keys are the paths for the Plans
+	Plans map[string]*tfe.Plan
+
+	// PlanJSONOutputs is a map containing the JSON output of Plans the mock TFC contains, the keys are the IDs of the Plans
+	PlanJSONOutputs map[string][]byte
+
+	// AssessmentResults is a map containing the current health assessment results the mock TFC contains, the keys are the IDs of the Workspaces that own them
+	AssessmentResults map[string]*AssessmentResult
+
+	// AssessmentJSONOutputs is a map containing the JSON output of health assessments the mock TFC contains, the keys are the IDs of the AssessmentResults
+	AssessmentJSONOutputs map[string][]byte
+
+	// NotificationConfigurations is a map of all the NotificationConfigurations the mock TFC contains, with their respective id as the keys
+	NotificationConfigurations map[string]*tfe.NotificationConfiguration
+
+	// TeamTokens is a map of the team tokens the mock TFC contains, with the IDs of the teams that own them as the keys
+	TeamTokens map[string]*tfe.TeamToken
+
+	// TeamTokenExpiries is a map of the times the team tokens expire at, with the IDs of the teams that own them as the keys
+	TeamTokenExpiries map[string]time.Time
+
+	// Logs is a map containing all the logs of Plans and Applies the mock TFC contains, the keys are the paths for the logs
+	Logs map[string]string
+
 	// StateVersions is a map containing the all the StateVersions the mock TFC contains, the keys are the IDs of the Workspaces that own them
 	StateVersions map[string]*tfe.StateVersion
 
@@ -54,6 +83,9 @@ type MockTFC struct {
 	// StateVersionOutputs is a map containing the all the StateVersionOutputs the mock TFC contains, the keys are the IDs of the StateVersion that own them
 	StateVersionOutputs map[string][]*tfe.StateVersionOutput
 
+	// States is a map containing the raw state of StateVersions the mock TFC contains, the keys are the IDs of the StateVersions
+	States map[string][]byte
+
 	// configurationVersionsById is a map of all the ConfigurationVersions the mock TFC server contains, the keys are the IDs of the configurationVersions
 	configurationVersionsById map[string]*tfe.ConfigurationVersion
 
@@ -80,15 +112,25 @@ type MockTFC struct {
 func NewMockTFC() *MockTFC {
 	mock := &MockTFC{
 		OrganizationName:                "team-rocket-blast-off",
+		CanManageWorkspaces:             true,
 		Projects:                        map[string]*tfe.Project{},
 		Workspaces:                      map[string]*tfe.Workspace{},
 		WorkspaceServiceCatalogMetadata: map[string]*ServiceCatalogMetadata{},
 		Runs:                            map[string]*tfe.Run{},
 		Vars:                            map[string][]*tfe.Variable{},
 		Applies:                         map[string]*tfe.Apply{},
+		Plans:                           map[string]*tfe.Plan{},
+		PlanJSONOutputs:                 map[string][]byte{},
+		AssessmentResults:               map[string]*AssessmentResult{},
+		AssessmentJSONOutputs:           map[string][]byte{},
+		TeamTokens:                      map[string]*tfe.TeamToken{},
+		TeamTokenExpiries:               map[string]time.Time{},
+		Logs:                            map[string]string{},
+		NotificationConfigurations:      map[string]*tfe.NotificationConfiguration{},
 		StateVersions:                   map[string]*tfe.StateVersion{},
 		StateVersionsByApply:            map[string][]*tfe.StateVersion{},
 		StateVersionOutputs:             map[string][]*tfe.StateVersionOutput{},
+		States:                          map[string][]byte{},
 		configurationVersionsById:       map[string]*tfe.ConfigurationVersion{},
 	}
 	mock.http = httptest.NewServer(mock)
@@ -207,6 +249,9 @@ func (srv *MockTFC) handlePOST(w http.ResponseWriter, r *http.Request) {
 	if srv.HandleWorkspacesPostRequests(w, r) {
 		return
 	}
+	if srv.HandleWorkspaceActionsPostRequests(w, r) {
+		return
+	}
 	if srv.HandleVarsPostRequests(w, r) {
 		return
 	}
@@ -219,6 +264,9 @@ func (srv *MockTFC) handlePOST(w http.ResponseWriter, r *http.Request) {
 	if srv.HandleTokensPostRequests(w, r) {
 		return
 	}
+	if srv.HandleNotificationConfigurationsPostRequests(w, r) {
+		return
+	}
 
 	// Not found error
 	w.WriteHeader(404)
@@ -232,6 +280,21 @@ func (srv *MockTFC) handleGET(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if srv.HandleLogsGetRequests(w, r) {
+		return
+	}
+	if srv.HandleTokensGetRequests(w, r) {
+		return
+	}
+	if srv.HandleTeamsGetRequests(w, r) {
+		return
+	}
+	if srv.HandleOrganizationsGetRequests(w, r) {
+		return
+	}
+	if srv.HandleNotificationConfigurationsGetRequests(w, r) {
+		return
+	}
 	if srv.HandleProjectsGetRequests(w, r) {
 		return
 	}
@@ -250,9 +313,15 @@ func (srv *MockTFC) handleGET(w http.ResponseWriter, r *http.Request) {
 	if srv.HandleAppliesGetRequests(w, r) {
 		return
 	}
+	if srv.HandlePlansGetRequests(w, r) {
+		return
+	}
 	if srv.HandleStateVersionsGetRequests(w, r) {
 		return
 	}
+	if srv.HandleAssessmentResultsGetRequests(w, r) {
+		return
+	}
 
 	// Not found error
 	w.WriteHeader(404)
@@ -262,6 +331,9 @@ func (srv *MockTFC) handlePUT(w http.ResponseWriter, r *http.Request) {
 	if srv.HandleConfigurationVersionsUploads(w, r) {
 		return
 	}
+	if srv.HandleNotificationConfigurationsPatchRequests(w, r) {
+		return
+	}
 	if srv.HandleVarsPatchRequests(w, r) {
 		return
 	}
@@ -279,11 +351,19 @@ func (srv *MockTFC) handleDELETE(w http.ResponseWriter, r *http.Request) {
 	if srv.HandleVarsDeleteRequests(w, r) {
 		return
 	}
+	if srv.HandleTokensDeleteRequests(w, r) {
+		return
+	}
 
 	w.WriteHeader(404)
 }
 
 func (srv *MockTFC) checkBaseRequest(r *http.Request) (int, []byte) {
+	// Log read URLs are pre-signed, so they are requested without the usual headers
+	if strings.HasPrefix(r.URL.Path, "/logs/") {
+		return 0, []byte{}
+	}
+
 	expectHeaders := []string{
 		"User-Agent",
 		"Authorization",
@@ -60,7 +60,8 @@ func (srv *MockTFC) MakeGetApplyResponse(apply tfe.Apply) map[string]interface{}
 			"id":   apply.ID,
 			"type": "applies",
 			"attributes": map[string]interface{}{
-				"status": apply.Status,
+				"status":       apply.Status,
+				"log-read-url": apply.LogReadURL,
 			},
 			"relationships": relationships,
 			"links": map[string]interface{}{
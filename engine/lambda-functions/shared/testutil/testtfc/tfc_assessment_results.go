@@ -0,0 +1,90 @@
+/*
+ * Copyright (c) HashiCorp, Inc.
+ * SPDX-License-Identifier: MPL-2.0
+ */
+
+package testtfc
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// AssessmentResult is the result of a health assessment of a workspace, which go-tfe has no type for
+type AssessmentResult struct {
+	ID        string
+	Drifted   bool
+	Succeeded bool
+	ErrorMsg  string
+	CreatedAt time.Time
+}
+
+// AddAssessmentResult makes the given result the current health assessment result of the workspace, along with the
+// JSON output of the assessment's plan
+func (srv *MockTFC) AddAssessmentResult(workspaceId string, result *AssessmentResult, jsonOutput []byte) *AssessmentResult {
+	srv.AssessmentResults[workspaceId] = result
+	if jsonOutput != nil {
+		srv.AssessmentJSONOutputs[result.ID] = jsonOutput
+	}
+
+	return result
+}
+
+func (srv *MockTFC) HandleAssessmentResultsGetRequests(w http.ResponseWriter, r *http.Request) bool {
+	urlPathParts := strings.Split(r.URL.Path, "/")
+
+	// /api/v2/workspaces/123456789042-amazingly/current-assessment-result => "", "api", "v2", "workspaces", "123456789042-amazingly", "current-assessment-result"
+	if len(urlPathParts) == 6 && urlPathParts[3] == "workspaces" && urlPathParts[5] == "current-assessment-result" {
+		result := srv.AssessmentResults[urlPathParts[4]]
+		if result == nil {
+			w.WriteHeader(404)
+			return true
+		}
+
+		body, err := json.Marshal(MakeGetAssessmentResultResponse(*result))
+		if err != nil {
+			w.WriteHeader(500)
+			return true
+		}
+		w.WriteHeader(200)
+		w.Write(body)
+		return true
+	}
+
+	// /api/v2/assessment-results/asmtres-123/json-output => "", "api", "v2", "assessment-results", "asmtres-123", "json-output"
+	if len(urlPathParts) == 6 && urlPathParts[3] == "assessment-results" && urlPathParts[5] == "json-output" {
+		jsonOutput, found := srv.AssessmentJSONOutputs[urlPathParts[4]]
+		if !found {
+			w.WriteHeader(404)
+			return true
+		}
+		w.WriteHeader(200)
+		w.Write(jsonOutput)
+		return true
+	}
+
+	return false
+}
+
+func MakeGetAssessmentResultResponse(result AssessmentResult) map[string]interface{} {
+	selfLink := fmt.Sprintf("/api/v2/assessment-results/%s", result.ID)
+
+	return map[string]interface{}{
+		"data": map[string]interface{}{
+			"id":   result.ID,
+			"type": "assessment-results",
+			"attributes": map[string]interface{}{
+				"drifted":    result.Drifted,
+				"succeeded":  result.Succeeded,
+				"error-msg":  result.ErrorMsg,
+				"created-at": result.CreatedAt.UTC().Format(time.RFC3339),
+			},
+			"links": map[string]interface{}{
+				"self": selfLink,
+			},
+		},
+	}
+}
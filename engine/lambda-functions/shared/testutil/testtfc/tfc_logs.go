@@ -0,0 +1,56 @@
+/*
+ * Copyright (c) HashiCorp, Inc.
+ * SPDX-License-Identifier: MPL-2.0
+ */
+
+package testtfc
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// AddLogs saves the log to the mock server and returns the URL it can be read from, which is to be used as the
+// LogReadURL of a Plan or Apply
+func (srv *MockTFC) AddLogs(logs string) string {
+	logPath := fmt.Sprintf("/logs/%s", uuid.New().String())
+
+	// Wrap the log in the STX and ETX markers, like the real log streams
+	srv.Logs[logPath] = "\x02" + logs + "\x03"
+
+	return fmt.Sprintf("%s%s", srv.Address, logPath)
+}
+
+func (srv *MockTFC) HandleLogsGetRequests(w http.ResponseWriter, r *http.Request) bool {
+	if !strings.HasPrefix(r.URL.Path, "/logs/") {
+		return false
+	}
+
+	logs, ok := srv.Logs[r.URL.Path]
+	if !ok {
+		return false
+	}
+
+	// Serve the requested chunk of the log
+	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil {
+		limit = len(logs)
+	}
+
+	start := offset
+	if start > len(logs) {
+		start = len(logs)
+	}
+	end := start + limit
+	if end > len(logs) {
+		end = len(logs)
+	}
+
+	w.WriteHeader(200)
+	w.Write([]byte(logs[start:end]))
+	return true
+}
@@ -0,0 +1,208 @@
+/*
+ * Copyright (c) HashiCorp, Inc.
+ * SPDX-License-Identifier: MPL-2.0
+ */
+
+package testtfc
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/hashicorp/go-tfe"
+	"log"
+	"net/http"
+	"strings"
+)
+
+func (srv *MockTFC) AddNotificationConfiguration(workspaceId string, notificationConfiguration *tfe.NotificationConfiguration) *tfe.NotificationConfiguration {
+	srv.requestLock.Lock()
+	defer srv.requestLock.Unlock()
+
+	notificationConfiguration.ID = NotificationConfigurationId(workspaceId)
+	notificationConfiguration.Subscribable = &tfe.Workspace{ID: workspaceId}
+	srv.NotificationConfigurations[notificationConfiguration.ID] = notificationConfiguration
+
+	return notificationConfiguration
+}
+
+// WorkspaceNotificationConfigurations returns the notification configurations of the workspace
+func (srv *MockTFC) WorkspaceNotificationConfigurations(workspaceId string) []*tfe.NotificationConfiguration {
+	notificationConfigurations := make([]*tfe.NotificationConfiguration, 0)
+	for _, notificationConfiguration := range srv.NotificationConfigurations {
+		if notificationConfiguration.Subscribable.ID == workspaceId {
+			notificationConfigurations = append(notificationConfigurations, notificationConfiguration)
+		}
+	}
+	return notificationConfigurations
+}
+
+func (srv *MockTFC) HandleNotificationConfigurationsPostRequests(w http.ResponseWriter, r *http.Request) bool {
+	// /api/v2/workspaces/ws-2jmj7l5rSw0yVb_v/notification-configurations => "", "api", "v2" "workspaces" "ws-2jmj7l5rSw0yVb_v" "notification-configurations"
+	urlPathParts := strings.Split(r.URL.Path, "/")
+
+	if len(urlPathParts) == 6 && urlPathParts[3] == "workspaces" && urlPathParts[5] == "notification-configurations" {
+		workspaceId := urlPathParts[4]
+
+		request := &NotificationConfigurationUpdateOrCreateRequest{}
+		if err := json.NewDecoder(r.Body).Decode(request); err != nil {
+			w.WriteHeader(500)
+			return true
+		}
+
+		notificationConfiguration := &tfe.NotificationConfiguration{}
+		copyRequestToNotificationConfiguration(notificationConfiguration, request)
+		notificationConfiguration = srv.AddNotificationConfiguration(workspaceId, notificationConfiguration)
+
+		writeNotificationConfigurationResponse(w, 201, MakeNotificationConfigurationResponse(notificationConfiguration))
+		return true
+	}
+
+	return false
+}
+
+func (srv *MockTFC) HandleNotificationConfigurationsPatchRequests(w http.ResponseWriter, r *http.Request) bool {
+	// /api/v2/notification-configurations/nc-rOOv9Dd => "", "api", "v2" "notification-configurations" "nc-rOOv9Dd"
+	urlPathParts := strings.Split(r.URL.Path, "/")
+
+	if len(urlPathParts) == 5 && urlPathParts[3] == "notification-configurations" {
+		notificationConfiguration := srv.NotificationConfigurations[urlPathParts[4]]
+		if notificationConfiguration == nil {
+			w.WriteHeader(404)
+			return true
+		}
+
+		request := &NotificationConfigurationUpdateOrCreateRequest{}
+		if err := json.NewDecoder(r.Body).Decode(request); err != nil {
+			w.WriteHeader(500)
+			return true
+		}
+
+		copyRequestToNotificationConfiguration(notificationConfiguration, request)
+
+		writeNotificationConfigurationResponse(w, 200, MakeNotificationConfigurationResponse(notificationConfiguration))
+		return true
+	}
+
+	return false
+}
+
+func (srv *MockTFC) HandleNotificationConfigurationsGetRequests(w http.ResponseWriter, r *http.Request) bool {
+	// /api/v2/workspaces/ws-2jmj7l5rSw0yVb_v/notification-configurations => "", "api", "v2" "workspaces" "ws-2jmj7l5rSw0yVb_v" "notification-configurations"
+	urlPathParts := strings.Split(r.URL.Path, "/")
+
+	if len(urlPathParts) == 6 && urlPathParts[3] == "workspaces" && urlPathParts[5] == "notification-configurations" {
+		srv.requestLock.Lock()
+		defer srv.requestLock.Unlock()
+
+		notificationConfigurations := srv.WorkspaceNotificationConfigurations(urlPathParts[4])
+
+		writeNotificationConfigurationResponse(w, 200, MakeListNotificationConfigurationsResponse(notificationConfigurations))
+		return true
+	}
+
+	return false
+}
+
+func writeNotificationConfigurationResponse(w http.ResponseWriter, statusCode int, response map[string]interface{}) {
+	body, err := json.Marshal(response)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+	w.WriteHeader(statusCode)
+	_, err = w.Write(body)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func MakeListNotificationConfigurationsResponse(notificationConfigurations []*tfe.NotificationConfiguration) map[string]interface{} {
+	data := make([]map[string]interface{}, 0)
+
+	for _, notificationConfiguration := range notificationConfigurations {
+		data = append(data, makeNotificationConfigurationData(notificationConfiguration))
+	}
+
+	return map[string]interface{}{
+		"data": data,
+		"meta": map[string]interface{}{
+			"pagination": map[string]interface{}{
+				"current-page": 1,
+				"page-size":    100,
+				"prev-page":    nil,
+				"next-page":    nil,
+				"total-pages":  1,
+				"total-count":  len(notificationConfigurations),
+			},
+		},
+	}
+}
+
+func MakeNotificationConfigurationResponse(notificationConfiguration *tfe.NotificationConfiguration) map[string]interface{} {
+	return map[string]interface{}{
+		"data": makeNotificationConfigurationData(notificationConfiguration),
+	}
+}
+
+func makeNotificationConfigurationData(notificationConfiguration *tfe.NotificationConfiguration) map[string]interface{} {
+	selfLink := fmt.Sprintf("/api/v2/notification-configurations/%s", notificationConfiguration.ID)
+
+	return map[string]interface{}{
+		"id":   notificationConfiguration.ID,
+		"type": "notification-configurations",
+		"attributes": map[string]interface{}{
+			"destination-type": notificationConfiguration.DestinationType,
+			"enabled":          notificationConfiguration.Enabled,
+			"name":             notificationConfiguration.Name,
+			"token":            "",
+			"triggers":         notificationConfiguration.Triggers,
+			"url":              notificationConfiguration.URL,
+		},
+		"relationships": map[string]interface{}{
+			"subscribable": map[string]interface{}{
+				"data": map[string]interface{}{
+					"id":   notificationConfiguration.Subscribable.ID,
+					"type": "workspaces",
+				},
+			},
+		},
+		"links": map[string]interface{}{
+			"self": selfLink,
+		},
+	}
+}
+
+func copyRequestToNotificationConfiguration(notificationConfiguration *tfe.NotificationConfiguration, request *NotificationConfigurationUpdateOrCreateRequest) {
+	attributes := request.Data.Attributes
+	if attributes.DestinationType != nil {
+		notificationConfiguration.DestinationType = *attributes.DestinationType
+	}
+	if attributes.Enabled != nil {
+		notificationConfiguration.Enabled = *attributes.Enabled
+	}
+	if attributes.Name != nil {
+		notificationConfiguration.Name = *attributes.Name
+	}
+	if attributes.Token != nil {
+		notificationConfiguration.Token = *attributes.Token
+	}
+	if attributes.Triggers != nil {
+		notificationConfiguration.Triggers = attributes.Triggers
+	}
+	if attributes.URL != nil {
+		notificationConfiguration.URL = *attributes.URL
+	}
+}
+
+type NotificationConfigurationUpdateOrCreateRequest struct {
+	Data struct {
+		Attributes struct {
+			DestinationType *tfe.NotificationDestinationType `json:"destination-type"`
+			Enabled         *bool                            `json:"enabled"`
+			Name            *string                          `json:"name"`
+			Token           *string                          `json:"token"`
+			Triggers        []string                         `json:"triggers"`
+			URL             *string                          `json:"url"`
+		} `json:"attributes"`
+	} `json:"data"`
+}
@@ -0,0 +1,43 @@
+/*
+ * Copyright (c) HashiCorp, Inc.
+ * SPDX-License-Identifier: MPL-2.0
+ */
+
+package testtfc
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+)
+
+func (srv *MockTFC) HandleOrganizationsGetRequests(w http.ResponseWriter, r *http.Request) bool {
+	if r.URL.Path != fmt.Sprintf("/api/v2/organizations/%s", srv.OrganizationName) {
+		return false
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"data": map[string]interface{}{
+			"id":   srv.OrganizationName,
+			"type": "organizations",
+			"attributes": map[string]interface{}{
+				"name": srv.OrganizationName,
+				"permissions": map[string]interface{}{
+					"can-create-workspace": srv.CanManageWorkspaces,
+				},
+			},
+		},
+	})
+	if err != nil {
+		w.WriteHeader(500)
+		return true
+	}
+	w.WriteHeader(200)
+	_, err = w.Write(body)
+	if err != nil {
+		log.Fatal(err)
+		return true
+	}
+	return true
+}
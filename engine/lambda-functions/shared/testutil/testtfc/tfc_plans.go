@@ -0,0 +1,75 @@
+/*
+ * Copyright (c) HashiCorp, Inc.
+ * SPDX-License-Identifier: MPL-2.0
+ */
+
+package testtfc
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/hashicorp/go-tfe"
+	"net/http"
+	"strings"
+)
+
+func (srv *MockTFC) AddPlan(plan *tfe.Plan) *tfe.Plan {
+	plan.ID = PlanId()
+
+	// Save the Plan to the mock server
+	planPath := fmt.Sprintf("/api/v2/plans/%s", plan.ID)
+	srv.Plans[planPath] = plan
+
+	return plan
+}
+
+func (srv *MockTFC) HandlePlansGetRequests(w http.ResponseWriter, r *http.Request) bool {
+	// /api/v2/plans/plan-123/json-output => "", "api", "v2", "plans", "plan-123", "json-output"
+	urlPathParts := strings.Split(r.URL.Path, "/")
+	if len(urlPathParts) == 6 && urlPathParts[3] == "plans" && urlPathParts[5] == "json-output" {
+		jsonOutput, found := srv.PlanJSONOutputs[urlPathParts[4]]
+		if !found {
+			w.WriteHeader(404)
+			return true
+		}
+		w.WriteHeader(200)
+		w.Write(jsonOutput)
+		return true
+	}
+
+	plan := srv.Plans[r.URL.Path]
+	if plan != nil {
+		body, err := json.Marshal(MakeGetPlanResponse(*plan))
+		if err != nil {
+			w.WriteHeader(500)
+			return true
+		}
+		w.WriteHeader(200)
+		w.Write(body)
+		return true
+	}
+
+	return false
+}
+
+func MakeGetPlanResponse(plan tfe.Plan) map[string]interface{} {
+	selfLink := fmt.Sprintf("/api/v2/plans/%s", plan.ID)
+
+	return map[string]interface{}{
+		"data": map[string]interface{}{
+			"id":   plan.ID,
+			"type": "plans",
+			"attributes": map[string]interface{}{
+				"status":                plan.Status,
+				"has-changes":           plan.HasChanges,
+				"log-read-url":          plan.LogReadURL,
+				"resource-additions":    plan.ResourceAdditions,
+				"resource-changes":      plan.ResourceChanges,
+				"resource-destructions": plan.ResourceDestructions,
+			},
+			"links": map[string]interface{}{
+				"self": selfLink,
+			},
+		},
+	}
+}
@@ -16,7 +16,7 @@ type ProjectFactoryParameters struct {
 	Name string
 }
 
-func (srv *MockTFC) AddProject(id string, p ProjectFactoryParameters) {
+func (srv *MockTFC) AddProject(id string, p ProjectFactoryParameters) *tfe.Project {
 	name := id
 	if p.Name != "" {
 		name = p.Name
@@ -31,16 +31,19 @@ func (srv *MockTFC) AddProject(id string, p ProjectFactoryParameters) {
 	// Save the Project to the mock server
 	projectId := fmt.Sprintf(id)
 	srv.Projects[projectId] = project
+
+	return project
 }
 
 func (srv *MockTFC) HandleProjectsPostRequests(w http.ResponseWriter, r *http.Request) bool {
 	if r.URL.Path == fmt.Sprintf("/api/v2/organizations/%s/projects", srv.OrganizationName) {
-		var project *tfe.Project
-		if err := json.NewDecoder(r.Body).Decode(&project); err != nil {
+		var projectRequest *ProjectCreateRequest
+		if err := json.NewDecoder(r.Body).Decode(&projectRequest); err != nil {
 			w.WriteHeader(500)
 			return true
 		}
-		srv.AddProject(project.Name, ProjectFactoryParameters{Name: project.Name})
+		name := projectRequest.Data.Attributes.Name
+		project := srv.AddProject(name, ProjectFactoryParameters{Name: name})
 
 		receipt := MakeProjectResponse(project)
 		body, err := json.Marshal(receipt)
@@ -126,3 +129,11 @@ func MakeProjectResponse(project *tfe.Project) map[string]interface{} {
 		},
 	}
 }
+
+type ProjectCreateRequest struct {
+	Data struct {
+		Attributes struct {
+			Name string `json:"name"`
+		} `json:"attributes"`
+	} `json:"data"`
+}
@@ -10,20 +10,43 @@ import (
 	"fmt"
 	"net/http"
 	"encoding/json"
+	"strings"
 	"time"
+	"sort"
 )
 
 type RunFactoryParameters struct {
-	RunStatus tfe.RunStatus
-	Apply     *tfe.Apply
+	RunStatus       tfe.RunStatus
+	Apply           *tfe.Apply
+	Plan            *tfe.Plan
+	PositionInQueue int
+	CreatedAt       time.Time
+	Actions         *tfe.RunActions
+	WorkspaceId     string
+	Message         string
+	RefreshOnly     bool
+	PlanOnly        bool
+	HasChanges      bool
 }
 
 func (srv *MockTFC) AddRun(runId string, p RunFactoryParameters) *tfe.Run {
 	// Create the mock run
 	run := &tfe.Run{
-		ID:     runId,
-		Status: p.RunStatus,
-		Apply:  p.Apply,
+		ID:              runId,
+		Status:          p.RunStatus,
+		Apply:           p.Apply,
+		Plan:            p.Plan,
+		PositionInQueue: p.PositionInQueue,
+		CreatedAt:       p.CreatedAt,
+		Actions:         p.Actions,
+		Message:         p.Message,
+		RefreshOnly:     p.RefreshOnly,
+		PlanOnly:        p.PlanOnly,
+		HasChanges:      p.HasChanges,
+	}
+
+	if p.WorkspaceId != "" {
+		run.Workspace = &tfe.Workspace{ID: p.WorkspaceId}
 	}
 
 	// Save the run to the mock server
@@ -66,10 +89,59 @@ func (srv *MockTFC) HandleRunsPostRequests(w http.ResponseWriter, r *http.Reques
 		return true
 	}
 
+	if strings.HasPrefix(r.URL.Path, "/api/v2/runs/") && strings.Contains(r.URL.Path, "/actions/") {
+		return srv.HandleRunActionsPostRequests(w, r)
+	}
+
 	return false
 }
 
+// HandleRunActionsPostRequests handles applying and discarding runs, moving the run on to the next status
+func (srv *MockTFC) HandleRunActionsPostRequests(w http.ResponseWriter, r *http.Request) bool {
+	pathParts := strings.Split(r.URL.Path, "/actions/")
+	run := srv.Runs[pathParts[0]]
+	if run == nil {
+		w.WriteHeader(404)
+		return true
+	}
+
+	switch pathParts[1] {
+	case "apply":
+		if run.Actions == nil || !run.Actions.IsConfirmable {
+			w.WriteHeader(409)
+			return true
+		}
+		run.Status = tfe.RunConfirmed
+	case "discard":
+		if run.Actions == nil || !run.Actions.IsDiscardable {
+			w.WriteHeader(409)
+			return true
+		}
+		run.Status = tfe.RunDiscarded
+	default:
+		w.WriteHeader(404)
+		return true
+	}
+
+	run.Actions = &tfe.RunActions{}
+	w.WriteHeader(202)
+	return true
+}
+
 func (srv *MockTFC) HandleRunsGetRequests(w http.ResponseWriter, r *http.Request) bool {
+	// /api/v2/workspaces/123456789042-amazingly/runs => "", "api", "v2", "workspaces", "123456789042-amazingly", "runs"
+	urlPathParts := strings.Split(r.URL.Path, "/")
+	if len(urlPathParts) == 6 && urlPathParts[3] == "workspaces" && urlPathParts[5] == "runs" {
+		body, err := json.Marshal(MakeListRunsResponse(srv.workspaceRuns(urlPathParts[4])))
+		if err != nil {
+			w.WriteHeader(500)
+			return true
+		}
+		w.WriteHeader(200)
+		w.Write(body)
+		return true
+	}
+
 	run := srv.Runs[r.URL.Path]
 	if run != nil {
 		body, err := json.Marshal(MakeGetRunResponse(*run))
@@ -85,6 +157,33 @@ func (srv *MockTFC) HandleRunsGetRequests(w http.ResponseWriter, r *http.Request
 	return false
 }
 
+// workspaceRuns returns the runs of the workspace, newest first like TFC lists them
+func (srv *MockTFC) workspaceRuns(workspaceId string) []*tfe.Run {
+	runs := []*tfe.Run{}
+	for _, run := range srv.Runs {
+		if run.Workspace != nil && run.Workspace.ID == workspaceId {
+			runs = append(runs, run)
+		}
+	}
+
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].CreatedAt.After(runs[j].CreatedAt)
+	})
+
+	return runs
+}
+
+func MakeListRunsResponse(runs []*tfe.Run) map[string]interface{} {
+	data := make([]interface{}, 0, len(runs))
+	for _, run := range runs {
+		data = append(data, MakeGetRunResponse(*run)["data"])
+	}
+
+	return map[string]interface{}{
+		"data": data,
+	}
+}
+
 func MakeGetRunResponse(run tfe.Run) map[string]interface{} {
 	selfLink := fmt.Sprintf("/api/v2/runs/%s", run.ID)
 
@@ -102,13 +201,43 @@ func MakeGetRunResponse(run tfe.Run) map[string]interface{} {
 		}
 	}
 
+	if run.Plan != nil {
+		relationships["plan"] = map[string]interface{}{
+			"data": map[string]interface{}{
+				"id":   run.Plan.ID,
+				"type": "plans",
+			},
+		}
+	}
+
+	attributes := map[string]interface{}{
+		"status":            run.Status,
+		"position-in-queue": run.PositionInQueue,
+		"auto-apply":        run.AutoApply,
+		"message":           run.Message,
+		"refresh-only":      run.RefreshOnly,
+		"plan-only":         run.PlanOnly,
+		"has-changes":       run.HasChanges,
+	}
+
+	if run.Actions != nil {
+		attributes["actions"] = map[string]interface{}{
+			"is-cancelable":       run.Actions.IsCancelable,
+			"is-confirmable":      run.Actions.IsConfirmable,
+			"is-discardable":      run.Actions.IsDiscardable,
+			"is-force-cancelable": run.Actions.IsForceCancelable,
+		}
+	}
+
+	if !run.CreatedAt.IsZero() {
+		attributes["created-at"] = run.CreatedAt.UTC().Format(time.RFC3339)
+	}
+
 	return map[string]interface{}{
 		"data": map[string]interface{}{
-			"id":   run.ID,
-			"type": "runs",
-			"attributes": map[string]interface{}{
-				"status": run.Status,
-			},
+			"id":            run.ID,
+			"type":          "runs",
+			"attributes":    attributes,
 			"relationships": relationships,
 			"links": map[string]interface{}{
 				"self": selfLink,
@@ -121,8 +250,11 @@ type RunPostRequest struct {
 	Data struct {
 		Id         int `json:"id"`
 		Attributes struct {
-			AutoApply bool `json:"auto-apply"`
-			IsDestroy bool `json:"is-destroy"`
+			AutoApply   bool   `json:"auto-apply"`
+			IsDestroy   bool   `json:"is-destroy"`
+			Message     string `json:"message"`
+			RefreshOnly bool   `json:"refresh-only"`
+			PlanOnly    bool   `json:"plan-only"`
 		} `json:"attributes"`
 		Relationships struct {
 			Workspace struct {
@@ -138,6 +270,9 @@ func RunFromRequest(req RunPostRequest) *tfe.Run {
 	return &tfe.Run{
 		AutoApply:              req.Data.Attributes.AutoApply,
 		IsDestroy:              req.Data.Attributes.IsDestroy,
+		Message:                req.Data.Attributes.Message,
+		RefreshOnly:            req.Data.Attributes.RefreshOnly,
+		PlanOnly:               req.Data.Attributes.PlanOnly,
 		CreatedAt:              time.Now(),
 		ForceCancelAvailableAt: time.Now(),
 		Workspace: &tfe.Workspace{
@@ -37,10 +37,36 @@ func (srv *MockTFC) AddStateVersion(applyId string, stateVersion *tfe.StateVersi
 	return stateVersion
 }
 
+// SetCurrentState adds a StateVersion with the given raw state, and makes it the current StateVersion of the Workspace
+func (srv *MockTFC) SetCurrentState(workspaceId string, state []byte) *tfe.StateVersion {
+	stateVersion := &tfe.StateVersion{
+		ID: StateVersionId(workspaceId),
+	}
+	stateVersion.DownloadURL = fmt.Sprintf("%s/api/v2/state-versions/%s/download", srv.Address, stateVersion.ID)
+
+	srv.StateVersions[workspaceId] = stateVersion
+	srv.States[stateVersion.ID] = state
+
+	return stateVersion
+}
+
 func (srv *MockTFC) HandleStateVersionsGetRequests(w http.ResponseWriter, r *http.Request) bool {
 	// /api/v2/workspaces/my-workspace/current-state-version => "", "api", "v2", "workspaces", "my-workspace", "current-state-version"
 	urlPathParts := strings.Split(r.URL.Path, "/")
 
+	// /api/v2/state-versions/sv-6DzZZJg0D_V0rcKz/download => "", "api", "v2", "state-versions", "sv-some-id", "download"
+	if len(urlPathParts) > 5 && urlPathParts[3] == "state-versions" && urlPathParts[5] == "download" {
+		state := srv.States[urlPathParts[4]]
+		if state == nil {
+			w.WriteHeader(404)
+			return true
+		}
+
+		w.WriteHeader(200)
+		w.Write(state)
+		return true
+	}
+
 	if urlPathParts[3] == "workspaces" && urlPathParts[5] == "current-state-version" {
 		workspaceId := urlPathParts[4]
 
@@ -121,8 +147,9 @@ func MakeGetStateVersionResponse(stateVersion tfe.StateVersion) map[string]inter
 			"id":   stateVersion.ID,
 			"type": "state-versions",
 			"attributes": map[string]interface{}{
-				"download-url": stateVersion.DownloadURL,
-				"created-at":   stateVersion.CreatedAt.UTC().Format(time.RFC3339),
+				"download-url":              stateVersion.DownloadURL,
+				"hosted-state-download-url": stateVersion.DownloadURL,
+				"created-at":                stateVersion.CreatedAt.UTC().Format(time.RFC3339),
 			},
 			"relationships": relationships,
 			"links": map[string]interface{}{
@@ -0,0 +1,44 @@
+/*
+ * Copyright (c) HashiCorp, Inc.
+ * SPDX-License-Identifier: MPL-2.0
+ */
+
+package testtfc
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+)
+
+func (srv *MockTFC) HandleTeamsGetRequests(w http.ResponseWriter, r *http.Request) bool {
+	// /api/v2/teams/team-roLYatraNNailuJ2 => "", "api", "v2" "teams" "team-roLYatraNNailuJ2"
+	urlPathParts := strings.Split(r.URL.Path, "/")
+
+	if len(urlPathParts) != 5 || urlPathParts[3] != "teams" {
+		return false
+	}
+
+	teamId := urlPathParts[4]
+	body, err := json.Marshal(map[string]interface{}{
+		"data": map[string]interface{}{
+			"id":   teamId,
+			"type": "teams",
+			"attributes": map[string]interface{}{
+				"name": teamId,
+			},
+		},
+	})
+	if err != nil {
+		w.WriteHeader(500)
+		return true
+	}
+	w.WriteHeader(200)
+	_, err = w.Write(body)
+	if err != nil {
+		log.Fatal(err)
+		return true
+	}
+	return true
+}
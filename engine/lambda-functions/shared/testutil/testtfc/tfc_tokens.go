@@ -7,12 +7,24 @@ package testtfc
 
 import (
 	"encoding/json"
+	"errors"
 	"github.com/hashicorp/go-tfe"
+	"io"
 	"log"
 	"net/http"
 	"strings"
+	"time"
 )
 
+// TeamTokenCreateRequest is the body of a request to create a team token
+type TeamTokenCreateRequest struct {
+	Data struct {
+		Attributes struct {
+			ExpiredAt *time.Time `json:"expired-at"`
+		} `json:"attributes"`
+	} `json:"data"`
+}
+
 func (srv *MockTFC) HandleTokensPostRequests(w http.ResponseWriter, r *http.Request) bool {
 	// /api/v2/teams/team-roLYatraNNailuJ2/authentication-token => "", "api", "v2" "teams" "team-roLYatraNNailuJ2" "authentication-token"
 	urlPathParts := strings.Split(r.URL.Path, "/")
@@ -23,10 +35,23 @@ func (srv *MockTFC) HandleTokensPostRequests(w http.ResponseWriter, r *http.Requ
 	if urlPathParts[3] == "teams" && urlPathParts[5] == "authentication-token" {
 		teamId := urlPathParts[4]
 
-		teamToken := &tfe.TeamToken{ID: teamId, Token: "newsupers3cret"}
+		// go-tfe creates team tokens without a body
+		request := &TeamTokenCreateRequest{}
+		if err := json.NewDecoder(r.Body).Decode(request); err != nil && !errors.Is(err, io.EOF) {
+			w.WriteHeader(500)
+			return true
+		}
+
+		teamToken := &tfe.TeamToken{ID: teamId, Token: "newsupers3cret", CreatedAt: time.Now().UTC().Truncate(time.Second)}
 		srv.SetToken(teamToken.Token)
+		srv.TeamTokens[teamId] = teamToken
+		if request.Data.Attributes.ExpiredAt != nil {
+			srv.TeamTokenExpiries[teamId] = *request.Data.Attributes.ExpiredAt
+		} else {
+			delete(srv.TeamTokenExpiries, teamId)
+		}
 
-		body, err := json.Marshal(MakeTeamTokenResponse(teamToken))
+		body, err := json.Marshal(srv.MakeTeamTokenResponse(teamToken))
 		if err != nil {
 			w.WriteHeader(500)
 			return true
@@ -43,17 +68,75 @@ func (srv *MockTFC) HandleTokensPostRequests(w http.ResponseWriter, r *http.Requ
 	return false
 }
 
-func MakeTeamTokenResponse(teamToken *tfe.TeamToken) map[string]interface{} {
+func (srv *MockTFC) HandleTokensGetRequests(w http.ResponseWriter, r *http.Request) bool {
+	// /api/v2/teams/team-roLYatraNNailuJ2/authentication-token => "", "api", "v2" "teams" "team-roLYatraNNailuJ2" "authentication-token"
+	urlPathParts := strings.Split(r.URL.Path, "/")
+
+	if len(urlPathParts) != 6 || urlPathParts[3] != "teams" || urlPathParts[5] != "authentication-token" {
+		return false
+	}
+
+	teamToken := srv.TeamTokens[urlPathParts[4]]
+	if teamToken == nil {
+		w.WriteHeader(404)
+		return true
+	}
+
+	// The secret value of a token is only returned when it is created
+	response := srv.MakeTeamTokenResponse(&tfe.TeamToken{ID: teamToken.ID, CreatedAt: teamToken.CreatedAt})
+	body, err := json.Marshal(response)
+	if err != nil {
+		w.WriteHeader(500)
+		return true
+	}
+	w.WriteHeader(200)
+	_, err = w.Write(body)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return true
+}
+
+func (srv *MockTFC) HandleTokensDeleteRequests(w http.ResponseWriter, r *http.Request) bool {
+	// /api/v2/teams/team-roLYatraNNailuJ2/authentication-token => "", "api", "v2" "teams" "team-roLYatraNNailuJ2" "authentication-token"
+	urlPathParts := strings.Split(r.URL.Path, "/")
+
+	if len(urlPathParts) < 6 {
+		return false
+	}
+	if urlPathParts[3] == "teams" && urlPathParts[5] == "authentication-token" {
+		teamId := urlPathParts[4]
+
+		if srv.TeamTokens[teamId] == nil {
+			w.WriteHeader(404)
+			return true
+		}
+
+		delete(srv.TeamTokens, teamId)
+		delete(srv.TeamTokenExpiries, teamId)
+		w.WriteHeader(204)
+		return true
+	}
+
+	return false
+}
+
+func (srv *MockTFC) MakeTeamTokenResponse(teamToken *tfe.TeamToken) map[string]interface{} {
+	var expiredAt interface{}
+	if expiry, ok := srv.TeamTokenExpiries[teamToken.ID]; ok {
+		expiredAt = expiry.Format(time.RFC3339)
+	}
+
 	return map[string]interface{}{
 		"data": map[string]interface{}{
 			"id":   "1337023",
 			"type": "authentication-tokens",
 			"attributes": map[string]interface{}{
-				"created-at":   teamToken.CreatedAt,
+				"created-at":   teamToken.CreatedAt.Format(time.RFC3339),
 				"last-used-at": teamToken.LastUsedAt,
 				"description":  teamToken.Description,
 				"token":        teamToken.Token,
-				"expired-at":   "",
+				"expired-at":   expiredAt,
 			},
 		},
 		"relationships": map[string]interface{}{},
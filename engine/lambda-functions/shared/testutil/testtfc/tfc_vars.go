@@ -205,15 +205,23 @@ func MakeListVarsResponse(vars []*tfe.Variable, page int, size int) map[string]i
 	paginatedData := vars[startIndex:endIndex]
 
 	for _, variable := range paginatedData {
+		// TFC never returns the values of sensitive variables
+		var value interface{} = variable.Value
+		if variable.Sensitive {
+			value = nil
+		}
+
 		selfLink := fmt.Sprintf("/api/v2/vars/%s", variable.ID)
 		datum := map[string]interface{}{
 			"id":   variable.ID,
 			"type": "vars",
 			"attributes": map[string]interface{}{
-				"key":      variable.Key,
-				"value":    variable.Value,
-				"category": variable.Category,
-				"hcl":      variable.HCL,
+				"key":         variable.Key,
+				"value":       value,
+				"description": variable.Description,
+				"category":    variable.Category,
+				"hcl":         variable.HCL,
+				"sensitive":   variable.Sensitive,
 			},
 			"relationships": map[string]interface{}{},
 			"links": map[string]interface{}{
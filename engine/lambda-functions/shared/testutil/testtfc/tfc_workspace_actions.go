@@ -0,0 +1,101 @@
+/*
+ * Copyright (c) HashiCorp, Inc.
+ * SPDX-License-Identifier: MPL-2.0
+ */
+
+package testtfc
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+type WorkspaceAddTagsRequest struct {
+	Data []struct {
+		Type       string `json:"type"`
+		Attributes struct {
+			Name string `json:"name"`
+		} `json:"attributes"`
+	} `json:"data"`
+}
+
+func (srv *MockTFC) HandleWorkspaceActionsPostRequests(w http.ResponseWriter, r *http.Request) bool {
+	// /api/v2/workspaces/ws-2jmj7l5rSw0yVb_v/actions/lock => "", "api", "v2", "workspaces", "ws-2jmj7l5rSw0yVb_v", "actions", "lock"
+	urlPathParts := strings.Split(r.URL.Path, "/")
+	if len(urlPathParts) != 7 || urlPathParts[3] != "workspaces" {
+		return false
+	}
+
+	workspaceId := urlPathParts[4]
+	action := strings.Join(urlPathParts[5:], "/")
+	if action != "actions/lock" && action != "actions/unlock" && action != "actions/force-unlock" && action != "actions/safe-delete" && action != "relationships/tags" {
+		return false
+	}
+
+	srv.requestLock.Lock()
+	defer srv.requestLock.Unlock()
+
+	workspace := srv.Workspaces[workspaceId]
+	if workspace == nil {
+		w.WriteHeader(404)
+		return true
+	}
+
+	switch action {
+	case "actions/lock":
+		if workspace.Locked {
+			w.WriteHeader(409)
+			return true
+		}
+		workspace.Locked = true
+
+	case "actions/unlock", "actions/force-unlock":
+		workspace.Locked = false
+
+	case "actions/safe-delete":
+		// TFC refuses to safe-delete workspaces that are locked or still manage resources
+		if workspace.Locked || workspace.ResourceCount > 0 {
+			w.WriteHeader(409)
+			return true
+		}
+
+		delete(srv.Workspaces, workspaceId)
+		w.WriteHeader(204)
+		return true
+
+	case "relationships/tags":
+		request := &WorkspaceAddTagsRequest{}
+		if err := json.NewDecoder(r.Body).Decode(request); err != nil {
+			w.WriteHeader(500)
+			return true
+		}
+
+		for _, tag := range request.Data {
+			if !containsTag(workspace.TagNames, tag.Attributes.Name) {
+				workspace.TagNames = append(workspace.TagNames, tag.Attributes.Name)
+			}
+		}
+
+		w.WriteHeader(204)
+		return true
+	}
+
+	body, err := json.Marshal(MakeWorkspaceResponse(workspace))
+	if err != nil {
+		w.WriteHeader(500)
+		return true
+	}
+	w.WriteHeader(200)
+	w.Write(body)
+	return true
+}
+
+func containsTag(tagNames []string, tagName string) bool {
+	for _, existingTagName := range tagNames {
+		if existingTagName == tagName {
+			return true
+		}
+	}
+	return false
+}
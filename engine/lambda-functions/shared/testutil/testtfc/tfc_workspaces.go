@@ -12,6 +12,7 @@ import (
 	"log"
 	"net/http"
 	"strings"
+	"time"
 )
 
 type WorkspaceFactoryParameters struct {
@@ -42,6 +43,21 @@ const ProvisionedProductIdMetadataHeaderKey = "Tfp-Aws-Service-Catalog-Prv-Produ
 const ProductVersionMetadataHeaderKey = "Tfp-Aws-Service-Catalog-Product-Ver"
 
 func (srv *MockTFC) HandleWorkspacesPostRequests(w http.ResponseWriter, r *http.Request) bool {
+	// /api/v2/organizations/team-rocket-blast-off/workspaces/123456789042-amazingly/actions/safe-delete => "", "api", "v2", "organizations", "team-rocket-blast-off", "workspaces", "123456789042-amazingly", "actions", "safe-delete"
+	urlPathParts := strings.Split(r.URL.Path, "/")
+	if len(urlPathParts) == 9 && urlPathParts[3] == "organizations" && urlPathParts[5] == "workspaces" && urlPathParts[8] == "safe-delete" {
+		workspaceId := urlPathParts[6]
+
+		if srv.Workspaces[workspaceId] == nil {
+			w.WriteHeader(404)
+			return true
+		}
+
+		delete(srv.Workspaces, workspaceId)
+		w.WriteHeader(204)
+		return true
+	}
+
 	if r.URL.Path == fmt.Sprintf("/api/v2/organizations/%s/workspaces", srv.OrganizationName) {
 		var workspace *tfe.Workspace
 		if err := json.NewDecoder(r.Body).Decode(&workspace); err != nil {
@@ -95,6 +111,21 @@ func (srv *MockTFC) HandleWorkspacesPatchRequests(w http.ResponseWriter, r *http
 		}
 
 		workspace.TerraformVersion = reqWorkspace.Data.Attributes.TerraformVersion
+		if reqWorkspace.Data.Attributes.Name != "" {
+			workspace.Name = reqWorkspace.Data.Attributes.Name
+		}
+		if projectId := reqWorkspace.Data.Relationships.Project.Data.Id; projectId != "" {
+			workspace.Project = &tfe.Project{ID: projectId}
+		}
+
+		// Persist metadata headers (if they were provided)
+		if productId := r.Header.Get(ProductIdMetadataHeaderKey); productId != "" {
+			srv.WorkspaceServiceCatalogMetadata[workspaceId] = &ServiceCatalogMetadata{
+				ProductId:            productId,
+				ProvisionedProductId: r.Header.Get(ProvisionedProductIdMetadataHeaderKey),
+				ProductVersion:       r.Header.Get(ProductVersionMetadataHeaderKey),
+			}
+		}
 
 		body, err := json.Marshal(MakeWorkspaceResponse(workspace))
 		if err != nil {
@@ -118,8 +149,15 @@ type WorkspaceUpdateRequest struct {
 		Id         int `json:"id"`
 		Attributes struct {
 			TerraformVersion string `json:"value"`
+			Name             string `json:"name"`
 		} `json:"attributes"`
-		Relationships struct{} `json:"relationships"`
+		Relationships struct {
+			Project struct {
+				Data struct {
+					Id string `json:"id"`
+				} `json:"data"`
+			} `json:"project"`
+		} `json:"relationships"`
 	} `json:"data"`
 }
 
@@ -128,6 +166,9 @@ func (srv *MockTFC) HandleWorkspacesGetRequests(w http.ResponseWriter, r *http.R
 		workspaces := make([]*tfe.Workspace, 0, len(srv.Workspaces))
 
 		for _, value := range srv.Workspaces {
+			if !matchesWorkspaceSearch(value, r.URL.Query().Get("search[name]"), r.URL.Query().Get("search[tags]"), r.URL.Query().Get("search[exclude-tags]")) {
+				continue
+			}
 			workspaces = append(workspaces, value)
 		}
 
@@ -198,7 +239,12 @@ func MakeListWorkspacesResponse(workspaces []*tfe.Workspace) map[string]interfac
 			"id":   workspace.ID,
 			"type": "workspaces",
 			"attributes": map[string]interface{}{
-				"name": workspace.Name,
+				"name":                workspace.Name,
+				"locked":              workspace.Locked,
+				"tag-names":           tagNamesOf(workspace),
+				"assessments-enabled": workspace.AssessmentsEnabled,
+				"resource-count":      workspace.ResourceCount,
+				"created-at":          workspace.CreatedAt.Format(time.RFC3339),
 			},
 			"relationships": map[string]interface{}{},
 			"links": map[string]interface{}{
@@ -222,7 +268,12 @@ func MakeWorkspaceResponse(workspace *tfe.Workspace) map[string]interface{} {
 			"id":   workspace.ID,
 			"type": "workspaces",
 			"attributes": map[string]interface{}{
-				"name": workspace.Name,
+				"name":                workspace.Name,
+				"locked":              workspace.Locked,
+				"tag-names":           tagNamesOf(workspace),
+				"assessments-enabled": workspace.AssessmentsEnabled,
+				"resource-count":      workspace.ResourceCount,
+				"created-at":          workspace.CreatedAt.Format(time.RFC3339),
 			},
 		},
 		"relationships": map[string]interface{}{},
@@ -231,3 +282,36 @@ func MakeWorkspaceResponse(workspace *tfe.Workspace) map[string]interface{} {
 		},
 	}
 }
+
+// matchesWorkspaceSearch checks if the workspace name contains the name search, and if the workspace has all the tags
+// of the comma-separated tags search and none of the comma-separated excluded tags
+func matchesWorkspaceSearch(workspace *tfe.Workspace, name string, tags string, excludeTags string) bool {
+	if !strings.Contains(workspace.Name, name) {
+		return false
+	}
+
+	if tags != "" {
+		for _, tag := range strings.Split(tags, ",") {
+			if !containsTag(workspace.TagNames, tag) {
+				return false
+			}
+		}
+	}
+
+	if excludeTags != "" {
+		for _, tag := range strings.Split(excludeTags, ",") {
+			if containsTag(workspace.TagNames, tag) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+func tagNamesOf(workspace *tfe.Workspace) []string {
+	if workspace.TagNames == nil {
+		return []string{}
+	}
+	return workspace.TagNames
+}
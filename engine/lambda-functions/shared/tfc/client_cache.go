@@ -0,0 +1,242 @@
+/*
+ * Copyright (c) HashiCorp, Inc.
+ * SPDX-License-Identifier: MPL-2.0
+ */
+
+package tfc
+
+import (
+	"context"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/secretsmanager"
+	"github.com/hashicorp/go-retryablehttp"
+	"github.com/hashicorp/go-tfe"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// defaultCredentialsTtl is how long the TFE credentials are cached across warm invocations, unless the
+// TFE_CREDENTIALS_CACHE_TTL_IN_SECONDS env var is set
+const defaultCredentialsTtl = 5 * time.Minute
+
+var clientCaches = struct {
+	lock   sync.Mutex
+	caches map[secretsmanager.SecretsManager]*ClientCache
+}{caches: map[secretsmanager.SecretsManager]*ClientCache{}}
+
+// ClientCache caches the TFE credentials read from a secret, and the TFE client created from them, so warm invocations
+// of a Lambda neither read the secret nor open new connections to TFC. Requests are always sent with the cached token,
+// and a request TFC rejects as unauthorized is retried once with the credentials read from the secret again, in case
+// the token was rotated since it was cached.
+type ClientCache struct {
+	secretsManager secretsmanager.SecretsManager
+	ttl            time.Duration
+	now            func() time.Time
+	// httpClient is shared by all clients created from the cache, so they reuse its connections
+	httpClient *http.Client
+
+	lock        sync.Mutex
+	credentials *secretsmanager.TFECredentialsSecret
+	fetchedAt   time.Time
+	client      *tfe.Client
+}
+
+// NewClientCache creates a cache of the TFE credentials read from the secret, which are read again once they are older
+// than the TTL
+func NewClientCache(secretsManager secretsmanager.SecretsManager, ttl time.Duration) *ClientCache {
+	cache := &ClientCache{
+		secretsManager: secretsManager,
+		ttl:            ttl,
+		now:            time.Now,
+	}
+
+	retryClient := retryablehttp.NewClient()
+	retryClient.RetryMax = 10
+	cache.httpClient = retryClient.HTTPClient
+	cache.httpClient.Transport = &refreshingTransport{cache: cache, base: cache.httpClient.Transport}
+	return cache
+}
+
+// clientCacheFor returns the cache of the credentials read from the secret, which lives as long as the Lambda execution
+// environment. Returns nil if the credentials must not be cached.
+func clientCacheFor(secretsManager secretsmanager.SecretsManager) *ClientCache {
+	ttl := credentialsTtl()
+	if ttl <= 0 || !reflect.TypeOf(secretsManager).Comparable() {
+		return nil
+	}
+
+	clientCaches.lock.Lock()
+	defer clientCaches.lock.Unlock()
+
+	cache, found := clientCaches.caches[secretsManager]
+	if !found {
+		cache = NewClientCache(secretsManager, ttl)
+		clientCaches.caches[secretsManager] = cache
+	}
+	return cache
+}
+
+// ResetClientCaches drops the caches of all secrets, so the credentials are read from the secrets again. Tests use it
+// to start from an empty cache.
+func ResetClientCaches() {
+	clientCaches.lock.Lock()
+	defer clientCaches.lock.Unlock()
+
+	clientCaches.caches = map[secretsmanager.SecretsManager]*ClientCache{}
+}
+
+func credentialsTtl() time.Duration {
+	ttl := os.Getenv("TFE_CREDENTIALS_CACHE_TTL_IN_SECONDS")
+	if ttl == "" {
+		return defaultCredentialsTtl
+	}
+
+	seconds, err := strconv.Atoi(ttl)
+	if err != nil {
+		log.Default().Printf("ignoring invalid TFE_CREDENTIALS_CACHE_TTL_IN_SECONDS %q: %v", ttl, err)
+		return defaultCredentialsTtl
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// GetCredentials returns the cached TFE credentials, reading them from the secret if they are missing or expired
+func (cache *ClientCache) GetCredentials(ctx context.Context) (*secretsmanager.TFECredentialsSecret, error) {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+
+	if cache.credentials != nil && cache.now().Sub(cache.fetchedAt) < cache.ttl {
+		return cache.credentials, nil
+	}
+	return cache.fetchCredentials(ctx)
+}
+
+// GetClient returns a TFE client using the cached credentials, sending the headers with every request. The client is
+// reused across invocations unless headers are given.
+func (cache *ClientCache) GetClient(ctx context.Context, headers http.Header) (*tfe.Client, error) {
+	credentials, err := cache.GetCredentials(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cache.lock.Lock()
+	client := cache.client
+	cache.lock.Unlock()
+	if len(headers) == 0 && client != nil {
+		return client, nil
+	}
+
+	// The lock is not held while creating the client, as it sends a request through the transport of the cache
+	client, err = clientWithHTTPClient(clientAddress(credentials.Hostname), credentials.Token, headers, cache.httpClient)
+	if err != nil {
+		return nil, err
+	}
+	if len(headers) == 0 {
+		cache.lock.Lock()
+		cache.client = client
+		cache.lock.Unlock()
+	}
+	return client, nil
+}
+
+// Invalidate drops the cached credentials unless they were refreshed since the given token was read, so they are read
+// from the secret again. Returns the token to use from then on.
+func (cache *ClientCache) Invalidate(ctx context.Context, staleToken string) (string, error) {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+
+	if cache.credentials != nil && cache.credentials.Token != staleToken {
+		return cache.credentials.Token, nil
+	}
+
+	credentials, err := cache.fetchCredentials(ctx)
+	if err != nil {
+		return "", err
+	}
+	return credentials.Token, nil
+}
+
+// fetchCredentials reads the credentials from the secret, the lock of the cache must be held
+func (cache *ClientCache) fetchCredentials(ctx context.Context) (*secretsmanager.TFECredentialsSecret, error) {
+	log.Default().Print("fetching TFC credentials from Secrets Manager")
+	credentials, err := cache.secretsManager.GetSecretValue(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Clients send requests to the hostname they were created for, so they cannot be reused if it changed
+	if cache.credentials == nil || cache.credentials.Hostname != credentials.Hostname {
+		cache.client = nil
+	}
+	cache.credentials = credentials
+	cache.fetchedAt = cache.now()
+	return credentials, nil
+}
+
+// token returns the cached token, or an empty string if no credentials were read yet
+func (cache *ClientCache) token() string {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+
+	if cache.credentials == nil {
+		return ""
+	}
+	return cache.credentials.Token
+}
+
+// refreshingTransport sends authorized requests with the cached token, which may have been refreshed since the client
+// sending them was created, and retries a request TFC rejects as unauthorized once with a refreshed token
+type refreshingTransport struct {
+	cache *ClientCache
+	base  http.RoundTripper
+}
+
+func (t *refreshingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Requests without a token, like uploads to pre-signed URLs, are sent as they are
+	token := t.cache.token()
+	if req.Header.Get("Authorization") == "" || token == "" {
+		return t.base.RoundTrip(req)
+	}
+
+	res, err := t.base.RoundTrip(withToken(req, token))
+	if err != nil || res.StatusCode != http.StatusUnauthorized {
+		return res, err
+	}
+
+	// The request can only be retried if its body can be read again
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return res, nil
+	}
+
+	freshToken, err := t.cache.Invalidate(req.Context(), token)
+	if err != nil {
+		log.Default().Printf("failed to refresh TFC credentials after an unauthorized response: %v", err)
+		return res, nil
+	}
+	if freshToken == token {
+		return res, nil
+	}
+
+	retry := withToken(req, freshToken)
+	if req.GetBody != nil {
+		if retry.Body, err = req.GetBody(); err != nil {
+			return res, nil
+		}
+	}
+
+	log.Default().Print("retrying TFC request with refreshed credentials after an unauthorized response")
+	_, _ = io.Copy(io.Discard, res.Body)
+	_ = res.Body.Close()
+	return t.base.RoundTrip(retry)
+}
+
+// withToken returns a copy of the request, authorized with the token
+func withToken(req *http.Request, token string) *http.Request {
+	authorized := req.Clone(req.Context())
+	authorized.Header.Set("Authorization", "Bearer "+token)
+	return authorized
+}
@@ -0,0 +1,225 @@
+/*
+ * Copyright (c) HashiCorp, Inc.
+ * SPDX-License-Identifier: MPL-2.0
+ */
+
+package tfc
+
+import (
+	"context"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/secretsmanager"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingSecretsManager holds TFE credentials that can be changed between reads, and counts how often they were read
+type countingSecretsManager struct {
+	hostname string
+	token    string
+	fetches  int
+}
+
+func (sm *countingSecretsManager) GetSecretValue(ctx context.Context) (*secretsmanager.TFECredentialsSecret, error) {
+	sm.fetches++
+	return &secretsmanager.TFECredentialsSecret{
+		Hostname: sm.hostname,
+		TeamId:   "team-4123nlol",
+		Token:    sm.token,
+	}, nil
+}
+
+func (sm *countingSecretsManager) UpdateSecretValue(ctx context.Context, secretValue string) error {
+	sm.token = secretValue
+	return nil
+}
+
+// newTokenServer starts a TFC stand-in that only authorizes requests with the given token, and counts the requests
+// other than pings
+func newTokenServer(token string, requests *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v2/ping" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		atomic.AddInt32(requests, 1)
+		if r.Header.Get("Authorization") != "Bearer "+token {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+}
+
+func TestClientCache_GetCredentialsExpiresAfterTtl(t *testing.T) {
+	sm := &countingSecretsManager{hostname: "https://tfc.example.com", token: "supers3cret"}
+	now := time.Now()
+	cache := NewClientCache(sm, time.Minute)
+	cache.now = func() time.Time { return now }
+
+	// Verify the credentials are read once while they are fresh
+	for i := 0; i < 3; i++ {
+		credentials, err := cache.GetCredentials(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, "supers3cret", credentials.Token)
+	}
+	assert.Equal(t, 1, sm.fetches)
+
+	// Verify the credentials are read again once they expired
+	sm.token = "newsupers3cret"
+	now = now.Add(time.Minute)
+	credentials, err := cache.GetCredentials(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "newsupers3cret", credentials.Token)
+	assert.Equal(t, 2, sm.fetches)
+}
+
+func TestClientCache_DisabledWithZeroTtl(t *testing.T) {
+	t.Setenv("TFE_CREDENTIALS_CACHE_TTL_IN_SECONDS", "0")
+	ResetClientCaches()
+	defer ResetClientCaches()
+
+	var requests int32
+	server := newTokenServer("supers3cret", &requests)
+	defer server.Close()
+
+	sm := &countingSecretsManager{hostname: server.URL, token: "supers3cret"}
+	assert.Nil(t, clientCacheFor(sm))
+
+	// Verify the credentials are read for every client
+	for i := 0; i < 2; i++ {
+		_, credentials, err := GetTFEClientAndCredentials(context.Background(), sm)
+		assert.NoError(t, err)
+		assert.Equal(t, "supers3cret", credentials.Token)
+	}
+	assert.Equal(t, 2, sm.fetches)
+}
+
+func TestClientCache_CachedAcrossInvocations(t *testing.T) {
+	ResetClientCaches()
+	defer ResetClientCaches()
+
+	var requests int32
+	server := newTokenServer("supers3cret", &requests)
+	defer server.Close()
+
+	sm := &countingSecretsManager{hostname: server.URL, token: "supers3cret"}
+
+	// Verify the credentials are read once, and the client is reused
+	firstClient, _, err := GetTFEClientAndCredentials(context.Background(), sm)
+	assert.NoError(t, err)
+	secondClient, _, err := GetTFEClientAndCredentials(context.Background(), sm)
+	assert.NoError(t, err)
+	assert.Same(t, firstClient, secondClient)
+	assert.Equal(t, 1, sm.fetches)
+
+	// Verify the reset drops the cached credentials
+	ResetClientCaches()
+	_, _, err = GetTFEClientAndCredentials(context.Background(), sm)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, sm.fetches)
+}
+
+func TestClientCache_InvalidateSkipsFetchAfterRefresh(t *testing.T) {
+	sm := &countingSecretsManager{hostname: "https://tfc.example.com", token: "supers3cret"}
+	cache := NewClientCache(sm, time.Hour)
+
+	_, err := cache.GetCredentials(context.Background())
+	assert.NoError(t, err)
+
+	// The token is rotated, and the first caller to find out refreshes the credentials
+	sm.token = "newsupers3cret"
+	token, err := cache.Invalidate(context.Background(), "supers3cret")
+	assert.NoError(t, err)
+	assert.Equal(t, "newsupers3cret", token)
+	assert.Equal(t, 2, sm.fetches)
+
+	// Verify another caller that still used the rotated token gets the refreshed token, without reading it again
+	token, err = cache.Invalidate(context.Background(), "supers3cret")
+	assert.NoError(t, err)
+	assert.Equal(t, "newsupers3cret", token)
+	assert.Equal(t, 2, sm.fetches)
+}
+
+func TestClientCache_HostnameChangeDropsClient(t *testing.T) {
+	var requests int32
+	server := newTokenServer("supers3cret", &requests)
+	defer server.Close()
+	otherServer := newTokenServer("supers3cret", &requests)
+	defer otherServer.Close()
+
+	sm := &countingSecretsManager{hostname: server.URL, token: "supers3cret"}
+	now := time.Now()
+	cache := NewClientCache(sm, time.Minute)
+	cache.now = func() time.Time { return now }
+
+	firstClient, err := cache.GetClient(context.Background(), http.Header{})
+	assert.NoError(t, err)
+
+	// Verify the client is kept when the credentials are read again for the same hostname
+	now = now.Add(time.Minute)
+	client, err := cache.GetClient(context.Background(), http.Header{})
+	assert.NoError(t, err)
+	assert.Same(t, firstClient, client)
+	assert.Equal(t, 2, sm.fetches)
+
+	// Verify the client is dropped once the credentials point to another hostname
+	sm.hostname = otherServer.URL
+	now = now.Add(time.Minute)
+	client, err = cache.GetClient(context.Background(), http.Header{})
+	assert.NoError(t, err)
+	assert.NotSame(t, firstClient, client)
+	assert.Equal(t, 3, sm.fetches)
+}
+
+func TestClientCache_RetriesUnauthorizedRequests(t *testing.T) {
+	var requests int32
+	server := newTokenServer("newsupers3cret", &requests)
+	defer server.Close()
+
+	sm := &countingSecretsManager{hostname: server.URL, token: "supers3cret"}
+	cache := NewClientCache(sm, time.Hour)
+	_, err := cache.GetCredentials(context.Background())
+	assert.NoError(t, err)
+
+	// The token is rotated after it was cached
+	sm.token = "newsupers3cret"
+
+	t.Run("requests with a body that cannot be read again are not retried", func(t *testing.T) {
+		atomic.StoreInt32(&requests, 0)
+		req, err := http.NewRequest(http.MethodPost, server.URL+"/api/v2/runs", io.NopCloser(strings.NewReader(`{"data":{}}`)))
+		assert.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer supers3cret")
+
+		res, err := cache.httpClient.Do(req)
+		assert.NoError(t, err)
+		res.Body.Close()
+
+		// Verify the unauthorized response was returned, without refreshing the credentials
+		assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+		assert.Equal(t, 1, sm.fetches)
+	})
+
+	t.Run("requests with a body that can be read again are retried with the refreshed token", func(t *testing.T) {
+		atomic.StoreInt32(&requests, 0)
+		req, err := http.NewRequest(http.MethodPost, server.URL+"/api/v2/runs", strings.NewReader(`{"data":{}}`))
+		assert.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer supers3cret")
+
+		res, err := cache.httpClient.Do(req)
+		assert.NoError(t, err)
+		res.Body.Close()
+
+		// Verify the request succeeded once the credentials were refreshed
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+		assert.Equal(t, 2, sm.fetches)
+	})
+}
@@ -0,0 +1,83 @@
+/*
+ * Copyright (c) HashiCorp, Inc.
+ * SPDX-License-Identifier: MPL-2.0
+ */
+
+package tfc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/hashicorp/go-tfe"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TerminatedTag is the tag of workspaces whose provisioned product was terminated, but which are retained until the
+// retention period passes
+const TerminatedTag = "terminated"
+
+// TerminatedAtTagPrefix is the prefix of the tag that holds the time the provisioned product was terminated at, as a
+// unix timestamp. TFC tags cannot hold other characters than lowercase letters, numbers, colons, hyphens and underscores.
+const TerminatedAtTagPrefix = "terminated-at:"
+
+const TerminatedLockReason = "The provisioned product of this workspace was terminated via AWS Service Catalog"
+
+// TerminatedAtTag returns the tag that records the time a provisioned product was terminated at
+func TerminatedAtTag(terminatedAt time.Time) string {
+	return TerminatedAtTagPrefix + strconv.FormatInt(terminatedAt.Unix(), 10)
+}
+
+// ParseTerminatedAt returns the time the provisioned product of the workspace was terminated at, as recorded in its tags
+func ParseTerminatedAt(tagNames []string) (time.Time, bool) {
+	for _, tagName := range tagNames {
+		if !strings.HasPrefix(tagName, TerminatedAtTagPrefix) {
+			continue
+		}
+
+		seconds, err := strconv.ParseInt(strings.TrimPrefix(tagName, TerminatedAtTagPrefix), 10, 64)
+		if err != nil {
+			continue
+		}
+
+		return time.Unix(seconds, 0), true
+	}
+
+	return time.Time{}, false
+}
+
+// RetainTerminatedWorkspace locks the workspace of a terminated provisioned product, so no runs can be queued in it,
+// and tags it as terminated, so it is deleted once the retention period passes
+func RetainTerminatedWorkspace(ctx context.Context, client *tfe.Client, workspace *tfe.Workspace, terminatedAt time.Time) error {
+	if !workspace.Locked {
+		_, err := client.Workspaces.Lock(ctx, workspace.ID, tfe.WorkspaceLockOptions{
+			Reason: tfe.String(TerminatedLockReason),
+		})
+		// The workspace may have been locked by a previous attempt
+		if err != nil && !errors.Is(err, tfe.ErrWorkspaceLocked) {
+			return Error(fmt.Errorf("failed to lock workspace: %w", err))
+		}
+	}
+
+	// Keep the termination time of a previous attempt, if there is one
+	terminatedAtTag := TerminatedAtTag(terminatedAt)
+	if previousTerminatedAt, found := ParseTerminatedAt(workspace.TagNames); found {
+		terminatedAtTag = TerminatedAtTag(previousTerminatedAt)
+	}
+
+	log.Default().Printf("retaining workspace %s, tagging it with %s and %s", workspace.Name, TerminatedTag, terminatedAtTag)
+	err := client.Workspaces.AddTags(ctx, workspace.ID, tfe.WorkspaceAddTagsOptions{
+		Tags: []*tfe.Tag{
+			{Name: TerminatedTag},
+			{Name: terminatedAtTag},
+		},
+	})
+	if err != nil {
+		return Error(fmt.Errorf("failed to tag workspace: %w", err))
+	}
+
+	return nil
+}
@@ -0,0 +1,117 @@
+/*
+ * Copyright (c) HashiCorp, Inc.
+ * SPDX-License-Identifier: MPL-2.0
+ */
+
+package tfc
+
+import (
+	"context"
+	"fmt"
+	"github.com/hashicorp/go-tfe"
+	"net/url"
+	"strings"
+)
+
+type RunPhase string
+
+// Enum values for RunPhase
+const (
+	RunPhasePending          RunPhase = "pending"
+	RunPhasePlanning         RunPhase = "planning"
+	RunPhaseChecking         RunPhase = "checking"
+	RunPhaseAwaitingDecision RunPhase = "awaitingDecision"
+	RunPhaseApplying         RunPhase = "applying"
+	RunPhaseCompleted        RunPhase = "completed"
+)
+
+// ResourceCounts holds the number of resources a run's plan will add, change and destroy
+type ResourceCounts struct {
+	Additions    int `json:"additions"`
+	Changes      int `json:"changes"`
+	Destructions int `json:"destructions"`
+}
+
+// GetRunPhase groups the fine-grained run statuses of TFC into the phases of a run's lifecycle
+func GetRunPhase(runStatus tfe.RunStatus) RunPhase {
+	switch runStatus {
+	case tfe.RunPending, tfe.RunFetching, tfe.RunFetchingCompleted, tfe.RunQueuing, tfe.RunPlanQueued:
+		return RunPhasePending
+	case tfe.RunPrePlanRunning, tfe.RunPrePlanCompleted, tfe.RunPlanning, tfe.RunPlanned:
+		return RunPhasePlanning
+	case tfe.RunCostEstimating, tfe.RunCostEstimated, tfe.RunPolicyChecking, tfe.RunPolicyChecked, tfe.RunPostPlanRunning, tfe.RunPostPlanCompleted:
+		return RunPhaseChecking
+	case tfe.RunPolicyOverride, tfe.RunPolicySoftFailed, tfe.RunPostPlanAwaitingDecision:
+		return RunPhaseAwaitingDecision
+	case tfe.RunConfirmed, tfe.RunApplyQueued, tfe.RunApplying:
+		return RunPhaseApplying
+	default:
+		return RunPhaseCompleted
+	}
+}
+
+// GetPlanResourceCounts fetches the resource counts of the run's plan, returning nil if the run has no plan yet
+func GetPlanResourceCounts(ctx context.Context, client *tfe.Client, run *tfe.Run) (*ResourceCounts, error) {
+	if run.Plan == nil || run.Plan.ID == "" {
+		return nil, nil
+	}
+
+	plan, err := client.Plans.Read(ctx, run.Plan.ID)
+	if err != nil {
+		return nil, Error(err)
+	}
+
+	return &ResourceCounts{
+		Additions:    plan.ResourceAdditions,
+		Changes:      plan.ResourceChanges,
+		Destructions: plan.ResourceDestructions,
+	}, nil
+}
+
+// GetRunUrl builds the link to the run in the web UI of the TFC/TFE host, which is `https://${hostname}/app/${organization}/workspaces/${workspaceName}/runs/${runId}`
+func GetRunUrl(hostname string, organization string, workspaceName string, runId string) string {
+	return fmt.Sprintf("%s/app/%s/workspaces/%s/runs/%s",
+		baseUrl(hostname),
+		url.PathEscape(organization),
+		url.PathEscape(workspaceName),
+		url.PathEscape(runId),
+	)
+}
+
+// GetWorkspaceDriftUrl builds the link to the drift page of the workspace's health assessments, which is `https://${hostname}/app/${organization}/workspaces/${workspaceName}/health/drift`
+func GetWorkspaceDriftUrl(hostname string, organization string, workspaceName string) string {
+	return fmt.Sprintf("%s/app/%s/workspaces/%s/health/drift",
+		baseUrl(hostname),
+		url.PathEscape(organization),
+		url.PathEscape(workspaceName),
+	)
+}
+
+func baseUrl(hostname string) string {
+	if !strings.HasPrefix(hostname, "https:") && !strings.HasPrefix(hostname, "http:") {
+		hostname = fmt.Sprintf("https://%s", hostname)
+	}
+
+	return strings.TrimSuffix(hostname, "/")
+}
+
+// IsRunSettled reports whether the run stopped progressing on its own, either because it completed or because it awaits
+// a decision, such that an execution waiting for the run should resume
+func IsRunSettled(run *tfe.Run) bool {
+	switch run.Status {
+	case tfe.RunApplied, tfe.RunPlannedAndFinished, tfe.RunErrored, tfe.RunCanceled, tfe.RunDiscarded:
+		return true
+	}
+
+	return IsRunAwaitingDecision(run)
+}
+
+// IsRunAwaitingDecision reports whether the run waits to be approved or rejected
+func IsRunAwaitingDecision(run *tfe.Run) bool {
+	if run.Status == tfe.RunPostPlanAwaitingDecision {
+		return true
+	}
+
+	// Runs that are not auto-applied wait for confirmation once they finished planning
+	return !run.AutoApply && run.Actions != nil && run.Actions.IsConfirmable
+}
@@ -26,6 +26,11 @@ func GetTFEClient(ctx context.Context, secretsManager secretsmanager.SecretsMana
 }
 
 func GetTFEClientWithHeaders(ctx context.Context, secretsManager secretsmanager.SecretsManager, headers http.Header) (*tfe.Client, error) {
+	// Reuse the credentials and client of previous invocations, unless they are not cached
+	if cache := clientCacheFor(secretsManager); cache != nil {
+		return cache.GetClient(ctx, headers)
+	}
+
 	// Fetch the TFE credentials/config from AWS Secrets Manager
 	log.Default().Print("fetching TFC credentials from Secrets Manager")
 	tfeCredentialsSecret, err := secretsManager.GetSecretValue(ctx)
@@ -37,24 +42,54 @@ func GetTFEClientWithHeaders(ctx context.Context, secretsManager secretsmanager.
 	return GetTFEClientWithCredentials(tfeCredentialsSecret, headers)
 }
 
+// GetTFEClientAndCredentials returns a TFE client along with the credentials it uses, for handlers that also need the
+// hostname of TFC. Both are cached like the clients returned by GetTFEClient.
+func GetTFEClientAndCredentials(ctx context.Context, secretsManager secretsmanager.SecretsManager) (*tfe.Client, *secretsmanager.TFECredentialsSecret, error) {
+	cache := clientCacheFor(secretsManager)
+	if cache == nil {
+		tfeCredentialsSecret, err := secretsManager.GetSecretValue(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		tfeClient, err := GetTFEClientWithCredentials(tfeCredentialsSecret, http.Header{})
+		return tfeClient, tfeCredentialsSecret, err
+	}
+
+	tfeCredentialsSecret, err := cache.GetCredentials(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	tfeClient, err := cache.GetClient(ctx, http.Header{})
+	return tfeClient, tfeCredentialsSecret, err
+}
+
 func GetTFEClientWithCredentials(tfeCredentialsSecret *secretsmanager.TFECredentialsSecret, headers http.Header) (*tfe.Client, error) {
-	if strings.HasPrefix(tfeCredentialsSecret.Hostname, "https:") || strings.HasPrefix(tfeCredentialsSecret.Hostname, "http:") {
-		return ClientWithDefaultConfig(tfeCredentialsSecret.Hostname, tfeCredentialsSecret.Token, headers)
+	return ClientWithDefaultConfig(clientAddress(tfeCredentialsSecret.Hostname), tfeCredentialsSecret.Token, headers)
+}
+
+// clientAddress returns the address of TFC at the hostname, prepending the protocol if it is missing
+func clientAddress(hostname string) string {
+	if strings.HasPrefix(hostname, "https:") || strings.HasPrefix(hostname, "http:") {
+		return hostname
 	}
 	log.Default().Print("prepending protocol to TFC client hostname")
-	return ClientWithDefaultConfig(fmt.Sprintf("https://%s", tfeCredentialsSecret.Hostname), tfeCredentialsSecret.Token, headers)
+	return fmt.Sprintf("https://%s", hostname)
 }
 
 func ClientWithDefaultConfig(address string, token string, headers http.Header) (*tfe.Client, error) {
-	log.Default().Printf("creating new TFC client for %s", address)
 	retryClient := retryablehttp.NewClient()
 	retryClient.RetryMax = 10
 
+	return clientWithHTTPClient(address, token, headers, retryClient.HTTPClient)
+}
+
+func clientWithHTTPClient(address string, token string, headers http.Header, httpClient *http.Client) (*tfe.Client, error) {
+	log.Default().Printf("creating new TFC client for %s", address)
 	return tfe.NewClient(&tfe.Config{
 		Address:           fmt.Sprintf(address),
 		Token:             token,
 		RetryServerErrors: true,
-		HTTPClient:        retryClient.HTTPClient,
+		HTTPClient:        httpClient,
 		Headers:           headers,
 	})
 }
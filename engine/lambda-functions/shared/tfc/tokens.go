@@ -0,0 +1,69 @@
+/*
+ * Copyright (c) HashiCorp, Inc.
+ * SPDX-License-Identifier: MPL-2.0
+ */
+
+package tfc
+
+import (
+	"context"
+	"fmt"
+	"github.com/hashicorp/go-tfe"
+	"net/url"
+	"time"
+)
+
+// TeamToken is a team token, with the expiry that the TeamToken of go-tfe does not hold
+type TeamToken struct {
+	ID        string     `jsonapi:"primary,authentication-tokens"`
+	CreatedAt time.Time  `jsonapi:"attr,created-at,iso8601"`
+	ExpiredAt *time.Time `jsonapi:"attr,expired-at,iso8601"`
+	Token     string     `jsonapi:"attr,token"`
+}
+
+// teamTokenCreateOptions are the options for creating a team token, which go-tfe does not support setting the expiry of
+type teamTokenCreateOptions struct {
+	// Type is a public field utilized by JSON:API to set the resource type via the field tag
+	Type string `jsonapi:"primary,authentication-tokens"`
+
+	ExpiredAt *time.Time `jsonapi:"attr,expired-at,iso8601,omitempty"`
+}
+
+// CreateTeamToken creates a new token for the team, replacing any existing token. The token expires at the given time,
+// or never if the time is zero.
+func CreateTeamToken(ctx context.Context, client *tfe.Client, teamId string, expiredAt time.Time) (*TeamToken, error) {
+	options := &teamTokenCreateOptions{}
+	if !expiredAt.IsZero() {
+		expiredAt = expiredAt.UTC().Truncate(time.Second)
+		options.ExpiredAt = &expiredAt
+	}
+
+	req, err := client.NewRequest("POST", teamTokenPath(teamId), options)
+	if err != nil {
+		return nil, err
+	}
+
+	tt := &TeamToken{}
+	if err = req.Do(ctx, tt); err != nil {
+		return nil, err
+	}
+	return tt, nil
+}
+
+// ReadTeamToken reads the current token of the team, without its secret value
+func ReadTeamToken(ctx context.Context, client *tfe.Client, teamId string) (*TeamToken, error) {
+	req, err := client.NewRequest("GET", teamTokenPath(teamId), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	tt := &TeamToken{}
+	if err = req.Do(ctx, tt); err != nil {
+		return nil, err
+	}
+	return tt, nil
+}
+
+func teamTokenPath(teamId string) string {
+	return fmt.Sprintf("teams/%s/authentication-token", url.QueryEscape(teamId))
+}
@@ -0,0 +1,43 @@
+/*
+ * Copyright (c) HashiCorp, Inc.
+ * SPDX-License-Identifier: MPL-2.0
+ */
+
+package tfc
+
+import (
+	"context"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/identifiers"
+	"github.com/hashicorp/go-tfe"
+)
+
+// ListProvisionedProductWorkspaces lists the workspaces named after provisioned products, leaving out the workspaces
+// of terminated provisioned products that are retained
+func ListProvisionedProductWorkspaces(ctx context.Context, client *tfe.Client, organization string) ([]*tfe.Workspace, error) {
+	var workspaces []*tfe.Workspace
+
+	pageNumber := 1
+	for {
+		page, err := client.Workspaces.List(ctx, organization, &tfe.WorkspaceListOptions{
+			ListOptions: tfe.ListOptions{
+				PageNumber: pageNumber,
+				PageSize:   100,
+			},
+			ExcludeTags: TerminatedTag,
+		})
+		if err != nil {
+			return nil, Error(err)
+		}
+
+		for _, workspace := range page.Items {
+			if _, _, ok := identifiers.ParseWorkspaceName(workspace.Name); ok {
+				workspaces = append(workspaces, workspace)
+			}
+		}
+
+		if page.Pagination == nil || page.NextPage == 0 {
+			return workspaces, nil
+		}
+		pageNumber = page.NextPage
+	}
+}
@@ -0,0 +1,63 @@
+/*
+ * Copyright (c) HashiCorp, Inc.
+ * SPDX-License-Identifier: MPL-2.0
+ */
+
+package main
+
+import (
+	"fmt"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/stepfunctions"
+	"log"
+	"time"
+)
+
+// StuckExecutionPolicy is what the rotation does about the executions still running after the maximum drain time
+type StuckExecutionPolicy string
+
+// Enum values for StuckExecutionPolicy
+const (
+	// AbortRotation fails the rotation, resuming the SQS queues without replacing the token
+	AbortRotation StuckExecutionPolicy = "abort"
+	// ProceedWithRotation replaces the token anyway, failing the executions still using it once they call TFC again
+	ProceedWithRotation StuckExecutionPolicy = "proceed"
+)
+
+// maxReportedExecutions is the maximum number of executions the rotation reports waiting on, keeping the output of the
+// Lambda well below the payload size limit of Step Functions
+const maxReportedExecutions = 20
+
+// OldestExecutions returns the oldest of the executions, which are sorted oldest first, up to maxReportedExecutions
+func OldestExecutions(executions []stepfunctions.RunningExecution) []stepfunctions.RunningExecution {
+	if len(executions) > maxReportedExecutions {
+		return executions[:maxReportedExecutions]
+	}
+	return executions
+}
+
+// CheckDrainTime decides what to do about the executions still running once the maximum drain time has passed since
+// the drain started. Returns whether the rotation proceeds without waiting for them, or an error if it is aborted.
+func (h *RotateTeamTokensHandler) CheckDrainTime(executions []stepfunctions.RunningExecution, drainStartedAt time.Time) (bool, error) {
+	if len(executions) == 0 || h.maxDrainTime <= 0 || time.Since(drainStartedAt) < h.maxDrainTime {
+		return false, nil
+	}
+
+	log.Default().Printf("the rotation has been waiting for state machine executions to finish for longer than %s", h.maxDrainTime)
+	return h.HandleStuckExecutions(executions)
+}
+
+// HandleStuckExecutions applies the stuck execution policy to the executions that did not finish in time, which are
+// sorted oldest first. Returns whether the rotation proceeds, or an error if it is aborted.
+func (h *RotateTeamTokensHandler) HandleStuckExecutions(executions []stepfunctions.RunningExecution) (bool, error) {
+	for _, execution := range OldestExecutions(executions) {
+		log.Default().Printf("state machine execution %s started at %s is still running", execution.ExecutionArn, execution.StartedAt.UTC().Format(time.RFC3339))
+	}
+
+	if h.stuckExecutionPolicy == ProceedWithRotation {
+		log.Default().Printf("rotating the token while %d state machine executions are still running, they fail once they use the previous token", len(executions))
+		return true, nil
+	}
+
+	oldest := executions[0]
+	return false, fmt.Errorf("%d state machine executions did not finish in time to rotate the token, the oldest is %s, started at %s", len(executions), oldest.ExecutionArn, oldest.StartedAt.UTC().Format(time.RFC3339))
+}
@@ -8,77 +8,177 @@ package main
 import (
 	"context"
 	"errors"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/enginestate"
 	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/secretsmanager"
 	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/stepfunctions"
 	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/token-rotation/lambda"
 	"log"
+	"time"
 )
 
 type RotateTeamTokensHandler struct {
 	// AWS service clients
 	stepFunctions  stepfunctions.StepFunctions
 	lambda         lambda.Lambda
-	secretsManager secretsmanager.SecretsManager
+	secretsManager secretsmanager.RotatingSecretsManager
+	engineState    enginestate.EngineState
+
+	// Secrets of the organizations with their own TFE credentials, by the names of the organizations
+	organizationSecrets map[string]secretsmanager.RotatingSecretsManager
 	// State machines to poll executions
 	provisioningStateMachineArn string
 	updatingStateMachineArn     string
 	terminatingStateMachineArn  string
+	// Teams the standby team rotation alternates between
+	teamId        string
+	standbyTeamId string
+	now           func() time.Time
+	// How often the rotation protocol of Secrets Manager polls the executions it waits for
+	drainPollInterval time.Duration
+	// How often the status of event source mappings is polled while they are enabled or disabled
+	mappingPollInterval time.Duration
+	// How long the rotation waits for executions to finish, without a maximum if zero, and what it does about them then
+	maxDrainTime         time.Duration
+	stuckExecutionPolicy StuckExecutionPolicy
+	// How long new tokens are valid for, tokens do not expire if zero
+	tokenLifetime time.Duration
 }
 
 func (h *RotateTeamTokensHandler) HandleRequest(ctx context.Context, request RotateTeamTokensRequest) (*RotateTeamTokensResponse, error) {
+	// Secrets Manager invokes the function with the step of its rotation protocol, instead of an operation
+	if request.Step != "" {
+		if err := h.HandleRotationStep(ctx, request); err != nil {
+			log.Default().Printf("error handling %s step of the rotation of the TFE credentials: %v", request.Step, err)
+			return nil, err
+		}
+		return &RotateTeamTokensResponse{}, nil
+	}
+
 	switch {
 	case request.Operation == Pausing:
-		eventSourceMappingsUuids, err := h.lambda.GetEventSourceMappingUuidTuples(ctx)
+		// Resume the queues if pausing fails halfway, instead of leaving some of them paused
+		if err := h.PauseQueues(ctx); err != nil {
+			log.Default().Printf("error pausing event source mappings: %v", err)
+			return nil, h.ResumeAfterFailure(ctx, err)
+		}
+		return &RotateTeamTokensResponse{}, nil
+	case request.Operation == Polling && request.SwitchedAt != nil:
+		// The queues are not paused by the standby team rotation, executions started after the switch use the new token
+		executions, err := h.StateMachineExecutionsStartedBefore(ctx, *request.SwitchedAt)
 		if err != nil {
-			log.Default().Printf("error getting event source mappings for function: %v", err)
+			log.Default().Printf("error polling state machine executions: %v", err)
 			return nil, err
 		}
-
-		err = h.UpdateEventSourceMappings(ctx, eventSourceMappingsUuids, false)
+		drainTimedOut, err := h.CheckDrainTime(executions, *request.SwitchedAt)
 		if err != nil {
-			log.Default().Printf("error updating event source mappings for function: %v", err)
 			return nil, err
 		}
-		return &RotateTeamTokensResponse{}, nil
+		return &RotateTeamTokensResponse{StateMachineExecutionCount: len(executions), WaitingOn: OldestExecutions(executions), DrainTimedOut: drainTimedOut}, nil
 	case request.Operation == Polling:
-		count, err := h.StateMachineExecutions(ctx)
+		executions, err := h.StateMachineExecutions(ctx)
 		if err != nil {
 			log.Default().Printf("error polling state machine executions: %v", err)
 			return nil, err
 		}
-		statuses, err := h.lambda.GetEventSourceMappingUuidTuples(ctx)
+		aggregatedStatus, err := h.EventSourceMappingsStatus(ctx)
 		if err != nil {
 			return nil, err
 		}
 
-		aggregatedStatus := lambda.EventSourceDisabling
-		if statuses.ProvisioningLambdaEventSourceMapping.EventSourceMappingStatus == lambda.EventSourceDisabled &&
-			statuses.UpdatingLambdaEventSourceMapping.EventSourceMappingStatus == lambda.EventSourceDisabled &&
-			statuses.TerminatingLambdaEventSourceMapping.EventSourceMappingStatus == lambda.EventSourceDisabled {
-			aggregatedStatus = lambda.EventSourceDisabled
+		// The drain started when the rotation paused the queues
+		progress, err := h.GetRotationProgress(ctx)
+		if err != nil {
+			return nil, err
+		}
+		drainTimedOut := false
+		if progress != nil && progress.QueuesPaused {
+			if drainTimedOut, err = h.CheckDrainTime(executions, progress.StartedAt); err != nil {
+				return nil, err
+			}
 		}
 
-		return &RotateTeamTokensResponse{StateMachineExecutionCount: count, EventSourceMappingStatus: aggregatedStatus}, err
+		return &RotateTeamTokensResponse{StateMachineExecutionCount: len(executions), EventSourceMappingStatus: aggregatedStatus, WaitingOn: OldestExecutions(executions), DrainTimedOut: drainTimedOut}, nil
 	case request.Operation == Rotating:
+		if err := h.RecordRotationPhase(ctx, PhaseRotating); err != nil {
+			return nil, h.ResumeAfterFailure(ctx, err)
+		}
+
 		err := h.RotateToken(ctx)
 		if err != nil {
 			log.Default().Printf("error rotating team token: %v", err)
+			return nil, h.ResumeAfterFailure(ctx, err)
+		}
+
+		if err = h.RecordRotationPhase(ctx, PhaseRotated); err != nil {
+			return nil, err
+		}
+		return &RotateTeamTokensResponse{}, nil
+	case request.Operation == Switching:
+		previousTeamId, err := h.SwitchToStandbyTeam(ctx)
+		if err != nil {
+			log.Default().Printf("error switching to standby team: %v", err)
+			return nil, err
+		}
+		switchedAt := h.now()
+		return &RotateTeamTokensResponse{SwitchedAt: &switchedAt, PreviousTeamId: previousTeamId}, nil
+	case request.Operation == Revoking:
+		err := h.RevokeTeamToken(ctx, request.PreviousTeamId)
+		if err != nil {
+			log.Default().Printf("error revoking team token: %v", err)
 			return nil, err
 		}
 		return &RotateTeamTokensResponse{}, nil
 	case request.Operation == Resuming:
-		eventSourceMappingsUuids, err := h.lambda.GetEventSourceMappingUuidTuples(ctx)
+		if err := h.ResumeQueues(ctx); err != nil {
+			log.Default().Printf("error resuming event source mappings: %v", err)
+			return nil, err
+		}
+		return &RotateTeamTokensResponse{}, nil
+	case request.Operation == Inspecting:
+		response, err := h.InspectRotation(ctx)
 		if err != nil {
-			log.Default().Printf("error resuming event source mappings for function: %v", err)
-			return &RotateTeamTokensResponse{}, err
+			log.Default().Printf("error inspecting token rotation: %v", err)
+			return nil, err
+		}
+		return response, nil
+	case request.Operation == ForceResuming:
+		// Resume the queues of a rotation that failed or is stuck, recording the failure that stopped it, if any
+		if request.Failure != "" {
+			err := h.UpdateRotationProgress(ctx, func(progress *RotationProgress) {
+				progress.Phase = PhaseFailed
+				progress.Error = request.Failure
+			})
+			if err != nil {
+				log.Default().Printf("error recording the failure of the token rotation: %v", err)
+			}
 		}
 
-		err = h.UpdateEventSourceMappings(ctx, eventSourceMappingsUuids, true)
-		if err != nil {
-			log.Default().Printf("error updating event source mappings for function: %v", err)
+		if err := h.ResumeQueues(ctx); err != nil {
+			log.Default().Printf("error force resuming event source mappings: %v", err)
 			return nil, err
 		}
 		return &RotateTeamTokensResponse{}, nil
+	case request.Operation == EnteringMaintenance:
+		response, err := h.EnterMaintenance(ctx, request.Reason)
+		if err != nil {
+			log.Default().Printf("error entering maintenance mode: %v", err)
+			return nil, err
+		}
+		return response, nil
+	case request.Operation == InspectingMaintenance:
+		response, err := h.MaintenanceStatus(ctx)
+		if err != nil {
+			log.Default().Printf("error inspecting maintenance mode: %v", err)
+			return nil, err
+		}
+		return response, nil
+	case request.Operation == ExitingMaintenance:
+		response, err := h.ExitMaintenance(ctx)
+		if err != nil {
+			log.Default().Printf("error exiting maintenance mode: %v", err)
+			return nil, err
+		}
+		return response, nil
 	default:
 		log.Printf("Unknown serviceCatalogOperation: %s\n", request.Operation)
 		return nil, errors.New("unknown operation")
@@ -8,12 +8,19 @@ package main
 import (
 	"context"
 	"encoding/json"
+	sharedsecretsmanager "github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/secretsmanager"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/stepfunctions"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/testutil/enginestate"
 	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/testutil/lambdafunction"
 	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/testutil/secretsmanager"
 	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/testutil/stepfunction"
 	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/testutil/testtfc"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/token-rotation/lambda"
+	"github.com/hashicorp/go-tfe"
 	"github.com/stretchr/testify/assert"
+	"net/http"
 	"testing"
+	"time"
 )
 
 // Check for success during Team Token Rotation
@@ -42,6 +49,7 @@ func TestTokenRotationHandler_SuccessPausing(t *testing.T) {
 	// Create a test instance of the Lambda function
 	testHandler := RotateTeamTokensHandler{
 		secretsManager: mockSecretsManager,
+		engineState:    enginestate.NewMockEngineState(),
 		stepFunctions:  mockStepFunctions,
 		lambda:         mockLambdaFunction,
 	}
@@ -92,6 +100,7 @@ func TestTokenRotationHandler_SuccessPolling(t *testing.T) {
 	// Create a test instance of the Lambda function
 	testHandler := RotateTeamTokensHandler{
 		secretsManager:              mockSecretsManager,
+		engineState:                 enginestate.NewMockEngineState(),
 		stepFunctions:               mockStepFunctions,
 		lambda:                      mockLambdaFunction,
 		provisioningStateMachineArn: "arn:provision-thing-123",
@@ -141,11 +150,13 @@ func TestTokenRotationHandler_SuccessRotating(t *testing.T) {
 	// Create a test instance of the Lambda function
 	testHandler := RotateTeamTokensHandler{
 		secretsManager:              mockSecretsManager,
+		engineState:                 enginestate.NewMockEngineState(),
 		stepFunctions:               mockStepFunctions,
 		lambda:                      mockLambdaFunction,
 		provisioningStateMachineArn: "arn:provision-thing-123",
 		updatingStateMachineArn:     "arn:update-thing-123",
 		terminatingStateMachineArn:  "arn:terminate-thing-123",
+		tokenLifetime:               37 * 24 * time.Hour,
 	}
 
 	// Create test request
@@ -166,8 +177,61 @@ func TestTokenRotationHandler_SuccessRotating(t *testing.T) {
 		t.Error(err)
 	}
 
-	// Verify that the Team Token has been rotated
+	// Verify that the Team Token has been rotated, and is no longer pending
 	assert.Equal(t, "newsupers3cret", mockSecretsManager.Token)
+	assert.Empty(t, mockSecretsManager.PendingVersionId)
+
+	// Verify the new Team Token expires after the token lifetime
+	assert.WithinDuration(t, time.Now().Add(37*24*time.Hour), tfcServer.TeamTokenExpiries["team-roLYatraNNailuJ2"], time.Minute)
+}
+
+func TestTokenRotationHandler_SuccessRotatingOrganizationTokens(t *testing.T) {
+	// Create mock TFC instances, for the default organization and for an organization with its own credentials
+	tfcServer := testtfc.NewMockTFC()
+	defer tfcServer.Stop()
+	organizationTfcServer := testtfc.NewMockTFC()
+	defer organizationTfcServer.Stop()
+
+	mockSecretsManager := &secretsmanager.MockSecretsManager{
+		Hostname: tfcServer.Address,
+		TeamId:   "team-roLYatraNNailuJ2",
+		Token:    "supers3cret",
+	}
+	mockOrganizationSecretsManager := &secretsmanager.MockSecretsManager{
+		Hostname: organizationTfcServer.Address,
+		TeamId:   "team-0rgAn1zat10nTeam",
+		Token:    "supers3cret",
+	}
+
+	// Create a test instance of the Lambda function
+	testHandler := RotateTeamTokensHandler{
+		secretsManager: mockSecretsManager,
+		organizationSecrets: map[string]sharedsecretsmanager.RotatingSecretsManager{
+			"other-org": mockOrganizationSecretsManager,
+		},
+		engineState:                 enginestate.NewMockEngineState(),
+		stepFunctions:               &stepfunction.MockStepFunctionsWithSuccessfulResponse{},
+		lambda:                      &lambdafunction.MockLambdaFunction{},
+		provisioningStateMachineArn: "arn:provision-thing-123",
+		updatingStateMachineArn:     "arn:update-thing-123",
+		terminatingStateMachineArn:  "arn:terminate-thing-123",
+		tokenLifetime:               37 * 24 * time.Hour,
+	}
+
+	// Send the test request
+	_, err := testHandler.HandleRequest(context.Background(), RotateTeamTokensRequest{Operation: Rotating})
+	if err != nil {
+		t.Error(err)
+	}
+
+	// Verify that the Team Tokens of both organizations have been rotated
+	assert.Equal(t, "newsupers3cret", mockSecretsManager.Token)
+	assert.Equal(t, "newsupers3cret", mockOrganizationSecretsManager.Token)
+	assert.Empty(t, mockOrganizationSecretsManager.PendingVersionId)
+
+	// Verify the Team Token of the organization was created in its own TFC instance
+	assert.Contains(t, organizationTfcServer.TeamTokenExpiries, "team-0rgAn1zat10nTeam")
+	assert.NotContains(t, tfcServer.TeamTokenExpiries, "team-0rgAn1zat10nTeam")
 }
 
 func TestTokenRotationHandler_SuccessResuming(t *testing.T) {
@@ -194,6 +258,7 @@ func TestTokenRotationHandler_SuccessResuming(t *testing.T) {
 	// Create a test instance of the Lambda function
 	testHandler := RotateTeamTokensHandler{
 		secretsManager: mockSecretsManager,
+		engineState:    enginestate.NewMockEngineState(),
 		stepFunctions:  mockStepFunctions,
 		lambda:         mockLambdaFunction,
 	}
@@ -224,6 +289,173 @@ func TestTokenRotationHandler_SuccessResuming(t *testing.T) {
 	assert.Equal(t, true, mockLambdaFunction.Terminating)
 }
 
+func TestTokenRotationHandler_SuccessStandbyTeamRotation(t *testing.T) {
+	// Create mock TFC instance
+	tfcServer := testtfc.NewMockTFC()
+	defer tfcServer.Stop()
+
+	mockSecretsManager := &secretsmanager.MockSecretsManager{
+		Hostname: tfcServer.Address,
+		TeamId:   "team-4123nlol",
+		Token:    "supers3cret",
+	}
+	tfcServer.TeamTokens["team-4123nlol"] = &tfe.TeamToken{Token: "supers3cret"}
+
+	// Create a test instance of the Lambda function
+	switchedAt := time.Date(2023, 5, 4, 3, 2, 1, 0, time.UTC)
+	testHandler := RotateTeamTokensHandler{
+		secretsManager:              mockSecretsManager,
+		engineState:                 enginestate.NewMockEngineState(),
+		stepFunctions:               &stepfunction.MockStepFunctionsWithSuccessfulResponse{},
+		lambda:                      &lambdafunction.MockLambdaFunction{},
+		provisioningStateMachineArn: "arn:provision-thing-123",
+		updatingStateMachineArn:     "arn:update-thing-123",
+		terminatingStateMachineArn:  "arn:terminate-thing-123",
+		teamId:                      "team-4123nlol",
+		standbyTeamId:               "team-standby",
+		now:                         func() time.Time { return switchedAt },
+	}
+
+	// Switch to the standby team
+	response, err := testHandler.HandleRequest(context.Background(), RotateTeamTokensRequest{Operation: Switching})
+	assert.NoError(t, err)
+	assert.Equal(t, "team-4123nlol", response.PreviousTeamId)
+	assert.Equal(t, switchedAt, *response.SwitchedAt)
+
+	// Verify the secret holds the token of the standby team, while the token of the previous team still exists
+	assert.Equal(t, "team-standby", mockSecretsManager.TeamId)
+	assert.Equal(t, "newsupers3cret", mockSecretsManager.Token)
+	assert.NotNil(t, tfcServer.TeamTokens["team-4123nlol"])
+
+	// Verify only the executions started before the switch are polled
+	response, err = testHandler.HandleRequest(context.Background(), RotateTeamTokensRequest{Operation: Polling, SwitchedAt: &switchedAt})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, response.StateMachineExecutionCount)
+
+	// Revoke the token of the previous team
+	_, err = testHandler.HandleRequest(context.Background(), RotateTeamTokensRequest{Operation: Revoking, PreviousTeamId: "team-4123nlol"})
+	assert.NoError(t, err)
+	assert.Nil(t, tfcServer.TeamTokens["team-4123nlol"])
+	assert.NotNil(t, tfcServer.TeamTokens["team-standby"])
+
+	// Verify the next rotation switches back to the original team
+	response, err = testHandler.HandleRequest(context.Background(), RotateTeamTokensRequest{Operation: Switching})
+	assert.NoError(t, err)
+	assert.Equal(t, "team-standby", response.PreviousTeamId)
+	assert.Equal(t, "team-4123nlol", mockSecretsManager.TeamId)
+}
+
+func TestTokenRotationHandler_SuccessSecretRotation(t *testing.T) {
+	// Create mock TFC instance
+	tfcServer := testtfc.NewMockTFC()
+	defer tfcServer.Stop()
+
+	mockSecretsManager := &secretsmanager.MockSecretsManager{
+		Hostname: tfcServer.Address,
+		TeamId:   "team-roLYatraNNailuJ2",
+		Token:    "supers3cret",
+	}
+
+	// Create mock Lambda function
+	mockLambdaFunction := &lambdafunction.MockLambdaFunction{
+		Provisioning: true,
+		Updating:     true,
+		Terminating:  true,
+	}
+
+	// Create a test instance of the Lambda function, without any running executions
+	testHandler := RotateTeamTokensHandler{
+		secretsManager:              mockSecretsManager,
+		engineState:                 enginestate.NewMockEngineState(),
+		stepFunctions:               &stepfunction.MockStepFunctionsWithSuccessfulResponse{RunningExecutions: map[string][]stepfunctions.RunningExecution{}},
+		lambda:                      mockLambdaFunction,
+		provisioningStateMachineArn: "arn:provision-thing-123",
+		updatingStateMachineArn:     "arn:update-thing-123",
+		terminatingStateMachineArn:  "arn:terminate-thing-123",
+		drainPollInterval:           time.Millisecond,
+	}
+
+	rotationRequest := func(step RotationStep) RotateTeamTokensRequest {
+		return RotateTeamTokensRequest{
+			Step:               step,
+			SecretId:           "arn:aws:secretsmanager:us-west-2:123456789042:secret:terraform-cloud-credentials",
+			ClientRequestToken: "rotation-version-1",
+		}
+	}
+
+	// Verify the new token is staged as the pending version while the queues are paused
+	_, err := testHandler.HandleRequest(context.Background(), rotationRequest(CreateSecret))
+	assert.NoError(t, err)
+	assert.Equal(t, "rotation-version-1", mockSecretsManager.PendingVersionId)
+	assert.Equal(t, "newsupers3cret", mockSecretsManager.PendingToken)
+	assert.Equal(t, "supers3cret", mockSecretsManager.Token)
+	assert.Equal(t, false, mockLambdaFunction.Provisioning)
+
+	// Verify retries of the createSecret step do not create another token
+	delete(tfcServer.TeamTokens, "team-roLYatraNNailuJ2")
+	_, err = testHandler.HandleRequest(context.Background(), rotationRequest(CreateSecret))
+	assert.NoError(t, err)
+	assert.Nil(t, tfcServer.TeamTokens["team-roLYatraNNailuJ2"])
+
+	for _, step := range []RotationStep{SetSecret, TestSecret, FinishSecret} {
+		_, err = testHandler.HandleRequest(context.Background(), rotationRequest(step))
+		assert.NoError(t, err)
+	}
+
+	// Verify the new token was made current, and the queues were resumed
+	assert.Equal(t, "newsupers3cret", mockSecretsManager.Token)
+	assert.Empty(t, mockSecretsManager.PendingVersionId)
+	assert.Equal(t, true, mockLambdaFunction.Provisioning)
+	assert.Equal(t, true, mockLambdaFunction.Updating)
+	assert.Equal(t, true, mockLambdaFunction.Terminating)
+}
+
+func TestTokenRotationHandler_SuccessPollingProceedsWithStuckExecutions(t *testing.T) {
+	// Create mock TFC instance
+	tfcServer := testtfc.NewMockTFC()
+	defer tfcServer.Stop()
+
+	mockSecretsManager := &secretsmanager.MockSecretsManager{
+		Hostname: tfcServer.Address,
+		TeamId:   "team-4123nlol",
+		Token:    "supers3cret",
+	}
+
+	// Create mock Lambda function
+	mockLambdaFunction := &lambdafunction.MockLambdaFunction{
+		Provisioning: true,
+		Updating:     true,
+		Terminating:  true,
+	}
+
+	// Create a test instance of the Lambda function, that stops waiting for executions right after pausing the queues
+	testHandler := RotateTeamTokensHandler{
+		secretsManager:              mockSecretsManager,
+		engineState:                 enginestate.NewMockEngineState(),
+		stepFunctions:               &stepfunction.MockStepFunctionsWithSuccessfulResponse{},
+		lambda:                      mockLambdaFunction,
+		provisioningStateMachineArn: "arn:provision-thing-123",
+		updatingStateMachineArn:     "arn:update-thing-123",
+		terminatingStateMachineArn:  "arn:terminate-thing-123",
+		maxDrainTime:                time.Nanosecond,
+		stuckExecutionPolicy:        ProceedWithRotation,
+	}
+
+	_, err := testHandler.HandleRequest(context.Background(), RotateTeamTokensRequest{Operation: Pausing})
+	assert.NoError(t, err)
+
+	// Send the test request
+	response, err := testHandler.HandleRequest(context.Background(), RotateTeamTokensRequest{Operation: Polling})
+	assert.NoError(t, err)
+
+	// Verify the rotation proceeds, and reports the oldest executions it stopped waiting on
+	assert.Equal(t, true, response.DrainTimedOut)
+	assert.Equal(t, 23, response.StateMachineExecutionCount)
+	assert.Len(t, response.WaitingOn, maxReportedExecutions)
+	assert.Equal(t, "arn:provision-thing-123:execution-0", response.WaitingOn[0].ExecutionArn)
+	assert.Equal(t, stepfunction.ExecutionsStartedLongAgo, response.WaitingOn[0].StartedAt)
+}
+
 // Check for errors during Team Token rotation
 
 func TestTokenRotationHandler_ErrorPausing(t *testing.T) {
@@ -250,6 +482,7 @@ func TestTokenRotationHandler_ErrorPausing(t *testing.T) {
 	// Create a test instance of the Lambda function
 	testHandler := RotateTeamTokensHandler{
 		secretsManager: mockSecretsManager,
+		engineState:    enginestate.NewMockEngineState(),
 		stepFunctions:  mockStepFunctions,
 		lambda:         mockLambdaFunction,
 	}
@@ -296,6 +529,7 @@ func TestTokenRotationHandler_ErrorPolling(t *testing.T) {
 	// Create a test instance of the Lambda function
 	testHandler := RotateTeamTokensHandler{
 		secretsManager:              mockSecretsManager,
+		engineState:                 enginestate.NewMockEngineState(),
 		stepFunctions:               mockStepFunctions,
 		lambda:                      mockLambdaFunction,
 		provisioningStateMachineArn: "",
@@ -340,6 +574,7 @@ func TestTokenRotationHandler_ErrorRotatingToken(t *testing.T) {
 	// Create a test instance of the Lambda function
 	testHandler := RotateTeamTokensHandler{
 		secretsManager: mockSecretsManager,
+		engineState:    enginestate.NewMockEngineState(),
 		stepFunctions:  mockStepFunctions,
 		lambda:         mockLambdaFunction,
 	}
@@ -355,6 +590,69 @@ func TestTokenRotationHandler_ErrorRotatingToken(t *testing.T) {
 	assert.Error(t, err, "function name or uuid not found")
 }
 
+func TestTokenRotationHandler_ErrorValidatingToken(t *testing.T) {
+	// Create mock TFC instance
+	tfcServer := testtfc.NewMockTFC()
+	defer tfcServer.Stop()
+
+	// Make TFC reject the new token, until it is accepted again
+	rejectToken := true
+	tfcServer.MockRequest(func(r *http.Request) bool {
+		return rejectToken && r.Method == http.MethodGet && r.URL.Path == "/api/v2/teams/team-roLYatraNNailuJ2"
+	}, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(401)
+		w.Write([]byte(`{"errors":[{"status":"401","title":"unauthorized"}]}`))
+	})
+
+	// Count the tokens created in TFC, without mocking the requests
+	tokenCreations := 0
+	tfcServer.MockRequest(func(r *http.Request) bool {
+		if r.Method == http.MethodPost && r.URL.Path == "/api/v2/teams/team-roLYatraNNailuJ2/authentication-token" {
+			tokenCreations++
+		}
+		return false
+	}, nil)
+
+	mockSecretsManager := &secretsmanager.MockSecretsManager{
+		Hostname: tfcServer.Address,
+		TeamId:   "team-roLYatraNNailuJ2",
+		Token:    "supers3cret",
+	}
+
+	// Create a test instance of the Lambda function
+	testHandler := RotateTeamTokensHandler{
+		secretsManager: mockSecretsManager,
+		engineState:    enginestate.NewMockEngineState(),
+		stepFunctions:  &stepfunction.MockStepFunctionsWithSuccessfulResponse{},
+		lambda:         &lambdafunction.MockLambdaFunction{},
+	}
+
+	// Send the test request
+	_, err := testHandler.HandleRequest(context.Background(), RotateTeamTokensRequest{Operation: Rotating})
+	assert.ErrorContains(t, err, "the new token of team team-roLYatraNNailuJ2 failed validation")
+	assert.ErrorContains(t, err, "creating it revoked the previous token of team team-roLYatraNNailuJ2, so the AWSCURRENT TFE credentials no longer work")
+
+	// Verify the secret was not switched to the new token, and the new token was kept as the pending version
+	assert.Equal(t, "supers3cret", mockSecretsManager.Token)
+	assert.Equal(t, "newsupers3cret", mockSecretsManager.PendingToken)
+	pendingVersionId, err := testHandler.PendingVersion(context.Background(), "team-roLYatraNNailuJ2")
+	assert.NoError(t, err)
+	assert.Equal(t, mockSecretsManager.PendingVersionId, pendingVersionId)
+
+	// Retry the rotation once TFC accepts the new token
+	rejectToken = false
+	_, err = testHandler.HandleRequest(context.Background(), RotateTeamTokensRequest{Operation: Rotating})
+	assert.NoError(t, err)
+
+	// Verify the pending token was promoted, instead of being revoked by yet another token
+	assert.Equal(t, 1, tokenCreations)
+	assert.Equal(t, "newsupers3cret", mockSecretsManager.Token)
+	assert.Empty(t, mockSecretsManager.PendingVersionId)
+	pendingVersionId, err = testHandler.PendingVersion(context.Background(), "team-roLYatraNNailuJ2")
+	assert.NoError(t, err)
+	assert.Empty(t, pendingVersionId)
+}
+
 func TestTokenRotationHandler_ErrorResuming(t *testing.T) {
 	// Create mock TFC instance
 	tfcServer := testtfc.NewMockTFC()
@@ -379,6 +677,7 @@ func TestTokenRotationHandler_ErrorResuming(t *testing.T) {
 	// Create a test instance of the Lambda function
 	testHandler := RotateTeamTokensHandler{
 		secretsManager: mockSecretsManager,
+		engineState:    enginestate.NewMockEngineState(),
 		stepFunctions:  mockStepFunctions,
 		lambda:         mockLambdaFunction,
 	}
@@ -404,3 +703,372 @@ func TestTokenRotationHandler_ErrorResuming(t *testing.T) {
 	assert.Equal(t, true, mockLambdaFunction.Updating)
 	assert.Equal(t, true, mockLambdaFunction.Terminating)
 }
+
+func TestTokenRotationHandler_ErrorRevokingCurrentTeam(t *testing.T) {
+	// Create mock TFC instance
+	tfcServer := testtfc.NewMockTFC()
+	defer tfcServer.Stop()
+
+	mockSecretsManager := &secretsmanager.MockSecretsManager{
+		Hostname: tfcServer.Address,
+		TeamId:   "team-4123nlol",
+		Token:    "supers3cret",
+	}
+	tfcServer.TeamTokens["team-4123nlol"] = &tfe.TeamToken{Token: "supers3cret"}
+
+	// Create a test instance of the Lambda function
+	testHandler := RotateTeamTokensHandler{
+		secretsManager: mockSecretsManager,
+		engineState:    enginestate.NewMockEngineState(),
+		stepFunctions:  &stepfunction.MockStepFunctionsWithSuccessfulResponse{},
+		lambda:         &lambdafunction.MockLambdaFunction{},
+		teamId:         "team-4123nlol",
+		standbyTeamId:  "team-standby",
+	}
+
+	// Send the test request
+	_, err := testHandler.HandleRequest(context.Background(), RotateTeamTokensRequest{Operation: Revoking, PreviousTeamId: "team-4123nlol"})
+
+	// Verify the token the secret holds was not revoked
+	assert.EqualError(t, err, "refusing to revoke the token of team \"team-4123nlol\", the TFE credentials use it")
+	assert.NotNil(t, tfcServer.TeamTokens["team-4123nlol"])
+}
+
+func TestTokenRotationHandler_ErrorSecretRotationDrainTimeout(t *testing.T) {
+	// Create mock TFC instance
+	tfcServer := testtfc.NewMockTFC()
+	defer tfcServer.Stop()
+
+	mockSecretsManager := &secretsmanager.MockSecretsManager{
+		Hostname: tfcServer.Address,
+		TeamId:   "team-roLYatraNNailuJ2",
+		Token:    "supers3cret",
+	}
+
+	// Create mock Lambda function
+	mockLambdaFunction := &lambdafunction.MockLambdaFunction{
+		Provisioning: true,
+		Updating:     true,
+		Terminating:  true,
+	}
+
+	// Create a test instance of the Lambda function, with executions that keep running
+	testHandler := RotateTeamTokensHandler{
+		secretsManager:              mockSecretsManager,
+		engineState:                 enginestate.NewMockEngineState(),
+		stepFunctions:               &stepfunction.MockStepFunctionsWithSuccessfulResponse{},
+		lambda:                      mockLambdaFunction,
+		provisioningStateMachineArn: "arn:provision-thing-123",
+		updatingStateMachineArn:     "arn:update-thing-123",
+		terminatingStateMachineArn:  "arn:terminate-thing-123",
+		drainPollInterval:           10 * time.Millisecond,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	// Send the test request, which stops draining before the invocation times out
+	_, err := testHandler.HandleRequest(ctx, RotateTeamTokensRequest{Step: CreateSecret, ClientRequestToken: "rotation-version-1"})
+	assert.NoError(t, err)
+
+	// Verify no token was created, and the current token was staged instead
+	assert.Empty(t, tfcServer.TeamTokens)
+	assert.Equal(t, "rotation-version-1", mockSecretsManager.PendingVersionId)
+	assert.Equal(t, "supers3cret", mockSecretsManager.PendingToken)
+	assert.Equal(t, false, mockLambdaFunction.Provisioning)
+
+	// Send the finishSecret step, in an invocation of its own
+	_, err = testHandler.HandleRequest(context.Background(), RotateTeamTokensRequest{Step: FinishSecret, ClientRequestToken: "rotation-version-1"})
+	assert.EqualError(t, err, "state machine executions did not finish within the createSecret step, the token was not rotated")
+
+	// Verify the pending version was discarded, and the queues were resumed
+	assert.Empty(t, mockSecretsManager.PendingVersionId)
+	assert.Equal(t, "supers3cret", mockSecretsManager.Token)
+	assert.Equal(t, true, mockLambdaFunction.Provisioning)
+	assert.Equal(t, true, mockLambdaFunction.Updating)
+	assert.Equal(t, true, mockLambdaFunction.Terminating)
+
+	progress, err := testHandler.GetRotationProgress(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, PhaseFailed, progress.Phase)
+	assert.False(t, progress.QueuesPaused)
+}
+
+func TestTokenRotationHandler_ErrorPollingMaxDrainTime(t *testing.T) {
+	// Create mock TFC instance
+	tfcServer := testtfc.NewMockTFC()
+	defer tfcServer.Stop()
+
+	mockSecretsManager := &secretsmanager.MockSecretsManager{
+		Hostname: tfcServer.Address,
+		TeamId:   "team-4123nlol",
+		Token:    "supers3cret",
+	}
+
+	// Create mock Lambda function
+	mockLambdaFunction := &lambdafunction.MockLambdaFunction{
+		Provisioning: true,
+		Updating:     true,
+		Terminating:  true,
+	}
+
+	// Create a test instance of the Lambda function, that stops waiting for executions right after pausing the queues
+	testHandler := RotateTeamTokensHandler{
+		secretsManager:              mockSecretsManager,
+		engineState:                 enginestate.NewMockEngineState(),
+		stepFunctions:               &stepfunction.MockStepFunctionsWithSuccessfulResponse{},
+		lambda:                      mockLambdaFunction,
+		provisioningStateMachineArn: "arn:provision-thing-123",
+		updatingStateMachineArn:     "arn:update-thing-123",
+		terminatingStateMachineArn:  "arn:terminate-thing-123",
+		maxDrainTime:                time.Nanosecond,
+		stuckExecutionPolicy:        AbortRotation,
+	}
+
+	_, err := testHandler.HandleRequest(context.Background(), RotateTeamTokensRequest{Operation: Pausing})
+	assert.NoError(t, err)
+
+	// Send the test request
+	_, err = testHandler.HandleRequest(context.Background(), RotateTeamTokensRequest{Operation: Polling})
+
+	// Verify the rotation is aborted, naming the oldest execution it waited on
+	assert.EqualError(t, err, "23 state machine executions did not finish in time to rotate the token, the oldest is arn:provision-thing-123:execution-0, started at 2023-05-01T00:00:00Z")
+}
+
+func TestTokenRotationHandler_ErrorRotatingResumesQueues(t *testing.T) {
+	// Create mock TFC instance, that fails to create team tokens
+	tfcServer := testtfc.NewMockTFC()
+	defer tfcServer.Stop()
+	tfcServer.MockRequest(func(r *http.Request) bool {
+		return r.Method == http.MethodPost && r.URL.Path == "/api/v2/teams/team-roLYatraNNailuJ2/authentication-token"
+	}, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(422)
+		w.Write([]byte(`{"errors":[{"status":"422","title":"invalid attribute"}]}`))
+	})
+
+	mockSecretsManager := &secretsmanager.MockSecretsManager{
+		Hostname: tfcServer.Address,
+		TeamId:   "team-roLYatraNNailuJ2",
+		Token:    "supers3cret",
+	}
+
+	// Create mock Lambda function, whose event source mappings take a few reads to change state
+	mockLambdaFunction := &lambdafunction.MockLambdaFunction{
+		Provisioning: true,
+		Updating:     true,
+		Terminating:  true,
+	}
+
+	// Create a test instance of the Lambda function
+	testHandler := RotateTeamTokensHandler{
+		secretsManager:              mockSecretsManager,
+		engineState:                 enginestate.NewMockEngineState(),
+		stepFunctions:               &stepfunction.MockStepFunctionsWithSuccessfulResponse{},
+		lambda:                      mockLambdaFunction,
+		provisioningStateMachineArn: "arn:provision-thing-123",
+		updatingStateMachineArn:     "arn:update-thing-123",
+		terminatingStateMachineArn:  "arn:terminate-thing-123",
+	}
+
+	// Verify pausing waits for the event source mappings to be disabled
+	mockLambdaFunction.PendingTransitions = 4
+	_, err := testHandler.HandleRequest(context.Background(), RotateTeamTokensRequest{Operation: Pausing})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, mockLambdaFunction.PendingTransitions)
+	assert.Equal(t, false, mockLambdaFunction.Provisioning)
+
+	// Send the test request
+	_, err = testHandler.HandleRequest(context.Background(), RotateTeamTokensRequest{Operation: Rotating})
+	assert.Error(t, err)
+
+	// Verify the queues were resumed, and the failure was recorded
+	assert.Equal(t, true, mockLambdaFunction.Provisioning)
+	assert.Equal(t, true, mockLambdaFunction.Updating)
+	assert.Equal(t, true, mockLambdaFunction.Terminating)
+
+	progress, err := testHandler.GetRotationProgress(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, PhaseFailed, progress.Phase)
+	assert.Equal(t, false, progress.QueuesPaused)
+	assert.NotEmpty(t, progress.Error)
+	assert.Equal(t, "supers3cret", mockSecretsManager.Token)
+}
+
+func TestTokenRotationHandler_SuccessInspectingAndForceResuming(t *testing.T) {
+	mockSecretsManager := &secretsmanager.MockSecretsManager{
+		TeamId: "team-roLYatraNNailuJ2",
+		Token:  "supers3cret",
+	}
+
+	// Create mock Lambda function
+	mockLambdaFunction := &lambdafunction.MockLambdaFunction{
+		Provisioning: true,
+		Updating:     true,
+		Terminating:  true,
+	}
+
+	// Create a test instance of the Lambda function
+	testHandler := RotateTeamTokensHandler{
+		secretsManager:              mockSecretsManager,
+		engineState:                 enginestate.NewMockEngineState(),
+		stepFunctions:               &stepfunction.MockStepFunctionsWithSuccessfulResponse{},
+		lambda:                      mockLambdaFunction,
+		provisioningStateMachineArn: "arn:provision-thing-123",
+		updatingStateMachineArn:     "arn:update-thing-123",
+		terminatingStateMachineArn:  "arn:terminate-thing-123",
+	}
+
+	// Pause the queues, like a rotation that got stuck afterwards
+	_, err := testHandler.HandleRequest(context.Background(), RotateTeamTokensRequest{Operation: Pausing})
+	assert.NoError(t, err)
+
+	// Verify the stuck rotation can be inspected
+	response, err := testHandler.HandleRequest(context.Background(), RotateTeamTokensRequest{Operation: Inspecting})
+	assert.NoError(t, err)
+	assert.Equal(t, PhasePaused, response.RotationProgress.Phase)
+	assert.Equal(t, true, response.RotationProgress.QueuesPaused)
+	assert.Equal(t, 23, response.StateMachineExecutionCount)
+	assert.Equal(t, map[string]lambda.EventSourceMappingStatus{
+		"provisioningFunctionName": lambda.EventSourceDisabled,
+		"updatingFunctionName":     lambda.EventSourceDisabled,
+		"terminatingFunctionName":  lambda.EventSourceDisabled,
+	}, response.EventSourceMappingStatuses)
+
+	// Send the test request
+	_, err = testHandler.HandleRequest(context.Background(), RotateTeamTokensRequest{Operation: ForceResuming, Failure: "States.Timeout"})
+	assert.NoError(t, err)
+
+	// Verify the queues were resumed, and the failure was recorded
+	assert.Equal(t, true, mockLambdaFunction.Provisioning)
+	assert.Equal(t, true, mockLambdaFunction.Updating)
+	assert.Equal(t, true, mockLambdaFunction.Terminating)
+
+	response, err = testHandler.HandleRequest(context.Background(), RotateTeamTokensRequest{Operation: Inspecting})
+	assert.NoError(t, err)
+	assert.Equal(t, PhaseFailed, response.RotationProgress.Phase)
+	assert.Equal(t, false, response.RotationProgress.QueuesPaused)
+	assert.Equal(t, "States.Timeout", response.RotationProgress.Error)
+}
+
+func TestTokenRotationHandler_SuccessMaintenanceMode(t *testing.T) {
+	// Create mock TFC instance
+	tfcServer := testtfc.NewMockTFC()
+	defer tfcServer.Stop()
+
+	mockSecretsManager := &secretsmanager.MockSecretsManager{
+		Hostname: tfcServer.Address,
+		TeamId:   "team-4123nlol",
+		Token:    "supers3cret",
+	}
+
+	// Create mock StepFunctions facade, with executions that are still running
+	mockStepFunctions := &stepfunction.MockStepFunctionsWithSuccessfulResponse{}
+
+	// Create mock Lambda function
+	mockLambdaFunction := &lambdafunction.MockLambdaFunction{
+		Provisioning: true,
+		Updating:     true,
+		Terminating:  true,
+	}
+
+	// Create a test instance of the Lambda function
+	testHandler := RotateTeamTokensHandler{
+		secretsManager:              mockSecretsManager,
+		engineState:                 enginestate.NewMockEngineState(),
+		stepFunctions:               mockStepFunctions,
+		lambda:                      mockLambdaFunction,
+		provisioningStateMachineArn: "arn:provision-thing-123",
+		updatingStateMachineArn:     "arn:update-thing-123",
+		terminatingStateMachineArn:  "arn:terminate-thing-123",
+	}
+
+	// Verify entering maintenance mode pauses the queues, and reports the executions that are still running
+	response, err := testHandler.HandleRequest(context.Background(), RotateTeamTokensRequest{Operation: EnteringMaintenance, Reason: "upgrading TFE"})
+	assert.NoError(t, err)
+	assert.Equal(t, false, mockLambdaFunction.Provisioning)
+	assert.Equal(t, false, mockLambdaFunction.Updating)
+	assert.Equal(t, false, mockLambdaFunction.Terminating)
+	assert.Equal(t, MaintenanceOwner, response.Maintenance.Lock.Owner)
+	assert.Equal(t, "upgrading TFE", response.Maintenance.Lock.Reason)
+	assert.Equal(t, false, response.Maintenance.Drained)
+	assert.Equal(t, 23, response.StateMachineExecutionCount)
+
+	// Verify the engine is reported as drained once the executions have finished
+	mockStepFunctions.RunningExecutions = map[string][]stepfunctions.RunningExecution{}
+	response, err = testHandler.HandleRequest(context.Background(), RotateTeamTokensRequest{Operation: InspectingMaintenance})
+	assert.NoError(t, err)
+	assert.Equal(t, true, response.Maintenance.Drained)
+	assert.Equal(t, lambda.EventSourceDisabled, response.EventSourceMappingStatuses["provisioningFunctionName"])
+
+	// Verify exiting maintenance mode resumes the queues
+	response, err = testHandler.HandleRequest(context.Background(), RotateTeamTokensRequest{Operation: ExitingMaintenance})
+	assert.NoError(t, err)
+	assert.Equal(t, true, mockLambdaFunction.Provisioning)
+	assert.Equal(t, true, mockLambdaFunction.Updating)
+	assert.Equal(t, true, mockLambdaFunction.Terminating)
+	assert.Nil(t, response.Maintenance.Lock)
+	assert.Equal(t, false, response.Maintenance.Drained)
+
+	// Verify exiting maintenance mode again fails
+	_, err = testHandler.HandleRequest(context.Background(), RotateTeamTokensRequest{Operation: ExitingMaintenance})
+	assert.EqualError(t, err, "the engine is not in maintenance mode")
+}
+
+func TestTokenRotationHandler_ErrorRotatingDuringMaintenance(t *testing.T) {
+	// Create mock TFC instance
+	tfcServer := testtfc.NewMockTFC()
+	defer tfcServer.Stop()
+
+	mockSecretsManager := &secretsmanager.MockSecretsManager{
+		Hostname: tfcServer.Address,
+		TeamId:   "team-4123nlol",
+		Token:    "supers3cret",
+	}
+
+	// Create mock Lambda function
+	mockLambdaFunction := &lambdafunction.MockLambdaFunction{
+		Provisioning: true,
+		Updating:     true,
+		Terminating:  true,
+	}
+
+	// Create a test instance of the Lambda function
+	testHandler := RotateTeamTokensHandler{
+		secretsManager:              mockSecretsManager,
+		engineState:                 enginestate.NewMockEngineState(),
+		stepFunctions:               &stepfunction.MockStepFunctionsWithSuccessfulResponse{},
+		lambda:                      mockLambdaFunction,
+		provisioningStateMachineArn: "arn:provision-thing-123",
+		updatingStateMachineArn:     "arn:update-thing-123",
+		terminatingStateMachineArn:  "arn:terminate-thing-123",
+		teamId:                      "team-4123nlol",
+		standbyTeamId:               "team-standby",
+	}
+
+	_, err := testHandler.HandleRequest(context.Background(), RotateTeamTokensRequest{Operation: EnteringMaintenance})
+	assert.NoError(t, err)
+
+	// Verify neither rotation mode can start, and the queues stay paused after the failed rotation is resumed
+	_, err = testHandler.HandleRequest(context.Background(), RotateTeamTokensRequest{Operation: Pausing})
+	assert.ErrorContains(t, err, "cannot rotate the team token: the SQS queues were paused for maintenance at ")
+	_, err = testHandler.HandleRequest(context.Background(), RotateTeamTokensRequest{Operation: Switching})
+	assert.ErrorContains(t, err, "the engine is in maintenance mode since ")
+	_, err = testHandler.HandleRequest(context.Background(), RotateTeamTokensRequest{Operation: ForceResuming})
+	assert.NoError(t, err)
+	assert.Equal(t, false, mockLambdaFunction.Provisioning)
+	assert.Equal(t, "supers3cret", mockSecretsManager.Token)
+
+	// Verify maintenance mode cannot be entered while a rotation has paused the queues
+	_, err = testHandler.HandleRequest(context.Background(), RotateTeamTokensRequest{Operation: ExitingMaintenance})
+	assert.NoError(t, err)
+	_, err = testHandler.HandleRequest(context.Background(), RotateTeamTokensRequest{Operation: Pausing})
+	assert.NoError(t, err)
+	_, err = testHandler.HandleRequest(context.Background(), RotateTeamTokensRequest{Operation: EnteringMaintenance})
+	assert.ErrorContains(t, err, "cannot enter maintenance mode: the SQS queues were paused for a token rotation at ")
+
+	// Verify maintenance mode can be entered once the rotation resumed the queues
+	_, err = testHandler.HandleRequest(context.Background(), RotateTeamTokensRequest{Operation: Resuming})
+	assert.NoError(t, err)
+	_, err = testHandler.HandleRequest(context.Background(), RotateTeamTokensRequest{Operation: EnteringMaintenance})
+	assert.NoError(t, err)
+}
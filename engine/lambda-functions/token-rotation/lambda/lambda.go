@@ -17,6 +17,7 @@ import (
 
 type Lambda interface {
 	GetEventSourceMappingUuidTuples(ctx context.Context) (*FunctionNameUuidTuples, error)
+	GetEventSourceMappingStatus(ctx context.Context, uuid string) (EventSourceMappingStatus, error)
 	EnableEventSourceMapping(ctx context.Context, functionName string, uuid string) error
 	DisableEventSourceMapping(ctx context.Context, functionName string, uuid string) error
 }
@@ -122,6 +123,21 @@ func (l *L) GetEventSourceMappingUuidTuples(ctx context.Context) (*FunctionNameU
 	return functionNameUuidTuples, nil
 }
 
+func (l *L) GetEventSourceMappingStatus(ctx context.Context, uuid string) (EventSourceMappingStatus, error) {
+	eventSourceMapping, err := l.Client.GetEventSourceMapping(ctx, &lambda.GetEventSourceMappingInput{
+		UUID: aws.String(uuid),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	status, ok := ParseEventSourceMappingStatus(aws.ToString(eventSourceMapping.State))
+	if !ok {
+		return "", fmt.Errorf("unknown event source mapping status: %s, please file an issue in the repository: https://github.com/hashicorp/aws-service-catalog-engine-for-tfc", aws.ToString(eventSourceMapping.State))
+	}
+	return status, nil
+}
+
 func (l *L) EnableEventSourceMapping(ctx context.Context, functionName string, uuid string) error {
 	log.Default().Printf("Enabling event source mapping of %s:%s", functionName, uuid)
 
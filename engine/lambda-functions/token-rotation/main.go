@@ -9,15 +9,35 @@ import (
 	"context"
 	lambdacore "github.com/aws/aws-lambda-go/lambda"
 	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/awsconfig"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/enginestate"
 	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/secretsmanager"
 	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/stepfunctions"
 	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/token-rotation/lambda"
 	"log"
 	"os"
+	"strconv"
+	"time"
 )
 
 type RotateTeamTokensRequest struct {
 	Operation Operation `json:"operation"`
+
+	// SwitchedAt is when the secret was switched to the standby team, only executions started before then are polled
+	SwitchedAt *time.Time `json:"switchedAt,omitempty"`
+
+	// PreviousTeamId is the team whose token is revoked once the executions using it have finished
+	PreviousTeamId string `json:"previousTeamId,omitempty"`
+
+	// Reason is why the engine enters maintenance mode, reported while it is in maintenance mode
+	Reason string `json:"reason,omitempty"`
+
+	// Failure is the cause of the failure the queues are force resumed after, recorded in the rotation progress
+	Failure string `json:"failure,omitempty"`
+
+	// Step, SecretId and ClientRequestToken are set instead of the operation when Secrets Manager rotates the secret
+	Step               RotationStep `json:"Step,omitempty"`
+	SecretId           string       `json:"SecretId,omitempty"`
+	ClientRequestToken string       `json:"ClientRequestToken,omitempty"`
 }
 
 type Operation string
@@ -28,11 +48,39 @@ const (
 	Polling  Operation = "POLLING"
 	Rotating Operation = "ROTATING"
 	Resuming Operation = "RESUMING"
+
+	// Operations for operators to inspect a rotation, and to resume the SQS queues of a failed or stuck rotation
+	Inspecting    Operation = "INSPECTING"
+	ForceResuming Operation = "FORCE_RESUMING"
+
+	// Operations for operators to stop new work for maintenance, poll the drain, and resume the work
+	EnteringMaintenance   Operation = "ENTERING_MAINTENANCE"
+	InspectingMaintenance Operation = "INSPECTING_MAINTENANCE"
+	ExitingMaintenance    Operation = "EXITING_MAINTENANCE"
+
+	// Operations of the standby team rotation, which rotates without pausing the SQS queues
+	Switching Operation = "SWITCHING"
+	Revoking  Operation = "REVOKING"
 )
 
 type RotateTeamTokensResponse struct {
 	StateMachineExecutionCount int                             `json:"stateMachineExecutionCount"`
 	EventSourceMappingStatus   lambda.EventSourceMappingStatus `json:"eventSourceMappingStatus"`
+	SwitchedAt                 *time.Time                      `json:"switchedAt,omitempty"`
+	PreviousTeamId             string                          `json:"previousTeamId,omitempty"`
+
+	// WaitingOn holds the oldest executions the rotation waits for, and DrainTimedOut whether the rotation proceeds
+	// without waiting for them any longer
+	WaitingOn     []stepfunctions.RunningExecution `json:"waitingOn,omitempty"`
+	DrainTimedOut bool                             `json:"drainTimedOut"`
+
+	// RotationProgress and EventSourceMappingStatuses are returned when inspecting a rotation, and the latter also by
+	// the maintenance mode operations
+	RotationProgress           *RotationProgress                          `json:"rotationProgress,omitempty"`
+	EventSourceMappingStatuses map[string]lambda.EventSourceMappingStatus `json:"eventSourceMappingStatuses,omitempty"`
+
+	// Maintenance is returned by the maintenance mode operations
+	Maintenance *MaintenanceStatus `json:"maintenance,omitempty"`
 }
 
 func main() {
@@ -57,13 +105,53 @@ func main() {
 	// Get terminating state machine ARN
 	terminatingStateMachineArn := os.Getenv("TERMINATING_STATE_MACHINE_ARN")
 
+	// Get the number of days new tokens expire after, they do not expire if unset
+	var tokenLifetime time.Duration
+	if tokenExpiry := os.Getenv("TOKEN_EXPIRY_IN_DAYS"); tokenExpiry != "" {
+		tokenExpiryInDays, err := strconv.Atoi(tokenExpiry)
+		if err != nil {
+			log.Fatalf("failed to parse TOKEN_EXPIRY_IN_DAYS: %s", err)
+		}
+		tokenLifetime = time.Duration(tokenExpiryInDays) * 24 * time.Hour
+	}
+
+	// Get the maximum time the rotation waits for executions to finish, there is no maximum if unset
+	var maxDrainTime time.Duration
+	if maxDrain := os.Getenv("MAX_DRAIN_TIME_IN_MINUTES"); maxDrain != "" {
+		maxDrainTimeInMinutes, err := strconv.Atoi(maxDrain)
+		if err != nil {
+			log.Fatalf("failed to parse MAX_DRAIN_TIME_IN_MINUTES: %s", err)
+		}
+		maxDrainTime = time.Duration(maxDrainTimeInMinutes) * time.Minute
+	}
+
+	stuckExecutionPolicy := StuckExecutionPolicy(os.Getenv("STUCK_EXECUTION_POLICY"))
+	if stuckExecutionPolicy == "" {
+		stuckExecutionPolicy = AbortRotation
+	}
+
+	organizationSecrets := map[string]secretsmanager.RotatingSecretsManager{}
+	for organization, organizationSecretsManager := range secretsManager.Organizations {
+		organizationSecrets[organization] = organizationSecretsManager
+	}
+
 	handler := RotateTeamTokensHandler{
 		secretsManager:              secretsManager,
+		organizationSecrets:         organizationSecrets,
+		engineState:                 enginestate.NewFromConfig(sdkConfig),
 		stepFunctions:               stepfunctions.NewFromConfig(sdkConfig),
 		lambda:                      lambda.NewFromConfig(sdkConfig),
 		provisioningStateMachineArn: provisioningStateMachineArn,
 		updatingStateMachineArn:     updatingStateMachineArn,
 		terminatingStateMachineArn:  terminatingStateMachineArn,
+		teamId:                      os.Getenv("TEAM_ID"),
+		standbyTeamId:               os.Getenv("STANDBY_TEAM_ID"),
+		now:                         time.Now,
+		drainPollInterval:           10 * time.Second,
+		mappingPollInterval:         2 * time.Second,
+		tokenLifetime:               tokenLifetime,
+		maxDrainTime:                maxDrainTime,
+		stuckExecutionPolicy:        stuckExecutionPolicy,
 	}
 
 	lambdacore.Start(handler.HandleRequest)
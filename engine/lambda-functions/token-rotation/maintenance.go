@@ -0,0 +1,200 @@
+/*
+ * Copyright (c) HashiCorp, Inc.
+ * SPDX-License-Identifier: MPL-2.0
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/enginestate"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/token-rotation/lambda"
+	"log"
+	"time"
+)
+
+// queuesLockKey is the key of the engine state item held by whoever paused the SQS queues, so that a token rotation
+// and maintenance mode never pause and resume the queues at the same time
+const queuesLockKey = "engine#queues-lock"
+
+// QueuesLockOwner is what paused the SQS queues
+type QueuesLockOwner string
+
+// Enum values for QueuesLockOwner
+const (
+	TokenRotationOwner QueuesLockOwner = "TOKEN_ROTATION"
+	MaintenanceOwner   QueuesLockOwner = "MAINTENANCE"
+)
+
+// QueuesLock records what paused the SQS queues, when, and why
+type QueuesLock struct {
+	Owner      QueuesLockOwner `json:"owner"`
+	AcquiredAt time.Time       `json:"acquiredAt"`
+	Reason     string          `json:"reason,omitempty"`
+}
+
+// MaintenanceStatus is the status of maintenance mode, reported while entering it, and when it is polled
+type MaintenanceStatus struct {
+	// Lock is what paused the SQS queues, if anything did
+	Lock *QueuesLock `json:"lock,omitempty"`
+	// Drained is whether the engine is in maintenance mode, its queues are paused, and no executions are running
+	Drained bool `json:"drained"`
+}
+
+// GetQueuesLock returns what paused the SQS queues, or nil if the lock is not held
+func (h *RotateTeamTokensHandler) GetQueuesLock(ctx context.Context) (*QueuesLock, error) {
+	item, err := h.engineState.Get(ctx, queuesLockKey)
+	if err != nil || item == nil {
+		return nil, err
+	}
+
+	lock := &QueuesLock{}
+	if err = json.Unmarshal([]byte(item.Value), lock); err != nil {
+		return nil, fmt.Errorf("failed to parse the lock of the SQS queues: %w", err)
+	}
+	return lock, nil
+}
+
+// AcquireQueuesLock takes the lock of the SQS queues for the owner, or returns an error if something else holds it.
+// Acquiring a lock the owner already holds succeeds, so retried operations do not lock themselves out.
+func (h *RotateTeamTokensHandler) AcquireQueuesLock(ctx context.Context, owner QueuesLockOwner, reason string) error {
+	value, err := json.Marshal(QueuesLock{Owner: owner, AcquiredAt: time.Now(), Reason: reason})
+	if err != nil {
+		return err
+	}
+
+	acquired, err := h.engineState.PutIfAbsent(ctx, enginestate.Item{Key: queuesLockKey, Value: string(value)})
+	if err != nil || acquired {
+		return err
+	}
+
+	lock, err := h.GetQueuesLock(ctx)
+	if err != nil {
+		return err
+	}
+	if lock == nil {
+		// The lock was released since it was taken, try again
+		return h.AcquireQueuesLock(ctx, owner, reason)
+	}
+	if lock.Owner != owner {
+		return fmt.Errorf("the SQS queues were paused for %s at %s", describeOwner(lock.Owner), lock.AcquiredAt.UTC().Format(time.RFC3339))
+	}
+	return nil
+}
+
+// ReleaseQueuesLock releases the lock of the SQS queues if the owner holds it
+func (h *RotateTeamTokensHandler) ReleaseQueuesLock(ctx context.Context, owner QueuesLockOwner) error {
+	lock, err := h.GetQueuesLock(ctx)
+	if err != nil || lock == nil || lock.Owner != owner {
+		return err
+	}
+	return h.engineState.Delete(ctx, queuesLockKey)
+}
+
+// CheckNotInMaintenance returns an error if the engine is in maintenance mode, for the token rotations that do not pause
+// the SQS queues, and therefore do not take their lock
+func (h *RotateTeamTokensHandler) CheckNotInMaintenance(ctx context.Context) error {
+	lock, err := h.GetQueuesLock(ctx)
+	if err != nil {
+		return err
+	}
+	if lock != nil && lock.Owner == MaintenanceOwner {
+		return fmt.Errorf("the engine is in maintenance mode since %s, exit maintenance mode before rotating the token", lock.AcquiredAt.UTC().Format(time.RFC3339))
+	}
+	return nil
+}
+
+// EnterMaintenance stops new work by pausing the SQS queues, unless a token rotation paused them, and reports the status
+// of the drain. Entering maintenance mode again reports the status without pausing the queues again.
+func (h *RotateTeamTokensHandler) EnterMaintenance(ctx context.Context, reason string) (*RotateTeamTokensResponse, error) {
+	if err := h.AcquireQueuesLock(ctx, MaintenanceOwner, reason); err != nil {
+		return nil, fmt.Errorf("cannot enter maintenance mode: %w", err)
+	}
+
+	log.Default().Printf("entering maintenance mode: %s", reason)
+	tuples, err := h.lambda.GetEventSourceMappingUuidTuples(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err = h.UpdateEventSourceMappings(ctx, tuples, false); err != nil {
+		// Leave the queues paused, so the operator can retry entering maintenance mode, or exit it
+		return nil, fmt.Errorf("failed to pause the SQS queues, enter maintenance mode again to retry, or exit it to resume the queues: %w", err)
+	}
+
+	return h.MaintenanceStatus(ctx)
+}
+
+// MaintenanceStatus reports what paused the SQS queues, the status of each event source mapping, and the executions
+// still running, so an operator can tell when the engine has drained
+func (h *RotateTeamTokensHandler) MaintenanceStatus(ctx context.Context) (*RotateTeamTokensResponse, error) {
+	lock, err := h.GetQueuesLock(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tuples, err := h.lambda.GetEventSourceMappingUuidTuples(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := map[string]lambda.EventSourceMappingStatus{}
+	disabled := true
+	for _, tuple := range []*lambda.FunctionNameUuidTuple{tuples.ProvisioningLambdaEventSourceMapping, tuples.UpdatingLambdaEventSourceMapping, tuples.TerminatingLambdaEventSourceMapping} {
+		statuses[tuple.FunctionName] = tuple.EventSourceMappingStatus
+		disabled = disabled && tuple.EventSourceMappingStatus == lambda.EventSourceDisabled
+	}
+
+	executions, err := h.StateMachineExecutions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	drained := lock != nil && lock.Owner == MaintenanceOwner && disabled && len(executions) == 0
+
+	return &RotateTeamTokensResponse{
+		StateMachineExecutionCount: len(executions),
+		WaitingOn:                  OldestExecutions(executions),
+		EventSourceMappingStatuses: statuses,
+		Maintenance:                &MaintenanceStatus{Lock: lock, Drained: drained},
+	}, nil
+}
+
+// ExitMaintenance resumes the SQS queues paused for maintenance
+func (h *RotateTeamTokensHandler) ExitMaintenance(ctx context.Context) (*RotateTeamTokensResponse, error) {
+	lock, err := h.GetQueuesLock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if lock == nil || lock.Owner != MaintenanceOwner {
+		return nil, errors.New("the engine is not in maintenance mode")
+	}
+
+	log.Default().Printf("exiting maintenance mode")
+	tuples, err := h.lambda.GetEventSourceMappingUuidTuples(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err = h.UpdateEventSourceMappings(ctx, tuples, true); err != nil {
+		return nil, err
+	}
+
+	// Release the lock only once the queues are resumed, so a failure can be retried by exiting maintenance mode again
+	if err = h.ReleaseQueuesLock(ctx, MaintenanceOwner); err != nil {
+		return nil, err
+	}
+	return h.MaintenanceStatus(ctx)
+}
+
+func describeOwner(owner QueuesLockOwner) string {
+	switch owner {
+	case TokenRotationOwner:
+		return "a token rotation"
+	case MaintenanceOwner:
+		return "maintenance"
+	default:
+		return string(owner)
+	}
+}
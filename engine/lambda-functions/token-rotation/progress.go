@@ -0,0 +1,234 @@
+/*
+ * Copyright (c) HashiCorp, Inc.
+ * SPDX-License-Identifier: MPL-2.0
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/enginestate"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/token-rotation/lambda"
+	"log"
+	"time"
+)
+
+// rotationProgressKey is the key of the engine state item holding the progress of the latest token rotation
+const rotationProgressKey = "token-rotation#progress"
+
+// rotationProgressTtl is how long the progress of a rotation is kept for inspection after it was last updated
+const rotationProgressTtl = 90 * 24 * time.Hour
+
+// RotationPhase is the phase a token rotation reached
+type RotationPhase string
+
+// Enum values for RotationPhase
+const (
+	PhasePaused    RotationPhase = "PAUSED"
+	PhaseRotating  RotationPhase = "ROTATING"
+	PhaseRotated   RotationPhase = "ROTATED"
+	PhaseCompleted RotationPhase = "COMPLETED"
+	PhaseFailed    RotationPhase = "FAILED"
+)
+
+// RotationProgress is the progress of a token rotation, recorded so that a rotation which stopped halfway can be
+// inspected, and the SQS queues it paused can be resumed
+type RotationProgress struct {
+	Phase     RotationPhase `json:"phase"`
+	StartedAt time.Time     `json:"startedAt"`
+	UpdatedAt time.Time     `json:"updatedAt"`
+	// QueuesPaused is whether the rotation paused the SQS queues, and has not resumed them yet
+	QueuesPaused bool `json:"queuesPaused"`
+	// DrainUnfinished is whether the executions did not finish within the createSecret step of a Secrets Manager
+	// rotation, in which case the finishSecret step resumes the queues without rotating the token
+	DrainUnfinished bool `json:"drainUnfinished,omitempty"`
+	// Error is the cause of the failure of the rotation, if it failed
+	Error string `json:"error,omitempty"`
+	// PendingVersions are the versions of the TFE credentials new tokens are staged as, by the IDs of their teams, so a
+	// retry promotes a token that was created but not made current, instead of revoking it with yet another token
+	PendingVersions map[string]string `json:"pendingVersions,omitempty"`
+}
+
+// GetRotationProgress returns the progress of the latest token rotation, or nil if no rotation was recorded
+func (h *RotateTeamTokensHandler) GetRotationProgress(ctx context.Context) (*RotationProgress, error) {
+	item, err := h.engineState.Get(ctx, rotationProgressKey)
+	if err != nil || item == nil {
+		return nil, err
+	}
+
+	progress := &RotationProgress{}
+	if err = json.Unmarshal([]byte(item.Value), progress); err != nil {
+		return nil, fmt.Errorf("failed to parse the progress of the token rotation: %w", err)
+	}
+	return progress, nil
+}
+
+// UpdateRotationProgress applies the update to the progress of the latest token rotation, or to a new rotation if none
+// was recorded
+func (h *RotateTeamTokensHandler) UpdateRotationProgress(ctx context.Context, update func(progress *RotationProgress)) error {
+	now := time.Now()
+
+	progress, err := h.GetRotationProgress(ctx)
+	if err != nil {
+		return err
+	}
+	if progress == nil {
+		progress = &RotationProgress{StartedAt: now}
+	}
+
+	update(progress)
+	progress.UpdatedAt = now
+
+	value, err := json.Marshal(progress)
+	if err != nil {
+		return err
+	}
+
+	return h.engineState.Put(ctx, enginestate.Item{
+		Key:       rotationProgressKey,
+		Value:     string(value),
+		ExpiresAt: now.Add(rotationProgressTtl),
+	})
+}
+
+// RecordRotationPhase records the phase the token rotation reached
+func (h *RotateTeamTokensHandler) RecordRotationPhase(ctx context.Context, phase RotationPhase) error {
+	return h.UpdateRotationProgress(ctx, func(progress *RotationProgress) {
+		progress.Phase = phase
+	})
+}
+
+// PendingVersion returns the version of the TFE credentials the new token of the team is staged as, or an empty string
+// if no token of the team is waiting to be made current
+func (h *RotateTeamTokensHandler) PendingVersion(ctx context.Context, teamId string) (string, error) {
+	progress, err := h.GetRotationProgress(ctx)
+	if err != nil || progress == nil {
+		return "", err
+	}
+	return progress.PendingVersions[teamId], nil
+}
+
+// RecordPendingVersion records the version of the TFE credentials the new token of the team is staged as, or clears it
+// if the version is empty
+func (h *RotateTeamTokensHandler) RecordPendingVersion(ctx context.Context, teamId string, versionId string) error {
+	return h.UpdateRotationProgress(ctx, func(progress *RotationProgress) {
+		if versionId == "" {
+			delete(progress.PendingVersions, teamId)
+			return
+		}
+		if progress.PendingVersions == nil {
+			progress.PendingVersions = map[string]string{}
+		}
+		progress.PendingVersions[teamId] = versionId
+	})
+}
+
+// PauseQueues starts a new rotation by disabling the event source mappings of the SQS queues, unless they were paused
+// for maintenance. The rotation is recorded as having paused the queues first, so they are resumed even if pausing
+// fails halfway.
+func (h *RotateTeamTokensHandler) PauseQueues(ctx context.Context) error {
+	if err := h.AcquireQueuesLock(ctx, TokenRotationOwner, "rotating the team token"); err != nil {
+		return fmt.Errorf("cannot rotate the team token: %w", err)
+	}
+
+	err := h.UpdateRotationProgress(ctx, func(progress *RotationProgress) {
+		// Keep the tokens staged by a rotation that failed, so this rotation can still promote them
+		*progress = RotationProgress{Phase: PhasePaused, StartedAt: time.Now(), QueuesPaused: true, PendingVersions: progress.PendingVersions}
+	})
+	if err != nil {
+		return err
+	}
+
+	tuples, err := h.lambda.GetEventSourceMappingUuidTuples(ctx)
+	if err != nil {
+		return err
+	}
+	return h.UpdateEventSourceMappings(ctx, tuples, false)
+}
+
+// ResumeQueues enables the event source mappings of the SQS queues, completing the rotation unless it failed. The
+// queues are left paused if they were paused for maintenance.
+func (h *RotateTeamTokensHandler) ResumeQueues(ctx context.Context) error {
+	lock, err := h.GetQueuesLock(ctx)
+	if err != nil {
+		return err
+	}
+
+	if lock != nil && lock.Owner != TokenRotationOwner {
+		log.Default().Printf("leaving the SQS queues paused for %s", describeOwner(lock.Owner))
+	} else {
+		tuples, err := h.lambda.GetEventSourceMappingUuidTuples(ctx)
+		if err != nil {
+			return err
+		}
+		if err = h.UpdateEventSourceMappings(ctx, tuples, true); err != nil {
+			return err
+		}
+		if err = h.ReleaseQueuesLock(ctx, TokenRotationOwner); err != nil {
+			return err
+		}
+	}
+
+	return h.UpdateRotationProgress(ctx, func(progress *RotationProgress) {
+		progress.QueuesPaused = false
+		if progress.Phase != PhaseFailed {
+			progress.Phase = PhaseCompleted
+		}
+	})
+}
+
+// ResumeAfterFailure records the failure of the rotation, and resumes the SQS queues so provisioning does not stop
+// silently. Returns the cause of the failure, along with any error resuming the queues.
+func (h *RotateTeamTokensHandler) ResumeAfterFailure(ctx context.Context, cause error) error {
+	log.Default().Printf("token rotation failed, resuming the SQS queues: %v", cause)
+
+	errs := []error{cause}
+	err := h.UpdateRotationProgress(ctx, func(progress *RotationProgress) {
+		progress.Phase = PhaseFailed
+		progress.Error = cause.Error()
+	})
+	if err != nil {
+		errs = append(errs, fmt.Errorf("failed to record the failure of the token rotation: %w", err))
+	}
+
+	// Resume the queues even if the failure was the cancellation of the context
+	if err = h.ResumeQueues(context.WithoutCancel(ctx)); err != nil {
+		log.Default().Printf("failed to resume the SQS queues after the token rotation failed: %v", err)
+		errs = append(errs, fmt.Errorf("failed to resume the SQS queues: %w", err))
+	}
+	return errors.Join(errs...)
+}
+
+// InspectRotation returns the progress of the latest token rotation, along with the status of each event source
+// mapping and the number of running state machine executions, so operators can tell whether a rotation is stuck
+func (h *RotateTeamTokensHandler) InspectRotation(ctx context.Context) (*RotateTeamTokensResponse, error) {
+	progress, err := h.GetRotationProgress(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tuples, err := h.lambda.GetEventSourceMappingUuidTuples(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := map[string]lambda.EventSourceMappingStatus{}
+	for _, tuple := range []*lambda.FunctionNameUuidTuple{tuples.ProvisioningLambdaEventSourceMapping, tuples.UpdatingLambdaEventSourceMapping, tuples.TerminatingLambdaEventSourceMapping} {
+		statuses[tuple.FunctionName] = tuple.EventSourceMappingStatus
+	}
+
+	executions, err := h.StateMachineExecutions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RotateTeamTokensResponse{
+		StateMachineExecutionCount: len(executions),
+		WaitingOn:                  OldestExecutions(executions),
+		RotationProgress:           progress,
+		EventSourceMappingStatuses: statuses,
+	}, nil
+}
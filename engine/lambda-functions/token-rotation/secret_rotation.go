@@ -0,0 +1,228 @@
+/*
+ * Copyright (c) HashiCorp, Inc.
+ * SPDX-License-Identifier: MPL-2.0
+ */
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/secretsmanager"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/tfc"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/token-rotation/lambda"
+	"github.com/hashicorp/go-tfe"
+	"log"
+	"net/http"
+	"time"
+)
+
+// RotationStep is a step of the rotation protocol of Secrets Manager
+type RotationStep string
+
+// Enum values for RotationStep
+const (
+	CreateSecret RotationStep = "createSecret"
+	SetSecret    RotationStep = "setSecret"
+	TestSecret   RotationStep = "testSecret"
+	FinishSecret RotationStep = "finishSecret"
+)
+
+// HandleRotationStep rotates the TFE credentials when Secrets Manager rotates the secret. The version Secrets Manager
+// creates for the rotation is identified by the client request token.
+func (h *RotateTeamTokensHandler) HandleRotationStep(ctx context.Context, request RotateTeamTokensRequest) error {
+	log.Default().Printf("handling %s step of the rotation of secret %s to version %s", request.Step, request.SecretId, request.ClientRequestToken)
+
+	if request.ClientRequestToken == "" {
+		return errors.New("the rotation request has no client request token")
+	}
+
+	switch request.Step {
+	case CreateSecret:
+		return h.createSecret(ctx, request.ClientRequestToken)
+	case SetSecret:
+		// The token is created in TFC by the createSecret step, so there is nothing left to set
+		return nil
+	case TestSecret:
+		return h.testSecret(ctx, request.ClientRequestToken)
+	case FinishSecret:
+		return h.finishSecret(ctx, request.ClientRequestToken)
+	default:
+		return fmt.Errorf("unknown rotation step %s", request.Step)
+	}
+}
+
+// createSecret creates a new team token and stores it as the pending version of the secret. Creating a token for the
+// team in use revokes its current token, so the SQS queues are paused until the executions using it have finished,
+// unless the standby team is rotated to. If the executions do not finish within the invocation, the current token is
+// staged instead, and the finishSecret step resumes the queues in an invocation of its own.
+func (h *RotateTeamTokensHandler) createSecret(ctx context.Context, versionId string) error {
+	pending, err := h.secretsManager.GetPendingSecretValue(ctx, versionId)
+	if err != nil {
+		return err
+	}
+	if pending != nil {
+		log.Default().Printf("version %s already holds a new token of team %s", versionId, pending.TeamId)
+		return nil
+	}
+
+	tfeCredentialsSecret, err := h.secretsManager.GetSecretValue(ctx)
+	if err != nil {
+		return err
+	}
+
+	tfeClient, err := tfc.GetTFEClientWithCredentials(tfeCredentialsSecret, http.Header{})
+	if err != nil {
+		return err
+	}
+
+	if h.standbyTeamId != "" {
+		if err = h.CheckNotInMaintenance(ctx); err != nil {
+			return err
+		}
+		return h.createToken(ctx, tfeClient, versionId, h.NextTeamId(tfeCredentialsSecret.TeamId))
+	}
+
+	// Resume the queues if the token cannot be staged, instead of leaving provisioning paused until the next attempt
+	drained, err := h.PauseAndDrain(ctx)
+	if err != nil {
+		return h.ResumeAfterFailure(ctx, err)
+	}
+	if !drained {
+		return h.stageCurrentToken(ctx, versionId, tfeCredentialsSecret)
+	}
+	if err = h.createToken(ctx, tfeClient, versionId, tfeCredentialsSecret.TeamId); err != nil {
+		return h.ResumeAfterFailure(ctx, err)
+	}
+	return h.RecordRotationPhase(ctx, PhaseRotating)
+}
+
+// createToken creates a new token for the team, and stores it as the pending version of the secret
+func (h *RotateTeamTokensHandler) createToken(ctx context.Context, tfeClient *tfe.Client, versionId string, teamId string) error {
+	log.Default().Printf("creating token for team %s", teamId)
+	tt, err := h.CreateTeamToken(ctx, tfeClient, teamId)
+	if err != nil {
+		return tfc.Error(err)
+	}
+
+	_, err = h.secretsManager.PutPendingTeamCredentials(ctx, versionId, teamId, tt.Token)
+	return err
+}
+
+// stageCurrentToken stores the current token as the pending version of the secret, so the rotation protocol carries on
+// to the finishSecret step without revoking the token, and records that the executions did not finish
+func (h *RotateTeamTokensHandler) stageCurrentToken(ctx context.Context, versionId string, tfeCredentialsSecret *secretsmanager.TFECredentialsSecret) error {
+	log.Default().Printf("state machine executions did not finish before the invocation timed out, staging the current token of team %s", tfeCredentialsSecret.TeamId)
+	err := h.UpdateRotationProgress(ctx, func(progress *RotationProgress) {
+		progress.DrainUnfinished = true
+	})
+	if err != nil {
+		return h.ResumeAfterFailure(ctx, err)
+	}
+
+	if _, err = h.secretsManager.PutPendingTeamCredentials(ctx, versionId, tfeCredentialsSecret.TeamId, tfeCredentialsSecret.Token); err != nil {
+		return h.ResumeAfterFailure(ctx, err)
+	}
+	return nil
+}
+
+// testSecret validates the token of the pending version of the secret
+func (h *RotateTeamTokensHandler) testSecret(ctx context.Context, versionId string) error {
+	pending, err := h.secretsManager.GetPendingSecretValue(ctx, versionId)
+	if err != nil {
+		return err
+	}
+	if pending == nil {
+		return fmt.Errorf("version %s of the TFE credentials is not pending", versionId)
+	}
+
+	if err = ValidateToken(ctx, pending.Hostname, pending.TeamId, pending.Token); err != nil {
+		err = fmt.Errorf("the new token of team %s failed validation: %w", pending.TeamId, err)
+		// Secrets Manager does not finish a rotation that failed its test, so the queues would stay paused
+		if h.standbyTeamId == "" {
+			return h.ResumeAfterFailure(ctx, err)
+		}
+		return err
+	}
+	return nil
+}
+
+// finishSecret makes the pending version of the secret current, and resumes the SQS queues if they were paused. If the
+// executions did not finish within the createSecret step, the pending version is discarded and the rotation fails
+// once the queues are resumed.
+func (h *RotateTeamTokensHandler) finishSecret(ctx context.Context, versionId string) error {
+	if h.standbyTeamId == "" {
+		progress, err := h.GetRotationProgress(ctx)
+		if err != nil {
+			return h.ResumeAfterFailure(ctx, err)
+		}
+		if progress != nil && progress.DrainUnfinished {
+			if err = h.secretsManager.DiscardPendingVersion(ctx, versionId); err != nil {
+				return h.ResumeAfterFailure(ctx, err)
+			}
+			return h.ResumeAfterFailure(ctx, errors.New("state machine executions did not finish within the createSecret step, the token was not rotated"))
+		}
+	}
+
+	if err := h.secretsManager.PromotePendingVersion(ctx, versionId); err != nil {
+		return err
+	}
+
+	if h.standbyTeamId != "" {
+		return nil
+	}
+
+	// The organizations with their own TFE credentials are rotated along, while the queues are still paused
+	if err := h.RotateOrganizationTokens(ctx); err != nil {
+		return h.ResumeAfterFailure(ctx, err)
+	}
+
+	if err := h.RecordRotationPhase(ctx, PhaseRotated); err != nil {
+		return err
+	}
+	return h.ResumeQueues(ctx)
+}
+
+// PauseAndDrain pauses the SQS queues and waits for all state machine executions to finish, until the maximum drain
+// time has passed or the invocation is about to time out. Returns whether the token can be rotated, which is not the
+// case if the invocation is about to time out.
+func (h *RotateTeamTokensHandler) PauseAndDrain(ctx context.Context) (bool, error) {
+	if err := h.PauseQueues(ctx); err != nil {
+		return false, err
+	}
+	drainStartedAt := time.Now()
+
+	for {
+		executions, err := h.StateMachineExecutions(ctx)
+		if err != nil {
+			return false, err
+		}
+		status, err := h.EventSourceMappingsStatus(ctx)
+		if err != nil {
+			return false, err
+		}
+		if status == lambda.EventSourceDisabled {
+			if len(executions) == 0 {
+				return true, nil
+			}
+			drainTimedOut, err := h.CheckDrainTime(executions, drainStartedAt)
+			if err != nil || drainTimedOut {
+				return drainTimedOut, err
+			}
+		}
+
+		// Leave enough time to stage the current token before the invocation times out
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < 2*h.drainPollInterval+maxEventSourceMappingTransition {
+			log.Default().Printf("event source mappings are %s and %d state machine executions are still running, stopping the drain", status, len(executions))
+			return false, nil
+		}
+
+		log.Default().Printf("waiting for %d state machine executions to finish", len(executions))
+		select {
+		case <-ctx.Done():
+			return false, ctx.Err()
+		case <-time.After(h.drainPollInterval):
+		}
+	}
+}
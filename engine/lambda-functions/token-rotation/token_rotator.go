@@ -7,12 +7,23 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/secretsmanager"
+	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/stepfunctions"
 	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/shared/tfc"
 	"github.com/hashicorp/aws-service-catalog-engine-for-tfc/engine/lambda-functions/token-rotation/lambda"
+	"github.com/hashicorp/go-tfe"
 	"log"
 	"net/http"
+	"sort"
+	"time"
 )
 
+// maxEventSourceMappingTransition is how long an event source mapping may take to become enabled or disabled
+const maxEventSourceMappingTransition = 2 * time.Minute
+
 func (h *RotateTeamTokensHandler) UpdateEventSourceMappings(ctx context.Context, tuples *lambda.FunctionNameUuidTuples, enabled bool) error {
 	// Update the event source mappings asynchronously and restart the SQS queues
 	// The update is an asynchronous operation, so await its completion
@@ -32,33 +43,139 @@ func (h *RotateTeamTokensHandler) UpdateEventSourceMappings(ctx context.Context,
 			return err
 		}
 	}
+
+	targetStatus := lambda.EventSourceDisabled
+	if enabled {
+		targetStatus = lambda.EventSourceEnabled
+	}
+	for _, tuple := range tuplesList {
+		if err := h.AwaitEventSourceMapping(ctx, tuple, targetStatus); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-func (h *RotateTeamTokensHandler) StateMachineExecutions(ctx context.Context) (int, error) {
-	// Return the number of executions for all state machines
-	count := 0
+// AwaitEventSourceMapping waits for the event source mapping to reach the target status
+func (h *RotateTeamTokensHandler) AwaitEventSourceMapping(ctx context.Context, tuple *lambda.FunctionNameUuidTuple, targetStatus lambda.EventSourceMappingStatus) error {
+	deadline := time.Now().Add(maxEventSourceMappingTransition)
+	for {
+		status, err := h.lambda.GetEventSourceMappingStatus(ctx, tuple.EventSourceMapping)
+		if err != nil {
+			return err
+		}
+		if status == targetStatus {
+			return nil
+		}
+
+		if !time.Now().Before(deadline) {
+			return fmt.Errorf("event source mapping %s of function %s is %s, and did not become %s within %s", tuple.EventSourceMapping, tuple.FunctionName, status, targetStatus, maxEventSourceMappingTransition)
+		}
+
+		log.Default().Printf("waiting for event source mapping %s of function %s to become %s, it is %s", tuple.EventSourceMapping, tuple.FunctionName, targetStatus, status)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(h.mappingPollInterval):
+		}
+	}
+}
+
+// EventSourceMappingsStatus returns Disabled once all event source mappings are disabled, and Disabling until then
+func (h *RotateTeamTokensHandler) EventSourceMappingsStatus(ctx context.Context) (lambda.EventSourceMappingStatus, error) {
+	statuses, err := h.lambda.GetEventSourceMappingUuidTuples(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	if statuses.ProvisioningLambdaEventSourceMapping.EventSourceMappingStatus == lambda.EventSourceDisabled &&
+		statuses.UpdatingLambdaEventSourceMapping.EventSourceMappingStatus == lambda.EventSourceDisabled &&
+		statuses.TerminatingLambdaEventSourceMapping.EventSourceMappingStatus == lambda.EventSourceDisabled {
+		return lambda.EventSourceDisabled, nil
+	}
+	return lambda.EventSourceDisabling, nil
+}
+
+// StateMachineExecutions returns the running executions of all state machines, oldest first
+func (h *RotateTeamTokensHandler) StateMachineExecutions(ctx context.Context) ([]stepfunctions.RunningExecution, error) {
+	return h.StateMachineExecutionsStartedBefore(ctx, time.Time{})
+}
+
+// StateMachineExecutionsStartedBefore returns the running executions of all state machines that were started before
+// the given time, and may still be using the previous token, oldest first. All running executions are returned if the
+// time is zero.
+func (h *RotateTeamTokensHandler) StateMachineExecutionsStartedBefore(ctx context.Context, startedBefore time.Time) ([]stepfunctions.RunningExecution, error) {
+	executions := []stepfunctions.RunningExecution{}
 	stateMachineArns := []string{h.provisioningStateMachineArn, h.updatingStateMachineArn, h.terminatingStateMachineArn}
 
 	for _, stateMachineArn := range stateMachineArns {
-		log.Default().Printf("getting state machine executions count for: %s", stateMachineArn)
-		// Get the state machine executions count
-		executionsCount, err := h.stepFunctions.GetStateMachineExecutionCount(ctx, stateMachineArn)
+		log.Default().Printf("listing running state machine executions of: %s", stateMachineArn)
+		runningExecutions, err := h.stepFunctions.ListRunningExecutions(ctx, stateMachineArn)
 		if err != nil {
-			return 0, err
+			return nil, err
+		}
+
+		for _, execution := range runningExecutions {
+			if startedBefore.IsZero() || execution.StartedAt.Before(startedBefore) {
+				executions = append(executions, execution)
+			}
 		}
-		// Return the count
-		count = count + executionsCount
 	}
-	return count, nil
+
+	sort.SliceStable(executions, func(i, j int) bool {
+		return executions[i].StartedAt.Before(executions[j].StartedAt)
+	})
+	return executions, nil
 }
 
 func (h *RotateTeamTokensHandler) RotateToken(ctx context.Context) error {
+	if err := h.rotateSecretToken(ctx, h.secretsManager); err != nil {
+		return err
+	}
+	return h.RotateOrganizationTokens(ctx)
+}
+
+// RotateOrganizationTokens rotates the tokens of the organizations with their own TFE credentials, while the SQS queues
+// are paused. The organizations have no standby teams, so their tokens are only rotated by pausing the queues.
+func (h *RotateTeamTokensHandler) RotateOrganizationTokens(ctx context.Context) error {
+	for organization, secretsManager := range h.organizationSecrets {
+		log.Default().Printf("rotating the token of organization %s", organization)
+		if err := h.rotateSecretToken(ctx, secretsManager); err != nil {
+			return fmt.Errorf("failed to rotate the token of organization %s: %w", organization, err)
+		}
+	}
+	return nil
+}
+
+// rotateSecretToken replaces the token of the team the secret holds the credentials of. Creating the new token revokes
+// the previous one, so the version the new token is staged as is recorded before it is created, and a retry promotes
+// the token staged by an earlier attempt instead of creating yet another one.
+func (h *RotateTeamTokensHandler) rotateSecretToken(ctx context.Context, secretsManager secretsmanager.RotatingSecretsManager) error {
 	// Fetch the TFE credentials/config from AWS Secrets Manager
-	tfeCredentialsSecret, err := h.secretsManager.GetSecretValue(ctx)
+	tfeCredentialsSecret, err := secretsManager.GetSecretValue(ctx)
 	if err != nil {
 		return err
 	}
+	teamId := tfeCredentialsSecret.TeamId
+
+	// Promote the token an earlier attempt created but did not make current, if it works
+	versionId, err := h.PendingVersion(ctx, teamId)
+	if err != nil {
+		return err
+	}
+	if versionId != "" {
+		pending, err := secretsManager.GetPendingSecretValue(ctx, versionId)
+		if err != nil {
+			return err
+		}
+		if pending != nil && pending.TeamId == teamId {
+			if err = ValidateToken(ctx, tfeCredentialsSecret.Hostname, teamId, pending.Token); err == nil {
+				log.Default().Printf("promoting the token of team %s staged as version %s by an earlier attempt", teamId, versionId)
+				return h.promotePendingToken(ctx, secretsManager, teamId, versionId)
+			}
+			log.Default().Printf("the token of team %s staged as version %s by an earlier attempt does not work, replacing it: %v", teamId, versionId, err)
+		}
+	}
 
 	// Use the credentials to create a TFE client
 	tfeClient, err := tfc.GetTFEClientWithCredentials(tfeCredentialsSecret, http.Header{})
@@ -66,12 +183,169 @@ func (h *RotateTeamTokensHandler) RotateToken(ctx context.Context) error {
 		return err
 	}
 
+	versionId = uuid.New().String()
+	if err = h.RecordPendingVersion(ctx, teamId, versionId); err != nil {
+		return err
+	}
+
 	// Creates a new Team Token, replacing any existing token, once all the state machine executions have finished
-	tt, err := tfeClient.TeamTokens.Create(ctx, tfeCredentialsSecret.TeamId)
+	tt, err := h.CreateTeamToken(ctx, tfeClient, teamId)
 	if err != nil {
 		return tfc.Error(err)
 	}
 
-	// Store the team token in Secrets Manager
-	return h.secretsManager.UpdateSecretValue(ctx, tt.Token)
+	if _, err = secretsManager.PutPendingTeamCredentials(ctx, versionId, teamId, tt.Token); err != nil {
+		return fmt.Errorf("failed to stage the new token of team %s as %s, and creating it revoked the previous token, so the %s TFE credentials no longer work until the rotation is retried: %w", teamId, secretsmanager.PendingVersionStage, secretsmanager.CurrentVersionStage, err)
+	}
+
+	if err = ValidateToken(ctx, tfeCredentialsSecret.Hostname, teamId, tt.Token); err != nil {
+		return pendingTokenError(teamId, versionId, fmt.Errorf("the new token of team %s failed validation: %w", teamId, err))
+	}
+
+	return h.promotePendingToken(ctx, secretsManager, teamId, versionId)
+}
+
+// promotePendingToken makes the validated token staged as the pending version of the secret current, and clears the
+// version from the progress of the rotation
+func (h *RotateTeamTokensHandler) promotePendingToken(ctx context.Context, secretsManager secretsmanager.RotatingSecretsManager, teamId string, versionId string) error {
+	if err := secretsManager.PromotePendingVersion(ctx, versionId); err != nil {
+		return pendingTokenError(teamId, versionId, fmt.Errorf("failed to promote the new token of team %s to %s: %w", teamId, secretsmanager.CurrentVersionStage, err))
+	}
+
+	log.Default().Printf("validated the new token of team %s and made it current", teamId)
+	return h.RecordPendingVersion(ctx, teamId, "")
+}
+
+// pendingTokenError reports a new token that was staged but not made current. Creating it revoked the previous token,
+// so the current TFE credentials no longer work until a retry promotes it.
+func pendingTokenError(teamId string, versionId string, err error) error {
+	return fmt.Errorf("%w; the new token is kept as %s version %s of the TFE credentials so a retry can promote it, but creating it revoked the previous token of team %s, so the %s TFE credentials no longer work", err, secretsmanager.PendingVersionStage, versionId, teamId, secretsmanager.CurrentVersionStage)
+}
+
+// CreateTeamToken creates a new token for the team, replacing any existing token. The token expires a little after the
+// next rotation is due, so it stops working if the rotation stops running.
+func (h *RotateTeamTokensHandler) CreateTeamToken(ctx context.Context, tfeClient *tfe.Client, teamId string) (*tfc.TeamToken, error) {
+	var expiredAt time.Time
+	if h.tokenLifetime > 0 {
+		expiredAt = time.Now().Add(h.tokenLifetime)
+	}
+
+	return tfc.CreateTeamToken(ctx, tfeClient, teamId, expiredAt)
+}
+
+// CommitToken stages the new token of the standby team as the pending version of the secret, and only makes it current
+// once it is validated. The token the secret holds keeps working, so a token that fails validation is discarded,
+// leaving the current version of the secret as it is.
+func (h *RotateTeamTokensHandler) CommitToken(ctx context.Context, secretsManager secretsmanager.RotatingSecretsManager, hostname string, teamId string, token string) error {
+	versionId, err := secretsManager.PutPendingTeamCredentials(ctx, "", teamId, token)
+	if err != nil {
+		return fmt.Errorf("failed to stage the new token of team %s as %s: %w", teamId, secretsmanager.PendingVersionStage, err)
+	}
+
+	if err = ValidateToken(ctx, hostname, teamId, token); err != nil {
+		if discardErr := secretsManager.DiscardPendingVersion(ctx, versionId); discardErr != nil {
+			log.Default().Printf("failed to discard pending version %s of the TFE credentials: %v", versionId, discardErr)
+		}
+		return fmt.Errorf("the new token of team %s failed validation, the TFE credentials were not updated: %w", teamId, err)
+	}
+
+	if err = secretsManager.PromotePendingVersion(ctx, versionId); err != nil {
+		return fmt.Errorf("failed to promote the new token of team %s to %s: %w", teamId, secretsmanager.CurrentVersionStage, err)
+	}
+
+	log.Default().Printf("validated the new token of team %s and made it current", teamId)
+	return nil
+}
+
+// ValidateToken checks the token works by reading the details of its team from TFC
+func ValidateToken(ctx context.Context, hostname string, teamId string, token string) error {
+	tfeClient, err := tfc.GetTFEClientWithCredentials(&secretsmanager.TFECredentialsSecret{
+		Hostname: hostname,
+		TeamId:   teamId,
+		Token:    token,
+	}, http.Header{})
+	if err != nil {
+		return err
+	}
+
+	_, err = tfeClient.Teams.Read(ctx, teamId)
+	return tfc.Error(err)
+}
+
+// SwitchToStandbyTeam creates a token for the team the secret does not currently hold, and switches the secret to it.
+// The token of the previous team keeps working, so executions using it are not interrupted. Returns the ID of the
+// previous team, whose token must be revoked once those executions have finished.
+func (h *RotateTeamTokensHandler) SwitchToStandbyTeam(ctx context.Context) (string, error) {
+	if h.teamId == "" || h.standbyTeamId == "" {
+		return "", errors.New("both TEAM_ID and STANDBY_TEAM_ID must be set to rotate using a standby team")
+	}
+
+	if err := h.CheckNotInMaintenance(ctx); err != nil {
+		return "", err
+	}
+
+	tfeCredentialsSecret, err := h.secretsManager.GetSecretValue(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	tfeClient, err := tfc.GetTFEClientWithCredentials(tfeCredentialsSecret, http.Header{})
+	if err != nil {
+		return "", err
+	}
+
+	previousTeamId := tfeCredentialsSecret.TeamId
+	nextTeamId := h.NextTeamId(previousTeamId)
+
+	// The standby team has no token in use, so replacing its token does not affect any executions
+	log.Default().Printf("creating token for standby team %s", nextTeamId)
+	tt, err := h.CreateTeamToken(ctx, tfeClient, nextTeamId)
+	if err != nil {
+		return "", tfc.Error(err)
+	}
+
+	if len(h.organizationSecrets) > 0 {
+		log.Default().Printf("the tokens of the organizations with their own TFE credentials are only rotated by pausing the SQS queues")
+	}
+
+	log.Default().Printf("switching TFE credentials from team %s to team %s", previousTeamId, nextTeamId)
+	if err = h.CommitToken(ctx, h.secretsManager, tfeCredentialsSecret.Hostname, nextTeamId, tt.Token); err != nil {
+		return "", err
+	}
+
+	return previousTeamId, nil
+}
+
+// NextTeamId returns the team the standby team rotation switches to from the given team
+func (h *RotateTeamTokensHandler) NextTeamId(currentTeamId string) string {
+	if currentTeamId == h.standbyTeamId {
+		return h.teamId
+	}
+	return h.standbyTeamId
+}
+
+// RevokeTeamToken deletes the token of the previous team, once no executions use it anymore
+func (h *RotateTeamTokensHandler) RevokeTeamToken(ctx context.Context, previousTeamId string) error {
+	tfeCredentialsSecret, err := h.secretsManager.GetSecretValue(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Never revoke the token the secret holds, which would stop all provisioning
+	if previousTeamId == "" || previousTeamId == tfeCredentialsSecret.TeamId {
+		return fmt.Errorf("refusing to revoke the token of team %q, the TFE credentials use it", previousTeamId)
+	}
+
+	tfeClient, err := tfc.GetTFEClientWithCredentials(tfeCredentialsSecret, http.Header{})
+	if err != nil {
+		return err
+	}
+
+	log.Default().Printf("revoking token of team %s", previousTeamId)
+	err = tfeClient.TeamTokens.Delete(ctx, previousTeamId)
+	if errors.Is(err, tfe.ErrResourceNotFound) {
+		log.Default().Printf("team %s has no token, it was already revoked", previousTeamId)
+		return nil
+	}
+	return tfc.Error(err)
 }
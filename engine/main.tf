@@ -7,6 +7,10 @@ terraform {
       source  = "hashicorp/aws"
       version = "5.12.0"
     }
+    random = {
+      source  = "hashicorp/random"
+      version = "3.5.1"
+    }
     tfe = {
       source  = "hashicorp/tfe"
       version = "0.45.0"
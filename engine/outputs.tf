@@ -20,3 +20,31 @@ output "tfc_organization" {
 output "tfc_hostname" {
   value = var.tfc_hostname
 }
+
+output "run_approval_topic_arn" {
+  value = aws_sns_topic.run_approval_requests.arn
+}
+
+output "token_rotation_alerts_topic_arn" {
+  value = aws_sns_topic.token_rotation_alerts.arn
+}
+
+output "run_decision_lambda_name" {
+  value = local.handle_run_decision_lambda_name
+}
+
+output "run_notification_url" {
+  value = var.enable_run_notifications ? aws_lambda_function_url.run_notification_handler[0].function_url : ""
+}
+
+output "state_archive_bucket_name" {
+  value = aws_s3_bucket.state_archive.id
+}
+
+output "workspace_adoption_lambda_name" {
+  value = aws_lambda_function.workspace_adoption.function_name
+}
+
+output "workspace_reconciliation_lambda_name" {
+  value = aws_lambda_function.workspace_reconciliation.function_name
+}
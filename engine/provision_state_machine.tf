@@ -36,7 +36,7 @@ data "aws_iam_policy_document" "policy_for_manage_provisioned_product" {
 
     actions = ["lambda:InvokeFunction"]
 
-    resources = [local.send_apply_lambda_arn, local.poll_run_status_lambda_arn, local.notify_run_result_lambda_arn, aws_lambda_function.parameter_parser.arn]
+    resources = compact([local.send_apply_lambda_arn, local.poll_run_status_lambda_arn, local.notify_run_result_lambda_arn, local.handle_run_decision_lambda_arn, local.register_run_waiter_lambda_arn, aws_lambda_function.parameter_parser.arn])
 
   }
 
@@ -124,6 +124,7 @@ resource "aws_sfn_state_machine" "provision_state_machine" {
       "Type": "Task",
       "Resource": "${local.send_apply_lambda_arn}",
       "Parameters": {
+        "schemaVersion": ${local.payload_schema_version},
         "awsAccountId.$": "$.identity.awsAccountId",
         "terraformOrganization.$": "$.terraformOrganization",
         "provisionedProductId.$": "$.provisionedProductId",
@@ -146,8 +147,39 @@ resource "aws_sfn_state_machine" "provision_state_machine" {
               "Next": "Notify run result failure"
           }
       ],
-      "Next": "Wait for apply to complete"
+      "Next": "Wait for apply ${local.run_wait_state_suffix}"
     },
+%{ if var.enable_run_notifications ~}
+    "Wait for apply notification": {
+      "Type": "Task",
+      "Comment": "Waits until TFC notifies the engine that the run completed, errored or needs attention, and falls back to polling the run when no notification arrives in time",
+      "Resource": "arn:aws:states:::lambda:invoke.waitForTaskToken",
+      "Parameters": {
+        "FunctionName": "${local.register_run_waiter_lambda_arn}",
+        "Payload": {
+          "taskToken.$": "$$.Task.Token",
+          "terraformRunId.$": "$.sendApplyResult.terraformRunId",
+          "terraformOrganization.$": "$.terraformOrganization",
+          "timeoutSeconds": ${var.run_notification_timeout_in_seconds}
+        }
+      },
+      "TimeoutSeconds": ${var.run_notification_timeout_in_seconds},
+      "ResultPath": null,
+      "Catch": [
+        {
+          "ErrorEquals": [ "States.Timeout" ],
+          "ResultPath": null,
+          "Next": "Poll run status"
+        },
+        {
+          "ErrorEquals": [ "States.ALL" ],
+          "ResultPath": null,
+          "Next": "Wait for apply to complete"
+        }
+      ],
+      "Next": "Poll run status"
+    },
+%{ endif ~}
     "Wait for apply to complete": {
       "Type": "Wait",
       "Seconds": 10,
@@ -157,7 +189,11 @@ resource "aws_sfn_state_machine" "provision_state_machine" {
       "Type": "Task",
       "Resource": "${local.poll_run_status_lambda_arn}",
       "Parameters": {
-        "terraformRunId.$": "$.sendApplyResult.terraformRunId"
+        "schemaVersion": ${local.payload_schema_version},
+        "terraformRunId.$": "$.sendApplyResult.terraformRunId",
+        "awsAccountId.$": "$.identity.awsAccountId",
+        "terraformOrganization.$": "$.terraformOrganization",
+        "provisionedProductId.$": "$.provisionedProductId"
       },
       "ResultPath": "$.pollRunResult",
       "Retry": [
@@ -193,13 +229,18 @@ resource "aws_sfn_state_machine" "provision_state_machine" {
         {
           "Variable": "$.pollRunResult.productProvisioningStatus",
           "StringEquals": "inProgress",
-          "Next": "Wait for apply to complete"
+          "Next": "Wait for apply ${local.run_wait_state_suffix}"
         },
         {
           "Variable": "$.pollRunResult.productProvisioningStatus",
           "StringEquals": "failed",
           "Next": "Convert poll run status error"
         },
+        {
+          "Variable": "$.pollRunResult.productProvisioningStatus",
+          "StringEquals": "awaitingApproval",
+          "Next": "Request apply approval"
+        },
         {
           "Variable": "$.pollRunResult.productProvisioningStatus",
           "StringEquals": "success",
@@ -208,6 +249,74 @@ resource "aws_sfn_state_machine" "provision_state_machine" {
       ],
       "Default": "Convert poll run status error"
     },
+    "Request apply approval": {
+      "Type": "Task",
+      "Comment": "Publishes an approval request for the run via the run decision Lambda function, and waits until the run is approved or rejected with the same function",
+      "Resource": "arn:aws:states:::lambda:invoke.waitForTaskToken",
+      "Parameters": {
+        "FunctionName": "${local.handle_run_decision_lambda_arn}",
+        "Payload": {
+          "taskToken.$": "$$.Task.Token",
+          "terraformRunId.$": "$.sendApplyResult.terraformRunId",
+          "terraformOrganization.$": "$.terraformOrganization",
+          "decision": "request",
+          "timeoutSeconds": ${var.run_approval_timeout_in_seconds},
+          "approvalRequest": {
+            "runUrl.$": "$.pollRunResult.runUrl",
+            "resourceCounts.$": "$.pollRunResult.resourceCounts",
+            "serviceCatalogOperation": "PROVISIONING",
+            "awsAccountId.$": "$.identity.awsAccountId",
+            "provisionedProductId.$": "$.provisionedProductId",
+            "recordId.$": "$.recordId"
+          }
+        }
+      },
+      "TimeoutSeconds": ${var.run_approval_timeout_in_seconds},
+      "ResultPath": "$.runDecisionResult",
+      "Catch": [
+        {
+          "ErrorEquals": [ "States.Timeout" ],
+          "ResultPath": "$.errorInfo",
+          "Next": "Expire apply approval"
+        },
+        {
+          "ErrorEquals": [ "States.ALL" ],
+          "ResultPath": "$.errorInfo",
+          "Next": "Notify run result failure"
+        }
+      ],
+      "Next": "Wait for apply ${local.run_wait_state_suffix}"
+    },
+    "Expire apply approval": {
+      "Type": "Task",
+      "Comment": "Discards the run in TFC, as it was not approved in time",
+      "Resource": "${local.handle_run_decision_lambda_arn}",
+      "Parameters": {
+        "terraformRunId.$": "$.sendApplyResult.terraformRunId",
+        "terraformOrganization.$": "$.terraformOrganization",
+        "decision": "expire"
+      },
+      "ResultPath": null,
+      "Catch": [
+        {
+          "ErrorEquals": [ "States.ALL" ],
+          "ResultPath": null,
+          "Next": "Convert apply approval timeout"
+        }
+      ],
+      "Next": "Convert apply approval timeout"
+    },
+    "Convert apply approval timeout": {
+        "Type": "Pass",
+        "Comment": "Restructures the approval timeout to a format the notify run result task understands",
+        "Parameters": {
+            "Error": "Run approval expired",
+            "Cause": "The run was not approved within ${var.run_approval_timeout_in_seconds} seconds, and was discarded in TFC",
+            "isWrapperError": true
+        },
+        "ResultPath": "$.errorInfo",
+        "Next": "Notify run result failure"
+    },
     "Convert poll run status error": {
         "Type": "Pass",
         "Comment": "Restructures error from the poll run status task to a format the notify run result task understands",
@@ -223,6 +332,7 @@ resource "aws_sfn_state_machine" "provision_state_machine" {
       "Type": "Task",
       "Resource": "${local.notify_run_result_lambda_arn}",
       "Parameters": {
+        "schemaVersion": ${local.payload_schema_version},
         "terraformRunId.$": "$.sendApplyResult.terraformRunId",
         "workflowToken.$": "$.token",
         "recordId.$": "$.recordId",
@@ -238,6 +348,7 @@ resource "aws_sfn_state_machine" "provision_state_machine" {
       "Type": "Task",
       "Resource": "${local.notify_run_result_lambda_arn}",
       "Parameters": {
+        "schemaVersion": ${local.payload_schema_version},
         "terraformRunId.$": "$.sendApplyResult.terraformRunId",
         "workflowToken.$": "$.token",
         "recordId.$": "$.recordId",
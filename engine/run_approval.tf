@@ -0,0 +1,9 @@
+# Copyright (c) HashiCorp, Inc.
+# SPDX-License-Identifier: MPL-2.0
+
+# Approval requests for runs awaiting confirmation are published to this topic. Each request contains the task token that
+# must be passed to the run decision Lambda function, together with an "approve" or "reject" decision.
+resource "aws_sns_topic" "run_approval_requests" {
+  name              = "ServiceCatalogTerraformCloudRunApprovalRequests"
+  kms_master_key_id = aws_kms_key.queue_key.key_id
+}
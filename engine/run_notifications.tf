@@ -0,0 +1,145 @@
+# Copyright (c) HashiCorp, Inc.
+# SPDX-License-Identifier: MPL-2.0
+
+# The token TFC signs run notifications with, so the webhook can verify they were sent by TFC
+resource "random_password" "run_notification_token" {
+  count   = var.enable_run_notifications ? 1 : 0
+  length  = 64
+  special = false
+}
+
+resource "aws_secretsmanager_secret" "run_notification_token" {
+  count = var.enable_run_notifications ? 1 : 0
+  name  = "terraform-cloud-run-notification-token-for-service-catalog-engine"
+}
+
+resource "aws_secretsmanager_secret_version" "run_notification_token" {
+  count         = var.enable_run_notifications ? 1 : 0
+  secret_id     = aws_secretsmanager_secret.run_notification_token[0].id
+  secret_string = random_password.run_notification_token[0].result
+}
+
+data "aws_iam_policy_document" "run_notification_handler_assume_role" {
+  statement {
+    effect = "Allow"
+
+    principals {
+      type        = "Service"
+      identifiers = ["lambda.amazonaws.com"]
+    }
+
+    actions = ["sts:AssumeRole"]
+  }
+}
+
+resource "aws_iam_role" "run_notification_handler" {
+  count              = var.enable_run_notifications ? 1 : 0
+  name               = "ServiceCatalogTerraformCloudRunNotificationHandlerRole"
+  assume_role_policy = data.aws_iam_policy_document.run_notification_handler_assume_role.json
+}
+
+resource "aws_iam_role_policy" "run_notification_handler" {
+  count  = var.enable_run_notifications ? 1 : 0
+  name   = "ServiceCatalogTerraformCloudRunNotificationHandlerPolicy"
+  role   = aws_iam_role.run_notification_handler[0].id
+  policy = data.aws_iam_policy_document.run_notification_handler[0].json
+}
+
+data "aws_iam_policy_document" "run_notification_handler" {
+  count   = var.enable_run_notifications ? 1 : 0
+  version = "2012-10-17"
+
+  statement {
+    sid = "NotificationTokenAccess"
+
+    effect = "Allow"
+
+    actions = ["secretsmanager:GetSecretValue"]
+
+    resources = [aws_secretsmanager_secret.run_notification_token[0].arn]
+  }
+
+  statement {
+    sid = "RunWaitersAccess"
+
+    effect = "Allow"
+
+    actions = ["dynamodb:GetItem", "dynamodb:DeleteItem"]
+
+    resources = [aws_dynamodb_table.engine_state.arn]
+
+    # The run waiters are stored next to the state of other features, which the function has no business with
+    condition {
+      test     = "ForAllValues:StringLike"
+      variable = "dynamodb:LeadingKeys"
+      values   = ["run-waiter#*"]
+    }
+  }
+
+  statement {
+    sid = "StepFunctionsTaskAccess"
+
+    effect = "Allow"
+
+    actions = ["states:SendTaskSuccess"]
+
+    # Task token callbacks are not authorized against the state machine, so they cannot be scoped to its ARN
+    resources = ["*"]
+  }
+}
+
+resource "aws_iam_role_policy_attachment" "run_notification_handler" {
+  for_each   = var.enable_run_notifications ? toset(["arn:aws:iam::aws:policy/AWSXrayWriteOnlyAccess", "arn:aws:iam::aws:policy/service-role/AWSLambdaBasicExecutionRole"]) : toset([])
+  role       = aws_iam_role.run_notification_handler[0].name
+  policy_arn = each.value
+}
+
+data "archive_file" "run_notification_handler" {
+  count       = var.enable_run_notifications ? 1 : 0
+  type        = "zip"
+  output_path = "dist/run_notification_handler.zip"
+  source_file = "${path.module}/lambda-functions/handle-run-notification/bootstrap"
+}
+
+resource "aws_cloudwatch_log_group" "run_notification_handler" {
+  count             = var.enable_run_notifications ? 1 : 0
+  name              = "/aws/lambda/ServiceCatalogTerraformCloudRunNotificationHandler"
+  retention_in_days = var.cloudwatch_log_retention_in_days
+}
+
+# Lambda that receives the run notifications of TFC, and resumes the state machine executions waiting for the runs
+resource "aws_lambda_function" "run_notification_handler" {
+  count         = var.enable_run_notifications ? 1 : 0
+  filename      = data.archive_file.run_notification_handler[0].output_path
+  function_name = "ServiceCatalogTerraformCloudRunNotificationHandler"
+  role          = aws_iam_role.run_notification_handler[0].arn
+  handler       = "bootstrap"
+  timeout       = 10
+
+  source_code_hash = data.archive_file.run_notification_handler[0].output_base64sha256
+
+  runtime       = "provided.al2"
+  architectures = ["arm64"]
+
+  environment {
+    variables = {
+      RUN_NOTIFICATION_TOKEN_SECRET_ID = aws_secretsmanager_secret.run_notification_token[0].arn
+      ENGINE_STATE_TABLE_NAME          = aws_dynamodb_table.engine_state.name
+    }
+  }
+
+  depends_on = [aws_cloudwatch_log_group.run_notification_handler]
+}
+
+# TFC sends the run notifications to this URL. The notifications are authenticated by their signature instead of IAM.
+resource "aws_lambda_function_url" "run_notification_handler" {
+  count              = var.enable_run_notifications ? 1 : 0
+  function_name      = aws_lambda_function.run_notification_handler[0].function_name
+  authorization_type = "NONE"
+}
+
+locals {
+  # The state machines wait for a run notification before polling the run, or only poll the run when TFC does not
+  # send run notifications
+  run_wait_state_suffix = var.enable_run_notifications ? "notification" : "to complete"
+}
@@ -0,0 +1,96 @@
+# Copyright (c) HashiCorp, Inc.
+# SPDX-License-Identifier: MPL-2.0
+
+# Keeps the final state and variables of the workspaces of terminated provisioned products, for audit and recovery
+resource "aws_kms_key" "state_archive" {
+  description             = "symmetric encryption KMS key for the archived state of terminated provisioned products"
+  enable_key_rotation     = true
+  deletion_window_in_days = 30
+}
+
+resource "aws_s3_bucket" "state_archive" {
+  bucket_prefix = "service-catalog-tfc-state-archive-"
+}
+
+resource "aws_s3_bucket_public_access_block" "state_archive" {
+  bucket = aws_s3_bucket.state_archive.id
+
+  block_public_acls       = true
+  block_public_policy     = true
+  ignore_public_acls      = true
+  restrict_public_buckets = true
+}
+
+resource "aws_s3_bucket_server_side_encryption_configuration" "state_archive" {
+  bucket = aws_s3_bucket.state_archive.id
+
+  rule {
+    apply_server_side_encryption_by_default {
+      kms_master_key_id = aws_kms_key.state_archive.arn
+      sse_algorithm     = "aws:kms"
+    }
+    bucket_key_enabled = true
+  }
+}
+
+resource "aws_s3_bucket_versioning" "state_archive" {
+  bucket = aws_s3_bucket.state_archive.id
+
+  versioning_configuration {
+    status = "Enabled"
+  }
+}
+
+resource "aws_s3_bucket_lifecycle_configuration" "state_archive" {
+  bucket = aws_s3_bucket.state_archive.id
+
+  rule {
+    id     = "expire-archived-state"
+    status = "Enabled"
+
+    filter {}
+
+    expiration {
+      days = var.state_archive_retention_in_days
+    }
+
+    noncurrent_version_expiration {
+      noncurrent_days = var.state_archive_retention_in_days
+    }
+  }
+
+  depends_on = [aws_s3_bucket_versioning.state_archive]
+}
+
+data "aws_iam_policy_document" "state_archive_bucket_policy" {
+  statement {
+    sid = "DenyInsecureTransport"
+
+    effect = "Deny"
+
+    principals {
+      type        = "*"
+      identifiers = ["*"]
+    }
+
+    actions = ["s3:*"]
+
+    resources = [
+      aws_s3_bucket.state_archive.arn,
+      "${aws_s3_bucket.state_archive.arn}/*"
+    ]
+
+    condition {
+      test     = "Bool"
+      variable = "aws:SecureTransport"
+      values   = ["false"]
+    }
+  }
+}
+
+resource "aws_s3_bucket_policy" "state_archive" {
+  bucket = aws_s3_bucket.state_archive.id
+  policy = data.aws_iam_policy_document.state_archive_bucket_policy.json
+
+  depends_on = [aws_s3_bucket_public_access_block.state_archive]
+}
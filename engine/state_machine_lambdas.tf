@@ -23,7 +23,21 @@ data "aws_iam_policy_document" "send_apply" {
 
     actions = ["secretsmanager:GetSecretValue"]
 
-    resources = [aws_secretsmanager_secret.team_token_values.arn]
+    resources = local.tfc_credentials_secret_arns
+  }
+
+  dynamic "statement" {
+    for_each = aws_secretsmanager_secret.run_notification_token[*].arn
+
+    content {
+      sid = "runNotificationTokenAccess"
+
+      effect = "Allow"
+
+      actions = ["secretsmanager:GetSecretValue"]
+
+      resources = [statement.value]
+    }
   }
 }
 
@@ -37,7 +51,7 @@ data "aws_iam_policy_document" "send_destroy" {
 
     actions = ["secretsmanager:GetSecretValue"]
 
-    resources = [aws_secretsmanager_secret.team_token_values.arn]
+    resources = local.tfc_credentials_secret_arns
   }
 }
 
@@ -51,7 +65,7 @@ data "aws_iam_policy_document" "poll_run_status" {
 
     actions = ["secretsmanager:GetSecretValue"]
 
-    resources = [aws_secretsmanager_secret.team_token_values.arn]
+    resources = local.tfc_credentials_secret_arns
   }
 }
 
@@ -80,22 +94,156 @@ data "aws_iam_policy_document" "notify_run_result" {
 
     actions = ["secretsmanager:GetSecretValue"]
 
-    resources = [aws_secretsmanager_secret.team_token_values.arn]
+    resources = local.tfc_credentials_secret_arns
+  }
+
+  statement {
+    sid = "StateArchiveAccess"
+
+    effect = "Allow"
+
+    actions = ["s3:PutObject"]
+
+    resources = ["${aws_s3_bucket.state_archive.arn}/*"]
+  }
+
+  statement {
+    sid = "StateArchiveKeyAccess"
+
+    effect = "Allow"
+
+    actions = ["kms:GenerateDataKey", "kms:Decrypt"]
+
+    resources = [aws_kms_key.state_archive.arn]
+  }
+}
+
+data "aws_iam_policy_document" "handle_run_decision" {
+  version = "2012-10-17"
+
+  statement {
+    sid = "StepFunctionsTaskAccess"
+
+    effect = "Allow"
+
+    actions = ["states:SendTaskSuccess", "states:SendTaskFailure"]
+
+    # Task token callbacks are not authorized against the state machine, so they cannot be scoped to its ARN
+    resources = ["*"]
+  }
+
+  statement {
+    sid = "RunApprovalsAccess"
+
+    effect = "Allow"
+
+    actions = ["dynamodb:GetItem", "dynamodb:PutItem", "dynamodb:DeleteItem"]
+
+    resources = [aws_dynamodb_table.engine_state.arn]
+
+    # The task tokens of runs awaiting approval are stored next to the state of other features, which the function has no business with
+    condition {
+      test     = "ForAllValues:StringLike"
+      variable = "dynamodb:LeadingKeys"
+      values   = ["run-approval#*"]
+    }
+  }
+
+  statement {
+    sid = "RunApprovalPermissions"
+
+    effect = "Allow"
+
+    actions = ["sns:Publish"]
+
+    resources = [aws_sns_topic.run_approval_requests.arn]
+  }
+
+  statement {
+    sid = "RunApprovalEncryptionPermissions"
+
+    effect = "Allow"
+
+    actions = ["kms:Decrypt", "kms:GenerateDataKey"]
+
+    resources = [aws_kms_key.queue_key.arn]
+  }
+
+  statement {
+    sid = "tfeCredentialsAccess"
+
+    effect = "Allow"
+
+    actions = ["secretsmanager:GetSecretValue"]
+
+    resources = local.tfc_credentials_secret_arns
+  }
+}
+
+data "aws_iam_policy_document" "register_run_waiter" {
+  count   = var.enable_run_notifications ? 1 : 0
+  version = "2012-10-17"
+
+  statement {
+    sid = "RunWaitersAccess"
+
+    effect = "Allow"
+
+    actions = ["dynamodb:GetItem", "dynamodb:PutItem", "dynamodb:DeleteItem"]
+
+    resources = [aws_dynamodb_table.engine_state.arn]
+
+    # The run waiters are stored next to the state of other features, which the function has no business with
+    condition {
+      test     = "ForAllValues:StringLike"
+      variable = "dynamodb:LeadingKeys"
+      values   = ["run-waiter#*"]
+    }
+  }
+
+  statement {
+    sid = "StepFunctionsTaskAccess"
+
+    effect = "Allow"
+
+    actions = ["states:SendTaskSuccess"]
+
+    # Task token callbacks are not authorized against the state machine, so they cannot be scoped to its ARN
+    resources = ["*"]
+  }
+
+  statement {
+    sid = "tfeCredentialsAccess"
+
+    effect = "Allow"
+
+    actions = ["secretsmanager:GetSecretValue"]
+
+    resources = local.tfc_credentials_secret_arns
   }
 }
 
 # Lambda Functions
 
 locals {
+  # Version of the payloads the state machines send the Lambda functions, which must match SchemaVersion in the
+  # shared/model package of the Lambda functions
+  payload_schema_version = 1
+
   default_lambda_function_timeout     = 60
   default_lambda_function_memory_size = 128
 
-  send_apply_lambda_name        = "ServiceCatalogEngineForTerraformCloudSendApply"
-  send_destroy_lambda_name      = "ServiceCatalogEngineForTerraformCloudSendDestroy"
-  poll_run_status_lambda_name   = "ServiceCatalogEngineForTerraformCloudPollRunStatus"
-  notify_run_result_lambda_name = "ServiceCatalogEngineForTerraformCloudNotifyRunResult"
+  send_apply_lambda_name          = "ServiceCatalogEngineForTerraformCloudSendApply"
+  send_destroy_lambda_name        = "ServiceCatalogEngineForTerraformCloudSendDestroy"
+  poll_run_status_lambda_name     = "ServiceCatalogEngineForTerraformCloudPollRunStatus"
+  notify_run_result_lambda_name   = "ServiceCatalogEngineForTerraformCloudNotifyRunResult"
+  handle_run_decision_lambda_name = "ServiceCatalogEngineForTerraformCloudHandleRunDecision"
+  register_run_waiter_lambda_name = "ServiceCatalogEngineForTerraformCloudRegisterRunWaiter"
 
-  lambda_functions = {
+  # The run waiter is only registered by the state machines when TFC sends run notifications
+  lambda_functions = { for name, function in local.all_lambda_functions : name => function if name != local.register_run_waiter_lambda_name || var.enable_run_notifications }
+
+  all_lambda_functions = {
     (local.send_apply_lambda_name) : {
       policy_document = data.aws_iam_policy_document.send_apply.json
       source_file     = "${path.module}/lambda-functions/send-apply/bootstrap"
@@ -115,6 +263,14 @@ locals {
       policy_document = data.aws_iam_policy_document.notify_run_result.json
       source_file     = "${path.module}/lambda-functions/notify-run-result/bootstrap"
     }
+    (local.handle_run_decision_lambda_name) : {
+      policy_document = data.aws_iam_policy_document.handle_run_decision.json
+      source_file     = "${path.module}/lambda-functions/handle-run-decision/bootstrap"
+    }
+    (local.register_run_waiter_lambda_name) : {
+      policy_document = var.enable_run_notifications ? data.aws_iam_policy_document.register_run_waiter[0].json : ""
+      source_file     = "${path.module}/lambda-functions/register-run-waiter/bootstrap"
+    }
   }
 }
 
@@ -189,8 +345,19 @@ resource "aws_lambda_function" "state_machine_lambda" {
 
   environment {
     variables = {
-      TFE_CREDENTIALS_SECRET_ID = aws_secretsmanager_secret.team_token_values.arn
-      TERRAFORM_VERSION         = var.terraform_version
+      TFE_CREDENTIALS_SECRET_ID               = aws_secretsmanager_secret.team_token_values.arn
+      TFE_ORGANIZATION_CREDENTIALS_SECRET_IDS = jsonencode(var.tfc_organization_credentials_secret_arns)
+      TERRAFORM_ORGANIZATION                  = var.tfc_organization
+      TERRAFORM_VERSION                       = var.terraform_version
+      REQUIRE_RUN_APPROVAL                    = var.require_run_approval
+      ENGINE_STATE_TABLE_NAME                 = aws_dynamodb_table.engine_state.name
+      RUN_APPROVAL_TOPIC_ARN                  = aws_sns_topic.run_approval_requests.arn
+      RUN_NOTIFICATION_TOKEN_SECRET_ID        = var.enable_run_notifications ? aws_secretsmanager_secret.run_notification_token[0].arn : ""
+      RUN_NOTIFICATION_URL                    = var.enable_run_notifications ? aws_lambda_function_url.run_notification_handler[0].function_url : ""
+      RECORD_OUTPUT_PREFIX                    = var.record_output_prefix
+      STATE_ARCHIVE_BUCKET_NAME               = aws_s3_bucket.state_archive.id
+      STATE_ARCHIVE_KMS_KEY_ID                = aws_kms_key.state_archive.arn
+      TERMINATED_WORKSPACE_RETENTION_IN_DAYS  = var.terminated_workspace_retention_in_days
     }
   }
 
@@ -204,10 +371,12 @@ resource "aws_lambda_function" "state_machine_lambda" {
 
 locals {
   # ARNs for each of the Lambda functions created in this file (for resources in other files to reference easily)
-  send_apply_lambda_arn        = lookup(aws_lambda_function.state_machine_lambda, local.send_apply_lambda_name, { arn : "" }).arn
-  send_destroy_lambda_arn      = lookup(aws_lambda_function.state_machine_lambda, local.send_destroy_lambda_name, { arn : "" }).arn
-  poll_run_status_lambda_arn   = lookup(aws_lambda_function.state_machine_lambda, local.poll_run_status_lambda_name, { arn : "" }).arn
-  notify_run_result_lambda_arn = lookup(aws_lambda_function.state_machine_lambda, local.notify_run_result_lambda_name, { arn : "" }).arn
+  send_apply_lambda_arn          = lookup(aws_lambda_function.state_machine_lambda, local.send_apply_lambda_name, { arn : "" }).arn
+  send_destroy_lambda_arn        = lookup(aws_lambda_function.state_machine_lambda, local.send_destroy_lambda_name, { arn : "" }).arn
+  poll_run_status_lambda_arn     = lookup(aws_lambda_function.state_machine_lambda, local.poll_run_status_lambda_name, { arn : "" }).arn
+  notify_run_result_lambda_arn   = lookup(aws_lambda_function.state_machine_lambda, local.notify_run_result_lambda_name, { arn : "" }).arn
+  handle_run_decision_lambda_arn = lookup(aws_lambda_function.state_machine_lambda, local.handle_run_decision_lambda_name, { arn : "" }).arn
+  register_run_waiter_lambda_arn = lookup(aws_lambda_function.state_machine_lambda, local.register_run_waiter_lambda_name, { arn : "" }).arn
 
   # ARNs of the IAM roles for some of the Lambda functions created in this file (for resources in other files to reference easily)
   send_apply_lambda_role_arn = lookup(aws_iam_role.state_machine_lambda, local.send_apply_lambda_name, { arn : "" }).arn
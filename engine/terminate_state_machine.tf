@@ -36,7 +36,7 @@ data "aws_iam_policy_document" "terminate_state_machine" {
 
     actions = ["lambda:InvokeFunction"]
 
-    resources = [local.send_destroy_lambda_arn, local.poll_run_status_lambda_arn, local.notify_run_result_lambda_arn, aws_lambda_function.parameter_parser.arn]
+    resources = compact([local.send_destroy_lambda_arn, local.poll_run_status_lambda_arn, local.notify_run_result_lambda_arn, local.handle_run_decision_lambda_arn, local.register_run_waiter_lambda_arn, aws_lambda_function.parameter_parser.arn])
 
   }
 
@@ -115,7 +115,8 @@ resource "aws_sfn_state_machine" "terminate_state_machine" {
       "Type": "Pass",
       "Comment": "Set default values for state so that future steps do not error on missing parameters",
       "Parameters": {
-        "terraformRunId": ""
+        "terraformRunId": "",
+        "workspaceMissing": false
       },
       "ResultPath": "$.sendDestroyResult",
       "Next": "Send destroy"
@@ -124,12 +125,14 @@ resource "aws_sfn_state_machine" "terminate_state_machine" {
       "Type": "Task",
       "Resource": "${local.send_destroy_lambda_arn}",
       "Parameters": {
+        "schemaVersion": ${local.payload_schema_version},
         "awsAccountId.$": "$.identity.awsAccountId",
         "terraformOrganization.$": "$.terraformOrganization",
         "provisionedProductId.$": "$.provisionedProductId"
       },
       "ResultSelector": {
-        "terraformRunId.$": "$.terraformRunId"
+        "terraformRunId.$": "$.terraformRunId",
+        "workspaceMissing.$": "$.workspaceMissing"
       },
       "ResultPath": "$.sendDestroyResult",
       "Catch": [
@@ -139,8 +142,51 @@ resource "aws_sfn_state_machine" "terminate_state_machine" {
               "Next": "Notify destroy result failure"
           }
       ],
-      "Next": "Wait for destroy to complete"
+      "Next": "Was there anything to destroy?"
     },
+    "Was there anything to destroy?": {
+      "Type": "Choice",
+      "Comment": "Skips the destroy run when the workspace does not exist, e.g. because provisioning failed before it was created, as the provisioned product is already terminated",
+      "Choices": [
+        {
+          "Variable": "$.sendDestroyResult.workspaceMissing",
+          "BooleanEquals": true,
+          "Next": "Notify destroy result"
+        }
+      ],
+      "Default": "Wait for destroy ${local.run_wait_state_suffix}"
+    },
+%{ if var.enable_run_notifications ~}
+    "Wait for destroy notification": {
+      "Type": "Task",
+      "Comment": "Waits until TFC notifies the engine that the run completed, errored or needs attention, and falls back to polling the run when no notification arrives in time",
+      "Resource": "arn:aws:states:::lambda:invoke.waitForTaskToken",
+      "Parameters": {
+        "FunctionName": "${local.register_run_waiter_lambda_arn}",
+        "Payload": {
+          "taskToken.$": "$$.Task.Token",
+          "terraformRunId.$": "$.sendDestroyResult.terraformRunId",
+          "terraformOrganization.$": "$.terraformOrganization",
+          "timeoutSeconds": ${var.run_notification_timeout_in_seconds}
+        }
+      },
+      "TimeoutSeconds": ${var.run_notification_timeout_in_seconds},
+      "ResultPath": null,
+      "Catch": [
+        {
+          "ErrorEquals": [ "States.Timeout" ],
+          "ResultPath": null,
+          "Next": "Poll destroy status"
+        },
+        {
+          "ErrorEquals": [ "States.ALL" ],
+          "ResultPath": null,
+          "Next": "Wait for destroy to complete"
+        }
+      ],
+      "Next": "Poll destroy status"
+    },
+%{ endif ~}
     "Wait for destroy to complete": {
       "Type": "Wait",
       "Seconds": 10,
@@ -150,7 +196,11 @@ resource "aws_sfn_state_machine" "terminate_state_machine" {
       "Type": "Task",
       "Resource": "${local.poll_run_status_lambda_arn}",
       "Parameters": {
-        "terraformRunId.$": "$.sendDestroyResult.terraformRunId"
+        "schemaVersion": ${local.payload_schema_version},
+        "terraformRunId.$": "$.sendDestroyResult.terraformRunId",
+        "awsAccountId.$": "$.identity.awsAccountId",
+        "terraformOrganization.$": "$.terraformOrganization",
+        "provisionedProductId.$": "$.provisionedProductId"
       },
       "ResultPath": "$.pollRunResult",
       "Retry": [
@@ -186,13 +236,18 @@ resource "aws_sfn_state_machine" "terminate_state_machine" {
         {
           "Variable": "$.pollRunResult.productProvisioningStatus",
           "StringEquals": "inProgress",
-          "Next": "Wait for destroy to complete"
+          "Next": "Wait for destroy ${local.run_wait_state_suffix}"
         },
         {
           "Variable": "$.pollRunResult.productProvisioningStatus",
           "StringEquals": "failed",
           "Next": "Convert poll destroy status error"
         },
+        {
+          "Variable": "$.pollRunResult.productProvisioningStatus",
+          "StringEquals": "awaitingApproval",
+          "Next": "Request destroy approval"
+        },
         {
           "Variable": "$.pollRunResult.productProvisioningStatus",
           "StringEquals": "success",
@@ -201,6 +256,74 @@ resource "aws_sfn_state_machine" "terminate_state_machine" {
       ],
       "Default": "Convert poll destroy status error"
     },
+    "Request destroy approval": {
+      "Type": "Task",
+      "Comment": "Publishes an approval request for the run via the run decision Lambda function, and waits until the run is approved or rejected with the same function",
+      "Resource": "arn:aws:states:::lambda:invoke.waitForTaskToken",
+      "Parameters": {
+        "FunctionName": "${local.handle_run_decision_lambda_arn}",
+        "Payload": {
+          "taskToken.$": "$$.Task.Token",
+          "terraformRunId.$": "$.sendDestroyResult.terraformRunId",
+          "terraformOrganization.$": "$.terraformOrganization",
+          "decision": "request",
+          "timeoutSeconds": ${var.run_approval_timeout_in_seconds},
+          "approvalRequest": {
+            "runUrl.$": "$.pollRunResult.runUrl",
+            "resourceCounts.$": "$.pollRunResult.resourceCounts",
+            "serviceCatalogOperation": "TERMINATING",
+            "awsAccountId.$": "$.identity.awsAccountId",
+            "provisionedProductId.$": "$.provisionedProductId",
+            "recordId.$": "$.recordId"
+          }
+        }
+      },
+      "TimeoutSeconds": ${var.run_approval_timeout_in_seconds},
+      "ResultPath": "$.runDecisionResult",
+      "Catch": [
+        {
+          "ErrorEquals": [ "States.Timeout" ],
+          "ResultPath": "$.errorInfo",
+          "Next": "Expire destroy approval"
+        },
+        {
+          "ErrorEquals": [ "States.ALL" ],
+          "ResultPath": "$.errorInfo",
+          "Next": "Notify destroy result failure"
+        }
+      ],
+      "Next": "Wait for destroy ${local.run_wait_state_suffix}"
+    },
+    "Expire destroy approval": {
+      "Type": "Task",
+      "Comment": "Discards the run in TFC, as it was not approved in time",
+      "Resource": "${local.handle_run_decision_lambda_arn}",
+      "Parameters": {
+        "terraformRunId.$": "$.sendDestroyResult.terraformRunId",
+        "terraformOrganization.$": "$.terraformOrganization",
+        "decision": "expire"
+      },
+      "ResultPath": null,
+      "Catch": [
+        {
+          "ErrorEquals": [ "States.ALL" ],
+          "ResultPath": null,
+          "Next": "Convert destroy approval timeout"
+        }
+      ],
+      "Next": "Convert destroy approval timeout"
+    },
+    "Convert destroy approval timeout": {
+        "Type": "Pass",
+        "Comment": "Restructures the approval timeout to a format the notify run result task understands",
+        "Parameters": {
+            "Error": "Run approval expired",
+            "Cause": "The run was not approved within ${var.run_approval_timeout_in_seconds} seconds, and was discarded in TFC",
+            "isWrapperError": true
+        },
+        "ResultPath": "$.errorInfo",
+        "Next": "Notify destroy result failure"
+    },
     "Convert poll destroy status error": {
         "Type": "Pass",
         "Comment": "Restructures error from the poll destroy status task to a format the notify run result task understands",
@@ -216,6 +339,7 @@ resource "aws_sfn_state_machine" "terminate_state_machine" {
       "Type": "Task",
       "Resource": "${local.notify_run_result_lambda_arn}",
       "Parameters": {
+        "schemaVersion": ${local.payload_schema_version},
         "terraformRunId.$": "$.sendDestroyResult.terraformRunId",
         "workflowToken.$": "$.token",
         "recordId.$": "$.recordId",
@@ -231,6 +355,7 @@ resource "aws_sfn_state_machine" "terminate_state_machine" {
       "Type": "Task",
       "Resource": "${local.notify_run_result_lambda_arn}",
       "Parameters": {
+        "schemaVersion": ${local.payload_schema_version},
         "terraformRunId.$": "$.sendDestroyResult.terraformRunId",
         "workflowToken.$": "$.token",
         "recordId.$": "$.recordId",
@@ -19,6 +19,19 @@ resource "tfe_team_token" "test_team_token" {
   team_id = tfe_team.provisioning_team.id
 }
 
+# Team the standby team token rotation alternates with, so a new token can be created without invalidating the token
+# that is in use
+resource "tfe_team" "standby_provisioning_team" {
+  count = var.token_rotation_mode == "standby_team" ? 1 : 0
+
+  name         = "${var.tfc_team}-standby"
+  organization = data.tfe_organization.organization.name
+  organization_access {
+    manage_projects   = true
+    manage_workspaces = true
+  }
+}
+
 resource "aws_secretsmanager_secret" "team_token_values" {
   name = "terraform-cloud-credentials-for-service-catalog-engine"
 }
@@ -31,3 +44,9 @@ resource "aws_secretsmanager_secret_version" "tfc_credentials" {
     token    = tfe_team_token.test_team_token.token
   })
 }
+
+locals {
+  # ARNs of the secrets holding TFE credentials, the default one first, followed by those of the organizations with their
+  # own TFE credentials
+  tfc_credentials_secret_arns = concat([aws_secretsmanager_secret.team_token_values.arn], values(var.tfc_organization_credentials_secret_arns))
+}
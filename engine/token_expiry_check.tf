@@ -0,0 +1,132 @@
+# Copyright (c) HashiCorp, Inc.
+# SPDX-License-Identifier: MPL-2.0
+
+data "aws_iam_policy_document" "token_expiry_check_assume_role" {
+  statement {
+    effect = "Allow"
+
+    principals {
+      type        = "Service"
+      identifiers = ["lambda.amazonaws.com"]
+    }
+
+    actions = ["sts:AssumeRole"]
+  }
+}
+
+resource "aws_iam_role" "token_expiry_check" {
+  name               = "ServiceCatalogTerraformCloudTokenExpiryCheckRole"
+  assume_role_policy = data.aws_iam_policy_document.token_expiry_check_assume_role.json
+}
+
+resource "aws_iam_role_policy" "token_expiry_check" {
+  name   = "ServiceCatalogTerraformCloudTokenExpiryCheckPolicy"
+  role   = aws_iam_role.token_expiry_check.id
+  policy = data.aws_iam_policy_document.token_expiry_check.json
+}
+
+data "aws_cloudwatch_event_bus" "default" {
+  name = "default"
+}
+
+data "aws_iam_policy_document" "token_expiry_check" {
+  version = "2012-10-17"
+
+  statement {
+    sid = "tfeCredentialsAccess"
+
+    effect = "Allow"
+
+    actions = ["secretsmanager:GetSecretValue"]
+
+    resources = local.tfc_credentials_secret_arns
+  }
+
+  statement {
+    sid = "TokenAlertEventsAccess"
+
+    effect = "Allow"
+
+    actions = ["events:PutEvents"]
+
+    resources = [data.aws_cloudwatch_event_bus.default.arn]
+  }
+
+  statement {
+    sid = "TokenMetricsAccess"
+
+    effect = "Allow"
+
+    actions = ["cloudwatch:PutMetricData"]
+
+    resources = ["*"]
+
+    condition {
+      test     = "StringEquals"
+      variable = "cloudwatch:namespace"
+      values   = ["ServiceCatalogTerraformCloud"]
+    }
+  }
+}
+
+resource "aws_iam_role_policy_attachment" "token_expiry_check" {
+  for_each   = toset(["arn:aws:iam::aws:policy/AWSXrayWriteOnlyAccess", "arn:aws:iam::aws:policy/service-role/AWSLambdaBasicExecutionRole"])
+  role       = aws_iam_role.token_expiry_check.name
+  policy_arn = each.value
+}
+
+data "archive_file" "token_expiry_check" {
+  type        = "zip"
+  output_path = "dist/token_expiry_check.zip"
+  source_file = "${path.module}/lambda-functions/check-token-expiry/bootstrap"
+}
+
+resource "aws_cloudwatch_log_group" "token_expiry_check" {
+  name              = "/aws/lambda/ServiceCatalogTerraformCloudTokenExpiryCheck"
+  retention_in_days = var.cloudwatch_log_retention_in_days
+}
+
+# Lambda that reports the age and expiry of the team token as CloudWatch metrics, and sends an EventBridge event when
+# the token expires soon or the rotation is overdue
+resource "aws_lambda_function" "token_expiry_check" {
+  filename      = data.archive_file.token_expiry_check.output_path
+  function_name = "ServiceCatalogTerraformCloudTokenExpiryCheck"
+  role          = aws_iam_role.token_expiry_check.arn
+  handler       = "bootstrap"
+
+  source_code_hash = data.archive_file.token_expiry_check.output_base64sha256
+
+  runtime       = "provided.al2"
+  architectures = ["arm64"]
+
+  environment {
+    variables = {
+      TFE_CREDENTIALS_SECRET_ID               = aws_secretsmanager_secret.team_token_values.arn
+      TFE_ORGANIZATION_CREDENTIALS_SECRET_IDS = jsonencode(var.tfc_organization_credentials_secret_arns)
+      TERRAFORM_ORGANIZATION                  = var.tfc_organization
+      TOKEN_ROTATION_INTERVAL_IN_DAYS         = var.token_rotation_interval_in_days
+      TOKEN_EXPIRY_WARNING_IN_DAYS            = var.token_expiry_warning_in_days
+    }
+  }
+
+  depends_on = [aws_cloudwatch_log_group.token_expiry_check]
+}
+
+resource "aws_cloudwatch_event_rule" "token_expiry_check_schedule" {
+  name                = "ServiceCatalogTerraformCloudTokenExpiryCheck"
+  description         = "Schedule for checking the expiry of the team token"
+  schedule_expression = "rate(1 day)"
+}
+
+resource "aws_cloudwatch_event_target" "token_expiry_check" {
+  rule = aws_cloudwatch_event_rule.token_expiry_check_schedule.name
+  arn  = aws_lambda_function.token_expiry_check.arn
+}
+
+resource "aws_lambda_permission" "token_expiry_check_schedule" {
+  statement_id  = "AllowExecutionFromEventBridge"
+  action        = "lambda:InvokeFunction"
+  function_name = aws_lambda_function.token_expiry_check.function_name
+  principal     = "events.amazonaws.com"
+  source_arn    = aws_cloudwatch_event_rule.token_expiry_check_schedule.arn
+}
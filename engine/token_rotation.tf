@@ -41,7 +41,7 @@ data "aws_iam_policy_document" "policy_for_rotate_team_token_handler" {
       "secretsmanager:UpdateSecret"
     ]
 
-    resources = ["${aws_secretsmanager_secret.team_token_values.arn}*"]
+    resources = formatlist("%s*", local.tfc_credentials_secret_arns)
   }
 
   statement {
@@ -72,12 +72,23 @@ data "aws_iam_policy_document" "policy_for_rotate_team_token_handler" {
     effect = "Allow"
 
     actions = [
-      "lambda:ListEventSourceMappings"
+      "lambda:ListEventSourceMappings",
+      "lambda:GetEventSourceMapping"
     ]
 
     resources = ["*"]
   }
 
+  statement {
+    sid = "rotationProgress"
+
+    effect = "Allow"
+
+    actions = ["dynamodb:GetItem", "dynamodb:PutItem", "dynamodb:DeleteItem"]
+
+    resources = [aws_dynamodb_table.engine_state.arn]
+  }
+
   statement {
     sid = "pollStateMachines"
 
@@ -120,20 +131,51 @@ resource "aws_lambda_function" "rotate_token_handler" {
   runtime       = "provided.al2"
   architectures = ["arm64"]
 
+  # Secrets Manager rotation pauses the queues and waits for the executions to finish within a single invocation
+  timeout = 900
+
   environment {
     variables = {
-      PROVISIONING_STATE_MACHINE_ARN = aws_sfn_state_machine.provision_state_machine.arn,
-      UPDATING_STATE_MACHINE_ARN     = aws_sfn_state_machine.update_state_machine.arn,
-      TERMINATING_STATE_MACHINE_ARN  = aws_sfn_state_machine.terminate_state_machine.arn,
-      PROVISIONING_FUNCTION_NAME     = aws_lambda_function.provision_handler.function_name,
-      UPDATING_FUNCTION_NAME         = aws_lambda_function.update_handler.function_name,
-      TERMINATING_FUNCTION_NAME      = aws_lambda_function.terminate_handler.function_name,
-      TEAM_ID                        = tfe_team.provisioning_team.id,
-      TFE_CREDENTIALS_SECRET_ID      = aws_secretsmanager_secret.team_token_values.arn
+      PROVISIONING_STATE_MACHINE_ARN          = aws_sfn_state_machine.provision_state_machine.arn,
+      UPDATING_STATE_MACHINE_ARN              = aws_sfn_state_machine.update_state_machine.arn,
+      TERMINATING_STATE_MACHINE_ARN           = aws_sfn_state_machine.terminate_state_machine.arn,
+      PROVISIONING_FUNCTION_NAME              = aws_lambda_function.provision_handler.function_name,
+      UPDATING_FUNCTION_NAME                  = aws_lambda_function.update_handler.function_name,
+      TERMINATING_FUNCTION_NAME               = aws_lambda_function.terminate_handler.function_name,
+      TEAM_ID                                 = tfe_team.provisioning_team.id,
+      STANDBY_TEAM_ID                         = var.token_rotation_mode == "standby_team" ? tfe_team.standby_provisioning_team[0].id : "",
+      TFE_CREDENTIALS_SECRET_ID               = aws_secretsmanager_secret.team_token_values.arn
+      TFE_ORGANIZATION_CREDENTIALS_SECRET_IDS = jsonencode(var.tfc_organization_credentials_secret_arns)
+      ENGINE_STATE_TABLE_NAME                 = aws_dynamodb_table.engine_state.name
+      TOKEN_EXPIRY_IN_DAYS                    = var.token_rotation_interval_in_days + var.token_expiry_margin_in_days
+      MAX_DRAIN_TIME_IN_MINUTES               = var.token_rotation_max_drain_in_minutes
+      STUCK_EXECUTION_POLICY                  = var.token_rotation_stuck_execution_policy
     }
   }
 }
 
+# Native Secrets Manager rotation of the TFE credentials, when enabled
+resource "aws_lambda_permission" "secrets_manager_rotate_token" {
+  count         = var.use_secrets_manager_rotation ? 1 : 0
+  statement_id  = "AllowSecretsManagerRotation"
+  action        = "lambda:InvokeFunction"
+  function_name = aws_lambda_function.rotate_token_handler.function_name
+  principal     = "secretsmanager.amazonaws.com"
+  source_arn    = aws_secretsmanager_secret.team_token_values.arn
+}
+
+resource "aws_secretsmanager_secret_rotation" "team_token_values" {
+  count               = var.use_secrets_manager_rotation ? 1 : 0
+  secret_id           = aws_secretsmanager_secret.team_token_values.id
+  rotation_lambda_arn = aws_lambda_function.rotate_token_handler.arn
+
+  rotation_rules {
+    automatically_after_days = var.token_rotation_interval_in_days
+  }
+
+  depends_on = [aws_lambda_permission.secrets_manager_rotate_token]
+}
+
 data "aws_iam_policy_document" "rotate_team_token" {
   statement {
     effect = "Allow"
@@ -147,6 +189,12 @@ data "aws_iam_policy_document" "rotate_team_token" {
   }
 }
 
+# Failed token rotations are published to this topic, subscribe to it to be alerted
+resource "aws_sns_topic" "token_rotation_alerts" {
+  name              = "ServiceCatalogTerraformCloudTokenRotationAlerts"
+  kms_master_key_id = aws_kms_key.queue_key.key_id
+}
+
 resource "aws_iam_role" "rotate_token_state_machine" {
   name               = "ServiceCatalogTerraformCloudTokenRotationStateMachineRole"
   assume_role_policy = data.aws_iam_policy_document.rotate_team_token.json
@@ -162,6 +210,28 @@ resource "aws_iam_role_policy" "rotate_team_token_state_machine_role_policy" {
 data "aws_iam_policy_document" "policy_for_rotate_team_token_state_machine" {
   version = "2012-10-17"
 
+  statement {
+    sid = "TokenRotationAlertPermissions"
+
+    effect = "Allow"
+
+    actions = ["sns:Publish"]
+
+    resources = [aws_sns_topic.token_rotation_alerts.arn]
+
+  }
+
+  statement {
+    sid = "TokenRotationAlertEncryptionPermissions"
+
+    effect = "Allow"
+
+    actions = ["kms:Decrypt", "kms:GenerateDataKey"]
+
+    resources = [aws_kms_key.queue_key.arn]
+
+  }
+
   statement {
     sid = "LambdaInvocationPermissions"
 
@@ -200,6 +270,9 @@ resource "aws_cloudwatch_event_rule" "rotate_token_schedule" {
   name                = "ServiceCatalogTerraformCloudRotateToken"
   description         = "Schedule for Token Rotation"
   schedule_expression = "rate(${var.token_rotation_interval_in_days} days)"
+
+  # Secrets Manager schedules the rotation itself when native rotation is used
+  is_enabled = !var.use_secrets_manager_rotation
 }
 
 resource "aws_cloudwatch_event_target" "token_rotation" {
@@ -266,7 +339,7 @@ resource "aws_sfn_state_machine" "rotate_token_state_machine" {
     enabled = true
   }
 
-  definition = <<EOF
+  definition = var.token_rotation_mode == "standby_team" ? local.standby_team_token_rotation_definition : <<EOF
 {
   "Comment": "A state machine that manages the team token rotation experience.",
   "StartAt": "Pause SQS processing",
@@ -277,6 +350,13 @@ resource "aws_sfn_state_machine" "rotate_token_state_machine" {
       "Parameters": {
         "operation": "PAUSING"
       },
+      "Catch": [
+        {
+          "ErrorEquals": ["States.ALL"],
+          "ResultPath": "$.error",
+          "Next": "Resume SQS processing after failure"
+        }
+      ],
       "Next": "Wait for all state machine executions to finish"
     },
     "Wait for all state machine executions to finish": {
@@ -303,6 +383,13 @@ resource "aws_sfn_state_machine" "rotate_token_state_machine" {
           "BackoffRate": 2
         }
       ],
+      "Catch": [
+        {
+          "ErrorEquals": ["States.ALL"],
+          "ResultPath": "$.error",
+          "Next": "Resume SQS processing after failure"
+        }
+      ],
       "Next": "Are there any outstanding state machine executions?"
     },
     "Are there any outstanding state machine executions?": {
@@ -312,8 +399,16 @@ resource "aws_sfn_state_machine" "rotate_token_state_machine" {
         {
           "And": [
             {
-              "Variable": "$.pollStateMachinesResult.stateMachineExecutionCount",
-              "NumericEquals": 0
+              "Or": [
+                {
+                  "Variable": "$.pollStateMachinesResult.stateMachineExecutionCount",
+                  "NumericEquals": 0
+                },
+                {
+                  "Variable": "$.pollStateMachinesResult.drainTimedOut",
+                  "BooleanEquals": true
+                }
+              ]
             },
             {
               "Variable": "$.pollStateMachinesResult.eventSourceMappingStatus",
@@ -343,6 +438,13 @@ resource "aws_sfn_state_machine" "rotate_token_state_machine" {
           "BackoffRate": 2
         }
       ],
+      "Catch": [
+        {
+          "ErrorEquals": ["States.ALL"],
+          "ResultPath": "$.error",
+          "Next": "Resume SQS processing after failure"
+        }
+      ],
       "Next": "Resume SQS processing"
     },
     "Resume SQS processing": {
@@ -363,7 +465,202 @@ resource "aws_sfn_state_machine" "rotate_token_state_machine" {
           "BackoffRate": 2
         }
       ],
+      "Catch": [
+        {
+          "ErrorEquals": ["States.ALL"],
+          "ResultPath": "$.error",
+          "Next": "Resume SQS processing after failure"
+        }
+      ],
       "End": true
+    },
+    "Resume SQS processing after failure": {
+      "Type": "Task",
+      "Comment": "Resumes the SQS queues, so provisioning does not stay paused after a failed rotation",
+      "Resource": "${aws_lambda_function.rotate_token_handler.arn}",
+      "Parameters": {
+        "operation": "FORCE_RESUMING",
+        "failure.$": "$.error.Cause"
+      },
+      "ResultPath": null,
+      "Retry": [
+        {
+          "ErrorEquals": [
+            "Lambda.ServiceException",
+            "Lambda.AWSLambdaException",
+            "Lambda.SdkClientException"
+          ],
+          "IntervalSeconds": 2,
+          "MaxAttempts": 6,
+          "BackoffRate": 2
+        }
+      ],
+      "Catch": [
+        {
+          "ErrorEquals": ["States.ALL"],
+          "ResultPath": "$.resumeError",
+          "Next": "Alert token rotation failure"
+        }
+      ],
+      "Next": "Alert token rotation failure"
+    },
+    "Alert token rotation failure": {
+      "Type": "Task",
+      "Resource": "arn:aws:states:::sns:publish",
+      "Parameters": {
+        "TopicArn": "${aws_sns_topic.token_rotation_alerts.arn}",
+        "Subject": "TFC team token rotation failed",
+        "Message.$": "States.Format('Rotation of the TFC team token of the Service Catalog engine failed in execution {}: {}. The TFE credentials secret was only updated if the new token was validated. The SQS queues are resumed after a failure; invoke the rotation Lambda with the INSPECTING operation to check they are running, and with the FORCE_RESUMING operation to resume them otherwise.', $$.Execution.Id, $.error.Cause)"
+      },
+      "Next": "Token rotation failed"
+    },
+    "Token rotation failed": {
+      "Type": "Fail",
+      "Error": "TokenRotationFailed",
+      "Cause": "Rotation of the TFC team token failed, see the alert published to the token rotation alerts topic"
+    }
+  }
+}
+EOF
+}
+
+locals {
+  # Rotates the team token without pausing the SQS queues: the secret is switched to a new token of the standby team,
+  # and the token of the previous team is only revoked once the executions and Lambda invocations using it are done.
+  # Lambda functions fetch the secret on every invocation, and time out after 15 minutes at most.
+  standby_team_token_rotation_definition = <<EOF
+{
+  "Comment": "A state machine that rotates the team token by alternating between two teams, without pausing provisioning.",
+  "StartAt": "Switch to standby team",
+  "States": {
+    "Switch to standby team": {
+      "Type": "Task",
+      "Resource": "${aws_lambda_function.rotate_token_handler.arn}",
+      "Parameters": {
+        "operation": "SWITCHING"
+      },
+      "ResultPath": "$.switchResult",
+      "Retry": [
+        {
+          "ErrorEquals": [
+            "Lambda.ServiceException",
+            "Lambda.AWSLambdaException",
+            "Lambda.SdkClientException"
+          ],
+          "IntervalSeconds": 2,
+          "MaxAttempts": 6,
+          "BackoffRate": 2
+        }
+      ],
+      "Catch": [
+        {
+          "ErrorEquals": ["States.ALL"],
+          "ResultPath": "$.error",
+          "Next": "Alert token rotation failure"
+        }
+      ],
+      "Next": "Wait for Lambda invocations using the previous token to finish"
+    },
+    "Wait for Lambda invocations using the previous token to finish": {
+      "Type": "Wait",
+      "Seconds": 900,
+      "Next": "Poll state machine executions started before the switch"
+    },
+    "Wait for state machine executions started before the switch to finish": {
+      "Type": "Wait",
+      "Seconds": 10,
+      "Next": "Poll state machine executions started before the switch"
+    },
+    "Poll state machine executions started before the switch": {
+      "Type": "Task",
+      "Resource": "${aws_lambda_function.rotate_token_handler.arn}",
+      "Parameters": {
+        "operation": "POLLING",
+        "switchedAt.$": "$.switchResult.switchedAt"
+      },
+      "ResultPath": "$.pollStateMachinesResult",
+      "Retry": [
+        {
+          "ErrorEquals": [
+            "Lambda.ServiceException",
+            "Lambda.AWSLambdaException",
+            "Lambda.SdkClientException"
+          ],
+          "IntervalSeconds": 2,
+          "MaxAttempts": 6,
+          "BackoffRate": 2
+        }
+      ],
+      "Catch": [
+        {
+          "ErrorEquals": ["States.ALL"],
+          "ResultPath": "$.error",
+          "Next": "Alert token rotation failure"
+        }
+      ],
+      "Next": "Are there any state machine executions started before the switch?"
+    },
+    "Are there any state machine executions started before the switch?": {
+      "Type": "Choice",
+      "Choices": [
+        {
+          "Or": [
+            {
+              "Variable": "$.pollStateMachinesResult.stateMachineExecutionCount",
+              "NumericEquals": 0
+            },
+            {
+              "Variable": "$.pollStateMachinesResult.drainTimedOut",
+              "BooleanEquals": true
+            }
+          ],
+          "Next": "Revoke token of previous team"
+        }
+      ],
+      "Default": "Wait for state machine executions started before the switch to finish"
+    },
+    "Revoke token of previous team": {
+      "Type": "Task",
+      "Resource": "${aws_lambda_function.rotate_token_handler.arn}",
+      "Parameters": {
+        "operation": "REVOKING",
+        "previousTeamId.$": "$.switchResult.previousTeamId"
+      },
+      "Retry": [
+        {
+          "ErrorEquals": [
+            "Lambda.ServiceException",
+            "Lambda.AWSLambdaException",
+            "Lambda.SdkClientException"
+          ],
+          "IntervalSeconds": 2,
+          "MaxAttempts": 6,
+          "BackoffRate": 2
+        }
+      ],
+      "Catch": [
+        {
+          "ErrorEquals": ["States.ALL"],
+          "ResultPath": "$.error",
+          "Next": "Alert token rotation failure"
+        }
+      ],
+      "End": true
+    },
+    "Alert token rotation failure": {
+      "Type": "Task",
+      "Resource": "arn:aws:states:::sns:publish",
+      "Parameters": {
+        "TopicArn": "${aws_sns_topic.token_rotation_alerts.arn}",
+        "Subject": "TFC team token rotation failed",
+        "Message.$": "States.Format('Rotation of the TFC team token of the Service Catalog engine failed in execution {}: {}. The TFE credentials secret was only updated if the new token was validated.', $$.Execution.Id, $.error.Cause)"
+      },
+      "Next": "Token rotation failed"
+    },
+    "Token rotation failed": {
+      "Type": "Fail",
+      "Error": "TokenRotationFailed",
+      "Cause": "Rotation of the TFC team token failed, see the alert published to the token rotation alerts topic"
     }
   }
 }
@@ -35,7 +35,7 @@ data "aws_iam_policy_document" "update_state_machine" {
 
     actions = ["lambda:InvokeFunction"]
 
-    resources = [local.send_apply_lambda_arn, local.poll_run_status_lambda_arn, local.notify_run_result_lambda_arn, aws_lambda_function.parameter_parser.arn]
+    resources = compact([local.send_apply_lambda_arn, local.poll_run_status_lambda_arn, local.notify_run_result_lambda_arn, local.handle_run_decision_lambda_arn, local.register_run_waiter_lambda_arn, aws_lambda_function.parameter_parser.arn])
 
   }
 
@@ -123,6 +123,7 @@ resource "aws_sfn_state_machine" "update_state_machine" {
       "Type": "Task",
       "Resource": "${local.send_apply_lambda_arn}",
       "Parameters": {
+        "schemaVersion": ${local.payload_schema_version},
         "awsAccountId.$": "$.identity.awsAccountId",
         "terraformOrganization.$": "$.terraformOrganization",
         "provisionedProductId.$": "$.provisionedProductId",
@@ -145,8 +146,39 @@ resource "aws_sfn_state_machine" "update_state_machine" {
               "Next": "Notify update result failure"
           }
       ],
-      "Next": "Wait for update to complete"
+      "Next": "Wait for update ${local.run_wait_state_suffix}"
     },
+%{ if var.enable_run_notifications ~}
+    "Wait for update notification": {
+      "Type": "Task",
+      "Comment": "Waits until TFC notifies the engine that the run completed, errored or needs attention, and falls back to polling the run when no notification arrives in time",
+      "Resource": "arn:aws:states:::lambda:invoke.waitForTaskToken",
+      "Parameters": {
+        "FunctionName": "${local.register_run_waiter_lambda_arn}",
+        "Payload": {
+          "taskToken.$": "$$.Task.Token",
+          "terraformRunId.$": "$.sendApplyResult.terraformRunId",
+          "terraformOrganization.$": "$.terraformOrganization",
+          "timeoutSeconds": ${var.run_notification_timeout_in_seconds}
+        }
+      },
+      "TimeoutSeconds": ${var.run_notification_timeout_in_seconds},
+      "ResultPath": null,
+      "Catch": [
+        {
+          "ErrorEquals": [ "States.Timeout" ],
+          "ResultPath": null,
+          "Next": "Poll update status"
+        },
+        {
+          "ErrorEquals": [ "States.ALL" ],
+          "ResultPath": null,
+          "Next": "Wait for update to complete"
+        }
+      ],
+      "Next": "Poll update status"
+    },
+%{ endif ~}
     "Wait for update to complete": {
       "Type": "Wait",
       "Seconds": 10,
@@ -156,7 +188,11 @@ resource "aws_sfn_state_machine" "update_state_machine" {
       "Type": "Task",
       "Resource": "${local.poll_run_status_lambda_arn}",
       "Parameters": {
-        "terraformRunId.$": "$.sendApplyResult.terraformRunId"
+        "schemaVersion": ${local.payload_schema_version},
+        "terraformRunId.$": "$.sendApplyResult.terraformRunId",
+        "awsAccountId.$": "$.identity.awsAccountId",
+        "terraformOrganization.$": "$.terraformOrganization",
+        "provisionedProductId.$": "$.provisionedProductId"
       },
       "ResultPath": "$.pollRunResult",
       "Retry": [
@@ -192,13 +228,18 @@ resource "aws_sfn_state_machine" "update_state_machine" {
         {
           "Variable": "$.pollRunResult.productProvisioningStatus",
           "StringEquals": "inProgress",
-          "Next": "Wait for update to complete"
+          "Next": "Wait for update ${local.run_wait_state_suffix}"
         },
         {
           "Variable": "$.pollRunResult.productProvisioningStatus",
           "StringEquals": "failed",
           "Next": "Convert poll update status error"
         },
+        {
+          "Variable": "$.pollRunResult.productProvisioningStatus",
+          "StringEquals": "awaitingApproval",
+          "Next": "Request update approval"
+        },
         {
           "Variable": "$.pollRunResult.productProvisioningStatus",
           "StringEquals": "success",
@@ -207,6 +248,74 @@ resource "aws_sfn_state_machine" "update_state_machine" {
       ],
       "Default": "Convert poll update status error"
     },
+    "Request update approval": {
+      "Type": "Task",
+      "Comment": "Publishes an approval request for the run via the run decision Lambda function, and waits until the run is approved or rejected with the same function",
+      "Resource": "arn:aws:states:::lambda:invoke.waitForTaskToken",
+      "Parameters": {
+        "FunctionName": "${local.handle_run_decision_lambda_arn}",
+        "Payload": {
+          "taskToken.$": "$$.Task.Token",
+          "terraformRunId.$": "$.sendApplyResult.terraformRunId",
+          "terraformOrganization.$": "$.terraformOrganization",
+          "decision": "request",
+          "timeoutSeconds": ${var.run_approval_timeout_in_seconds},
+          "approvalRequest": {
+            "runUrl.$": "$.pollRunResult.runUrl",
+            "resourceCounts.$": "$.pollRunResult.resourceCounts",
+            "serviceCatalogOperation": "UPDATING",
+            "awsAccountId.$": "$.identity.awsAccountId",
+            "provisionedProductId.$": "$.provisionedProductId",
+            "recordId.$": "$.recordId"
+          }
+        }
+      },
+      "TimeoutSeconds": ${var.run_approval_timeout_in_seconds},
+      "ResultPath": "$.runDecisionResult",
+      "Catch": [
+        {
+          "ErrorEquals": [ "States.Timeout" ],
+          "ResultPath": "$.errorInfo",
+          "Next": "Expire update approval"
+        },
+        {
+          "ErrorEquals": [ "States.ALL" ],
+          "ResultPath": "$.errorInfo",
+          "Next": "Notify update result failure"
+        }
+      ],
+      "Next": "Wait for update ${local.run_wait_state_suffix}"
+    },
+    "Expire update approval": {
+      "Type": "Task",
+      "Comment": "Discards the run in TFC, as it was not approved in time",
+      "Resource": "${local.handle_run_decision_lambda_arn}",
+      "Parameters": {
+        "terraformRunId.$": "$.sendApplyResult.terraformRunId",
+        "terraformOrganization.$": "$.terraformOrganization",
+        "decision": "expire"
+      },
+      "ResultPath": null,
+      "Catch": [
+        {
+          "ErrorEquals": [ "States.ALL" ],
+          "ResultPath": null,
+          "Next": "Convert update approval timeout"
+        }
+      ],
+      "Next": "Convert update approval timeout"
+    },
+    "Convert update approval timeout": {
+        "Type": "Pass",
+        "Comment": "Restructures the approval timeout to a format the notify run result task understands",
+        "Parameters": {
+            "Error": "Run approval expired",
+            "Cause": "The run was not approved within ${var.run_approval_timeout_in_seconds} seconds, and was discarded in TFC",
+            "isWrapperError": true
+        },
+        "ResultPath": "$.errorInfo",
+        "Next": "Notify update result failure"
+    },
     "Convert poll update status error": {
         "Type": "Pass",
         "Comment": "Restructures error from the poll update status task to a format the notify run result task understands",
@@ -222,6 +331,7 @@ resource "aws_sfn_state_machine" "update_state_machine" {
       "Type": "Task",
       "Resource": "${local.notify_run_result_lambda_arn}",
       "Parameters": {
+        "schemaVersion": ${local.payload_schema_version},
         "terraformRunId.$": "$.sendApplyResult.terraformRunId",
         "workflowToken.$": "$.token",
         "recordId.$": "$.recordId",
@@ -244,6 +354,7 @@ resource "aws_sfn_state_machine" "update_state_machine" {
       "Type": "Task",
       "Resource": "${local.notify_run_result_lambda_arn}",
       "Parameters": {
+        "schemaVersion": ${local.payload_schema_version},
         "terraformRunId.$": "$.sendApplyResult.terraformRunId",
         "workflowToken.$": "$.token",
         "recordId.$": "$.recordId",
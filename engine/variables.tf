@@ -42,8 +42,149 @@ variable "token_rotation_interval_in_days" {
   description = "Interval for automatic rotation of the Terraform Cloud API Token that Service Catalog uses to authenticate with Terraform Cloud. Default is 30 days"
 }
 
+variable "token_rotation_mode" {
+  type        = string
+  default     = "pause"
+  description = "How the team token is rotated. \"pause\" pauses the SQS queues and waits for all executions to finish before replacing the token. \"standby_team\" creates a standby TFC team, and alternates between the two teams without pausing provisioning"
+
+  validation {
+    condition     = contains(["pause", "standby_team"], var.token_rotation_mode)
+    error_message = "The token_rotation_mode must be either \"pause\" or \"standby_team\"."
+  }
+}
+
+variable "token_rotation_max_drain_in_minutes" {
+  type        = number
+  default     = 0
+  description = "Maximum number of minutes the rotation waits for running state machine executions to finish before applying the stuck execution policy. Waits without a maximum if 0"
+}
+
+variable "token_rotation_stuck_execution_policy" {
+  type        = string
+  default     = "abort"
+  description = "What the rotation does about the executions still running after the maximum drain time. \"abort\" fails the rotation and resumes provisioning without replacing the token. \"proceed\" replaces the token anyway, failing the executions that still use the previous token"
+
+  validation {
+    condition     = contains(["abort", "proceed"], var.token_rotation_stuck_execution_policy)
+    error_message = "The token_rotation_stuck_execution_policy must be either \"abort\" or \"proceed\"."
+  }
+}
+
+variable "token_expiry_margin_in_days" {
+  type        = number
+  default     = 7
+  description = "Number of days team tokens stay valid after the next rotation is due, so the token stops working when rotation stops running, but not before an overdue rotation is noticed"
+}
+
+variable "token_expiry_warning_in_days" {
+  type        = number
+  default     = 3
+  description = "Number of days before the team token expires that the daily token expiry check sends an EventBridge event"
+}
+
+variable "use_secrets_manager_rotation" {
+  type        = bool
+  default     = false
+  description = "Whether Secrets Manager rotates the TFE credentials with its native rotation schedule, instead of the token rotation state machine. The state machine can still be started manually"
+}
+
 variable "terraform_version" {
   type        = string
   default     = "1.5.4"
   description = "Version of Terraform Core to use in Terraform Cloud for all Service Catalog products"
 }
+
+variable "require_run_approval" {
+  type        = bool
+  default     = false
+  description = "When set to true, runs are not applied automatically. Instead, an approval request is published to the run approval SNS topic, and the run is applied once it is approved"
+}
+
+variable "run_approval_timeout_in_seconds" {
+  type        = number
+  default     = 86400
+  description = "Number of seconds a run may await approval before it expires and is discarded. Default is 1 day"
+}
+
+variable "enable_run_notifications" {
+  type        = bool
+  default     = true
+  description = "When set to true, TFC notifies the engine when runs complete via a webhook, instead of the engine polling the runs. Disable this when TFC cannot reach the webhook's Lambda function URL, e.g. for TFE installations without internet access"
+}
+
+variable "run_notification_timeout_in_seconds" {
+  type        = number
+  default     = 300
+  description = "Number of seconds to wait for a run notification before polling the run instead. Runs are polled at this interval when notifications are lost"
+}
+
+variable "record_output_prefix" {
+  type        = string
+  default     = "sc_"
+  description = "Prefix of the Terraform outputs that are published as Service Catalog record outputs, without the prefix. Products without any outputs named with this prefix publish all their outputs. Set to an empty string to always publish all outputs"
+}
+
+variable "state_archive_retention_in_days" {
+  type        = number
+  default     = 365
+  description = "Number of days the final state and variables of the workspaces of terminated provisioned products are kept in the state archive bucket"
+}
+
+variable "terminated_workspace_retention_in_days" {
+  type        = number
+  default     = 0
+  description = "Number of days the workspaces of terminated provisioned products are locked and retained for investigation, before the workspace reaper deletes them. Workspaces are deleted right away when set to 0"
+}
+
+variable "drift_detection_schedule_expression" {
+  type        = string
+  default     = "rate(6 hours)"
+  description = "Schedule expression of the drift detection, which reports drifted provisioned products as EventBridge events and CloudWatch metrics. Each run queues a batch of refresh-only plans and reports the results of the previous batch"
+}
+
+variable "drift_detection_batch_size" {
+  type        = number
+  default     = 25
+  description = "Maximum number of refresh-only plans the drift detection queues per run. Workspaces with health assessments enabled in TFC are not included, as their assessment results are reported instead"
+}
+
+variable "drift_event_bus_name" {
+  type        = string
+  default     = "default"
+  description = "Name of the EventBridge event bus the drift detection sends its events to"
+}
+
+variable "reconciliation_schedule_expression" {
+  type        = string
+  default     = "rate(1 day)"
+  description = "Schedule expression of the workspace reconciliation, which reports workspaces without a provisioned product and provisioned products without a workspace. Scheduled runs only report orphans, they never delete workspaces"
+}
+
+variable "reconciliation_role_name" {
+  type        = string
+  default     = ""
+  description = "Name of the IAM role the workspace reconciliation assumes in spoke accounts to scan their provisioned products, which must allow servicecatalog:ScanProvisionedProducts. When empty, only the provisioned products of the account of the engine are scanned, and the other accounts are reported as unverified"
+}
+
+variable "orphan_workspace_grace_period_in_hours" {
+  type        = number
+  default     = 24
+  description = "Number of hours a workspace without a provisioned product must exist before the workspace reconciliation deletes it as an orphan"
+}
+
+variable "tfc_organization_credentials_secret_arns" {
+  type        = map(string)
+  default     = {}
+  description = "ARNs of the Secrets Manager secrets holding the TFE credentials of the organizations that do not use the credentials of tfc_organization, by the names of the organizations. Each secret holds the hostname, team ID and team token of its organization as a JSON object with the hostname, id and token keys, so organizations may be on TFE"
+}
+
+variable "organization_routes" {
+  type = list(object({
+    organization  = string
+    product_ids   = optional(list(string), [])
+    portfolio_ids = optional(list(string), [])
+    account_ids   = optional(list(string), [])
+  }))
+  default     = []
+  description = "Routes of provisioning requests to TFC organizations other than tfc_organization, matched by the ID of the product, then by the IDs of the portfolios the product is in, then by the ID of the account of the provisioned product. Requests matching no route use tfc_organization"
+}
@@ -0,0 +1,81 @@
+# Copyright (c) HashiCorp, Inc.
+# SPDX-License-Identifier: MPL-2.0
+
+data "aws_iam_policy_document" "workspace_adoption_assume_role" {
+  statement {
+    effect = "Allow"
+
+    principals {
+      type        = "Service"
+      identifiers = ["lambda.amazonaws.com"]
+    }
+
+    actions = ["sts:AssumeRole"]
+  }
+}
+
+resource "aws_iam_role" "workspace_adoption" {
+  name               = "ServiceCatalogTerraformCloudWorkspaceAdoptionRole"
+  assume_role_policy = data.aws_iam_policy_document.workspace_adoption_assume_role.json
+}
+
+resource "aws_iam_role_policy" "workspace_adoption" {
+  name   = "ServiceCatalogTerraformCloudWorkspaceAdoptionPolicy"
+  role   = aws_iam_role.workspace_adoption.id
+  policy = data.aws_iam_policy_document.workspace_adoption.json
+}
+
+data "aws_iam_policy_document" "workspace_adoption" {
+  version = "2012-10-17"
+
+  statement {
+    sid = "tfeCredentialsAccess"
+
+    effect = "Allow"
+
+    actions = ["secretsmanager:GetSecretValue"]
+
+    resources = local.tfc_credentials_secret_arns
+  }
+}
+
+resource "aws_iam_role_policy_attachment" "workspace_adoption" {
+  for_each   = toset(["arn:aws:iam::aws:policy/AWSXrayWriteOnlyAccess", "arn:aws:iam::aws:policy/service-role/AWSLambdaBasicExecutionRole"])
+  role       = aws_iam_role.workspace_adoption.name
+  policy_arn = each.value
+}
+
+data "archive_file" "workspace_adoption" {
+  type        = "zip"
+  output_path = "dist/workspace_adoption.zip"
+  source_file = "${path.module}/lambda-functions/adopt-workspace/bootstrap"
+}
+
+resource "aws_cloudwatch_log_group" "workspace_adoption" {
+  name              = "/aws/lambda/ServiceCatalogTerraformCloudWorkspaceAdoption"
+  retention_in_days = var.cloudwatch_log_retention_in_days
+}
+
+# Lambda that adopts existing workspaces as the workspaces of provisioned products, invoked by administrators
+resource "aws_lambda_function" "workspace_adoption" {
+  filename      = data.archive_file.workspace_adoption.output_path
+  function_name = "ServiceCatalogTerraformCloudWorkspaceAdoption"
+  role          = aws_iam_role.workspace_adoption.arn
+  handler       = "bootstrap"
+  timeout       = 300
+
+  source_code_hash = data.archive_file.workspace_adoption.output_base64sha256
+
+  runtime       = "provided.al2"
+  architectures = ["arm64"]
+
+  environment {
+    variables = {
+      TFE_CREDENTIALS_SECRET_ID               = aws_secretsmanager_secret.team_token_values.arn
+      TFE_ORGANIZATION_CREDENTIALS_SECRET_IDS = jsonencode(var.tfc_organization_credentials_secret_arns)
+      TERRAFORM_ORGANIZATION                  = var.tfc_organization
+    }
+  }
+
+  depends_on = [aws_cloudwatch_log_group.workspace_adoption]
+}
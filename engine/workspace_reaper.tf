@@ -0,0 +1,101 @@
+# Copyright (c) HashiCorp, Inc.
+# SPDX-License-Identifier: MPL-2.0
+
+data "aws_iam_policy_document" "workspace_reaper_assume_role" {
+  statement {
+    effect = "Allow"
+
+    principals {
+      type        = "Service"
+      identifiers = ["lambda.amazonaws.com"]
+    }
+
+    actions = ["sts:AssumeRole"]
+  }
+}
+
+resource "aws_iam_role" "workspace_reaper" {
+  name               = "ServiceCatalogTerraformCloudWorkspaceReaperRole"
+  assume_role_policy = data.aws_iam_policy_document.workspace_reaper_assume_role.json
+}
+
+resource "aws_iam_role_policy" "workspace_reaper" {
+  name   = "ServiceCatalogTerraformCloudWorkspaceReaperPolicy"
+  role   = aws_iam_role.workspace_reaper.id
+  policy = data.aws_iam_policy_document.workspace_reaper.json
+}
+
+data "aws_iam_policy_document" "workspace_reaper" {
+  version = "2012-10-17"
+
+  statement {
+    sid = "tfeCredentialsAccess"
+
+    effect = "Allow"
+
+    actions = ["secretsmanager:GetSecretValue"]
+
+    resources = local.tfc_credentials_secret_arns
+  }
+}
+
+resource "aws_iam_role_policy_attachment" "workspace_reaper" {
+  for_each   = toset(["arn:aws:iam::aws:policy/AWSXrayWriteOnlyAccess", "arn:aws:iam::aws:policy/service-role/AWSLambdaBasicExecutionRole"])
+  role       = aws_iam_role.workspace_reaper.name
+  policy_arn = each.value
+}
+
+data "archive_file" "workspace_reaper" {
+  type        = "zip"
+  output_path = "dist/workspace_reaper.zip"
+  source_file = "${path.module}/lambda-functions/reap-terminated-workspaces/bootstrap"
+}
+
+resource "aws_cloudwatch_log_group" "workspace_reaper" {
+  name              = "/aws/lambda/ServiceCatalogTerraformCloudWorkspaceReaper"
+  retention_in_days = var.cloudwatch_log_retention_in_days
+}
+
+# Lambda that deletes the workspaces of terminated provisioned products once their retention period has passed
+resource "aws_lambda_function" "workspace_reaper" {
+  filename      = data.archive_file.workspace_reaper.output_path
+  function_name = "ServiceCatalogTerraformCloudWorkspaceReaper"
+  role          = aws_iam_role.workspace_reaper.arn
+  handler       = "bootstrap"
+  timeout       = 300
+
+  source_code_hash = data.archive_file.workspace_reaper.output_base64sha256
+
+  runtime       = "provided.al2"
+  architectures = ["arm64"]
+
+  environment {
+    variables = {
+      TFE_CREDENTIALS_SECRET_ID               = aws_secretsmanager_secret.team_token_values.arn
+      TFE_ORGANIZATION_CREDENTIALS_SECRET_IDS = jsonencode(var.tfc_organization_credentials_secret_arns)
+      TERRAFORM_ORGANIZATION                  = var.tfc_organization
+      TERMINATED_WORKSPACE_RETENTION_IN_DAYS  = var.terminated_workspace_retention_in_days
+    }
+  }
+
+  depends_on = [aws_cloudwatch_log_group.workspace_reaper]
+}
+
+resource "aws_cloudwatch_event_rule" "workspace_reaper_schedule" {
+  name                = "ServiceCatalogTerraformCloudWorkspaceReaper"
+  description         = "Schedule for deleting the workspaces of terminated provisioned products"
+  schedule_expression = "rate(1 day)"
+}
+
+resource "aws_cloudwatch_event_target" "workspace_reaper" {
+  rule = aws_cloudwatch_event_rule.workspace_reaper_schedule.name
+  arn  = aws_lambda_function.workspace_reaper.arn
+}
+
+resource "aws_lambda_permission" "workspace_reaper_schedule" {
+  statement_id  = "AllowExecutionFromEventBridge"
+  action        = "lambda:InvokeFunction"
+  function_name = aws_lambda_function.workspace_reaper.function_name
+  principal     = "events.amazonaws.com"
+  source_arn    = aws_cloudwatch_event_rule.workspace_reaper_schedule.arn
+}
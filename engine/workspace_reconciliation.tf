@@ -0,0 +1,127 @@
+# Copyright (c) HashiCorp, Inc.
+# SPDX-License-Identifier: MPL-2.0
+
+data "aws_iam_policy_document" "workspace_reconciliation_assume_role" {
+  statement {
+    effect = "Allow"
+
+    principals {
+      type        = "Service"
+      identifiers = ["lambda.amazonaws.com"]
+    }
+
+    actions = ["sts:AssumeRole"]
+  }
+}
+
+resource "aws_iam_role" "workspace_reconciliation" {
+  name               = "ServiceCatalogTerraformCloudWorkspaceReconciliationRole"
+  assume_role_policy = data.aws_iam_policy_document.workspace_reconciliation_assume_role.json
+}
+
+resource "aws_iam_role_policy" "workspace_reconciliation" {
+  name   = "ServiceCatalogTerraformCloudWorkspaceReconciliationPolicy"
+  role   = aws_iam_role.workspace_reconciliation.id
+  policy = data.aws_iam_policy_document.workspace_reconciliation.json
+}
+
+data "aws_iam_policy_document" "workspace_reconciliation" {
+  version = "2012-10-17"
+
+  statement {
+    sid = "tfeCredentialsAccess"
+
+    effect = "Allow"
+
+    actions = ["secretsmanager:GetSecretValue"]
+
+    resources = local.tfc_credentials_secret_arns
+  }
+
+  statement {
+    sid = "scanProvisionedProducts"
+
+    effect = "Allow"
+
+    actions = ["servicecatalog:ScanProvisionedProducts"]
+
+    resources = ["*"]
+  }
+
+  dynamic "statement" {
+    for_each = var.reconciliation_role_name == "" ? [] : [var.reconciliation_role_name]
+
+    content {
+      sid = "assumeSpokeReconciliationRole"
+
+      effect = "Allow"
+
+      actions = ["sts:AssumeRole"]
+
+      resources = ["arn:aws:iam::*:role/${statement.value}"]
+    }
+  }
+}
+
+resource "aws_iam_role_policy_attachment" "workspace_reconciliation" {
+  for_each   = toset(["arn:aws:iam::aws:policy/AWSXrayWriteOnlyAccess", "arn:aws:iam::aws:policy/service-role/AWSLambdaBasicExecutionRole"])
+  role       = aws_iam_role.workspace_reconciliation.name
+  policy_arn = each.value
+}
+
+data "archive_file" "workspace_reconciliation" {
+  type        = "zip"
+  output_path = "dist/workspace_reconciliation.zip"
+  source_file = "${path.module}/lambda-functions/reconcile-workspaces/bootstrap"
+}
+
+resource "aws_cloudwatch_log_group" "workspace_reconciliation" {
+  name              = "/aws/lambda/ServiceCatalogTerraformCloudWorkspaceReconciliation"
+  retention_in_days = var.cloudwatch_log_retention_in_days
+}
+
+# Lambda that cross-checks the workspaces of provisioned products with Service Catalog, reporting orphaned workspaces
+# and provisioned products. Administrators can invoke it with cleanUp set to delete the orphaned workspaces that are empty
+resource "aws_lambda_function" "workspace_reconciliation" {
+  filename      = data.archive_file.workspace_reconciliation.output_path
+  function_name = "ServiceCatalogTerraformCloudWorkspaceReconciliation"
+  role          = aws_iam_role.workspace_reconciliation.arn
+  handler       = "bootstrap"
+  timeout       = 900
+
+  source_code_hash = data.archive_file.workspace_reconciliation.output_base64sha256
+
+  runtime       = "provided.al2"
+  architectures = ["arm64"]
+
+  environment {
+    variables = {
+      TFE_CREDENTIALS_SECRET_ID               = aws_secretsmanager_secret.team_token_values.arn
+      TFE_ORGANIZATION_CREDENTIALS_SECRET_IDS = jsonencode(var.tfc_organization_credentials_secret_arns)
+      TERRAFORM_ORGANIZATION                  = var.tfc_organization
+      RECONCILIATION_ROLE_NAME                = var.reconciliation_role_name
+      ORPHAN_GRACE_PERIOD_IN_HOURS            = var.orphan_workspace_grace_period_in_hours
+    }
+  }
+
+  depends_on = [aws_cloudwatch_log_group.workspace_reconciliation]
+}
+
+resource "aws_cloudwatch_event_rule" "workspace_reconciliation_schedule" {
+  name                = "ServiceCatalogTerraformCloudWorkspaceReconciliation"
+  description         = "Schedule for reporting orphaned workspaces and provisioned products"
+  schedule_expression = var.reconciliation_schedule_expression
+}
+
+resource "aws_cloudwatch_event_target" "workspace_reconciliation" {
+  rule = aws_cloudwatch_event_rule.workspace_reconciliation_schedule.name
+  arn  = aws_lambda_function.workspace_reconciliation.arn
+}
+
+resource "aws_lambda_permission" "workspace_reconciliation_schedule" {
+  statement_id  = "AllowExecutionFromEventBridge"
+  action        = "lambda:InvokeFunction"
+  function_name = aws_lambda_function.workspace_reconciliation.function_name
+  principal     = "events.amazonaws.com"
+  source_arn    = aws_cloudwatch_event_rule.workspace_reconciliation_schedule.arn
+}
@@ -35,14 +35,35 @@ provider "tfe" {
 module "terraform_cloud_reference_engine" {
   source = "./engine"
 
-  tfc_organization                 = var.tfc_organization
-  tfc_team                         = var.tfc_team
-  tfc_aws_audience                 = var.tfc_aws_audience
-  tfc_hostname                     = var.tfc_hostname
-  cloudwatch_log_retention_in_days = var.cloudwatch_log_retention_in_days
-  enable_xray_tracing              = var.enable_xray_tracing
-  token_rotation_interval_in_days  = var.token_rotation_interval_in_days
-  terraform_version                = var.terraform_version
+  tfc_organization                         = var.tfc_organization
+  tfc_team                                 = var.tfc_team
+  tfc_aws_audience                         = var.tfc_aws_audience
+  tfc_hostname                             = var.tfc_hostname
+  cloudwatch_log_retention_in_days         = var.cloudwatch_log_retention_in_days
+  enable_xray_tracing                      = var.enable_xray_tracing
+  token_rotation_interval_in_days          = var.token_rotation_interval_in_days
+  token_rotation_mode                      = var.token_rotation_mode
+  token_rotation_max_drain_in_minutes      = var.token_rotation_max_drain_in_minutes
+  token_rotation_stuck_execution_policy    = var.token_rotation_stuck_execution_policy
+  token_expiry_margin_in_days              = var.token_expiry_margin_in_days
+  token_expiry_warning_in_days             = var.token_expiry_warning_in_days
+  use_secrets_manager_rotation             = var.use_secrets_manager_rotation
+  terraform_version                        = var.terraform_version
+  require_run_approval                     = var.require_run_approval
+  run_approval_timeout_in_seconds          = var.run_approval_timeout_in_seconds
+  enable_run_notifications                 = var.enable_run_notifications
+  run_notification_timeout_in_seconds      = var.run_notification_timeout_in_seconds
+  record_output_prefix                     = var.record_output_prefix
+  state_archive_retention_in_days          = var.state_archive_retention_in_days
+  terminated_workspace_retention_in_days   = var.terminated_workspace_retention_in_days
+  drift_detection_schedule_expression      = var.drift_detection_schedule_expression
+  drift_detection_batch_size               = var.drift_detection_batch_size
+  drift_event_bus_name                     = var.drift_event_bus_name
+  reconciliation_schedule_expression       = var.reconciliation_schedule_expression
+  reconciliation_role_name                 = var.reconciliation_role_name
+  orphan_workspace_grace_period_in_hours   = var.orphan_workspace_grace_period_in_hours
+  tfc_organization_credentials_secret_arns = var.tfc_organization_credentials_secret_arns
+  organization_routes                      = var.organization_routes
 }
 
 # Creates an AWS Service Catalog Portfolio to house the example product
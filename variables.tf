@@ -42,8 +42,139 @@ variable "token_rotation_interval_in_days" {
   description = "Interval for automatic rotation of the Terraform Cloud API Token that Service Catalog uses to authenticate with Terraform Cloud. Default is 30 days."
 }
 
+variable "token_rotation_mode" {
+  type        = string
+  default     = "pause"
+  description = "How the team token is rotated, either \"pause\" or \"standby_team\"."
+}
+
+variable "token_rotation_max_drain_in_minutes" {
+  type        = number
+  default     = 0
+  description = "Maximum number of minutes the rotation waits for running executions to finish, without a maximum if 0."
+}
+
+variable "token_rotation_stuck_execution_policy" {
+  type        = string
+  default     = "abort"
+  description = "What the rotation does about executions still running after the maximum drain time, either \"abort\" or \"proceed\"."
+}
+
+variable "token_expiry_margin_in_days" {
+  type        = number
+  default     = 7
+  description = "Number of days team tokens stay valid after the next rotation is due."
+}
+
+variable "token_expiry_warning_in_days" {
+  type        = number
+  default     = 3
+  description = "Number of days before the team token expires that the token expiry check sends an EventBridge event."
+}
+
+variable "use_secrets_manager_rotation" {
+  type        = bool
+  default     = false
+  description = "Whether Secrets Manager rotates the TFE credentials with its native rotation schedule, instead of the token rotation state machine."
+}
+
 variable "terraform_version" {
   type        = string
   default     = "1.5.4"
   description = "Version of Terraform Core to use in Terraform Cloud for all Service Catalog products"
 }
+
+variable "require_run_approval" {
+  type        = bool
+  default     = false
+  description = "When set to true, runs must be approved before they are applied. Approval requests are published to an SNS topic"
+}
+
+variable "run_approval_timeout_in_seconds" {
+  type        = number
+  default     = 86400
+  description = "Number of seconds a run may await approval before it expires and is discarded. Default is 1 day"
+}
+
+variable "enable_run_notifications" {
+  type        = bool
+  default     = true
+  description = "When set to true, TFC notifies the engine when runs complete via a webhook, instead of the engine polling the runs. Disable this when TFC cannot reach the webhook"
+}
+
+variable "run_notification_timeout_in_seconds" {
+  type        = number
+  default     = 300
+  description = "Number of seconds to wait for a run notification before polling the run instead"
+}
+
+variable "record_output_prefix" {
+  type        = string
+  default     = "sc_"
+  description = "Prefix of the Terraform outputs that are published as Service Catalog record outputs"
+}
+
+variable "state_archive_retention_in_days" {
+  type        = number
+  default     = 365
+  description = "Number of days the final state and variables of terminated provisioned products are archived for"
+}
+
+variable "terminated_workspace_retention_in_days" {
+  type        = number
+  default     = 0
+  description = "Number of days the workspaces of terminated provisioned products are retained for, before they are deleted"
+}
+
+variable "drift_detection_schedule_expression" {
+  type        = string
+  default     = "rate(6 hours)"
+  description = "Schedule expression of the drift detection of provisioned products"
+}
+
+variable "drift_detection_batch_size" {
+  type        = number
+  default     = 25
+  description = "Maximum number of refresh-only plans the drift detection queues per run"
+}
+
+variable "drift_event_bus_name" {
+  type        = string
+  default     = "default"
+  description = "Name of the EventBridge event bus that drift events are sent to"
+}
+
+variable "reconciliation_schedule_expression" {
+  type        = string
+  default     = "rate(1 day)"
+  description = "Schedule expression of the reconciliation of workspaces with Service Catalog"
+}
+
+variable "reconciliation_role_name" {
+  type        = string
+  default     = ""
+  description = "Name of the IAM role assumed in spoke accounts to scan their provisioned products during reconciliation"
+}
+
+variable "orphan_workspace_grace_period_in_hours" {
+  type        = number
+  default     = 24
+  description = "Number of hours a workspace without a provisioned product must exist before reconciliation can delete it"
+}
+
+variable "tfc_organization_credentials_secret_arns" {
+  type        = map(string)
+  default     = {}
+  description = "ARNs of the Secrets Manager secrets holding the TFE credentials of other organizations, by the names of the organizations"
+}
+
+variable "organization_routes" {
+  type = list(object({
+    organization  = string
+    product_ids   = optional(list(string), [])
+    portfolio_ids = optional(list(string), [])
+    account_ids   = optional(list(string), [])
+  }))
+  default     = []
+  description = "Routes of provisioning requests to other organizations by product, portfolio or account ID"
+}